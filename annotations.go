@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+)
+
+// SchemaAnnotationHeaderPrefix prefixes the headers a schema's annotations are
+// forwarded as, such as Schema-Annotation-Classification.
+const SchemaAnnotationHeaderPrefix = "Schema-Annotation-"
+
+// annotationName matches the names annotations can have, which must make
+// valid header names.
+var annotationName = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+
+// checkAnnotations returns an error if an annotation can't be sent as a
+// header.
+func checkAnnotations(schema Schema) error {
+	for _, name := range sortedKeys(schema.Annotations) {
+		if !annotationName.MatchString(name) {
+			return fmt.Errorf("invalid annotation name %q, only letters, digits and dashes are allowed", name)
+		}
+		if strings.ContainsAny(schema.Annotations[name], "\r\n") {
+			return fmt.Errorf("annotation %q can't span lines", name)
+		}
+	}
+	return nil
+}
+
+// stampAnnotations sets the annotations of a schema as headers of a message.
+func stampAnnotations(header nats.Header, schema Schema) {
+	for name, value := range schema.Annotations {
+		header.Set(SchemaAnnotationHeaderPrefix+name, value)
+	}
+}
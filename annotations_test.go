@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/nats-io/nats.go"
+)
+
+func TestCheckAnnotations(t *testing.T) {
+	valid := Schema{Annotations: map[string]string{"Classification": "confidential", "Retention-Class": "90d"}}
+	if err := checkAnnotations(valid); err != nil {
+		t.Errorf("Expected the annotations to be valid, got %v", err)
+	}
+
+	for _, annotations := range []map[string]string{
+		{"Data Classification": "confidential"},
+		{"Classification:": "confidential"},
+		{"Classification": "confidential\r\nX-Injected: true"},
+	} {
+		if err := checkAnnotations(Schema{Annotations: annotations}); err == nil {
+			t.Errorf("Expected %v to be rejected", annotations)
+		}
+	}
+}
+
+func TestForwardedMessagesCarryAnnotations(t *testing.T) {
+	reg := NewSchemaRegistry(nil, nil)
+	schema := Schema{Name: "orders", Subject: "orders.*", Annotations: map[string]string{"Classification": "confidential"}}
+
+	msg := reg.forwardMsg("orders.created", schema, "orders.created", nil, nats.NewMsg("orders.created"))
+	if value := msg.Header.Get("Schema-Annotation-Classification"); value != "confidential" {
+		t.Errorf("Expected the annotation to be forwarded, got %q", value)
+	}
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/micro"
+)
+
+// States a proposal can be in. Only pending proposals can be reviewed.
+const (
+	ProposalPending  = "pending"
+	ProposalApproved = "approved"
+	ProposalRejected = "rejected"
+)
+
+// Proposal is a schema change waiting for review. It isn't used for
+// validation until it is approved.
+type Proposal struct {
+	Schema Schema `json:"schema"`
+	State  string `json:"state"`
+	// Report is the dry run of the proposed schema against the revision that
+	// was current when it was proposed, for reviewers.
+	Report     DryRunReport `json:"report"`
+	By         string       `json:"by,omitempty"`
+	Time       time.Time    `json:"time"`
+	ReviewedBy string       `json:"reviewed_by,omitempty"`
+	ReviewedAt *time.Time   `json:"reviewed_at,omitempty"`
+	// Reason explains why the proposal was rejected.
+	Reason string `json:"reason,omitempty"`
+}
+
+// ReviewRequest is the body of a rejection.
+type ReviewRequest struct {
+	Reason string `json:"reason,omitempty"`
+}
+
+// errApprovalRequired is returned for direct changes to a registry that only
+// accepts approved proposals.
+var errApprovalRequired = newError("403", "schema changes must be proposed and approved")
+
+// proposalKey returns the kv key the latest proposal for a schema is stored
+// under. Like version keys it contains a dot, so the watcher ignores it.
+func proposalKey(name string) string {
+	return "_proposal." + nameToken(name)
+}
+
+// Propose subject: $SCHEMA.PROPOSE.<schema_name>
+func (reg *SchemaRegistry) Propose(r micro.Request) {
+	var schema Schema
+	err := json.Unmarshal(r.Data(), &schema)
+	if err != nil {
+		r.Error("400", err.Error(), nil)
+		return
+	}
+
+	// Pull out the schema name from the subject
+	schema.Name = subjectName(r.Subject())
+
+	if !reg.authorize(r, schema.Name) {
+		return
+	}
+
+	proposal, err := reg.propose(schema, RequestIdentity(nats.Header(r.Headers())))
+	if err != nil {
+		r.Error(errorCode(err), err.Error(), nil)
+		return
+	}
+
+	r.RespondJSON(proposal)
+}
+
+// propose stores a schema as a pending proposal, replacing any earlier
+// proposal for it. Invalid schemas are rejected right away.
+func (reg *SchemaRegistry) propose(schema Schema, user string) (Proposal, error) {
+	proposal := Proposal{Schema: schema, State: ProposalPending, By: user, Time: time.Now().UTC()}
+
+	if err := reg.writable(); err != nil {
+		return proposal, err
+	}
+	if err := reg.verifier.Verify(schema); err != nil {
+		return proposal, newError("403", err.Error())
+	}
+
+	report, err := reg.dryRun(schema)
+	if err != nil {
+		return proposal, err
+	}
+	if !report.Valid {
+		return proposal, newError("400", "invalid schema: %s", strings.Join(report.Errors, ", "))
+	}
+	proposal.Report = report
+
+	data, err := json.Marshal(proposal)
+	if err != nil {
+		return proposal, err
+	}
+	_, err = reg.kv.Put(proposalKey(schema.Name), data)
+	return proposal, err
+}
+
+// Proposal subject: $SCHEMA.PROPOSAL.<schema_name>
+func (reg *SchemaRegistry) GetProposal(r micro.Request) {
+	name := subjectName(r.Subject())
+
+	proposal, _, err := reg.proposal(name)
+	if err != nil {
+		r.Error(errorCode(err), err.Error(), nil)
+		return
+	}
+
+	r.RespondJSON(proposal)
+}
+
+// Approve subject: $SCHEMA.APPROVE.<schema_name>
+func (reg *SchemaRegistry) Approve(r micro.Request) {
+	name := subjectName(r.Subject())
+
+	if !reg.authorizeReview(r, name) {
+		return
+	}
+
+	proposal, err := reg.approveProposal(name, RequestIdentity(nats.Header(r.Headers())))
+	if err != nil {
+		r.Error(errorCode(err), err.Error(), nil)
+		return
+	}
+
+	r.RespondJSON(proposal)
+}
+
+// approveProposal applies a pending proposal. It fails if the schema changed
+// since the proposal was made, as the reviewed changes would no longer be
+// accurate. The proposal is claimed as approved before it's applied, so
+// concurrent reviews can't apply it twice, and put back to pending if it
+// can't be applied.
+func (reg *SchemaRegistry) approveProposal(name string, user string) (Proposal, error) {
+	proposal, rev, err := reg.reviewable(name, user)
+	if err != nil {
+		return proposal, err
+	}
+	if proposal.By == "" {
+		return proposal, newError("403", "proposal for schema %q has no known author, propose it again with --trust-request-info", name)
+	}
+
+	current, exists, err := reg.current(name)
+	if err != nil {
+		return proposal, err
+	}
+	if exists && current.Revision != proposal.Report.Revision {
+		return proposal, newError("409", "schema %q changed since it was proposed, propose it again", name)
+	}
+
+	pending := proposal
+	rev, err = reg.review(&proposal, rev, ProposalApproved, user, "")
+	if err != nil {
+		return proposal, err
+	}
+
+	schema := proposal.Schema
+	schema.UpdatedBy = proposal.By
+	schema.RequestID = ""
+	if exists {
+		schema, err = reg.update(schema)
+	} else {
+		schema, err = reg.register(schema)
+	}
+	if err != nil {
+		if _, restoreErr := reg.storeProposal(pending, rev); restoreErr != nil {
+			logger("approvals").Error("error putting back a proposal that couldn't be applied", "schema", name, "error", restoreErr)
+		}
+		return pending, err
+	}
+
+	proposal.Schema = schema
+	if _, err := reg.storeProposal(proposal, rev); err != nil {
+		logger("approvals").Warn("error recording the applied revision on the proposal", "schema", name, "error", err)
+	}
+	return proposal, nil
+}
+
+// Reject subject: $SCHEMA.REJECT.<schema_name>
+func (reg *SchemaRegistry) Reject(r micro.Request) {
+	var req ReviewRequest
+	err := json.Unmarshal(r.Data(), &req)
+	if err != nil {
+		r.Error("400", err.Error(), nil)
+		return
+	}
+
+	name := subjectName(r.Subject())
+
+	if !reg.authorizeReview(r, name) {
+		return
+	}
+
+	proposal, err := reg.rejectProposal(name, RequestIdentity(nats.Header(r.Headers())), req.Reason)
+	if err != nil {
+		r.Error(errorCode(err), err.Error(), nil)
+		return
+	}
+
+	r.RespondJSON(proposal)
+}
+
+// rejectProposal closes a pending proposal without applying it, recording why.
+func (reg *SchemaRegistry) rejectProposal(name string, user string, reason string) (Proposal, error) {
+	if reason == "" {
+		return Proposal{}, newError("400", "a reason is required to reject a proposal")
+	}
+
+	proposal, rev, err := reg.reviewable(name, user)
+	if err != nil {
+		return proposal, err
+	}
+
+	_, err = reg.review(&proposal, rev, ProposalRejected, user, reason)
+	return proposal, err
+}
+
+// proposal returns the latest proposal for a schema and the revision it is
+// stored at.
+func (reg *SchemaRegistry) proposal(name string) (Proposal, uint64, error) {
+	var proposal Proposal
+
+	entry, err := reg.kv.Get(proposalKey(name))
+	if errors.Is(err, nats.ErrKeyNotFound) {
+		return proposal, 0, newError("404", "no proposal for schema %q", name)
+	}
+	if err != nil {
+		return proposal, 0, err
+	}
+
+	err = json.Unmarshal(entry.Value(), &proposal)
+	return proposal, entry.Revision(), err
+}
+
+// reviewable returns a proposal that user may review. Reviewers must be known
+// from the client information the server attached, proposals must be pending,
+// and can't be reviewed by their author.
+func (reg *SchemaRegistry) reviewable(name string, user string) (Proposal, uint64, error) {
+	if user == "" {
+		return Proposal{}, 0, newError("403", "reviewing proposals requires a user attached by the server, see --trust-request-info")
+	}
+
+	if err := reg.writable(); err != nil {
+		return Proposal{}, 0, err
+	}
+
+	proposal, rev, err := reg.proposal(name)
+	if err != nil {
+		return proposal, rev, err
+	}
+	if proposal.State != ProposalPending {
+		return proposal, rev, newError("409", "proposal for schema %q is already %s", name, proposal.State)
+	}
+	if user == proposal.By {
+		return proposal, rev, newError("403", "proposals can't be reviewed by their author")
+	}
+	return proposal, rev, nil
+}
+
+// review records the outcome of a review, returning the revision the
+// proposal is stored at. The proposal is updated at the revision it was read
+// at, so it can only be reviewed once.
+func (reg *SchemaRegistry) review(proposal *Proposal, rev uint64, state string, user string, reason string) (uint64, error) {
+	now := time.Now().UTC()
+	proposal.State = state
+	proposal.ReviewedBy = user
+	proposal.ReviewedAt = &now
+	proposal.Reason = reason
+
+	return reg.storeProposal(*proposal, rev)
+}
+
+// storeProposal updates a proposal stored at a revision, returning its new
+// revision.
+func (reg *SchemaRegistry) storeProposal(proposal Proposal, rev uint64) (uint64, error) {
+	data, err := json.Marshal(proposal)
+	if err != nil {
+		return 0, err
+	}
+	rev, err = reg.kv.Update(proposalKey(proposal.Schema.Name), data, rev)
+	if errors.Is(err, nats.ErrKeyExists) {
+		return 0, newError("409", "proposal for schema %q was modified concurrently", proposal.Schema.Name)
+	}
+	return rev, err
+}
+
+// authorizeReview responds with a 403 error and returns false if the request
+// may not approve or reject proposals for the named schema.
+func (reg *SchemaRegistry) authorizeReview(r micro.Request, name string) bool {
+	if reg.approvers.Allowed(name, nats.Header(r.Headers())) {
+		return true
+	}
+	r.Error("403", fmt.Sprintf("not authorized to review schema %q", name), nil)
+	return false
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/nats-io/nats.go/micro"
+)
+
+func TestReviewChecks(t *testing.T) {
+	reg := NewSchemaRegistry(nil, nil)
+
+	_, err := reg.rejectProposal("foo", "alice", "")
+	if errorCode(err) != "400" {
+		t.Errorf("Expected a rejection without a reason to fail, got %v", err)
+	}
+
+	reg.readOnly = true
+	_, err = reg.propose(Schema{Name: "foo", Subject: "foo", Body: "{}"}, "alice")
+	if errorCode(err) != "403" {
+		t.Errorf("Expected proposing to a read-only registry to fail, got %v", err)
+	}
+	_, err = reg.approveProposal("foo", "bob")
+	if errorCode(err) != "403" {
+		t.Errorf("Expected approving on a read-only registry to fail, got %v", err)
+	}
+}
+
+func TestApproveProposal(t *testing.T) {
+	kv := newMemKV()
+	reg := NewSchemaRegistry(kv, nil)
+
+	_, err := reg.propose(Schema{Name: "foo", Subject: "foo", Body: `{"type": "object"}`}, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = reg.approveProposal("foo", "")
+	if errorCode(err) != "403" {
+		t.Errorf("Expected approving without a known user to fail, got %v", err)
+	}
+	_, err = reg.approveProposal("foo", "alice")
+	if errorCode(err) != "403" {
+		t.Errorf("Expected authors not to approve their own proposals, got %v", err)
+	}
+
+	proposal, err := reg.approveProposal("foo", "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proposal.State != ProposalApproved || proposal.ReviewedBy != "bob" || proposal.Schema.Revision == 0 {
+		t.Errorf("Expected the proposal to be applied and approved by bob, got %+v", proposal)
+	}
+	stored, _, err := reg.proposal("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.State != ProposalApproved || stored.Schema.Revision != proposal.Schema.Revision {
+		t.Errorf("Expected the stored proposal to record the applied revision, got %+v", stored)
+	}
+
+	_, err = reg.approveProposal("foo", "carol")
+	if errorCode(err) != "409" {
+		t.Errorf("Expected a proposal to be approved once, got %v", err)
+	}
+}
+
+func TestApproveProposalFailure(t *testing.T) {
+	kv := newMemKV()
+	reg := NewSchemaRegistry(kv, nil)
+
+	_, err := reg.propose(Schema{Name: "foo", Subject: "foo", Body: `{"type": "object"}`}, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The schema was registered by someone else since
+	_, err = reg.register(Schema{Name: "foo", Subject: "foo", Body: `{}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = reg.approveProposal("foo", "bob")
+	if errorCode(err) != "409" {
+		t.Errorf("Expected a proposal for a changed schema not to be applied, got %v", err)
+	}
+	stored, _, err := reg.proposal("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.State != ProposalPending {
+		t.Errorf("Expected the proposal to stay pending, got %q", stored.State)
+	}
+
+	// A proposal that can't be applied once claimed is put back to pending
+	_, err = reg.propose(Schema{Name: "bar", Subject: "bar", Body: `{"type": "object"}`}, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := defaultSettings()
+	s.Limits.MaxSchemaSize = 1
+	reg.opts.Store(s)
+	_, err = reg.approveProposal("bar", "bob")
+	if errorCode(err) != "413" {
+		t.Errorf("Expected a proposal exceeding the limits not to be applied, got %v", err)
+	}
+	stored, _, err = reg.proposal("bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.State != ProposalPending || stored.ReviewedBy != "" {
+		t.Errorf("Expected the proposal to be put back to pending, got %+v", stored)
+	}
+}
+
+func TestProposalIdentities(t *testing.T) {
+	reg := NewSchemaRegistry(newMemKV(), nil)
+	TrustRequestInfo(true)
+	defer TrustRequestInfo(false)
+
+	as := func(user string, handler func(micro.Request), subject, data string) string {
+		req := &recordingRequest{
+			subject: subject,
+			headers: micro.Headers{requestInfoHeader: []string{`{"acc":"ORDERS","user":"` + user + `"}`}},
+			data:    []byte(data),
+		}
+		handler(req)
+		return req.resp.Header.Get(micro.ErrorCodeHeader)
+	}
+
+	if code := as("alice", reg.Propose, "$SCHEMA.PROPOSE.orders", `{"subject": "orders", "body": "{\"type\": \"object\"}"}`); code != "" {
+		t.Fatalf("Expected the proposal to be made, got %q", code)
+	}
+	if code := as("bob", reg.Approve, "$SCHEMA.APPROVE.orders", ""); code != "" {
+		t.Fatalf("Expected the proposal to be approved, got %q", code)
+	}
+
+	proposal, _, err := reg.proposal("orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proposal.By != "alice@ORDERS" || proposal.ReviewedBy != "bob@ORDERS" {
+		t.Errorf("Expected the proposal to record alice@ORDERS and bob@ORDERS, got %q and %q", proposal.By, proposal.ReviewedBy)
+	}
+	if schema, _, _ := reg.current("orders"); schema.UpdatedBy != "alice@ORDERS" {
+		t.Errorf("Expected the applied revision to be updated by alice@ORDERS, got %q", schema.UpdatedBy)
+	}
+}
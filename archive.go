@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/micro"
+)
+
+// HistoryEntry is a single mutation of a schema.
+type HistoryEntry struct {
+	Revision  uint64    `json:"revision"`
+	Operation string    `json:"operation"`
+	Time      time.Time `json:"time"`
+	Schema    *Schema   `json:"schema,omitempty"`
+}
+
+// CreateArchive creates a stream mirroring the kv bucket's backing stream. The
+// mirror keeps every message, and denies deletes and purges, so it retains the
+// complete history of every schema regardless of the bucket's history depth.
+func CreateArchive(js nats.JetStreamContext, kv nats.KeyValue, name string) error {
+	_, err := js.AddStream(&nats.StreamConfig{
+		Name:        name,
+		Description: fmt.Sprintf("Complete mutation history of the %s schema bucket.", kv.Bucket()),
+		Mirror:      &nats.StreamSource{Name: "KV_" + kv.Bucket()},
+		DenyDelete:  true,
+		DenyPurge:   true,
+	})
+	if errors.Is(err, nats.ErrStreamNameAlreadyInUse) {
+		return nil
+	}
+	return err
+}
+
+// History subject: $SCHEMA.HISTORY.<schema_name>
+func (reg *SchemaRegistry) GetHistory(r micro.Request) {
+	name := subjectName(r.Subject())
+
+	history, err := reg.history(name)
+	if err != nil {
+		r.Error("500", err.Error(), nil)
+		return
+	}
+	if len(history) == 0 {
+		r.Error("404", "Not found", nil)
+		return
+	}
+
+	r.RespondJSON(history)
+}
+
+// history returns every mutation of a schema, from the archive if there is
+// one and otherwise from what the kv bucket retains.
+func (reg *SchemaRegistry) history(name string) ([]HistoryEntry, error) {
+	if reg.archive != "" {
+		return reg.archivedHistory(name)
+	}
+	return reg.kvHistory(name)
+}
+
+// archivedHistory reads every mutation of a schema from the archive stream.
+func (reg *SchemaRegistry) archivedHistory(name string) ([]HistoryEntry, error) {
+	subject := fmt.Sprintf("$KV.%s.%s", reg.kv.Bucket(), nameToken(name))
+	info, err := reg.js.StreamInfo(reg.archive, &nats.StreamInfoRequest{SubjectsFilter: subject})
+	if err != nil {
+		return nil, err
+	}
+	if info.State.Subjects[subject] == 0 {
+		return nil, nil
+	}
+
+	sub, err := reg.js.SubscribeSync(subject, nats.OrderedConsumer(), nats.BindStream(reg.archive), nats.DeliverAll())
+	if err != nil {
+		return nil, err
+	}
+	defer sub.Unsubscribe()
+
+	var history []HistoryEntry
+	for {
+		msg, err := sub.NextMsg(2 * time.Second)
+		if err != nil {
+			return nil, err
+		}
+		meta, err := msg.Metadata()
+		if err != nil {
+			return nil, err
+		}
+
+		op := nats.KeyValuePut
+		switch msg.Header.Get("KV-Operation") {
+		case "DEL":
+			op = nats.KeyValueDelete
+		case "PURGE":
+			op = nats.KeyValuePurge
+		}
+		history = append(history, historyEntry(meta.Sequence.Stream, op, meta.Timestamp, msg.Data))
+
+		if meta.NumPending == 0 {
+			return history, nil
+		}
+	}
+}
+
+// kvHistory reads the history of a schema that the kv bucket still retains.
+func (reg *SchemaRegistry) kvHistory(name string) ([]HistoryEntry, error) {
+	entries, err := reg.kv.History(nameToken(name))
+	if errors.Is(err, nats.ErrKeyNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var history []HistoryEntry
+	for _, entry := range entries {
+		history = append(history, historyEntry(entry.Revision(), entry.Operation(), entry.Created(), entry.Value()))
+	}
+	return history, nil
+}
+
+func historyEntry(rev uint64, op nats.KeyValueOp, created time.Time, data []byte) HistoryEntry {
+	entry := HistoryEntry{
+		Revision:  rev,
+		Operation: operationName(op),
+		Time:      created,
+	}
+	if op != nats.KeyValuePut {
+		return entry
+	}
+
+	var schema Schema
+	if err := json.Unmarshal(data, &schema); err == nil {
+		schema.Revision = rev
+		entry.Schema = &schema
+	}
+	return entry
+}
+
+func operationName(op nats.KeyValueOp) string {
+	switch op {
+	case nats.KeyValueDelete:
+		return "delete"
+	case nats.KeyValuePurge:
+		return "purge"
+	default:
+		return "put"
+	}
+}
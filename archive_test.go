@@ -0,0 +1,53 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/nats-io/nats.go/micro"
+)
+
+func TestKVHistory(t *testing.T) {
+	reg := NewSchemaRegistry(newMemKV(), nil)
+	if _, err := reg.register(Schema{Name: "orders", Subject: "orders.*", Body: `{"type": "object"}`}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reg.update(Schema{Name: "orders", Subject: "orders.*", Body: `{"type": "object", "required": ["id"]}`}); err != nil {
+		t.Fatal(err)
+	}
+	if err := reg.unregister("orders"); err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := reg.history("orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 3 {
+		t.Fatalf("Expected 3 mutations, got %+v", history)
+	}
+	for i, operation := range []string{"put", "put", "delete"} {
+		if history[i].Operation != operation {
+			t.Errorf("Expected mutation %d to be a %s, got %s", i, operation, history[i].Operation)
+		}
+	}
+	if history[1].Schema == nil || history[1].Schema.Revision != history[1].Revision || history[1].Schema.Body != `{"type": "object", "required": ["id"]}` {
+		t.Errorf("Expected the update to carry the schema at its revision, got %+v", history[1].Schema)
+	}
+	if history[2].Schema != nil {
+		t.Errorf("Expected the deletion not to carry a schema, got %+v", history[2].Schema)
+	}
+
+	history, err = reg.history("customers")
+	if err != nil || len(history) != 0 {
+		t.Errorf("Expected no history of a missing schema, got %+v, %v", history, err)
+	}
+}
+
+func TestGetHistoryNotFound(t *testing.T) {
+	reg := NewSchemaRegistry(newMemKV(), nil)
+	r := &recordingRequest{subject: "$SCHEMA.HISTORY.orders"}
+	reg.GetHistory(r)
+	if r.resp == nil || r.resp.Header.Get(micro.ErrorCodeHeader) != "404" {
+		t.Errorf("Expected the history of a missing schema to be a 404, got %+v", r.resp)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/nats-io/nats.go/micro"
+)
+
+// asyncAPIVersion is the AsyncAPI specification version documents follow.
+const asyncAPIVersion = "2.6.0"
+
+// GenerateAsyncAPI builds an AsyncAPI document describing the subjects of the
+// given schemas and the messages published on them. Drafts, disabled schemas
+// and components are left out.
+func GenerateAsyncAPI(schemas []Schema, version string) map[string]interface{} {
+	channels := map[string]interface{}{}
+	messages := map[string]interface{}{}
+
+	// Several schemas may share a subject
+	bySubject := map[string][]Schema{}
+	for _, schema := range schemas {
+		if schema.State == StateDraft || schema.State == StateDisabled || isComponent(schema.Name) {
+			continue
+		}
+		bySubject[schema.Subject] = append(bySubject[schema.Subject], schema)
+	}
+
+	for _, subject := range sortedKeys(bySubject) {
+		var refs []interface{}
+		channel := map[string]interface{}{}
+
+		for _, schema := range bySubject[subject] {
+			message := map[string]interface{}{
+				"name":        schema.Name,
+				"contentType": "application/json",
+				"payload":     rawSchema(schema.Body),
+			}
+			if schema.HeaderBody != "" {
+				message["headers"] = rawSchema(schema.HeaderBody)
+			}
+			if schema.State == StateDeprecated {
+				message["summary"] = "Deprecated"
+			}
+			messages[schema.Name] = message
+			refs = append(refs, map[string]interface{}{"$ref": "#/components/messages/" + schema.Name})
+
+			for name, param := range schema.Parameters {
+				paramSchema := map[string]interface{}{"type": "string"}
+				if len(param.Enum) > 0 {
+					paramSchema["enum"] = param.Enum
+				}
+				if param.Pattern != "" {
+					paramSchema["pattern"] = param.Pattern
+				}
+				params, _ := channel["parameters"].(map[string]interface{})
+				if params == nil {
+					params = map[string]interface{}{}
+					channel["parameters"] = params
+				}
+				params[name] = map[string]interface{}{"schema": paramSchema}
+			}
+		}
+
+		// Producers publish validated messages, consumers subscribe to them
+		var message interface{} = refs[0]
+		if len(refs) > 1 {
+			message = map[string]interface{}{"oneOf": refs}
+		}
+		channel["publish"] = map[string]interface{}{"message": message}
+		channels[subject] = channel
+	}
+
+	return map[string]interface{}{
+		"asyncapi": asyncAPIVersion,
+		"info": map[string]interface{}{
+			"title":       "Schema Registry",
+			"version":     version,
+			"description": "Subjects and message contracts enforced by the schema registry.",
+		},
+		"defaultContentType": "application/json",
+		"channels":           channels,
+		"components":         map[string]interface{}{"messages": messages},
+	}
+}
+
+// rawSchema embeds a schema body as JSON, falling back to the string when it
+// isn't valid JSON.
+func rawSchema(body string) interface{} {
+	if json.Valid([]byte(body)) {
+		return json.RawMessage(body)
+	}
+	return body
+}
+
+// AsyncAPI subject: $SCHEMA.ASYNCAPI
+func (reg *SchemaRegistry) GetAsyncAPI(r micro.Request) {
+	r.RespondJSON(GenerateAsyncAPI(reg.list(), serviceVersion))
+}
+
+// PrintAsyncAPI writes an AsyncAPI document of the registered schemas.
+func PrintAsyncAPI(cfg Config, w io.Writer) error {
+	nc, _, kv, err := OpenBucket(cfg)
+	if err != nil {
+		return err
+	}
+	defer nc.Close()
+
+	schemas, err := loadSchemas(kv)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(GenerateAsyncAPI(schemas, serviceVersion))
+}
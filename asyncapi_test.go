@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+func TestGenerateAsyncAPI(t *testing.T) {
+	doc := GenerateAsyncAPI([]Schema{
+		{Name: "orders", Subject: "orders.{region}", Body: `{"type": "object"}`, State: StateActive,
+			Parameters: map[string]Parameter{"region": {Enum: []string{"eu", "us"}}}},
+		{Name: "draft", Subject: "drafts.>", Body: `{}`, State: StateDraft},
+	}, "1.0.0")
+
+	channels := doc["channels"].(map[string]interface{})
+	if len(channels) != 1 {
+		t.Fatalf("Expected drafts to be left out, got %v", channels)
+	}
+
+	channel, ok := channels["orders.{region}"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected a channel for the subject template, got %v", channels)
+	}
+	if _, ok := channel["parameters"].(map[string]interface{})["region"]; !ok {
+		t.Errorf("Expected the subject parameter to be documented")
+	}
+
+	messages := doc["components"].(map[string]interface{})["messages"].(map[string]interface{})
+	if _, ok := messages["orders"]; !ok {
+		t.Errorf("Expected a message for the schema")
+	}
+}
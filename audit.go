@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go/micro"
+)
+
+// DriftReportSubject is where a report is published after every audit of a
+// stream.
+const DriftReportSubject = "$SCHEMA.DRIFT.REPORT"
+
+// maxDriftErrors caps the example violations kept per schema in a report.
+const maxDriftErrors = 5
+
+// DriftStats counts the audited messages of a schema.
+type DriftStats struct {
+	Sampled    int `json:"sampled"`
+	Violations int `json:"violations"`
+	// Unvalidated counts messages that weren't forwarded by the registry,
+	// meaning their producer bypassed validation.
+	Unvalidated   int      `json:"unvalidated"`
+	ViolationRate float64  `json:"violation_rate"`
+	Errors        []string `json:"errors,omitempty"`
+}
+
+// DriftReport is the outcome of auditing the most recent messages of a stream
+// against the schemas bound to their subjects.
+type DriftReport struct {
+	Stream  string    `json:"stream"`
+	Time    time.Time `json:"time"`
+	Sampled int       `json:"sampled"`
+	// Unmatched counts messages on subjects no schema is bound to.
+	Unmatched int                    `json:"unmatched"`
+	Schemas   map[string]*DriftStats `json:"schemas,omitempty"`
+	Error     string                 `json:"error,omitempty"`
+}
+
+// Auditor periodically samples streams and validates what producers actually
+// published, to notice messages that bypassed the registry.
+type Auditor struct {
+	reg      *SchemaRegistry
+	streams  []string
+	interval time.Duration
+	// count is the number of messages sampled per stream
+	count int
+
+	mu      sync.Mutex
+	reports map[string]DriftReport
+}
+
+func NewAuditor(reg *SchemaRegistry, streams []string, interval time.Duration, count int) *Auditor {
+	return &Auditor{
+		reg:      reg,
+		streams:  streams,
+		interval: interval,
+		count:    count,
+		reports:  map[string]DriftReport{},
+	}
+}
+
+// Run audits every stream once per interval. It runs this in a goroutine and
+// takes a context for cancelation.
+func (a *Auditor) Run(c context.Context) {
+	go func() {
+		ticker := time.NewTicker(a.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-c.Done():
+				return
+			case <-ticker.C:
+				if !a.reg.elector.Leading(JobAudit) {
+					continue
+				}
+				for _, stream := range a.streams {
+					a.Audit(stream)
+				}
+			}
+		}
+	}()
+}
+
+// Audit validates the most recent messages of a stream and publishes the
+// report.
+func (a *Auditor) Audit(stream string) DriftReport {
+	report := DriftReport{Stream: stream, Time: time.Now().UTC(), Schemas: map[string]*DriftStats{}}
+
+	msgs, err := a.reg.recentMessages(stream, "", a.count)
+	if err != nil {
+		report.Error = err.Error()
+		return a.finish(report)
+	}
+
+	for _, msg := range msgs {
+		report.Sampled++
+		// Forwarded messages may have left the subject the schema is bound to
+		subject := msg.Subject
+		if original := msg.Header.Get("Schema-Original-Subject"); original != "" {
+			subject = original
+		}
+
+		schema, ok := a.reg.match(subject)
+		if !ok {
+			report.Unmatched++
+			continue
+		}
+
+		stats := report.Schemas[schema.Name]
+		if stats == nil {
+			stats = &DriftStats{}
+			report.Schemas[schema.Name] = stats
+		}
+		stats.Sampled++
+		if msg.Header.Get("Schema-Validated") != "true" {
+			stats.Unvalidated++
+		}
+		if _, err := a.reg.check(schema, subject, msg.Data, msg.Header); err != nil {
+			stats.Violations++
+			if len(stats.Errors) < maxDriftErrors {
+				text := err.Error()
+				if id := msg.Header.Get(SchemaRequestIDHeader); id != "" {
+					text = "request " + id + ": " + text
+				}
+				stats.Errors = append(stats.Errors, text)
+			}
+		}
+	}
+	for _, stats := range report.Schemas {
+		stats.ViolationRate = float64(stats.Violations) / float64(stats.Sampled)
+	}
+
+	return a.finish(report)
+}
+
+func (a *Auditor) finish(report DriftReport) DriftReport {
+	a.mu.Lock()
+	a.reports[report.Stream] = report
+	a.mu.Unlock()
+
+	for name, stats := range report.Schemas {
+		if stats.Violations > 0 || stats.Unvalidated > 0 {
+			logger("audit").Warn("Drift in stream", "stream", report.Stream, "schema", name,
+				"violations", stats.Violations, "sampled", stats.Sampled, "unvalidated", stats.Unvalidated)
+		}
+	}
+	if report.Error != "" {
+		logger("audit").Error("error auditing stream", "stream", report.Stream, "error", report.Error)
+	}
+
+	data, err := json.Marshal(report)
+	if err == nil {
+		err = a.reg.nc.Publish(DriftReportSubject, data)
+	}
+	if err != nil {
+		logger("audit").Error("error publishing drift report", "error", err)
+	}
+
+	return report
+}
+
+// Drift status subject: $SCHEMA.DRIFT.STATUS
+func (a *Auditor) Status(r micro.Request) {
+	a.mu.Lock()
+	reports := make([]DriftReport, 0, len(a.reports))
+	for _, stream := range sortedKeys(a.reports) {
+		reports = append(reports, a.reports[stream])
+	}
+	a.mu.Unlock()
+
+	r.RespondJSON(reports)
+}
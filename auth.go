@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"sync/atomic"
+
+	"github.com/nats-io/nats.go"
+)
+
+// AuthTokenHeader is the request header carrying a token for mutating endpoints.
+const AuthTokenHeader = "Schema-Auth-Token"
+
+// requestInfoHeader is added by the NATS server to requests crossing a service
+// import that shares client information, and identifies the requesting user.
+const requestInfoHeader = "Nats-Request-Info"
+
+// trustRequestInfo honours the requestInfoHeader. Clients in the registry's
+// own account can set the header on their requests themselves, so it's only
+// trusted when the operator says every client reaches the registry through a
+// service import sharing client information, or an auth callout, where the
+// server sets it.
+var trustRequestInfo atomic.Bool
+
+// TrustRequestInfo sets whether users and identities are taken from the
+// Nats-Request-Info header.
+func TrustRequestInfo(trust bool) {
+	trustRequestInfo.Store(trust)
+}
+
+// AuthRule grants mutation rights on the schemas whose names match Namespace,
+// or that Owner owns.
+type AuthRule struct {
+	// Namespace is a glob matched against the schema name, e.g. "orders_*".
+	Namespace string `json:"namespace,omitempty"`
+	// Owner is a team, whose rule follows the schemas transferred to and
+	// from it.
+	Owner  string   `json:"owner,omitempty"`
+	Tokens []string `json:"tokens,omitempty"`
+	Users  []string `json:"users,omitempty"`
+}
+
+// Authorizer decides whether a request may mutate a schema. A nil Authorizer
+// allows everything.
+type Authorizer struct {
+	Rules []AuthRule `json:"rules"`
+}
+
+// LoadAuthorizer reads authorization rules from a JSON file. An empty path
+// returns a nil Authorizer.
+func LoadAuthorizer(file string) (*Authorizer, error) {
+	if file == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var auth Authorizer
+	err = json.Unmarshal(data, &auth)
+	if err != nil {
+		return nil, err
+	}
+
+	return &auth, nil
+}
+
+// Allowed returns true if a request with the given headers may mutate the named
+// schema. A request is allowed when any rule matching the name accepts either
+// its token or its user.
+func (a *Authorizer) Allowed(name string, headers nats.Header) bool {
+	return a.AllowedOwner(name, "", headers)
+}
+
+// AllowedOwner is like Allowed, for a schema owned by a team. When that team
+// has rules, they take precedence: only they apply to the schema, and the
+// namespace rules matching its name no longer do.
+func (a *Authorizer) AllowedOwner(name, owner string, headers nats.Header) bool {
+	if a == nil {
+		return true
+	}
+
+	token := headers.Get(AuthTokenHeader)
+	user := RequestUser(headers)
+	owned := owner != "" && a.hasOwner(owner)
+
+	for _, rule := range a.Rules {
+		if owned {
+			if rule.Owner != owner {
+				continue
+			}
+		} else if matched, _ := path.Match(rule.Namespace, name); !matched {
+			continue
+		}
+		if token != "" && contains(rule.Tokens, token) {
+			return true
+		}
+		if user != "" && contains(rule.Users, user) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasOwner returns true if any rule applies to the schemas owned by a team.
+func (a *Authorizer) hasOwner(owner string) bool {
+	for _, rule := range a.Rules {
+		if rule.Owner == owner {
+			return true
+		}
+	}
+	return false
+}
+
+// requestClient is the client information the NATS server attaches to a
+// request. With auth callout, the user is the one the callout service issued.
+type requestClient struct {
+	Account string `json:"acc"`
+	User    string `json:"user"`
+}
+
+// requestInfo returns the client information of a request, empty unless it's
+// trusted.
+func requestInfo(headers nats.Header) requestClient {
+	var client requestClient
+	info := headers.Get(requestInfoHeader)
+	if info == "" || !trustRequestInfo.Load() {
+		return client
+	}
+	if err := json.Unmarshal([]byte(info), &client); err != nil {
+		return requestClient{}
+	}
+	return client
+}
+
+// RequestUser returns the user the NATS server attached to the request, if any.
+func RequestUser(headers nats.Header) string {
+	return requestInfo(headers).User
+}
+
+// RequestIdentity describes who made a request, as the user and account the
+// NATS server attached to it, e.g. alice@ORDERS. It is empty if the server
+// didn't attach any.
+func RequestIdentity(headers nats.Header) string {
+	client := requestInfo(headers)
+	switch {
+	case client.User != "" && client.Account != "":
+		return client.User + "@" + client.Account
+	case client.User != "":
+		return client.User
+	}
+	return client.Account
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/nats-io/nats.go"
+)
+
+func TestAuthorizerAllowed(t *testing.T) {
+	auth := &Authorizer{Rules: []AuthRule{
+		{Namespace: "orders_*", Tokens: []string{"s3cret"}},
+		{Namespace: "*", Users: []string{"admin"}},
+	}}
+
+	headers := nats.Header{}
+	headers.Set(AuthTokenHeader, "s3cret")
+	if auth.Allowed("orders_created", headers) == false {
+		t.Errorf("Expected token to be allowed in its namespace")
+	}
+	if auth.Allowed("payments", headers) == true {
+		t.Errorf("Expected token to not be allowed outside its namespace")
+	}
+
+	headers = nats.Header{}
+	headers.Set(requestInfoHeader, `{"acc":"APP","user":"admin"}`)
+	if auth.Allowed("payments", headers) == true {
+		t.Errorf("Expected user to not be allowed while request info isn't trusted")
+	}
+	TrustRequestInfo(true)
+	defer TrustRequestInfo(false)
+	if auth.Allowed("payments", headers) == false {
+		t.Errorf("Expected user to be allowed")
+	}
+
+	var none *Authorizer
+	if none.Allowed("payments", nats.Header{}) == false {
+		t.Errorf("Expected nil authorizer to allow everything")
+	}
+}
+
+func TestAuthorizerAllowedOwner(t *testing.T) {
+	auth := &Authorizer{Rules: []AuthRule{
+		{Owner: "payments", Tokens: []string{"payments-token"}},
+		{Namespace: "orders_*", Tokens: []string{"orders-token"}},
+	}}
+
+	headers := nats.Header{}
+	headers.Set(AuthTokenHeader, "payments-token")
+	if !auth.AllowedOwner("refunds", "payments", headers) {
+		t.Errorf("Expected the owner's token to be allowed")
+	}
+	if auth.AllowedOwner("refunds", "orders", headers) {
+		t.Errorf("Expected the token to not be allowed once the schema is transferred")
+	}
+	if auth.Allowed("refunds", headers) {
+		t.Errorf("Expected an owner rule to not match schemas without an owner")
+	}
+
+	headers.Set(AuthTokenHeader, "orders-token")
+	if auth.AllowedOwner("orders_created", "payments", headers) {
+		t.Errorf("Expected the owner's rules to take precedence over namespace rules")
+	}
+	if !auth.AllowedOwner("orders_created", "shipping", headers) {
+		t.Errorf("Expected namespace rules to apply to owners without rules")
+	}
+}
+
+func TestRequestIdentity(t *testing.T) {
+	headers := nats.Header{}
+	headers.Set(requestInfoHeader, `{"acc":"ORDERS","user":"alice"}`)
+	if identity := RequestIdentity(headers); identity != "" {
+		t.Errorf("Expected no identity while request info isn't trusted, got %q", identity)
+	}
+
+	TrustRequestInfo(true)
+	defer TrustRequestInfo(false)
+	tests := map[string]string{
+		`{"acc":"ORDERS","user":"alice"}`: "alice@ORDERS",
+		`{"user":"alice"}`:                "alice",
+		`{"acc":"ORDERS"}`:                "ORDERS",
+		`not json`:                        "",
+	}
+	for info, expected := range tests {
+		headers := nats.Header{}
+		headers.Set(requestInfoHeader, info)
+		if identity := RequestIdentity(headers); identity != expected {
+			t.Errorf("Expected %s to identify %q, got %q", info, expected, identity)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// SchemaFailedItemsHeader lists the indexes of the items of a rejected batch
+// that failed validation.
+const SchemaFailedItemsHeader = "Schema-Failed-Items"
+
+// validateBatch validates every item of a batch payload, and fails with the
+// violations of each item that doesn't conform, prefixed with its index.
+func (reg *SchemaRegistry) validateBatch(schema Schema, data []byte) error {
+	var items []json.RawMessage
+	if err := json.Unmarshal(data, &items); err != nil {
+		return &ValidationError{What: "payload", Violations: []string{"(root): schema " + schema.Name + " takes batches, expected an array"}}
+	}
+
+	body, err := reg.payloadBody(schema)
+	if err != nil {
+		return err
+	}
+	// The body is compiled once for all items
+	compiled, err := gojsonschema.NewSchema(gojsonschema.NewStringLoader(body))
+	if err != nil {
+		return err
+	}
+
+	verr := &ValidationError{What: "payload"}
+	for i, item := range items {
+		result, err := compiled.Validate(gojsonschema.NewBytesLoader(item))
+		if err != nil {
+			return err
+		}
+		var problems []string
+		for _, desc := range result.Errors() {
+			problems = append(problems, desc.String())
+		}
+		if len(problems) == 0 {
+			problems, err = reg.extraViolations(schema, item)
+			if err != nil {
+				return fmt.Errorf("item %d: %w", i, err)
+			}
+		}
+		if len(problems) == 0 {
+			continue
+		}
+
+		verr.Items = append(verr.Items, i)
+		for _, problem := range problems {
+			verr.Violations = append(verr.Violations, fmt.Sprintf("[%d] %s", i, problem))
+		}
+	}
+	if len(verr.Items) > 0 {
+		return verr
+	}
+	return nil
+}
+
+// failedItemsHeader returns the headers a rejection of a batch is answered
+// with, naming the items that failed.
+func failedItemsHeader(err error) nats.Header {
+	verr, ok := err.(*ValidationError)
+	if !ok || len(verr.Items) == 0 {
+		return nil
+	}
+	indexes := make([]string, len(verr.Items))
+	for i, item := range verr.Items {
+		indexes[i] = strconv.Itoa(item)
+	}
+	return nats.Header{SchemaFailedItemsHeader: []string{strings.Join(indexes, ",")}}
+}
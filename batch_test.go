@@ -0,0 +1,34 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidateBatch(t *testing.T) {
+	reg := NewSchemaRegistry(nil, nil)
+	schema := Schema{Name: "orders", Batch: true, Body: `{"type": "object", "required": ["id"], "properties": {"id": {"type": "integer"}}}`}
+
+	if err := reg.validatePayload(schema, []byte(`[{"id": 1}, {"id": 2}]`)); err != nil {
+		t.Errorf("Expected a valid batch, got %v", err)
+	}
+	if err := reg.validatePayload(schema, []byte(`[]`)); err != nil {
+		t.Errorf("Expected an empty batch to be valid, got %v", err)
+	}
+
+	err := reg.validatePayload(schema, []byte(`[{"id": 1}, {}, {"id": "x"}]`))
+	verr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("Expected a validation error, got %v", err)
+	}
+	if !reflect.DeepEqual(verr.Items, []int{1, 2}) || len(verr.Violations) != 2 || verr.Violations[0] != "[1] (root): id is required" {
+		t.Errorf("Expected the failed items to be reported, got %v %v", verr.Items, verr.Violations)
+	}
+	if header := failedItemsHeader(err); header.Get(SchemaFailedItemsHeader) != "1,2" {
+		t.Errorf("Expected the failed items in the header, got %v", header)
+	}
+
+	if _, ok := reg.validatePayload(schema, []byte(`{"id": 1}`)).(*ValidationError); !ok {
+		t.Errorf("Expected a payload that isn't an array to be rejected")
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/nats-io/nats.go"
+)
+
+// BucketConfig describes the KV bucket schemas are stored in.
+type BucketConfig struct {
+	Name     string
+	History  int
+	Replicas int
+	Storage  string
+}
+
+// storageType parses the storage names accepted in the config.
+func storageType(storage string) (nats.StorageType, error) {
+	switch storage {
+	case "file", "":
+		return nats.FileStorage, nil
+	case "memory":
+		return nats.MemoryStorage, nil
+	}
+	return 0, fmt.Errorf("unknown storage type %q, expected file or memory", storage)
+}
+
+// jetStreamOptions returns the options of the JetStream context of a domain
+// or API prefix, at most one of which may be given.
+func jetStreamOptions(domain, apiPrefix string) ([]nats.JSOpt, error) {
+	switch {
+	case domain != "" && apiPrefix != "":
+		return nil, errors.New("a JetStream domain and API prefix can't both be given, a domain implies its prefix")
+	case domain != "":
+		return []nats.JSOpt{nats.Domain(domain)}, nil
+	case apiPrefix != "":
+		return []nats.JSOpt{nats.APIPrefix(apiPrefix)}, nil
+	}
+	return nil, nil
+}
+
+// openBucket opens the schema bucket, creating it if it doesn't exist. An
+// existing bucket is updated to the configured history and replicas instead
+// of failing when they differ. Its storage type can't be changed. Entries of
+// the bucket never expire, as the ID counter, versions and locks must outlive
+// the schemas, so a max age set on it is removed.
+func openBucket(js nats.JetStreamContext, cfg BucketConfig) (nats.KeyValue, error) {
+	storage, err := storageType(cfg.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.History < 1 || cfg.History > nats.KeyValueMaxHistory {
+		return nil, fmt.Errorf("bucket history must be between 1 and %d", nats.KeyValueMaxHistory)
+	}
+	if cfg.Replicas < 1 {
+		return nil, errors.New("bucket replicas must be at least 1")
+	}
+
+	kv, err := js.KeyValue(cfg.Name)
+	if errors.Is(err, nats.ErrBucketNotFound) {
+		return js.CreateKeyValue(&nats.KeyValueConfig{
+			Bucket:      cfg.Name,
+			Description: "Register and manages schemas.",
+			History:     uint8(cfg.History),
+			Replicas:    cfg.Replicas,
+			Storage:     storage,
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := js.StreamInfo("KV_" + cfg.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	stream := info.Config
+	if stream.Storage != storage {
+		logger("bucket").Warn("Bucket storage can't be changed", "bucket", cfg.Name, "storage", stream.Storage.String(), "configured", storage.String())
+	}
+	if stream.MaxMsgsPerSubject == int64(cfg.History) && stream.Replicas == cfg.Replicas && stream.MaxAge == 0 {
+		return kv, nil
+	}
+
+	stream.MaxMsgsPerSubject = int64(cfg.History)
+	stream.Replicas = cfg.Replicas
+	stream.MaxAge = 0
+	_, err = js.UpdateStream(&stream)
+	if err != nil {
+		return nil, fmt.Errorf("updating bucket %q: %w", cfg.Name, err)
+	}
+	logger("bucket").Info("Updated bucket", "bucket", cfg.Name, "history", cfg.History, "replicas", cfg.Replicas)
+	return kv, nil
+}
@@ -0,0 +1,18 @@
+package main
+
+import "testing"
+
+func TestJetStreamOptions(t *testing.T) {
+	if opts, err := jetStreamOptions("", ""); err != nil || len(opts) != 0 {
+		t.Errorf("Expected no options by default, got %v, %v", opts, err)
+	}
+	if opts, err := jetStreamOptions("hub", ""); err != nil || len(opts) != 1 {
+		t.Errorf("Expected a domain option, got %v, %v", opts, err)
+	}
+	if opts, err := jetStreamOptions("", "$JS.ORDERS.API"); err != nil || len(opts) != 1 {
+		t.Errorf("Expected an API prefix option, got %v, %v", opts, err)
+	}
+	if _, err := jetStreamOptions("hub", "$JS.ORDERS.API"); err == nil {
+		t.Errorf("Expected a domain and API prefix together to be rejected")
+	}
+}
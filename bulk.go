@@ -0,0 +1,216 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/micro"
+)
+
+// Statuses of the schemas of a bulk registration.
+const (
+	BulkRegistered = "registered"
+	BulkFailed     = "failed"
+	// BulkRolledBack schemas were registered, and removed again when another
+	// one failed.
+	BulkRolledBack = "rolled_back"
+	// BulkSkipped schemas weren't attempted since another one failed first.
+	BulkSkipped = "skipped"
+)
+
+// BulkResult is the outcome of registering one schema of a bulk request.
+type BulkResult struct {
+	Name   string  `json:"name"`
+	Status string  `json:"status"`
+	Schema *Schema `json:"schema,omitempty"`
+	Error  string  `json:"error,omitempty"`
+}
+
+// BulkReport is the outcome of a bulk registration, with a result per schema
+// in the order they were requested.
+type BulkReport struct {
+	Registered bool         `json:"registered"`
+	Results    []BulkResult `json:"results"`
+}
+
+// Register bulk subject: $SCHEMA.REGISTER_BULK
+func (reg *SchemaRegistry) RegisterBulk(r micro.Request) {
+	var schemas []Schema
+	err := json.Unmarshal(r.Data(), &schemas)
+	if err != nil {
+		r.Error("400", err.Error(), nil)
+		return
+	}
+
+	for _, schema := range schemas {
+		if !reg.authorize(r, schema.Name) {
+			return
+		}
+	}
+	if reg.requireApproval {
+		r.Error(errorCode(errApprovalRequired), errApprovalRequired.Error(), nil)
+		return
+	}
+
+	identity := RequestIdentity(nats.Header(r.Headers()))
+	for i := range schemas {
+		schemas[i].UpdatedBy = identity
+		schemas[i].RequestID = RequestID(r)
+	}
+	report, err := reg.registerBulk(schemas)
+	if err != nil {
+		data, _ := json.Marshal(report)
+		r.Error(errorCode(err), err.Error(), data)
+		return
+	}
+
+	r.RespondJSON(report)
+}
+
+// registerBulk registers schemas that don't exist yet, all or none of them.
+// Schemas are registered after those of the request they reference. When one
+// fails, those registered before it are removed again, so other instances may
+// briefly see them.
+func (reg *SchemaRegistry) registerBulk(schemas []Schema) (BulkReport, error) {
+	report := BulkReport{Results: make([]BulkResult, len(schemas))}
+	for i, schema := range schemas {
+		report.Results[i] = BulkResult{Name: schema.Name, Status: BulkSkipped}
+	}
+	if err := checkBulkNames(schemas); err != nil {
+		return report, err
+	}
+
+	order, err := bulkOrder(schemas)
+	if err != nil {
+		return report, err
+	}
+
+	var registered []int
+	for _, i := range order {
+		schema, err := reg.register(schemas[i])
+		if err != nil {
+			report.Results[i].Status = BulkFailed
+			report.Results[i].Error = err.Error()
+			for _, j := range registered {
+				reg.rollbackRegister(*report.Results[j].Schema)
+				report.Results[j].Status = BulkRolledBack
+				report.Results[j].Schema = nil
+			}
+			return report, newError(errorCode(err), "schema %q: %v", schemas[i].Name, err)
+		}
+		// Schemas registered later may reference it before the watcher
+		// caches it
+		reg.remember(schema)
+
+		report.Results[i].Status = BulkRegistered
+		report.Results[i].Schema = &schema
+		registered = append([]int{i}, registered...)
+	}
+
+	report.Registered = true
+	return report, nil
+}
+
+// checkBulkNames returns an error if a schema of a request has an invalid
+// name, or is given more than once.
+func checkBulkNames(schemas []Schema) error {
+	seen := map[string]bool{}
+	for _, schema := range schemas {
+		if schema.Name == "" || strings.ContainsAny(schema.Name, " */>") {
+			return newError("400", "invalid schema name %q", schema.Name)
+		}
+		if seen[schema.Name] {
+			return newError("400", "schema %q is given more than once", schema.Name)
+		}
+		seen[schema.Name] = true
+	}
+	return nil
+}
+
+// bulkOrder returns the indexes of schemas in the order they can be
+// registered, every schema after the schemas of the request it references.
+func bulkOrder(schemas []Schema) ([]int, error) {
+	index := map[string]int{}
+	for i, schema := range schemas {
+		index[schema.Name] = i
+	}
+
+	var order []int
+	// state is 1 while a schema's references are being ordered, 2 after
+	state := make([]int, len(schemas))
+	var visit func(i int, path []string) error
+	visit = func(i int, path []string) error {
+		switch state[i] {
+		case 1:
+			return newError("400", "schemas reference each other: %s", strings.Join(append(path, schemas[i].Name), " -> "))
+		case 2:
+			return nil
+		}
+		state[i] = 1
+		for _, ref := range registryRefs(schemas[i].Body) {
+			if j, ok := index[ref]; ok {
+				if err := visit(j, append(path, schemas[i].Name)); err != nil {
+					return err
+				}
+			}
+		}
+		state[i] = 2
+		order = append(order, i)
+		return nil
+	}
+	for i := range schemas {
+		if err := visit(i, nil); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// remember caches a schema ahead of the watcher.
+func (reg *SchemaRegistry) remember(schema Schema) {
+	reg.schemasMu.Lock()
+	defer reg.schemasMu.Unlock()
+	reg.schemas[schema.Name] = schema
+}
+
+// rollbackRegister removes a schema registered by a failed bulk registration,
+// along with its version and the entries indexing it by ID and fingerprint.
+// Like unregistering, the removal is kept in its history.
+func (reg *SchemaRegistry) rollbackRegister(schema Schema) {
+	reg.schemasMu.Lock()
+	delete(reg.schemas, schema.Name)
+	reg.schemasMu.Unlock()
+
+	err := reg.kv.Delete(nameToken(schema.Name))
+	if err != nil {
+		logger("registry").Error("error rolling back schema", "schema", schema.Name, "error", err)
+		return
+	}
+
+	if reg.immutable {
+		err = reg.kv.Delete(versionKey(schema.Name, schema.Version))
+		if err != nil {
+			logger("registry").Error("error rolling back version", "schema", schema.Name, "version", schema.Version, "error", err)
+		}
+	}
+	if schema.ID != 0 {
+		err = reg.kv.Delete(idKey(schema.ID))
+		if err != nil {
+			logger("registry").Error("error rolling back schema id", "schema", schema.Name, "id", schema.ID, "error", err)
+		}
+	}
+	// Schemas with the same body share the fingerprint, so only this one's
+	// entry is removed
+	if entry, err := reg.kv.Get(fingerprintKey(schema.Fingerprint)); err == nil {
+		var indexed Schema
+		if json.Unmarshal(entry.Value(), &indexed) == nil && indexed.Name == schema.Name {
+			err = reg.kv.Delete(fingerprintKey(schema.Fingerprint))
+			if err != nil {
+				logger("registry").Error("error rolling back fingerprint", "schema", schema.Name, "fingerprint", schema.Fingerprint, "error", err)
+			}
+		}
+	}
+
+	reg.publishEvent(EventDelete, schema.Name, nil)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBulkOrder(t *testing.T) {
+	schemas := []Schema{
+		{Name: "orders", Body: `{"properties": {"customer": {"$ref": "schema:customer"}, "address": {"$ref": "schema:defs_address"}}}`},
+		{Name: "customer", Body: `{"properties": {"address": {"$ref": "schema:defs_address"}}}`},
+		{Name: "defs_address", Body: `{"type": "object"}`},
+		{Name: "payments", Body: `{"properties": {"money": {"$ref": "schema:defs_money"}}}`},
+	}
+	order, err := bulkOrder(schemas)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(order, []int{2, 1, 0, 3}) {
+		t.Errorf("Expected referenced schemas to come first, got %v", order)
+	}
+
+	cycle := []Schema{
+		{Name: "a", Body: `{"$ref": "schema:b"}`},
+		{Name: "b", Body: `{"$ref": "schema:a"}`},
+	}
+	if _, err := bulkOrder(cycle); errorCode(err) != "400" {
+		t.Errorf("Expected schemas referencing each other to be rejected, got %v", err)
+	}
+}
+
+func TestRegisterBulkInvalid(t *testing.T) {
+	reg := NewSchemaRegistry(nil, nil)
+
+	report, err := reg.registerBulk([]Schema{{Name: "a"}, {Name: "a"}})
+	if errorCode(err) != "400" {
+		t.Errorf("Expected duplicate names to be rejected, got %v", err)
+	}
+	if len(report.Results) != 2 || report.Results[0].Status != BulkSkipped || report.Registered {
+		t.Errorf("Expected nothing to be registered, got %+v", report)
+	}
+
+	if _, err := reg.registerBulk([]Schema{{Name: "orders.*"}}); errorCode(err) != "400" {
+		t.Errorf("Expected an invalid name to be rejected, got %v", err)
+	}
+}
+
+func TestRegisterBulkRollback(t *testing.T) {
+	kv := newMemKV()
+	reg := NewSchemaRegistry(kv, nil)
+	reg.immutable = true
+
+	report, err := reg.registerBulk([]Schema{
+		{Name: "address", Subject: "address", Body: `{"type": "object"}`},
+		{Name: "orders", Subject: "orders", Body: `{"type": "unknown"}`},
+	})
+	if err == nil || report.Results[0].Status != BulkRolledBack {
+		t.Fatalf("Expected the registration to be rolled back, got %+v, %v", report, err)
+	}
+
+	for _, key := range []string{nameToken("address"), versionKey("address", 1), idKey(1), fingerprintKey(Fingerprint(`{"type": "object"}`))} {
+		if _, err := kv.Get(key); err == nil {
+			t.Errorf("Expected %s to be removed", key)
+		}
+	}
+
+	// The version is free again
+	schema, err := reg.register(Schema{Name: "address", Subject: "address", Body: `{"type": "object"}`})
+	if err != nil || schema.Version != 1 {
+		t.Errorf("Expected version 1 to be registered again, got %d, %v", schema.Version, err)
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// SchemaBundleHeader asks GET requests for the fully dereferenced schema.
+const SchemaBundleHeader = "Schema-Bundle"
+
+// RegistryRefPrefix starts a $ref to another registered schema, such as
+// schema:address or schema:address#/definitions/street.
+const RegistryRefPrefix = "schema:"
+
+// hasRegistryRefs cheaply tells whether a body may reference other schemas.
+func hasRegistryRefs(body string) bool {
+	return strings.Contains(body, `"`+RegistryRefPrefix)
+}
+
+// Bundle returns a single self-contained schema document, with every internal
+// and registry $ref replaced by what it points to. lookup returns the body of
+// a registered schema. Other references, such as to URLs, are left alone.
+// Recursive schemas can't be fully dereferenced and return an error.
+func Bundle(body string, lookup func(name string) (string, bool)) (string, error) {
+	var root interface{}
+	err := json.Unmarshal([]byte(body), &root)
+	if err != nil {
+		return "", newError("400", "invalid schema: %v", err)
+	}
+
+	b := bundler{lookup: lookup, docs: map[string]interface{}{}}
+	bundled, err := b.deref(root, root, "", nil)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(bundled)
+	return string(data), err
+}
+
+type bundler struct {
+	lookup func(name string) (string, bool)
+	// docs caches the parsed bodies of referenced schemas
+	docs map[string]interface{}
+}
+
+// deref replaces the references in node, resolving internal ones against
+// root, the document named doc. stack holds the references being expanded,
+// to detect recursion.
+func (b bundler) deref(node, root interface{}, doc string, stack []string) (interface{}, error) {
+	switch n := node.(type) {
+	case []interface{}:
+		out := make([]interface{}, len(n))
+		for i, item := range n {
+			derefed, err := b.deref(item, root, doc, stack)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = derefed
+		}
+		return out, nil
+	case map[string]interface{}:
+		ref, isRef := n["$ref"].(string)
+		if isRef && (strings.HasPrefix(ref, "#") || strings.HasPrefix(ref, RegistryRefPrefix)) {
+			return b.resolve(n, ref, root, doc, stack)
+		}
+
+		out := make(map[string]interface{}, len(n))
+		for k, v := range n {
+			derefed, err := b.deref(v, root, doc, stack)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = derefed
+		}
+		return out, nil
+	}
+	return node, nil
+}
+
+// resolve expands a reference. Keywords next to the $ref are kept, alongside
+// the referenced schema in an allOf.
+func (b bundler) resolve(node map[string]interface{}, ref string, root interface{}, doc string, stack []string) (interface{}, error) {
+	target, pointer, _ := strings.Cut(ref, "#")
+	if target != "" {
+		name := strings.TrimPrefix(target, RegistryRefPrefix)
+		parsed, err := b.document(name)
+		if err != nil {
+			return nil, err
+		}
+		root, doc = parsed, name
+	}
+
+	key := doc + "#" + pointer
+	for _, expanding := range stack {
+		if expanding == key {
+			return nil, newError("409", "recursive reference %q can't be dereferenced", ref)
+		}
+	}
+
+	resolved, err := resolvePointer(root, pointer)
+	if err != nil {
+		return nil, newError("400", "unresolvable reference %q: %v", ref, err)
+	}
+	resolved, err = b.deref(resolved, root, doc, append(stack, key))
+	if err != nil {
+		return nil, err
+	}
+	if inlined, ok := resolved.(map[string]interface{}); ok && target != "" && pointer == "" {
+		// Identifiers of the referenced document would change how the rest of
+		// it is resolved
+		delete(inlined, "$schema")
+		delete(inlined, "$id")
+	}
+
+	if len(node) == 1 {
+		return resolved, nil
+	}
+	out := map[string]interface{}{"allOf": []interface{}{resolved}}
+	for k, v := range node {
+		if k == "$ref" {
+			continue
+		}
+		derefed, err := b.deref(v, root, doc, stack)
+		if err != nil {
+			return nil, err
+		}
+		out[k] = derefed
+	}
+	return out, nil
+}
+
+// document returns the parsed body of a registered schema.
+func (b bundler) document(name string) (interface{}, error) {
+	if doc, ok := b.docs[name]; ok {
+		return doc, nil
+	}
+
+	body, ok := b.lookup(name)
+	if !ok {
+		return nil, newError("400", "referenced schema %q is not registered", name)
+	}
+	var doc interface{}
+	err := json.Unmarshal([]byte(body), &doc)
+	if err != nil {
+		return nil, newError("400", "referenced schema %q: %v", name, err)
+	}
+	b.docs[name] = doc
+	return doc, nil
+}
+
+// resolvePointer returns the value a JSON Pointer fragment points to in doc.
+func resolvePointer(doc interface{}, pointer string) (interface{}, error) {
+	pointer, err := url.PathUnescape(pointer)
+	if err != nil {
+		return nil, err
+	}
+	if pointer == "" {
+		return doc, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid pointer %q", pointer)
+	}
+
+	node := doc
+	for _, token := range strings.Split(pointer[1:], "/") {
+		token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+		switch n := node.(type) {
+		case map[string]interface{}:
+			v, ok := n[token]
+			if !ok {
+				return nil, fmt.Errorf("%q not found", token)
+			}
+			node = v
+		case []interface{}:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(n) {
+				return nil, fmt.Errorf("index %q out of range", token)
+			}
+			node = n[i]
+		default:
+			return nil, fmt.Errorf("%q not found", token)
+		}
+	}
+	return node, nil
+}
+
+// lookupBody returns the body of a cached schema, for resolving references.
+func (reg *SchemaRegistry) lookupBody(name string) (string, bool) {
+	reg.schemasMu.RLock()
+	defer reg.schemasMu.RUnlock()
+
+	schema, ok := reg.schemas[name]
+	return schema.Body, ok
+}
+
+// compileBody returns an error if a body, with its registry references
+// resolved, is not a usable JSON Schema.
+func (reg *SchemaRegistry) compileBody(body string) error {
+	resolved, err := reg.resolvedBody(body)
+	if err != nil {
+		return err
+	}
+	if err := CompileSchema(resolved); err != nil {
+		return newError("400", "invalid schema: %v", err)
+	}
+	return nil
+}
+
+// resolvedBody returns a body with its registry references dereferenced, so it
+// can be compiled and validated against on its own.
+func (reg *SchemaRegistry) resolvedBody(body string) (string, error) {
+	if !hasRegistryRefs(body) {
+		return body, nil
+	}
+	return Bundle(body, reg.lookupBody)
+}
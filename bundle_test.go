@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestBundle(t *testing.T) {
+	registered := map[string]string{
+		"address": `{"$schema": "http://json-schema.org/draft-07/schema#", "type": "object", "properties": {"street": {"$ref": "#/definitions/street"}}, "definitions": {"street": {"type": "string"}}}`,
+	}
+	lookup := func(name string) (string, bool) {
+		body, ok := registered[name]
+		return body, ok
+	}
+
+	body := `{"type": "object", "properties": {"home": {"$ref": "schema:address"}, "id": {"$ref": "#/definitions/id", "description": "order id"}}, "definitions": {"id": {"type": "integer"}}}`
+	bundled, err := Bundle(body, lookup)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var doc map[string]interface{}
+	json.Unmarshal([]byte(bundled), &doc)
+	props := doc["properties"].(map[string]interface{})
+
+	home := props["home"].(map[string]interface{})
+	street := home["properties"].(map[string]interface{})["street"]
+	if !reflect.DeepEqual(street, map[string]interface{}{"type": "string"}) {
+		t.Errorf("Expected the referenced schema's own refs to be resolved, got %v", street)
+	}
+	if _, ok := home["$schema"]; ok {
+		t.Errorf("Expected $schema to be dropped from the inlined schema")
+	}
+	id := props["id"].(map[string]interface{})
+	if id["description"] != "order id" || id["allOf"] == nil {
+		t.Errorf("Expected keywords next to the $ref to be kept, got %v", id)
+	}
+
+	if _, err := Bundle(`{"$ref": "schema:missing"}`, lookup); errorCode(err) != "400" {
+		t.Errorf("Expected an unregistered reference to fail, got %v", err)
+	}
+	recursive := `{"type": "object", "properties": {"child": {"$ref": "#"}}}`
+	if _, err := Bundle(recursive, lookup); errorCode(err) != "409" {
+		t.Errorf("Expected a recursive schema to fail, got %v", err)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"sync"
+)
+
+// resultKey identifies a payload validated against a revision of a schema.
+// Strictness is part of it, as reloading the settings may change it for the
+// same revision.
+type resultKey struct {
+	name     string
+	revision uint64
+	strict   bool
+	hash     [sha256.Size]byte
+}
+
+type resultEntry struct {
+	key resultKey
+	err error
+}
+
+// ResultCache remembers the verdicts of recent validations, so identical
+// payloads sent again and again are only validated once per schema revision.
+// It evicts the least recently used verdict when full. A nil cache caches
+// nothing.
+type ResultCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[resultKey]*list.Element
+}
+
+// NewResultCache returns a cache of at most size verdicts, or nil if size is
+// zero.
+func NewResultCache(size int) *ResultCache {
+	if size < 1 {
+		return nil
+	}
+	return &ResultCache{
+		size:    size,
+		order:   list.New(),
+		entries: map[resultKey]*list.Element{},
+	}
+}
+
+// resultKeyFor returns the cache key of a payload validated against schema,
+// strictly or not.
+func resultKeyFor(schema Schema, strict bool, data []byte) resultKey {
+	return resultKey{name: schema.Name, revision: schema.Revision, strict: strict, hash: sha256.Sum256(data)}
+}
+
+// Get returns the cached verdict, a nil error for a valid payload, and
+// whether there was one.
+func (c *ResultCache) Get(key resultKey) (error, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*resultEntry).err, true
+}
+
+// Add caches a verdict.
+func (c *ResultCache) Add(key resultKey, err error) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		elem.Value.(*resultEntry).err = err
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&resultEntry{key: key, err: err})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*resultEntry).key)
+	}
+}
+
+// Len returns the number of cached verdicts.
+func (c *ResultCache) Len() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.order.Len()
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+)
+
+func TestResultCache(t *testing.T) {
+	cache := NewResultCache(2)
+	schema := Schema{Name: "numbers", Revision: 1}
+
+	one := resultKeyFor(schema, false, []byte("1"))
+	two := resultKeyFor(schema, false, []byte(`"two"`))
+	cache.Add(one, nil)
+	cache.Add(two, errors.New("invalid payload"))
+
+	if err, ok := cache.Get(one); !ok || err != nil {
+		t.Errorf("Expected a cached valid verdict, got %v %v", err, ok)
+	}
+	if err, ok := cache.Get(two); !ok || err == nil {
+		t.Errorf("Expected a cached invalid verdict, got %v %v", err, ok)
+	}
+
+	schema.Revision = 2
+	if _, ok := cache.Get(resultKeyFor(schema, false, []byte("1"))); ok {
+		t.Errorf("Expected verdicts of other revisions not to be used")
+	}
+
+	// one was used least recently
+	cache.Add(resultKeyFor(schema, false, []byte("3")), nil)
+	if _, ok := cache.Get(one); ok {
+		t.Errorf("Expected the least recently used verdict to be evicted")
+	}
+	if cache.Len() != 2 {
+		t.Errorf("Expected 2 cached verdicts, got %d", cache.Len())
+	}
+
+	var disabled *ResultCache
+	disabled.Add(one, nil)
+	if _, ok := disabled.Get(one); ok {
+		t.Errorf("Expected a nil cache to cache nothing")
+	}
+}
+
+func TestCachedVerdictsFollowStrictness(t *testing.T) {
+	reg := NewSchemaRegistry(nil, nil)
+	reg.results = NewResultCache(10)
+	schema := Schema{Name: "orders", Revision: 1, Body: `{"type": "object", "properties": {"id": {"type": "integer"}}}`}
+	data := []byte(`{"id": 1, "extra": true}`)
+
+	if _, err := reg.checkMsg(schema, "orders", data, nats.Header{}); err != nil {
+		t.Fatalf("Expected unexpected properties to pass, got %v", err)
+	}
+
+	// Reloading the settings turns strictness on for the same revision
+	s := defaultSettings()
+	s.Strict = true
+	reg.opts.Store(s)
+	if _, err := reg.checkMsg(schema, "orders", data, nats.Header{}); err == nil {
+		t.Errorf("Expected unexpected properties to be rejected once strict")
+	}
+}
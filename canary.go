@@ -0,0 +1,217 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/micro"
+)
+
+// DefaultCanaryPeriod is how long a revision in canary must agree with the
+// previous one when its canary doesn't say.
+const DefaultCanaryPeriod = time.Hour
+
+// Canary keeps validating payloads against the previous revision of a schema
+// after an update, shadow-validating them against the new one, until the new
+// one has agreed with it for a clean period.
+type Canary struct {
+	// Period is how long the new revision must reach the same verdicts as the
+	// previous one before payloads are validated against it, such as 30m.
+	// Defaults to an hour.
+	Period string `json:"period,omitempty"`
+	// Previous is the revision payloads are validated against meanwhile and
+	// Since when the canary started, both set by the registry.
+	Previous uint64    `json:"previous,omitempty"`
+	Since    time.Time `json:"since,omitempty"`
+}
+
+// checkCanary returns an error if a schema's canary is invalid, and starts it
+// against the current revision. Canaries copied along by updates not
+// changing the body, such as those of the lifecycle, carry on as they were.
+func checkCanary(schema *Schema, current Schema, exists bool) error {
+	canary := schema.Canary
+	if canary == nil {
+		return nil
+	}
+	if !exists {
+		return newError("400", "schema %q has no previous revision to canary against", schema.Name)
+	}
+	if canary.Period == "" {
+		canary.Period = DefaultCanaryPeriod.String()
+	}
+	period, err := time.ParseDuration(canary.Period)
+	if err != nil || period <= 0 {
+		return newError("400", "invalid canary period %q of schema %q, expected a duration such as 30m", canary.Period, schema.Name)
+	}
+	if current.Canary != nil && *canary == *current.Canary && schema.Body == current.Body {
+		return nil
+	}
+	canary.Previous = current.Revision
+	canary.Since = time.Now().UTC()
+	return nil
+}
+
+// CanaryStatus reports how a revision in canary compares with the previous
+// one, as seen by an instance.
+type CanaryStatus struct {
+	Schema   string    `json:"schema"`
+	Revision uint64    `json:"revision"`
+	Previous uint64    `json:"previous"`
+	Since    time.Time `json:"since"`
+	Period   string    `json:"period"`
+	// Until is when payloads are validated against the new revision, unless
+	// they diverge again before.
+	Until time.Time `json:"until"`
+	// Promoted is true once payloads are validated against the new revision.
+	Promoted bool `json:"promoted"`
+	// Validated counts the payloads validated against both revisions,
+	// Accepted those only the new one accepts and Rejected those only the new
+	// one rejects.
+	Validated      uint64     `json:"validated"`
+	Accepted       uint64     `json:"accepted"`
+	Rejected       uint64     `json:"rejected"`
+	LastDivergence *time.Time `json:"last_divergence,omitempty"`
+	// LastError is why the last diverging payload was rejected.
+	LastError string `json:"last_error,omitempty"`
+}
+
+// canaryRun counts the verdicts of a revision in canary.
+type canaryRun struct {
+	revision     uint64
+	validated    uint64
+	accepted     uint64
+	rejected     uint64
+	lastDiverged time.Time
+	lastError    string
+}
+
+// CanaryLog compares the verdicts of the revisions in canary with those of
+// the revisions before them, per schema, since this instance started.
+type CanaryLog struct {
+	mu   sync.Mutex
+	runs map[string]*canaryRun
+}
+
+func NewCanaryLog() *CanaryLog {
+	return &CanaryLog{runs: map[string]*canaryRun{}}
+}
+
+// run returns the run of a revision in canary, starting it over for a new
+// revision. It must be called with the lock held.
+func (l *CanaryLog) run(schema Schema) *canaryRun {
+	run := l.runs[schema.Name]
+	if run == nil || run.revision != schema.Revision {
+		run = &canaryRun{revision: schema.Revision}
+		l.runs[schema.Name] = run
+	}
+	return run
+}
+
+// Record counts a payload validated against a revision in canary and the one
+// before it, with the errors of both.
+func (l *CanaryLog) Record(schema Schema, canaryErr, previousErr error, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	run := l.run(schema)
+	run.validated++
+	switch {
+	case canaryErr == nil && previousErr != nil:
+		run.accepted++
+		run.lastError = previousErr.Error()
+	case canaryErr != nil && previousErr == nil:
+		run.rejected++
+		run.lastError = canaryErr.Error()
+	default:
+		return
+	}
+	run.lastDiverged = now
+}
+
+// Status reports on a revision in canary at a point in time.
+func (l *CanaryLog) Status(schema Schema, now time.Time) CanaryStatus {
+	canary := schema.Canary
+	period, err := time.ParseDuration(canary.Period)
+	if err != nil {
+		period = DefaultCanaryPeriod
+	}
+	status := CanaryStatus{
+		Schema:   schema.Name,
+		Revision: schema.Revision,
+		Previous: canary.Previous,
+		Since:    canary.Since,
+		Period:   canary.Period,
+		Until:    canary.Since.Add(period),
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	run := l.run(schema)
+	status.Validated = run.validated
+	status.Accepted = run.accepted
+	status.Rejected = run.rejected
+	status.LastError = run.lastError
+	if !run.lastDiverged.IsZero() {
+		last := run.lastDiverged
+		status.LastDivergence = &last
+		if until := last.Add(period); until.After(status.Until) {
+			status.Until = until
+		}
+	}
+	status.Promoted = !now.Before(status.Until)
+	return status
+}
+
+// canaryRevision returns the revision payloads of a schema are validated
+// against while it's in canary, keeping the state of the head like pinned
+// revisions, and true if the schema is to be shadow-validated.
+func (reg *SchemaRegistry) canaryRevision(schema Schema, now time.Time) (Schema, bool) {
+	if schema.Canary == nil || reg.canaries.Status(schema, now).Promoted {
+		return schema, false
+	}
+	previous, err := reg.storedRevision(schema.Name, schema.Canary.Previous)
+	if err != nil {
+		logger("canary").Error("error loading the previous revision, validating against the new one", "schema", schema.Name, "revision", schema.Canary.Previous, "error", err)
+		return schema, false
+	}
+	previous.State = schema.State
+	return previous, true
+}
+
+// shadowValidate validates a payload against a revision in canary, and
+// reports it if the verdict differs from that of the previous revision.
+func (reg *SchemaRegistry) shadowValidate(m *nats.Msg, schema Schema, subject string, data []byte, previousErr error) {
+	_, err := reg.check(schema, subject, data, m.Header)
+	reg.canaries.Record(schema, err, previousErr, time.Now().UTC())
+
+	switch {
+	case err == nil && previousErr != nil:
+		requestLogger(m, schema, subject).Warn("Canary revision accepts a payload the previous one rejects", "previous", schema.Canary.Previous, "error", previousErr)
+	case err != nil && previousErr == nil:
+		requestLogger(m, schema, subject).Warn("Canary revision rejects a payload the previous one accepts", "previous", schema.Canary.Previous, "error", err)
+	}
+}
+
+// Canary subject: $SCHEMA.CANARY.<schema_name>
+//
+// Returns how the revision of a schema in canary compares with the previous
+// one, as seen by this instance.
+func (reg *SchemaRegistry) GetCanary(r micro.Request) {
+	name := subjectName(r.Subject())
+
+	reg.schemasMu.RLock()
+	schema, ok := reg.schemas[name]
+	reg.schemasMu.RUnlock()
+	if !ok {
+		r.Error("404", "Not found", nil)
+		return
+	}
+	if schema.Canary == nil {
+		r.Error("404", "schema is not in canary", nil)
+		return
+	}
+
+	r.RespondJSON(reg.canaries.Status(schema, time.Now()))
+}
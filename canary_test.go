@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCheckCanary(t *testing.T) {
+	current := Schema{Name: "orders", Revision: 4, Body: `{"type": "object"}`}
+
+	schema := Schema{Name: "orders", Body: `{"type": "object", "required": ["id"]}`, Canary: &Canary{}}
+	if err := checkCanary(&schema, current, true); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if schema.Canary.Previous != 4 || schema.Canary.Period != "1h0m0s" || schema.Canary.Since.IsZero() {
+		t.Errorf("Expected the canary to start against revision 4 for an hour, got %+v", schema.Canary)
+	}
+
+	// A lifecycle update copying the revision keeps its canary going
+	current = schema
+	current.Revision = 5
+	copied := current
+	copied.Canary = &Canary{}
+	*copied.Canary = *current.Canary
+	copied.State = StateDeprecated
+	if err := checkCanary(&copied, current, true); err != nil || copied.Canary.Previous != 4 {
+		t.Errorf("Expected the copied canary to keep revision 4, got %+v, %v", copied.Canary, err)
+	}
+
+	bad := Schema{Name: "orders", Canary: &Canary{Period: "soon"}}
+	if err := checkCanary(&bad, current, true); errorCode(err) != "400" {
+		t.Errorf("Expected an invalid period to be rejected, got %v", err)
+	}
+	if err := checkCanary(&Schema{Name: "orders", Canary: &Canary{}}, Schema{}, false); errorCode(err) != "400" {
+		t.Errorf("Expected a canary without a previous revision to be rejected, got %v", err)
+	}
+}
+
+func TestCanaryLog(t *testing.T) {
+	since := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	schema := Schema{Name: "orders", Revision: 5, Canary: &Canary{Period: "1h", Previous: 4, Since: since}}
+	log := NewCanaryLog()
+
+	log.Record(schema, nil, nil, since.Add(time.Minute))
+	if status := log.Status(schema, since.Add(59*time.Minute)); status.Promoted || status.Validated != 1 {
+		t.Errorf("Expected the canary to run for its period, got %+v", status)
+	}
+	if status := log.Status(schema, since.Add(time.Hour)); !status.Promoted {
+		t.Errorf("Expected the canary to be promoted after a clean period, got %+v", status)
+	}
+
+	log.Record(schema, errors.New("id is required"), nil, since.Add(30*time.Minute))
+	status := log.Status(schema, since.Add(time.Hour))
+	if status.Promoted || status.Rejected != 1 || status.LastError != "id is required" {
+		t.Errorf("Expected a divergence to restart the clean period, got %+v", status)
+	}
+	if !status.Until.Equal(since.Add(90 * time.Minute)) {
+		t.Errorf("Expected the canary to run until 01:30, got %v", status.Until)
+	}
+
+	schema.Revision = 6
+	if status := log.Status(schema, since); status.Validated != 0 {
+		t.Errorf("Expected a new revision to start over, got %+v", status)
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/nats-io/nats.go/micro"
+)
+
+// ChangelogRequest selects the revisions a changelog covers. Without From the
+// changelog covers the whole history, without To it ends at the latest
+// revision.
+type ChangelogRequest struct {
+	From uint64 `json:"from,omitempty"`
+	To   uint64 `json:"to,omitempty"`
+}
+
+// Changelog subject: $SCHEMA.CHANGELOG.<schema_name>
+func (reg *SchemaRegistry) GetChangelog(r micro.Request) {
+	var req ChangelogRequest
+	if len(r.Data()) > 0 {
+		err := json.Unmarshal(r.Data(), &req)
+		if err != nil {
+			r.Error("400", err.Error(), nil)
+			return
+		}
+	}
+
+	name := subjectName(r.Subject())
+
+	history, err := reg.history(name)
+	if err != nil {
+		r.Error("500", err.Error(), nil)
+		return
+	}
+	if len(history) == 0 {
+		r.Error("404", "Not found", nil)
+		return
+	}
+
+	changelog, err := Changelog(name, history, req.From, req.To)
+	if err != nil {
+		r.Error(errorCode(err), err.Error(), nil)
+		return
+	}
+
+	r.Respond([]byte(changelog))
+}
+
+// Changelog renders the changes in a schema's history as Markdown, for release
+// notes. With a from revision it describes the changes from it to the to
+// revision, or the latest one. Otherwise it lists the changes of every
+// revision, newest first.
+func Changelog(name string, history []HistoryEntry, from, to uint64) (string, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Changelog for %s\n", name)
+
+	if from != 0 {
+		old, new := findRevision(history, from), findRevision(history, to)
+		if to == 0 {
+			new = latestRevision(history)
+		}
+		if old == nil || new == nil {
+			return "", newError("404", "revision not found")
+		}
+
+		fmt.Fprintf(&b, "\n## Revision %d to %d\n\n", old.Revision, new.Revision)
+		err := writeRevisionChanges(&b, old, new)
+		return b.String(), err
+	}
+
+	var sections []string
+	var previous *Schema
+	for _, entry := range history {
+		var section strings.Builder
+		fmt.Fprintf(&section, "\n## Revision %d (%s)\n\n", entry.Revision, entry.Time.Format("2006-01-02"))
+
+		switch {
+		case entry.Schema == nil:
+			section.WriteString("- Unregistered\n")
+		case previous == nil:
+			fmt.Fprintf(&section, "- Registered for subject `%s`\n", entry.Schema.Subject)
+		default:
+			err := writeRevisionChanges(&section, previous, entry.Schema)
+			if err != nil {
+				return "", err
+			}
+		}
+
+		previous = entry.Schema
+		sections = append([]string{section.String()}, sections...)
+	}
+
+	b.WriteString(strings.Join(sections, ""))
+	return b.String(), nil
+}
+
+// writeRevisionChanges writes a list item for every change between two
+// revisions, breaking changes first.
+func writeRevisionChanges(b *strings.Builder, old, new *Schema) error {
+	changes, err := DiffSchemas(old.Body, new.Body)
+	if err != nil {
+		return newError("400", err.Error())
+	}
+
+	var lines []string
+	if old.Subject != new.Subject {
+		lines = append(lines, fmt.Sprintf("Subject changed from `%s` to `%s`", old.Subject, new.Subject))
+	}
+	if old.State != new.State {
+		lines = append(lines, fmt.Sprintf("State changed from %s to %s", old.State, new.State))
+	}
+	for _, c := range BreakingChanges(changes) {
+		lines = append(lines, "**Breaking:** "+capitalize(c.Description))
+	}
+	for _, c := range changes {
+		if !c.Breaking {
+			lines = append(lines, capitalize(c.Description))
+		}
+	}
+	if len(lines) == 0 {
+		lines = append(lines, "No changes to the payload schema")
+	}
+
+	for _, line := range lines {
+		fmt.Fprintf(b, "- %s\n", line)
+	}
+	return nil
+}
+
+// findRevision returns the schema stored at a revision, or nil if the history
+// has no put at that revision.
+func findRevision(history []HistoryEntry, revision uint64) *Schema {
+	for _, entry := range history {
+		if entry.Revision == revision {
+			return entry.Schema
+		}
+	}
+	return nil
+}
+
+// latestRevision returns the schema of the most recent put in the history.
+func latestRevision(history []HistoryEntry) *Schema {
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Schema != nil {
+			return history[i].Schema
+		}
+	}
+	return nil
+}
+
+func capitalize(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	return string(unicode.ToUpper(r)) + s[size:]
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChangelog(t *testing.T) {
+	v1 := &Schema{Subject: "orders", State: StateActive, Revision: 1,
+		Body: `{"type": "object", "properties": {"amount": {"type": "number"}, "status": {"enum": ["OPEN"]}}}`}
+	v2 := &Schema{Subject: "orders", State: StateActive, Revision: 2,
+		Body: `{"type": "object", "properties": {"amount": {"type": "number"}, "status": {"enum": ["OPEN", "CANCELLED"]}}, "required": ["amount"]}`}
+	history := []HistoryEntry{
+		{Revision: 1, Operation: "put", Time: time.Now(), Schema: v1},
+		{Revision: 2, Operation: "put", Time: time.Now(), Schema: v2},
+	}
+
+	changelog, err := Changelog("orders", history, 0, 0)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	for _, line := range []string{
+		"- **Breaking:** Field `amount` is now required",
+		"- Enum `status` gained value CANCELLED",
+		"- Registered for subject `orders`",
+	} {
+		if !strings.Contains(changelog, line) {
+			t.Errorf("Expected changelog to contain %q, got:\n%s", line, changelog)
+		}
+	}
+	if strings.Index(changelog, "Revision 2") > strings.Index(changelog, "Revision 1") {
+		t.Errorf("Expected the newest revision first, got:\n%s", changelog)
+	}
+
+	if _, err := Changelog("orders", history, 3, 0); errorCode(err) != "404" {
+		t.Errorf("Expected an unknown revision to be not found, got %v", err)
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/nats-io/nats.go/micro"
+)
+
+// The checks a CI verdict combines, in the order they run.
+const (
+	CheckCompile       = "compile"
+	CheckLint          = "lint"
+	CheckCompatibility = "compatibility"
+	CheckExamples      = "examples"
+)
+
+// CheckRequest is a candidate revision of a schema, as a CI pipeline would
+// propose it.
+type CheckRequest struct {
+	Name   string `json:"name"`
+	Schema Schema `json:"schema"`
+}
+
+// CheckResult is the outcome of a single check. Warnings don't fail it.
+type CheckResult struct {
+	Check    string   `json:"check"`
+	Pass     bool     `json:"pass"`
+	Errors   []string `json:"errors,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// CheckVerdict passes if every check passed, meaning the registry would
+// accept the candidate as the next revision.
+type CheckVerdict struct {
+	Name string `json:"name"`
+	// Revision is the current revision the candidate was compared against,
+	// zero if the schema isn't registered yet.
+	Revision uint64        `json:"revision,omitempty"`
+	Pass     bool          `json:"pass"`
+	Checks   []CheckResult `json:"checks"`
+	Changes  []Change      `json:"changes,omitempty"`
+}
+
+// CI check subject: $SCHEMA.CI.CHECK
+func (reg *SchemaRegistry) CICheck(r micro.Request) {
+	var req CheckRequest
+	err := json.Unmarshal(r.Data(), &req)
+	if err != nil {
+		r.Error("400", err.Error(), nil)
+		return
+	}
+	if req.Name == "" {
+		r.Error("400", "missing schema name", nil)
+		return
+	}
+
+	verdict, err := reg.ciCheck(req.Name, req.Schema)
+	if err != nil {
+		r.Error(errorCode(err), err.Error(), nil)
+		return
+	}
+
+	r.RespondJSON(verdict)
+}
+
+// ciCheck runs every check an update of the named schema goes through, without
+// storing anything. Checks that depend on a compiling body are skipped when it
+// doesn't compile.
+func (reg *SchemaRegistry) ciCheck(name string, schema Schema) (CheckVerdict, error) {
+	schema.Name = name
+	verdict := CheckVerdict{Name: name, Pass: true}
+	record := func(result CheckResult) {
+		result.Pass = len(result.Errors) == 0
+		verdict.Pass = verdict.Pass && result.Pass
+		verdict.Checks = append(verdict.Checks, result)
+	}
+
+	current, exists, err := reg.current(name)
+	if err != nil {
+		return verdict, err
+	}
+	if exists {
+		verdict.Revision = current.Revision
+		if schema.Examples == nil {
+			schema.Examples = current.Examples
+		}
+	}
+
+	compile := CheckResult{Check: CheckCompile}
+	err = deriveBody(&schema)
+	if err == nil {
+		err = reg.settings().Limits.CheckSchema(schema)
+	}
+	if err == nil {
+		err = reg.compileBody(schema.Body)
+	}
+	if err == nil {
+		err = reg.checkRules(schema)
+	}
+	if err == nil {
+		err = reg.resolvePlugin(&schema)
+	}
+	if err == nil {
+		err = checkComponent(schema)
+	}
+	if err != nil {
+		compile.Errors = append(compile.Errors, err.Error())
+		record(compile)
+		return verdict, nil
+	}
+	record(compile)
+
+	lint := CheckResult{Check: CheckLint}
+	issues, err := Lint(schema.Body)
+	if err != nil {
+		lint.Errors = append(lint.Errors, err.Error())
+	}
+	for _, issue := range overrideSeverities(issues, reg.settings().Lint) {
+		if issue.Severity == LintError {
+			lint.Errors = append(lint.Errors, issue.String())
+		} else {
+			lint.Warnings = append(lint.Warnings, issue.String())
+		}
+	}
+	record(lint)
+
+	compat := CheckResult{Check: CheckCompatibility}
+	if exists {
+		verdict.Changes, err = DiffSchemas(current.Body, schema.Body)
+		if err != nil {
+			compat.Errors = append(compat.Errors, err.Error())
+		}
+		for _, c := range BreakingChanges(verdict.Changes) {
+			// Breaking changes are only refused in backward compatibility mode
+			if reg.settings().Compatibility == CompatibilityBackward {
+				compat.Errors = append(compat.Errors, c.Description)
+			} else {
+				compat.Warnings = append(compat.Warnings, c.Description)
+			}
+		}
+		consumers, err := reg.consumers(schema.Name)
+		if err != nil {
+			compat.Errors = append(compat.Errors, err.Error())
+		}
+		if impact := consumerImpact(consumers, verdict.Changes); len(impact) > 0 {
+			desc := "breaks consumers " + describeImpact(impact)
+			if reg.settings().Compatibility == CompatibilityBackward {
+				compat.Errors = append(compat.Errors, desc)
+			} else {
+				compat.Warnings = append(compat.Warnings, desc)
+			}
+		}
+	}
+	if err := checkSemVer(current, exists, schema); err != nil {
+		compat.Errors = append(compat.Errors, err.Error())
+	}
+	if err := reg.checkDependents(schema, nil); err != nil {
+		compat.Errors = append(compat.Errors, err.Error())
+	}
+	record(compat)
+
+	examples := CheckResult{Check: CheckExamples}
+	broken := reg.brokenExamples(schema)
+	if reg.settings().Examples == ExamplesWarn {
+		examples.Warnings = broken
+	} else {
+		examples.Errors = broken
+	}
+	record(examples)
+
+	return verdict, nil
+}
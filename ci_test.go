@@ -0,0 +1,56 @@
+package main
+
+import "testing"
+
+func TestCICheck(t *testing.T) {
+	reg := NewSchemaRegistry(newMemKV(), nil)
+	reg.settings().Compatibility = CompatibilityBackward
+	if _, err := reg.register(Schema{Name: "orders", Subject: "orders", Body: `{"type": "object", "properties": {"id": {"type": "integer", "description": "The order ID"}}}`}); err != nil {
+		t.Fatal(err)
+	}
+
+	failed := func(verdict CheckVerdict) []string {
+		var checks []string
+		for _, check := range verdict.Checks {
+			if !check.Pass {
+				checks = append(checks, check.Check)
+			}
+		}
+		return checks
+	}
+
+	verdict, err := reg.ciCheck("orders", Schema{Subject: "orders", Body: `{"type": "object", "properties": {"id": {"type": "integer", "description": "The order ID"}, "total": {"type": "number", "description": "The total"}}}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !verdict.Pass || len(verdict.Checks) != 4 || len(failed(verdict)) != 0 {
+		t.Errorf("Expected every check to pass, got %+v", verdict)
+	}
+	if verdict.Revision == 0 || len(verdict.Changes) == 0 {
+		t.Errorf("Expected the changes from the current revision, got %+v", verdict)
+	}
+
+	verdict, err = reg.ciCheck("orders", Schema{Subject: "orders", Body: `{"type": "object", "properties": {"id": {"type": "string", "description": "The order ID"}}}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checks := failed(verdict); verdict.Pass || len(checks) != 1 || checks[0] != CheckCompatibility {
+		t.Errorf("Expected only the compatibility check to fail, got %+v", verdict)
+	}
+
+	verdict, err = reg.ciCheck("customers", Schema{Subject: "customers", Body: `{"properties": {"id": {"type": "string", "description": "The customer ID"}}}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checks := failed(verdict); verdict.Pass || len(checks) != 1 || checks[0] != CheckLint {
+		t.Errorf("Expected only the lint check to fail, got %+v", verdict)
+	}
+
+	verdict, err = reg.ciCheck("orders", Schema{Subject: "orders", Body: `{"type": "nope"}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verdict.Pass || len(verdict.Checks) != 1 || verdict.Checks[0].Check != CheckCompile {
+		t.Errorf("Expected the other checks to be skipped when the body doesn't compile, got %+v", verdict)
+	}
+}
@@ -0,0 +1,121 @@
+// Package client is a Go client of the schema registry. Services depend on
+// the Registry interface, so their unit tests can use the in-memory Mock
+// instead of a registry reached over NATS.
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/micro"
+)
+
+// Schema is a registered schema, as the registry returns it.
+type Schema struct {
+	Name     string `json:"name"`
+	Subject  string `json:"subject,omitempty"`
+	Revision uint64 `json:"revision,omitempty"`
+	Type     string `json:"type"`
+	Body     string `json:"body"`
+	State    string `json:"state,omitempty"`
+	Version  uint64 `json:"version,omitempty"`
+}
+
+// Registry registers, gets and validates against schemas.
+type Registry interface {
+	// Register registers a new schema.
+	Register(schema Schema) (Schema, error)
+	// Get returns the current revision of a schema.
+	Get(name string) (Schema, error)
+	// Validate returns a *ValidationError if payload doesn't conform to the
+	// body of the named schema.
+	Validate(name string, payload []byte) error
+}
+
+// Error is an error the registry answered a request with. Code is the HTTP
+// like status code, such as 404 for a schema that isn't registered.
+type Error struct {
+	Code        string
+	Description string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Description)
+}
+
+// ValidationError is returned for a payload that doesn't conform to a schema.
+type ValidationError struct {
+	Violations []string
+}
+
+func (e *ValidationError) Error() string {
+	return "payload is invalid: " + strings.Join(e.Violations, "; ")
+}
+
+// Client is a Registry reached over NATS.
+type Client struct {
+	nc      *nats.Conn
+	timeout time.Duration
+}
+
+var _ Registry = (*Client)(nil)
+
+// New returns a client of the registry the connection reaches, whose
+// requests time out after timeout.
+func New(nc *nats.Conn, timeout time.Duration) *Client {
+	return &Client{nc: nc, timeout: timeout}
+}
+
+// nameToken returns the subject token of a schema name, whose dots are
+// written as slashes.
+func nameToken(name string) string {
+	return strings.ReplaceAll(name, ".", "/")
+}
+
+// request sends a request to the registry and decodes its reply into v.
+func (c *Client) request(subject string, data []byte, v interface{}) error {
+	msg, err := c.nc.Request(subject, data, c.timeout)
+	if err != nil {
+		return err
+	}
+	if code := msg.Header.Get(micro.ErrorCodeHeader); code != "" {
+		return &Error{Code: code, Description: msg.Header.Get(micro.ErrorHeader)}
+	}
+	return json.Unmarshal(msg.Data, v)
+}
+
+func (c *Client) Register(schema Schema) (Schema, error) {
+	data, err := json.Marshal(schema)
+	if err != nil {
+		return schema, err
+	}
+	var registered Schema
+	err = c.request("$SCHEMA.REGISTER."+nameToken(schema.Name), data, &registered)
+	return registered, err
+}
+
+func (c *Client) Get(name string) (Schema, error) {
+	var schema Schema
+	err := c.request("$SCHEMA.GET."+nameToken(name), nil, &schema)
+	return schema, err
+}
+
+// Validate validates the payload against the named schema with
+// $SCHEMA.TRY.<name>, without passing it on to the subject of the schema.
+func (c *Client) Validate(name string, payload []byte) error {
+	var result struct {
+		Valid  bool     `json:"valid"`
+		Errors []string `json:"errors"`
+	}
+	err := c.request("$SCHEMA.TRY."+nameToken(name), payload, &result)
+	if err != nil {
+		return err
+	}
+	if !result.Valid {
+		return &ValidationError{Violations: result.Errors}
+	}
+	return nil
+}
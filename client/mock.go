@@ -0,0 +1,81 @@
+package client
+
+import (
+	"sync"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// Mock is an in-memory Registry for unit tests, needing no NATS connection.
+// It validates against the schema bodies alone, without references to other
+// schemas, rules or the other checks of the registry.
+type Mock struct {
+	mu      sync.Mutex
+	schemas map[string]Schema
+	// revision is the last revision given to a schema
+	revision uint64
+}
+
+var _ Registry = (*Mock)(nil)
+
+// NewMock returns a Mock holding schemas.
+func NewMock(schemas ...Schema) *Mock {
+	m := &Mock{schemas: map[string]Schema{}}
+	for _, schema := range schemas {
+		m.Register(schema)
+	}
+	return m
+}
+
+func (m *Mock) Register(schema Schema) (Schema, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.schemas[schema.Name]; ok {
+		return schema, &Error{Code: "409", Description: "schema already exists"}
+	}
+	if _, err := gojsonschema.NewSchema(gojsonschema.NewStringLoader(schema.Body)); err != nil {
+		return schema, &Error{Code: "400", Description: "invalid schema: " + err.Error()}
+	}
+
+	if schema.Type == "" {
+		schema.Type = "json"
+	}
+	schema.State = "active"
+	m.revision++
+	schema.Revision = m.revision
+	schema.Version = 1
+	m.schemas[schema.Name] = schema
+	return schema, nil
+}
+
+func (m *Mock) Get(name string) (Schema, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	schema, ok := m.schemas[name]
+	if !ok {
+		return schema, &Error{Code: "404", Description: "Not found"}
+	}
+	return schema, nil
+}
+
+func (m *Mock) Validate(name string, payload []byte) error {
+	schema, err := m.Get(name)
+	if err != nil {
+		return err
+	}
+
+	result, err := gojsonschema.Validate(gojsonschema.NewStringLoader(schema.Body), gojsonschema.NewBytesLoader(payload))
+	if err != nil {
+		return &Error{Code: "400", Description: err.Error()}
+	}
+	if result.Valid() {
+		return nil
+	}
+	verr := &ValidationError{}
+	for _, desc := range result.Errors() {
+		verr.Violations = append(verr.Violations, desc.String())
+	}
+	return verr
+}
@@ -0,0 +1,35 @@
+package client
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMock(t *testing.T) {
+	var registry Registry = NewMock(Schema{Name: "orders", Body: `{"type": "object", "required": ["id"]}`})
+
+	if err := registry.Validate("orders", []byte(`{"id": 1}`)); err != nil {
+		t.Errorf("Expected a valid payload to pass, got %v", err)
+	}
+	err := registry.Validate("orders", []byte(`{}`))
+	var verr *ValidationError
+	if !errors.As(err, &verr) || len(verr.Violations) != 1 {
+		t.Errorf("Expected a violation of an invalid payload, got %v", err)
+	}
+
+	var rerr *Error
+	if _, err := registry.Get("payments"); !errors.As(err, &rerr) || rerr.Code != "404" {
+		t.Errorf("Expected an unknown schema not to be found, got %v", err)
+	}
+	if _, err := registry.Register(Schema{Name: "orders", Body: `{}`}); !errors.As(err, &rerr) || rerr.Code != "409" {
+		t.Errorf("Expected registering a schema twice to be rejected, got %v", err)
+	}
+	if _, err := registry.Register(Schema{Name: "payments", Body: `{"type": 1}`}); !errors.As(err, &rerr) || rerr.Code != "400" {
+		t.Errorf("Expected an invalid schema to be rejected, got %v", err)
+	}
+
+	schema, err := registry.Register(Schema{Name: "payments", Body: `{"type": "object"}`})
+	if err != nil || schema.Revision != 2 || schema.Type != "json" {
+		t.Errorf("Expected the schema to be registered, got %+v, %v", schema, err)
+	}
+}
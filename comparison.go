@@ -0,0 +1,164 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/micro"
+)
+
+// maxDivergences is how many of the latest diverging payloads a comparison
+// keeps.
+const maxDivergences = 20
+
+// Divergence is a payload a schema and the schema it's compared with reached
+// different verdicts about. Only one of them has an error.
+type Divergence struct {
+	Time      time.Time `json:"time"`
+	RequestID string    `json:"request_id,omitempty"`
+	Subject   string    `json:"subject"`
+	// Error is why the schema rejected the payload, ComparedError why the
+	// compared schema did.
+	Error         string `json:"error,omitempty"`
+	ComparedError string `json:"compared_error,omitempty"`
+}
+
+// Comparison reports where the verdicts of a schema and the schema it's
+// compared with differ, as seen by an instance.
+type Comparison struct {
+	Schema   string `json:"schema"`
+	Compared string `json:"compared"`
+	// Validated counts the payloads validated against both schemas, Accepted
+	// those only the compared schema accepts and Rejected those only the
+	// compared schema rejects.
+	Validated uint64 `json:"validated"`
+	Accepted  uint64 `json:"accepted"`
+	Rejected  uint64 `json:"rejected"`
+	// Divergences are the latest diverging payloads, newest first.
+	Divergences []Divergence `json:"divergences"`
+}
+
+// checkCompare returns an error if the schema a schema is compared with isn't
+// registered.
+func (reg *SchemaRegistry) checkCompare(schema Schema) error {
+	if schema.Compare == "" {
+		return nil
+	}
+	if schema.Compare == schema.Name {
+		return newError("400", "schema %q can't be compared with itself", schema.Name)
+	}
+	_, exists, err := reg.current(schema.Compare)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return newError("400", "compared schema %q is not registered", schema.Compare)
+	}
+	return nil
+}
+
+// ComparisonLog records where the verdicts of schemas and the schemas they
+// are compared with differ, since this instance started.
+type ComparisonLog struct {
+	mu          sync.Mutex
+	comparisons map[string]*Comparison
+}
+
+func NewComparisonLog() *ComparisonLog {
+	return &ComparisonLog{comparisons: map[string]*Comparison{}}
+}
+
+// comparison returns the comparison of a schema with another, starting it
+// over when the schema is compared with a different one. It must be called
+// with the lock held.
+func (l *ComparisonLog) comparison(schema, compared string) *Comparison {
+	c := l.comparisons[schema]
+	if c == nil || c.Compared != compared {
+		c = &Comparison{Schema: schema, Compared: compared, Divergences: []Divergence{}}
+		l.comparisons[schema] = c
+	}
+	return c
+}
+
+// Record counts a payload validated against a schema and the schema it's
+// compared with, keeping it if their errors differ.
+func (l *ComparisonLog) Record(schema, compared string, divergence Divergence, err, comparedErr error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	c := l.comparison(schema, compared)
+	c.Validated++
+	switch {
+	case err != nil && comparedErr == nil:
+		c.Accepted++
+		divergence.Error = err.Error()
+	case err == nil && comparedErr != nil:
+		c.Rejected++
+		divergence.ComparedError = comparedErr.Error()
+	default:
+		return
+	}
+
+	c.Divergences = append([]Divergence{divergence}, c.Divergences...)
+	if len(c.Divergences) > maxDivergences {
+		c.Divergences = c.Divergences[:maxDivergences]
+	}
+}
+
+// Get returns the comparison of a schema with another.
+func (l *ComparisonLog) Get(schema, compared string) Comparison {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	c := *l.comparison(schema, compared)
+	c.Divergences = append([]Divergence{}, c.Divergences...)
+	return c
+}
+
+// compareVerdicts validates a payload against the schema a schema is compared
+// with, and records it if the verdicts differ. The compared schema's body and
+// rules apply, the subject and headers were checked by the schema.
+func (reg *SchemaRegistry) compareVerdicts(m *nats.Msg, schema Schema, subject string, data []byte, err error) {
+	reg.schemasMu.RLock()
+	compared, ok := reg.schemas[schema.Compare]
+	reg.schemasMu.RUnlock()
+	if !ok {
+		requestLogger(m, schema, subject).Debug("Compared schema isn't registered", "compared", schema.Compare)
+		return
+	}
+
+	comparedErr := reg.validatePayload(compared, data)
+	divergence := Divergence{
+		Time:      time.Now().UTC(),
+		RequestID: m.Header.Get(SchemaRequestIDHeader),
+		Subject:   subject,
+	}
+	reg.comparisons.Record(schema.Name, compared.Name, divergence, err, comparedErr)
+
+	if (err == nil) != (comparedErr == nil) {
+		requestLogger(m, schema, subject).Info("Compared schema reached a different verdict", "compared", compared.Name, "error", err, "compared_error", comparedErr)
+	}
+}
+
+// Comparison subject: $SCHEMA.COMPARISON.<schema_name>
+//
+// Returns where the verdicts of a schema and the schema it's compared with
+// differ, as seen by this instance.
+func (reg *SchemaRegistry) GetComparison(r micro.Request) {
+	name := subjectName(r.Subject())
+
+	reg.schemasMu.RLock()
+	schema, ok := reg.schemas[name]
+	reg.schemasMu.RUnlock()
+	if !ok {
+		r.Error("404", "Not found", nil)
+		return
+	}
+	if schema.Compare == "" {
+		r.Error("404", "schema is not compared with another", nil)
+		return
+	}
+
+	r.RespondJSON(reg.comparisons.Get(name, schema.Compare))
+}
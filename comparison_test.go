@@ -0,0 +1,37 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestComparisonLog(t *testing.T) {
+	log := NewComparisonLog()
+
+	log.Record("orders", "orders_generated", Divergence{Subject: "orders"}, nil, nil)
+	log.Record("orders", "orders_generated", Divergence{Subject: "orders", RequestID: "a"}, nil, errors.New("id is required"))
+	log.Record("orders", "orders_generated", Divergence{Subject: "orders", RequestID: "b"}, errors.New("amount must be a number"), nil)
+
+	c := log.Get("orders", "orders_generated")
+	if c.Validated != 3 || c.Accepted != 1 || c.Rejected != 1 {
+		t.Errorf("Expected 3 payloads with one diverging each way, got %+v", c)
+	}
+	if len(c.Divergences) != 2 || c.Divergences[0].RequestID != "b" || c.Divergences[0].Error != "amount must be a number" {
+		t.Errorf("Expected the latest divergence first, got %+v", c.Divergences)
+	}
+	if c.Divergences[1].ComparedError != "id is required" {
+		t.Errorf("Expected the compared schema's error, got %+v", c.Divergences[1])
+	}
+
+	for i := 0; i < maxDivergences+5; i++ {
+		log.Record("orders", "orders_generated", Divergence{RequestID: fmt.Sprint(i)}, nil, errors.New("invalid"))
+	}
+	if c := log.Get("orders", "orders_generated"); len(c.Divergences) != maxDivergences {
+		t.Errorf("Expected %d divergences to be kept, got %d", maxDivergences, len(c.Divergences))
+	}
+
+	if c := log.Get("orders", "orders_v2"); c.Validated != 0 || len(c.Divergences) != 0 {
+		t.Errorf("Expected comparing with another schema to start over, got %+v", c)
+	}
+}
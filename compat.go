@@ -0,0 +1,277 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// Compatibility modes enforced on updates.
+const (
+	CompatibilityNone     = "none"
+	CompatibilityBackward = "backward"
+)
+
+// Change is a single difference between two revisions of a schema. A change
+// is breaking when payloads valid under the old revision may be rejected by the
+// new one.
+type Change struct {
+	Path        string `json:"path"`
+	Description string `json:"description"`
+	Breaking    bool   `json:"breaking"`
+	// added marks new properties, which can't break consumers
+	added bool
+}
+
+// CompileSchema returns an error if body is not a usable JSON Schema.
+func CompileSchema(body string) error {
+	_, err := gojsonschema.NewSchema(gojsonschema.NewStringLoader(body))
+	return err
+}
+
+// DiffSchemas compares two JSON Schema bodies and returns the changes from old
+// to new.
+func DiffSchemas(oldBody, newBody string) ([]Change, error) {
+	var old, new interface{}
+	if err := json.Unmarshal([]byte(oldBody), &old); err != nil {
+		return nil, fmt.Errorf("old schema: %w", err)
+	}
+	if err := json.Unmarshal([]byte(newBody), &new); err != nil {
+		return nil, fmt.Errorf("new schema: %w", err)
+	}
+
+	var changes []Change
+	diffNode("", asObject(old), asObject(new), &changes)
+	return changes, nil
+}
+
+// BreakingChanges filters the breaking changes out of a diff.
+func BreakingChanges(changes []Change) []Change {
+	var breaking []Change
+	for _, c := range changes {
+		if c.Breaking {
+			breaking = append(breaking, c)
+		}
+	}
+	return breaking
+}
+
+// describeChanges joins the descriptions of changes into a single line.
+func describeChanges(changes []Change) string {
+	var descs []string
+	for _, c := range changes {
+		descs = append(descs, c.Description)
+	}
+	return strings.Join(descs, ", ")
+}
+
+func diffNode(path string, old, new map[string]interface{}, changes *[]Change) {
+	add := func(breaking bool, format string, args ...interface{}) {
+		*changes = append(*changes, Change{Path: path, Description: fmt.Sprintf(format, args...), Breaking: breaking})
+	}
+	// Changes to a property are at its path rather than its parent's
+	addField := func(name string, breaking bool, format string) *Change {
+		field := joinPath(path, name)
+		*changes = append(*changes, Change{Path: field, Description: fmt.Sprintf(format, field), Breaking: breaking})
+		return &(*changes)[len(*changes)-1]
+	}
+	subject := "schema"
+	if path != "" {
+		subject = fmt.Sprintf("field `%s`", path)
+	}
+
+	// Types
+	oldTypes, newTypes := typeSet(old), typeSet(new)
+	for _, t := range sortedKeys(oldTypes) {
+		if !allowsType(newTypes, t) {
+			add(true, "%s no longer allows type %s", subject, t)
+		}
+	}
+	for _, t := range sortedKeys(newTypes) {
+		if !allowsType(oldTypes, t) {
+			add(false, "%s now allows type %s", subject, t)
+		}
+	}
+
+	// Enums
+	oldEnum, oldHasEnum := old["enum"].([]interface{})
+	newEnum, newHasEnum := new["enum"].([]interface{})
+	switch {
+	case oldHasEnum && newHasEnum:
+		for _, v := range oldEnum {
+			if !containsValue(newEnum, v) {
+				add(true, "enum %s lost value %v", enumName(path), v)
+			}
+		}
+		for _, v := range newEnum {
+			if !containsValue(oldEnum, v) {
+				add(false, "enum %s gained value %v", enumName(path), v)
+			}
+		}
+	case newHasEnum:
+		add(true, "%s is now restricted to %d enum values", subject, len(newEnum))
+	case oldHasEnum:
+		add(false, "%s is no longer restricted to enum values", subject)
+	}
+
+	// Keywords that can only be compared for equality
+	for _, keyword := range []string{"pattern", "format", "const"} {
+		o, oOk := old[keyword]
+		n, nOk := new[keyword]
+		switch {
+		case oOk && nOk && !reflect.DeepEqual(o, n):
+			add(true, "%s %s changed from %v to %v", subject, keyword, o, n)
+		case nOk && !oOk:
+			add(true, "%s now requires %s %v", subject, keyword, n)
+		case oOk && !nOk:
+			add(false, "%s no longer requires %s %v", subject, keyword, o)
+		}
+	}
+
+	// Lower and upper bounds
+	for _, keyword := range []string{"minimum", "exclusiveMinimum", "minLength", "minItems", "minProperties"} {
+		diffBound(old, new, keyword, true, add, subject)
+	}
+	for _, keyword := range []string{"maximum", "exclusiveMaximum", "maxLength", "maxItems", "maxProperties"} {
+		diffBound(old, new, keyword, false, add, subject)
+	}
+
+	// Required fields
+	oldRequired, newRequired := stringSet(old["required"]), stringSet(new["required"])
+	for _, name := range sortedKeys(newRequired) {
+		if !oldRequired[name] {
+			addField(name, true, "field `%s` is now required")
+		}
+	}
+	for _, name := range sortedKeys(oldRequired) {
+		if !newRequired[name] {
+			addField(name, false, "field `%s` is no longer required")
+		}
+	}
+
+	// Additional properties
+	oldClosed, newClosed := old["additionalProperties"] == false, new["additionalProperties"] == false
+	if newClosed && !oldClosed {
+		add(true, "%s no longer allows additional properties", subject)
+	}
+	if oldClosed && !newClosed {
+		add(false, "%s now allows additional properties", subject)
+	}
+
+	// Properties
+	oldProps, newProps := asObject(old["properties"]), asObject(new["properties"])
+	for _, name := range sortedKeys(oldProps) {
+		if _, ok := newProps[name]; !ok {
+			addField(name, newClosed, "field `%s` was removed")
+		}
+	}
+	for _, name := range sortedKeys(newProps) {
+		oldProp, ok := oldProps[name]
+		if !ok {
+			addField(name, false, "field `%s` was added").added = true
+			continue
+		}
+		diffNode(joinPath(path, name), asObject(oldProp), asObject(newProps[name]), changes)
+	}
+
+	// Array items
+	if oldItems, ok := old["items"].(map[string]interface{}); ok {
+		if newItems, ok := new["items"].(map[string]interface{}); ok {
+			diffNode(path+"[]", oldItems, newItems, changes)
+		}
+	}
+}
+
+func diffBound(old, new map[string]interface{}, keyword string, lower bool, add func(bool, string, ...interface{}), subject string) {
+	o, oOk := old[keyword].(float64)
+	n, nOk := new[keyword].(float64)
+	switch {
+	case oOk && nOk && o != n:
+		tightened := n > o
+		if !lower {
+			tightened = n < o
+		}
+		add(tightened, "%s %s changed from %v to %v", subject, keyword, o, n)
+	case nOk && !oOk:
+		add(true, "%s now has %s %v", subject, keyword, n)
+	case oOk && !nOk:
+		add(false, "%s no longer has %s %v", subject, keyword, o)
+	}
+}
+
+// typeSet returns the types a schema node allows, or an empty set if it
+// doesn't restrict the type.
+func typeSet(node map[string]interface{}) map[string]bool {
+	set := map[string]bool{}
+	switch t := node["type"].(type) {
+	case string:
+		set[t] = true
+	case []interface{}:
+		for _, v := range t {
+			if s, ok := v.(string); ok {
+				set[s] = true
+			}
+		}
+	}
+	return set
+}
+
+// allowsType returns true if a type set allows t. An empty set allows every
+// type, and number allows integer.
+func allowsType(set map[string]bool, t string) bool {
+	return len(set) == 0 || set[t] || (t == "integer" && set["number"])
+}
+
+func asObject(v interface{}) map[string]interface{} {
+	if m, ok := v.(map[string]interface{}); ok {
+		return m
+	}
+	return map[string]interface{}{}
+}
+
+func stringSet(v interface{}) map[string]bool {
+	set := map[string]bool{}
+	list, _ := v.([]interface{})
+	for _, item := range list {
+		if s, ok := item.(string); ok {
+			set[s] = true
+		}
+	}
+	return set
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func containsValue(list []interface{}, v interface{}) bool {
+	for _, item := range list {
+		if reflect.DeepEqual(item, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func enumName(path string) string {
+	if path == "" {
+		return "schema"
+	}
+	return fmt.Sprintf("`%s`", path)
+}
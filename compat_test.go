@@ -0,0 +1,60 @@
+package main
+
+import "testing"
+
+func TestDiffSchemas(t *testing.T) {
+	old := `{
+		"type": "object",
+		"required": ["id"],
+		"properties": {
+			"id": {"type": "string"},
+			"amount": {"type": "number"},
+			"status": {"enum": ["OPEN", "CLOSED"]}
+		}
+	}`
+
+	changes, err := DiffSchemas(old, `{
+		"type": "object",
+		"required": ["id"],
+		"properties": {
+			"id": {"type": "string"},
+			"amount": {"type": "number"},
+			"status": {"enum": ["OPEN", "CLOSED", "CANCELLED"]},
+			"note": {"type": "string"}
+		}
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 || len(BreakingChanges(changes)) != 0 {
+		t.Errorf("Expected two compatible changes, got %+v", changes)
+	}
+
+	changes, err = DiffSchemas(old, `{
+		"type": "object",
+		"required": ["id", "amount"],
+		"properties": {
+			"id": {"type": "integer"},
+			"amount": {"type": "number"},
+			"status": {"enum": ["OPEN"]}
+		}
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	breaking := BreakingChanges(changes)
+	if len(breaking) != 3 {
+		t.Fatalf("Expected three breaking changes, got %+v", changes)
+	}
+	if breaking[0].Description != "field `amount` is now required" {
+		t.Errorf("Unexpected description %q", breaking[0].Description)
+	}
+
+	changes, err = DiffSchemas(`{"type": "integer"}`, `{"type": "number"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(BreakingChanges(changes)) != 0 {
+		t.Errorf("Expected widening integer to number to be compatible, got %+v", changes)
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// ComponentNamespace is the namespace of shared schema fragments, such as
+// defs_address or defs_money. Components don't validate a subject of their
+// own, they are only referenced by other schemas as schema:defs_<name>.
+const ComponentNamespace = "defs_"
+
+// isComponent returns true if the named schema is a shared component or enum.
+func isComponent(name string) bool {
+	return strings.HasPrefix(name, ComponentNamespace) || strings.HasPrefix(name, EnumNamespace)
+}
+
+// checkComponent returns an error if a component is bound to a subject.
+func checkComponent(schema Schema) error {
+	if isComponent(schema.Name) && schema.Subject != "" {
+		return newError("400", "component %q can't have a subject, it can only be referenced", schema.Name)
+	}
+	return nil
+}
+
+// registryRefs returns the names of the schemas a body references.
+func registryRefs(body string) []string {
+	if !hasRegistryRefs(body) {
+		return nil
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal([]byte(body), &doc); err != nil {
+		return nil
+	}
+
+	names := map[string]bool{}
+	var walk func(node interface{})
+	walk = func(node interface{}) {
+		switch n := node.(type) {
+		case []interface{}:
+			for _, item := range n {
+				walk(item)
+			}
+		case map[string]interface{}:
+			if ref, ok := n["$ref"].(string); ok && strings.HasPrefix(ref, RegistryRefPrefix) {
+				name, _, _ := strings.Cut(strings.TrimPrefix(ref, RegistryRefPrefix), "#")
+				names[name] = true
+			}
+			for _, v := range n {
+				walk(v)
+			}
+		}
+	}
+	walk(doc)
+	return sortedKeys(names)
+}
+
+// dependents returns the cached schemas referencing the named schema, directly
+// or through other schemas, sorted by name.
+func (reg *SchemaRegistry) dependents(name string) []Schema {
+	reg.schemasMu.RLock()
+	refs := map[string][]string{}
+	schemas := map[string]Schema{}
+	for _, schema := range reg.schemas {
+		refs[schema.Name] = registryRefs(schema.Body)
+		schemas[schema.Name] = schema
+	}
+	reg.schemasMu.RUnlock()
+
+	found := map[string]bool{}
+	queue := []string{name}
+	for len(queue) > 0 {
+		target := queue[0]
+		queue = queue[1:]
+		for dependent, names := range refs {
+			if found[dependent] || dependent == name || !contains(names, target) {
+				continue
+			}
+			found[dependent] = true
+			queue = append(queue, dependent)
+		}
+	}
+
+	var dependents []Schema
+	for dependent := range found {
+		dependents = append(dependents, schemas[dependent])
+	}
+	sort.Slice(dependents, func(i, j int) bool { return dependents[i].Name < dependents[j].Name })
+	return dependents
+}
+
+// checkDependents re-validates the schemas referencing a schema against its
+// new revision. Every dependent must still compile and, in backward
+// compatibility mode, must not change in a breaking way. Pending are the new
+// bodies of schemas updated along with it, which dependents are checked with
+// instead of their current ones.
+func (reg *SchemaRegistry) checkDependents(schema Schema, pending map[string]string) error {
+	dependents := reg.dependents(schema.Name)
+	if len(dependents) == 0 {
+		return nil
+	}
+
+	lookup := func(name string) (string, bool) {
+		if name == schema.Name {
+			return schema.Body, true
+		}
+		if body, ok := pending[name]; ok {
+			return body, true
+		}
+		return reg.lookupBody(name)
+	}
+
+	for _, dependent := range dependents {
+		updated, ok := pending[dependent.Name]
+		if !ok {
+			updated = dependent.Body
+		}
+		body, err := Bundle(updated, lookup)
+		if err == nil {
+			err = CompileSchema(body)
+		}
+		if err != nil {
+			return newError("409", "dependent schema %q would break: %v", dependent.Name, err)
+		}
+
+		if reg.settings().Compatibility != CompatibilityBackward {
+			continue
+		}
+		old, err := Bundle(dependent.Body, reg.lookupBody)
+		if err != nil {
+			return err
+		}
+		changes, err := DiffSchemas(old, body)
+		if err != nil {
+			return newError("400", err.Error())
+		}
+		if breaking := BreakingChanges(changes); len(breaking) > 0 {
+			return newError("409", "incompatible change to dependent schema %q: %s", dependent.Name, describeChanges(breaking))
+		}
+	}
+	return nil
+}
+
+// dependentNames returns the names of schemas.
+func dependentNames(schemas []Schema) []string {
+	names := make([]string, len(schemas))
+	for i, schema := range schemas {
+		names[i] = schema.Name
+	}
+	return names
+}
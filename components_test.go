@@ -0,0 +1,35 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDependents(t *testing.T) {
+	reg := NewSchemaRegistry(nil, nil)
+	reg.settings().Compatibility = CompatibilityBackward
+	reg.schemas = map[string]Schema{
+		"defs_money":   {Name: "defs_money", Body: `{"type": "number"}`},
+		"defs_price":   {Name: "defs_price", Body: `{"type": "object", "properties": {"amount": {"$ref": "schema:defs_money"}}}`},
+		"orders":       {Name: "orders", Subject: "orders", Body: `{"type": "object", "properties": {"price": {"$ref": "schema:defs_price"}}}`},
+		"unrelated_id": {Name: "unrelated_id", Subject: "ids", Body: `{"type": "string"}`},
+	}
+
+	names := dependentNames(reg.dependents("defs_money"))
+	if !reflect.DeepEqual(names, []string{"defs_price", "orders"}) {
+		t.Errorf("Expected direct and indirect dependents, got %v", names)
+	}
+
+	err := reg.checkDependents(Schema{Name: "defs_money", Body: `{"type": "integer"}`}, nil)
+	if errorCode(err) != "409" {
+		t.Errorf("Expected a breaking change to a dependent to be rejected, got %v", err)
+	}
+	err = reg.checkDependents(Schema{Name: "defs_money", Body: `{"type": ["number", "string"]}`}, nil)
+	if err != nil {
+		t.Errorf("Expected a compatible change to be accepted, got %v", err)
+	}
+
+	if err := checkComponent(Schema{Name: "defs_money", Subject: "money"}); errorCode(err) != "400" {
+		t.Errorf("Expected a component with a subject to be rejected, got %v", err)
+	}
+}
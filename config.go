@@ -0,0 +1,287 @@
+package main
+
+import (
+	"flag"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// Config holds the command line configuration for the registry.
+type Config struct {
+	// Server is the URL of the NATS server to connect to.
+	Server string
+	// LogLevel is the lowest level logged: debug, info, warn or error.
+	LogLevel string
+	// LogFormat is the format of the log output, text or json.
+	LogFormat string
+	// Bucket configures the KV bucket schemas are stored in.
+	Bucket BucketConfig
+	// JSDomain is the JetStream domain the bucket and the registry's other
+	// streams live in, for hub and leaf node deployments. JSAPIPrefix is the
+	// JetStream API prefix to use instead, such as one imported from
+	// another account. Both empty use the server's own JetStream.
+	JSDomain    string
+	JSAPIPrefix string
+	// AuthFile is the path to a JSON file of authorization rules for the
+	// mutating endpoints. When empty all mutations are allowed.
+	AuthFile string
+	// TrustRequestInfo takes users and identities from the Nats-Request-Info
+	// header, which clients can only be kept from forging when they reach the
+	// registry through a service import sharing client information, or an
+	// auth callout.
+	TrustRequestInfo bool
+	// ApproversFile is the path to a JSON file of rules, in the format of
+	// AuthFile, for who may approve and reject proposed schemas.
+	ApproversFile string
+	// RequireApproval rejects direct registrations and updates, so schemas
+	// only change through approved proposals.
+	RequireApproval bool
+	// SigningKeysFile is the path to a JSON file of public nkeys per
+	// namespace. Schemas in those namespaces must carry a valid signature.
+	SigningKeysFile string
+	// QuotasFile is a JSON file with the quotas of namespaces.
+	QuotasFile string
+	// WebhooksFile is the path to a JSON file of HTTP endpoints notified of
+	// schema changes per namespace.
+	WebhooksFile string
+	// Immutable makes every update store a new, permanently retrievable
+	// version instead of only mutating the head.
+	Immutable bool
+	// SchemaTTL unregisters schemas that haven't been updated for this long.
+	// Zero keeps them forever.
+	SchemaTTL time.Duration
+	// ArchiveStream is the name of a stream that mirrors every schema
+	// mutation indefinitely. When empty no archive is kept.
+	ArchiveStream string
+	// SeedDir is a directory of schema definitions registered on startup.
+	SeedDir string
+	// SyncBucket is an Object Store bucket of schema definitions the registry
+	// is continuously reconciled with.
+	SyncBucket string
+	// SyncPrune unregisters schemas that are not defined in SyncBucket.
+	SyncPrune bool
+	// AuditStreams is a comma separated list of streams whose most recent
+	// AuditSample messages are validated every AuditInterval, to report
+	// producers bypassing validation.
+	AuditStreams  string
+	AuditInterval time.Duration
+	AuditSample   int
+	// Compatibility is the compatibility mode enforced when updating a
+	// schema, either "none" or "backward".
+	Compatibility string
+	// Examples is what to do when a change breaks a schema's examples,
+	// "reject" or "warn".
+	Examples string
+	// ValidationWorkers is the maximum number of payloads validated
+	// concurrently.
+	ValidationWorkers int
+	// ValidatePrefix is the subject prefix validation requests are received
+	// under. When empty it is $SCHEMA.VALIDATE.
+	ValidatePrefix string
+	// QueueGroup is the queue group validation requests are shared in
+	// between registry instances.
+	QueueGroup string
+	// ValidateSubscriptions is the number of parallel subscriptions to the
+	// validation requests.
+	ValidateSubscriptions int
+	// WorkQueue is the name of a work-queue stream validation requests are
+	// stored in until they are validated and forwarded. When empty they are
+	// received directly.
+	WorkQueue string
+	// Inline subscribes to the schemas' own subjects and validates messages
+	// published there, forwarding them under InlinePrefix.
+	Inline       bool
+	InlinePrefix string
+	// ShutdownTimeout bounds how long the whole shutdown waits for in-flight
+	// requests, validations and pending publishes.
+	ShutdownTimeout time.Duration
+	// ResponseTimeout bounds how long a request whose reply is validated
+	// waits for the service to respond.
+	ResponseTimeout time.Duration
+	// FailureSamples is the number of rejected payloads kept per schema.
+	FailureSamples int
+	// ErrorDetail is how much of a validation error rejections are answered
+	// with, unless the request asks otherwise: compact, full or a number of
+	// violations.
+	ErrorDetail string
+	// ForwardRetries is how often publishing a validated message is retried
+	// before giving up, waiting ForwardBackoff and doubling it in between.
+	ForwardRetries int
+	ForwardBackoff time.Duration
+	// DeadLetterStream is the name of a stream rejected messages are stored
+	// in so they can be replayed. When empty they are dropped.
+	DeadLetterStream string
+	// HTTPAddr is the address the HTTP gateway listens on. When empty the
+	// gateway is disabled.
+	HTTPAddr string
+	// UI serves the web dashboard from the HTTP gateway.
+	UI bool
+	// PprofAddr is the address the net/http/pprof profiles are served on,
+	// without authentication. When empty they aren't served.
+	PprofAddr string
+	// AsyncAPI prints an AsyncAPI document of the registered schemas and
+	// exits instead of serving.
+	AsyncAPI bool
+	// Graph prints the graph of the registered schemas in this format, dot
+	// or mermaid, and exits instead of serving.
+	Graph string
+	// DocsDir is a directory a static documentation site of the registered
+	// schemas is written to, before exiting instead of serving.
+	DocsDir string
+	// EnforceStream is a stream to make store only validated messages, before
+	// exiting instead of serving.
+	EnforceStream string
+	// ReadOnly serves schemas and validations but rejects every change to
+	// the registered schemas.
+	ReadOnly bool
+	// Mirror is the URL of the cluster whose registry is replicated. Mirrors
+	// are read-only.
+	Mirror string
+	// ValidationTimeout bounds how long validating a single message may take.
+	ValidationTimeout time.Duration
+	// ResultCacheSize is the number of validation verdicts cached.
+	ResultCacheSize int
+	// Environments is a comma separated list of environments, in promotion
+	// order, each with its own bucket and subject prefix.
+	Environments string
+	// ObjectBuckets is a comma separated list of the Object Store buckets
+	// validation requests may reference their payload in.
+	ObjectBuckets string
+	// ReferenceBuckets is a comma separated list of the KV buckets exists
+	// rules may look payload fields up in.
+	ReferenceBuckets string
+	// ReferenceCacheTTL is how long the lookups of exists rules are cached.
+	ReferenceCacheTTL time.Duration
+	// EncryptionKeys is the KV bucket of the keys schema fields are encrypted
+	// with. When empty encryption is disabled.
+	EncryptionKeys string
+	// DecryptersFile is the path to a JSON file of rules, in the format of
+	// AuthFile, for who may decrypt payloads. When empty no one may.
+	DecryptersFile string
+	// Plugins configures the WebAssembly plugins validating payloads.
+	Plugins PluginConfig
+	// Limits caps payload and schema sizes and schema complexity.
+	Limits Limits
+	// Contracts advertises the contract of every schema as an endpoint of a
+	// micro service of its own.
+	Contracts bool
+	// LeaderLease is how long the instance elected to run expiry sweeps and
+	// drift audits leads before renewing, and how long others wait to take
+	// over when it stops. Zero runs them on every instance.
+	LeaderLease time.Duration
+	// Strict rejects payloads with properties the bodies of their schemas
+	// don't declare, unless a schema says otherwise.
+	Strict bool
+	// SettingsFile is the path to a JSON file of Settings overriding the
+	// flags of the same name. It's reloaded on SIGHUP.
+	SettingsFile string
+	// ImportConfluent is the URL of a Confluent or Karapace registry to import
+	// every subject from before exiting.
+	ImportConfluent string
+	// ImportOpenAPI is the path to an OpenAPI 3 document whose component
+	// schemas are registered before exiting, named under OpenAPIPrefix or
+	// else the document's title.
+	ImportOpenAPI string
+	OpenAPIPrefix string
+	// MigrateTo is a bucket to copy the schema bucket into before exiting,
+	// in the JetStream domain MigrateDomain if set.
+	MigrateTo     string
+	MigrateDomain string
+}
+
+// ParseConfig parses the command line flags into a Config.
+func ParseConfig() Config {
+	var cfg Config
+	flag.StringVar(&cfg.Server, "server", nats.DefaultURL, "URL of the NATS server to connect to")
+	flag.StringVar(&cfg.LogLevel, "log-level", "info", "lowest level logged: debug, info, warn or error")
+	flag.StringVar(&cfg.LogFormat, "log-format", LogText, "format of the log output: text or json")
+	flag.StringVar(&cfg.Bucket.Name, "bucket", "schema_registry", "name of the KV bucket schemas are stored in")
+	flag.IntVar(&cfg.Bucket.History, "bucket-history", 10, "number of revisions the bucket keeps per key")
+	flag.IntVar(&cfg.Bucket.Replicas, "bucket-replicas", 1, "number of replicas of the bucket in a clustered JetStream")
+	flag.StringVar(&cfg.Bucket.Storage, "bucket-storage", "file", "storage of the bucket: file or memory")
+	flag.DurationVar(&cfg.SchemaTTL, "bucket-ttl", 0, "unregister schemas that haven't been updated for this long, 0 to keep them forever")
+	flag.StringVar(&cfg.JSDomain, "js-domain", "", "JetStream domain of the bucket and streams, the server's own by default")
+	flag.StringVar(&cfg.JSAPIPrefix, "js-api-prefix", "", "JetStream API prefix of the bucket and streams, instead of a domain")
+	flag.StringVar(&cfg.AuthFile, "auth-config", "", "path to a JSON file with authorization rules for register/update/unregister")
+	flag.BoolVar(&cfg.TrustRequestInfo, "trust-request-info", false, "authorize and record users from the Nats-Request-Info header, only safe when the server sets it for every client")
+	flag.StringVar(&cfg.ApproversFile, "approvers", "", "path to a JSON file with rules for who may approve and reject proposals")
+	flag.BoolVar(&cfg.RequireApproval, "require-approval", false, "only change schemas through approved proposals")
+	flag.StringVar(&cfg.SigningKeysFile, "signing-keys", "", "path to a JSON file with the public keys allowed to sign schemas per namespace")
+	flag.StringVar(&cfg.QuotasFile, "quotas", "", "path to a JSON file with schema count and validation rate quotas per namespace")
+	flag.StringVar(&cfg.WebhooksFile, "webhooks", "", "path to a JSON file with webhooks to notify of schema changes")
+	flag.BoolVar(&cfg.Immutable, "immutable", false, "store every schema version permanently instead of updating in place")
+	flag.StringVar(&cfg.ArchiveStream, "archive-stream", "", "name of a stream archiving the complete history of every schema")
+	flag.StringVar(&cfg.SeedDir, "seed-dir", "", "directory of JSON/YAML schema definitions to register on startup")
+	flag.StringVar(&cfg.SyncBucket, "sync-bucket", "", "Object Store bucket of schema definitions to keep the registry in sync with")
+	flag.BoolVar(&cfg.SyncPrune, "sync-prune", false, "unregister schemas that are not defined in the sync bucket")
+	flag.StringVar(&cfg.AuditStreams, "audit-streams", "", "comma separated streams to audit for messages violating their schemas")
+	flag.DurationVar(&cfg.AuditInterval, "audit-interval", 5*time.Minute, "how often the audited streams are sampled")
+	flag.IntVar(&cfg.AuditSample, "audit-sample", 100, "number of recent messages sampled per audited stream")
+	flag.StringVar(&cfg.Compatibility, "compatibility", CompatibilityNone, "compatibility enforced on updates: none or backward")
+	flag.StringVar(&cfg.Examples, "examples", ExamplesReject, "what to do when a change breaks a schema's examples: reject or warn")
+	flag.IntVar(&cfg.ValidationWorkers, "validation-workers", runtime.NumCPU(), "maximum number of payloads validated concurrently")
+	flag.StringVar(&cfg.ValidatePrefix, "validate-prefix", "", "subject prefix validation requests are received under (default $SCHEMA.VALIDATE)")
+	flag.StringVar(&cfg.QueueGroup, "queue-group", "schema_registry", "queue group validation requests are shared in between instances")
+	flag.IntVar(&cfg.ValidateSubscriptions, "validate-subscriptions", 1, "number of parallel subscriptions to validation requests")
+	flag.StringVar(&cfg.WorkQueue, "work-queue", "", "name of a work-queue stream to store validation requests in until they are forwarded")
+	flag.BoolVar(&cfg.Inline, "inline", false, "validate messages published on the schemas' subjects, without producers using $SCHEMA.VALIDATE")
+	flag.StringVar(&cfg.InlinePrefix, "inline-prefix", "validated", "subject prefix inline validated messages without a destination are forwarded under")
+	flag.DurationVar(&cfg.ShutdownTimeout, "shutdown-timeout", 30*time.Second, "how long shutting down may take in all, waiting for in-flight validations")
+	flag.DurationVar(&cfg.ResponseTimeout, "response-timeout", 5*time.Second, "how long to wait for replies that are validated against a response schema")
+	flag.IntVar(&cfg.FailureSamples, "failure-samples", 20, "number of recently rejected payloads kept per schema")
+	flag.StringVar(&cfg.ErrorDetail, "errors", ErrorsFull, "violations rejections are answered with by default: compact, full or a number")
+	flag.IntVar(&cfg.ForwardRetries, "forward-retries", 3, "how often publishing a validated message is retried before giving up")
+	flag.DurationVar(&cfg.ForwardBackoff, "forward-backoff", 100*time.Millisecond, "wait before the first retry of publishing a validated message, doubling for every retry")
+	flag.StringVar(&cfg.DeadLetterStream, "dead-letter-stream", "", "name of a stream storing rejected messages for later replay")
+	flag.StringVar(&cfg.HTTPAddr, "http-addr", "", "address for the HTTP gateway to listen on, e.g. :8080")
+	flag.BoolVar(&cfg.UI, "ui", false, "serve the web dashboard from the HTTP gateway")
+	flag.StringVar(&cfg.PprofAddr, "pprof-addr", "", "address to serve the unauthenticated pprof profiles on /debug/pprof/ on, e.g. localhost:6060")
+	flag.BoolVar(&cfg.AsyncAPI, "asyncapi", false, "print an AsyncAPI document of the registered schemas and exit")
+	flag.StringVar(&cfg.Graph, "graph", "", "print the graph of the registered schemas as dot or mermaid and exit")
+	flag.StringVar(&cfg.DocsDir, "docs-dir", "", "write an HTML documentation site of the registered schemas to this directory and exit")
+	flag.StringVar(&cfg.EnforceStream, "enforce-stream", "", "make this stream store only messages validated by the registry and exit")
+	flag.BoolVar(&cfg.ReadOnly, "read-only", false, "reject registering, updating and unregistering schemas")
+	flag.StringVar(&cfg.Mirror, "mirror", "", "replicate the schemas of the registry in the cluster at this URL")
+	flag.BoolVar(&cfg.Contracts, "contracts", true, "advertise the contract of every schema as an endpoint of the <service>_contracts micro service")
+	flag.DurationVar(&cfg.LeaderLease, "leader-lease", 15*time.Second, "lease of the instance elected to run expiry sweeps and drift audits, 0 to run them on every instance")
+	flag.BoolVar(&cfg.Strict, "strict", false, "reject payloads with properties their schema doesn't declare, unless the schema sets strict to false")
+	flag.StringVar(&cfg.SettingsFile, "settings", "", "path to a JSON file of settings overriding the flags of the same name, reloaded on SIGHUP")
+	flag.StringVar(&cfg.ImportConfluent, "import-confluent", "", "import all subjects from the Confluent registry at this URL and exit")
+	flag.StringVar(&cfg.ImportOpenAPI, "import-openapi", "", "register the component schemas of this OpenAPI 3 document and exit")
+	flag.StringVar(&cfg.OpenAPIPrefix, "openapi-prefix", "", "prefix of the names and subjects of imported OpenAPI components, defaults to the document's title")
+	flag.StringVar(&cfg.MigrateTo, "migrate-to", "", "copy the schema bucket with its history into this new bucket, verify it and exit")
+	flag.StringVar(&cfg.MigrateDomain, "migrate-domain", "", "JetStream domain of the bucket to migrate to, that of --js-domain by default")
+	flag.DurationVar(&cfg.ValidationTimeout, "validation-timeout", 2*time.Second, "how long validating a single message may take, 0 for no limit")
+	flag.IntVar(&cfg.ResultCacheSize, "result-cache", 10000, "number of validation verdicts cached for repeated identical payloads, 0 to disable")
+	flag.StringVar(&cfg.Environments, "environments", "", "comma separated environments in promotion order, e.g. dev,staging,prod")
+	flag.StringVar(&cfg.ObjectBuckets, "object-buckets", "", "comma separated Object Store buckets validation requests may reference their payload in")
+	flag.StringVar(&cfg.ReferenceBuckets, "reference-buckets", "", "comma separated KV buckets exists rules may look payload fields up in")
+	flag.DurationVar(&cfg.ReferenceCacheTTL, "reference-cache-ttl", 30*time.Second, "how long the lookups of exists rules are cached, 0 to disable")
+	flag.StringVar(&cfg.EncryptionKeys, "encryption-keys", "", "KV bucket of the keys schema fields marked x-encrypt are encrypted with")
+	flag.StringVar(&cfg.DecryptersFile, "decrypters", "", "path to a JSON file with rules for who may decrypt payloads")
+	flag.StringVar(&cfg.Plugins.Bucket, "plugin-bucket", "", "Object Store bucket of WebAssembly plugins schemas may validate payloads with")
+	flag.IntVar(&cfg.Plugins.Memory, "plugin-memory", 16, "memory in MiB a plugin may use while validating a payload")
+	flag.DurationVar(&cfg.Plugins.Timeout, "plugin-timeout", 100*time.Millisecond, "how long a plugin may take to validate a payload")
+	flag.IntVar(&cfg.Limits.MaxPayload, "max-payload", 0, "maximum payload size in bytes accepted for validation, 0 for no limit")
+	flag.IntVar(&cfg.Limits.MaxSchemaSize, "max-schema-size", 0, "maximum size in bytes of a schema body, 0 for no limit")
+	flag.IntVar(&cfg.Limits.MaxSchemaDepth, "max-schema-depth", 0, "maximum nesting depth of a schema body, 0 for no limit")
+	flag.IntVar(&cfg.Limits.MaxObjectSize, "max-object-size", 0, "maximum size in bytes of a payload referenced in an Object Store, 0 for no limit")
+	flag.IntVar(&cfg.Limits.MaxSchemaPatterns, "max-schema-patterns", 0, "maximum number of regular expressions in a schema body, 0 for no limit")
+	flag.Parse()
+	return cfg
+}
+
+// ParseList parses a comma separated list of names, such as buckets or
+// streams, ignoring empty ones.
+func ParseList(list string) []string {
+	var names []string
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
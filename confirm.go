@@ -0,0 +1,86 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// SchemaConfirmHeader echoes the token unregistering an active schema asks for.
+const SchemaConfirmHeader = "Schema-Confirm"
+
+// confirmWindow is how long a confirmation token can be used.
+const confirmWindow = time.Minute
+
+// confirmKey returns the kv key holding the pending confirmation of
+// unregistering a schema. Tokens are stored so any instance can confirm them.
+func confirmKey(name string) string {
+	return "_confirm." + nameToken(name)
+}
+
+// Confirmation is the token a requester must echo back to unregister an
+// active schema.
+type Confirmation struct {
+	Token   string    `json:"token"`
+	Expires time.Time `json:"expires"`
+}
+
+// confirmUnregister returns nil if the named schema may be unregistered: it
+// isn't active, or the token confirms it. Otherwise it returns a new
+// confirmation along with a 428 error asking for it. Schemas that can't be
+// unregistered anyway, such as on a read-only registry, fail with that error
+// instead, without a token.
+func (reg *SchemaRegistry) confirmUnregister(name, token string) (*Confirmation, error) {
+	if err := reg.checkUnregister(name); err != nil {
+		return nil, err
+	}
+	schema, exists, err := reg.current(name)
+	if err != nil || !exists || schema.State != StateActive {
+		return nil, err
+	}
+
+	return reg.confirm(confirmKey(name), token, fmt.Sprintf("schema %q", name), fmt.Sprintf("schema %q is active", name))
+}
+
+// confirm returns nil if token confirms the pending confirmation stored under
+// key. Without a token, it stores a new confirmation and returns it along with
+// a 428 error giving the reason it's asked for.
+func (reg *SchemaRegistry) confirm(key, token, what, reason string) (*Confirmation, error) {
+	if token != "" {
+		entry, err := reg.kv.Get(key)
+		if err != nil && !errors.Is(err, nats.ErrKeyNotFound) {
+			return nil, err
+		}
+		var pending Confirmation
+		if entry != nil {
+			err = json.Unmarshal(entry.Value(), &pending)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if pending.Token == "" || pending.Token != token || time.Now().After(pending.Expires) {
+			return nil, newError("409", "invalid or expired confirmation token for %s", what)
+		}
+		return nil, reg.kv.Delete(key)
+	}
+
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return nil, err
+	}
+	confirmation := &Confirmation{Token: hex.EncodeToString(random), Expires: time.Now().UTC().Add(confirmWindow)}
+	data, err := json.Marshal(confirmation)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := reg.kv.Put(key, data); err != nil {
+		return nil, err
+	}
+	return confirmation, newError("428", "%s, confirm unregistering by sending the %s header %s within %v",
+		reason, SchemaConfirmHeader, confirmation.Token, confirmWindow)
+}
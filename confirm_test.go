@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestConfirmUnregister(t *testing.T) {
+	kv := newMemKV()
+	reg := NewSchemaRegistry(kv, nil)
+	if _, err := reg.register(Schema{Name: "orders", Subject: "orders.*", Body: `{"type": "object"}`}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Schemas that aren't active, or don't exist, need no confirmation
+	if confirmation, err := reg.confirmUnregister("customers", ""); confirmation != nil || err != nil {
+		t.Errorf("Expected a missing schema not to need confirmation, got %+v, %v", confirmation, err)
+	}
+
+	confirmation, err := reg.confirmUnregister("orders", "")
+	if errorCode(err) != "428" || confirmation == nil || confirmation.Token == "" {
+		t.Fatalf("Expected a 428 error with a token, got %+v, %v", confirmation, err)
+	}
+	if confirmation.Expires.Before(time.Now()) || confirmation.Expires.After(time.Now().Add(confirmWindow)) {
+		t.Errorf("Expected the token to expire within %v, got %v", confirmWindow, confirmation.Expires)
+	}
+
+	if _, err := reg.confirmUnregister("orders", "wrong"); errorCode(err) != "409" {
+		t.Errorf("Expected a wrong token to fail with 409, got %v", err)
+	}
+	if _, err := reg.confirmUnregister("orders", confirmation.Token); err != nil {
+		t.Errorf("Expected the echoed token to confirm, got %v", err)
+	}
+	if _, err := reg.confirmUnregister("orders", confirmation.Token); errorCode(err) != "409" {
+		t.Errorf("Expected a used token to fail with 409, got %v", err)
+	}
+
+	// Tokens stop working once they expire
+	confirmation, _ = reg.confirmUnregister("orders", "")
+	expired, _ := json.Marshal(Confirmation{Token: confirmation.Token, Expires: time.Now().Add(-time.Second)})
+	if _, err := kv.Put(confirmKey("orders"), expired); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reg.confirmUnregister("orders", confirmation.Token); errorCode(err) != "409" {
+		t.Errorf("Expected an expired token to fail with 409, got %v", err)
+	}
+
+	// Every request for a token replaces the previous one
+	first, _ := reg.confirmUnregister("orders", "")
+	second, _ := reg.confirmUnregister("orders", "")
+	if first.Token == second.Token {
+		t.Fatalf("Expected a new token every time")
+	}
+	if _, err := reg.confirmUnregister("orders", first.Token); errorCode(err) != "409" {
+		t.Errorf("Expected a replaced token to fail with 409, got %v", err)
+	}
+	if _, err := reg.confirmUnregister("orders", second.Token); err != nil {
+		t.Errorf("Expected the latest token to confirm, got %v", err)
+	}
+}
+
+func TestConfirmUnregisterChecksFirst(t *testing.T) {
+	kv := newMemKV()
+	reg := NewSchemaRegistry(kv, nil)
+	if _, err := reg.register(Schema{Name: "orders", Subject: "orders.*", Body: `{"type": "object"}`}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reg.lock("orders", Lock{Reason: "migration"}); err != nil {
+		t.Fatal(err)
+	}
+	if confirmation, err := reg.confirmUnregister("orders", ""); confirmation != nil || errorCode(err) != "423" {
+		t.Errorf("Expected a locked schema to fail without a token, got %+v, %v", confirmation, err)
+	}
+
+	reg.readOnly = true
+	if confirmation, err := reg.confirmUnregister("orders", ""); confirmation != nil || errorCode(err) != "403" {
+		t.Errorf("Expected a read-only registry to fail without a token, got %+v, %v", confirmation, err)
+	}
+	if _, err := kv.Get(confirmKey("orders")); err == nil {
+		t.Errorf("Expected no token to be stored")
+	}
+}
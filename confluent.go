@@ -0,0 +1,378 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// ConfluentVersion is a single schema version as served by a Confluent (or
+// Karapace) schema registry.
+type ConfluentVersion struct {
+	Subject    string `json:"subject"`
+	Version    int    `json:"version"`
+	ID         int    `json:"id"`
+	SchemaType string `json:"schemaType"`
+	Schema     string `json:"schema"`
+}
+
+// ConfluentClient reads subjects and versions from a Confluent compatible
+// registry over its REST API. Credentials may be given in the URL.
+type ConfluentClient struct {
+	URL    string
+	Client *http.Client
+}
+
+// Subjects lists every subject in the registry.
+func (c *ConfluentClient) Subjects() ([]string, error) {
+	var subjects []string
+	err := c.get("/subjects", &subjects)
+	return subjects, err
+}
+
+// Versions returns every version of a subject, oldest first.
+func (c *ConfluentClient) Versions(subject string) ([]ConfluentVersion, error) {
+	var numbers []int
+	err := c.get("/subjects/"+url.PathEscape(subject)+"/versions", &numbers)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]ConfluentVersion, 0, len(numbers))
+	for _, n := range numbers {
+		var version ConfluentVersion
+		err = c.get(fmt.Sprintf("/subjects/%s/versions/%d", url.PathEscape(subject), n), &version)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, version)
+	}
+	return versions, nil
+}
+
+func (c *ConfluentClient) get(path string, v interface{}) error {
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Get(strings.TrimSuffix(c.URL, "/") + path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", path, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+var invalidNameChars = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+// keySubject returns true for the Confluent subjects of record keys. NATS
+// messages have no keys, so they have no NATS subject to validate.
+func keySubject(subject string) bool {
+	return strings.HasSuffix(subject, "-key")
+}
+
+// ConvertConfluent turns a Confluent schema version into a schema definition.
+// JSON schemas are taken as is and Avro schemas are translated to JSON Schema.
+// Following the topic name strategy, the NATS subject is the Confluent subject
+// without its -value suffix. Key schemas can't be converted.
+func ConvertConfluent(version ConfluentVersion) (Schema, error) {
+	schema := Schema{
+		Name:    invalidNameChars.ReplaceAllString(version.Subject, "_"),
+		Subject: strings.TrimSuffix(version.Subject, "-value"),
+		Type:    "jsonschema",
+	}
+	if keySubject(version.Subject) {
+		return schema, fmt.Errorf("subject %q is a key schema, NATS messages have no keys", version.Subject)
+	}
+
+	switch version.SchemaType {
+	case "JSON":
+		schema.Body = version.Schema
+	case "", "AVRO":
+		body, err := AvroToJSONSchema(version.Schema)
+		if err != nil {
+			return schema, err
+		}
+		schema.Body = body
+	default:
+		return schema, fmt.Errorf("unsupported schema type %q", version.SchemaType)
+	}
+
+	return schema, nil
+}
+
+// ImportConfluent registers every value subject of a Confluent registry,
+// replaying its versions in order. Versions up to and including the one matching the
+// stored definition are skipped, so an import can safely be run again.
+func (reg *SchemaRegistry) ImportConfluent(client *ConfluentClient) error {
+	subjects, err := client.Subjects()
+	if err != nil {
+		return err
+	}
+
+	for _, subject := range subjects {
+		// Key schemas would validate the values of their topic's NATS subject
+		if keySubject(subject) {
+			logger("import").Info("Skipping key subject", "subject", subject)
+			continue
+		}
+
+		versions, err := client.Versions(subject)
+		if err != nil {
+			return fmt.Errorf("importing subject %q: %w", subject, err)
+		}
+
+		var schemas []Schema
+		for _, version := range versions {
+			schema, err := ConvertConfluent(version)
+			if err != nil {
+				logger("import").Warn("Skipping version", "subject", subject, "version", version.Version, "error", err)
+				continue
+			}
+			schemas = append(schemas, schema)
+		}
+		if len(schemas) == 0 {
+			continue
+		}
+
+		current, exists, err := reg.current(schemas[0].Name)
+		if err != nil {
+			return err
+		}
+		if exists {
+			for i, schema := range schemas {
+				if !definitionChanged(current, schema) {
+					schemas = schemas[i+1:]
+					break
+				}
+			}
+		}
+
+		for _, schema := range schemas {
+			action, err := reg.apply(schema)
+			if err != nil {
+				return fmt.Errorf("importing subject %q: %w", subject, err)
+			}
+			if action != "" {
+				logger("import").Info("Imported schema", "schema", schema.Name, "action", action)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Import copies the schemas of the Confluent registry given in the config into
+// the schema bucket.
+func Import(cfg Config) error {
+	verifier, err := LoadVerifier(cfg.SigningKeysFile)
+	if err != nil {
+		return err
+	}
+
+	nc, _, kv, err := OpenBucket(cfg)
+	if err != nil {
+		return err
+	}
+	defer nc.Close()
+
+	registry := NewSchemaRegistry(kv, nc)
+	registry.verifier = verifier
+	registry.immutable = cfg.Immutable
+
+	return registry.ImportConfluent(&ConfluentClient{URL: cfg.ImportConfluent})
+}
+
+// AvroToJSONSchema translates an Avro schema into an equivalent JSON Schema
+// describing the JSON encoding of its records.
+func AvroToJSONSchema(avro string) (string, error) {
+	var parsed interface{}
+	err := json.Unmarshal([]byte(avro), &parsed)
+	if err != nil {
+		return "", fmt.Errorf("invalid avro schema: %w", err)
+	}
+
+	converter := avroConverter{
+		named:   map[string]map[string]interface{}{},
+		pending: map[string]bool{},
+	}
+	doc, err := converter.convert(parsed, "")
+	if err != nil {
+		return "", err
+	}
+	doc["$schema"] = "http://json-schema.org/draft-07/schema#"
+
+	encoded, err := json.Marshal(doc)
+	return string(encoded), err
+}
+
+// avroConverter keeps track of named types so later references to them can
+// be resolved.
+type avroConverter struct {
+	named   map[string]map[string]interface{}
+	pending map[string]bool
+}
+
+func (c avroConverter) convert(avro interface{}, namespace string) (map[string]interface{}, error) {
+	switch t := avro.(type) {
+	case string:
+		return c.primitive(t, namespace)
+	case []interface{}:
+		// Unions
+		var options []interface{}
+		for _, option := range t {
+			converted, err := c.convert(option, namespace)
+			if err != nil {
+				return nil, err
+			}
+			options = append(options, converted)
+		}
+		return map[string]interface{}{"anyOf": options}, nil
+	case map[string]interface{}:
+		return c.complex(t, namespace)
+	}
+	return nil, fmt.Errorf("invalid avro type %v", avro)
+}
+
+func (c avroConverter) primitive(name, namespace string) (map[string]interface{}, error) {
+	switch name {
+	case "null":
+		return map[string]interface{}{"type": "null"}, nil
+	case "boolean":
+		return map[string]interface{}{"type": "boolean"}, nil
+	case "int", "long":
+		return map[string]interface{}{"type": "integer"}, nil
+	case "float", "double":
+		return map[string]interface{}{"type": "number"}, nil
+	case "bytes", "string":
+		return map[string]interface{}{"type": "string"}, nil
+	}
+
+	if named, ok := c.named[name]; ok {
+		return named, nil
+	}
+	if named, ok := c.named[namespace+"."+name]; ok {
+		return named, nil
+	}
+	// Recursive references can't be expressed without $refs, so they accept
+	// anything
+	if c.pending[name] || c.pending[namespace+"."+name] {
+		return map[string]interface{}{}, nil
+	}
+	return nil, fmt.Errorf("unknown avro type %q", name)
+}
+
+func (c avroConverter) complex(avro map[string]interface{}, namespace string) (map[string]interface{}, error) {
+	typ, _ := avro["type"].(string)
+	if ns, ok := avro["namespace"].(string); ok {
+		namespace = ns
+	}
+
+	var doc map[string]interface{}
+	switch typ {
+	case "record", "error":
+		properties := map[string]interface{}{}
+		required := []interface{}{}
+		doc = map[string]interface{}{
+			"type":                 "object",
+			"properties":           properties,
+			"additionalProperties": false,
+		}
+		fullName := qualifiedName(avro, namespace)
+		c.pending[fullName] = true
+
+		fields, _ := avro["fields"].([]interface{})
+		for _, f := range fields {
+			field, _ := f.(map[string]interface{})
+			name, _ := field["name"].(string)
+			if name == "" {
+				return nil, fmt.Errorf("avro record field without a name")
+			}
+			converted, err := c.convert(field["type"], namespace)
+			if err != nil {
+				return nil, fmt.Errorf("field %q: %w", name, err)
+			}
+			if doc, ok := field["doc"].(string); ok {
+				converted = withDescription(converted, doc)
+			}
+			properties[name] = converted
+			if _, hasDefault := field["default"]; !hasDefault {
+				required = append(required, name)
+			}
+		}
+		if len(required) > 0 {
+			doc["required"] = required
+		}
+		delete(c.pending, fullName)
+		c.define(avro, namespace, doc)
+	case "enum":
+		doc = map[string]interface{}{"type": "string", "enum": avro["symbols"]}
+		c.define(avro, namespace, doc)
+	case "fixed":
+		doc = map[string]interface{}{"type": "string"}
+		c.define(avro, namespace, doc)
+	case "array":
+		items, err := c.convert(avro["items"], namespace)
+		if err != nil {
+			return nil, err
+		}
+		doc = map[string]interface{}{"type": "array", "items": items}
+	case "map":
+		values, err := c.convert(avro["values"], namespace)
+		if err != nil {
+			return nil, err
+		}
+		doc = map[string]interface{}{"type": "object", "additionalProperties": values}
+	default:
+		// Primitives may also be written as {"type": "string"}, optionally
+		// with a logical type
+		var err error
+		doc, err = c.primitive(typ, namespace)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if description, ok := avro["doc"].(string); ok {
+		doc["description"] = description
+	}
+	return doc, nil
+}
+
+// define records a named type under both its short and full name.
+func (c avroConverter) define(avro map[string]interface{}, namespace string, doc map[string]interface{}) {
+	name, _ := avro["name"].(string)
+	if name == "" {
+		return
+	}
+	c.named[name] = doc
+	c.named[qualifiedName(avro, namespace)] = doc
+}
+
+// qualifiedName returns the full name of a named avro type.
+func qualifiedName(avro map[string]interface{}, namespace string) string {
+	name, _ := avro["name"].(string)
+	if namespace == "" || strings.Contains(name, ".") {
+		return name
+	}
+	return namespace + "." + name
+}
+
+// withDescription returns a copy of a converted type with a description, so
+// shared named types aren't modified.
+func withDescription(doc map[string]interface{}, description string) map[string]interface{} {
+	described := make(map[string]interface{}, len(doc)+1)
+	for k, v := range doc {
+		described[k] = v
+	}
+	described["description"] = description
+	return described
+}
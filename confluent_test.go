@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAvroToJSONSchema(t *testing.T) {
+	avro := `{
+		"type": "record",
+		"name": "Order",
+		"namespace": "shop",
+		"fields": [
+			{"name": "id", "type": "long"},
+			{"name": "status", "type": {"type": "enum", "name": "Status", "symbols": ["NEW", "PAID"]}},
+			{"name": "previous", "type": ["null", "Status"], "default": null},
+			{"name": "tags", "type": {"type": "array", "items": "string"}},
+			{"name": "parent", "type": ["null", "shop.Order"], "default": null}
+		]
+	}`
+
+	body, err := AvroToJSONSchema(avro)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	cases := []struct {
+		data  string
+		valid bool
+	}{
+		{`{"id": 1, "status": "NEW", "tags": []}`, true},
+		{`{"id": 1, "status": "NEW", "previous": "PAID", "tags": ["a"], "parent": {"id": 2}}`, true},
+		{`{"id": 1, "status": "LOST", "tags": []}`, false},
+		{`{"id": 1.5, "status": "NEW", "tags": []}`, false},
+		{`{"status": "NEW", "tags": []}`, false},
+		{`{"id": 1, "status": "NEW", "tags": [], "extra": true}`, false},
+	}
+	for _, c := range cases {
+		problems, err := violations([]byte(c.data), body)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if (len(problems) == 0) != c.valid {
+			t.Errorf("Expected %s valid to be %v, got %v", c.data, c.valid, problems)
+		}
+	}
+
+	_, err = AvroToJSONSchema(`{"type": "record", "name": "X", "fields": [{"name": "a", "type": "Unknown"}]}`)
+	if err == nil {
+		t.Errorf("Expected an error for an unknown type")
+	}
+}
+
+func TestConvertConfluent(t *testing.T) {
+	schema, err := ConvertConfluent(ConfluentVersion{
+		Subject:    "orders.created-value",
+		SchemaType: "JSON",
+		Schema:     `{"type": "object"}`,
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if schema.Name != "orders_created-value" || schema.Subject != "orders.created" {
+		t.Errorf("Expected name and subject to be derived from the Confluent subject, got %q %q", schema.Name, schema.Subject)
+	}
+	if !json.Valid([]byte(schema.Body)) {
+		t.Errorf("Expected the body to be kept, got %q", schema.Body)
+	}
+
+	_, err = ConvertConfluent(ConfluentVersion{Subject: "orders.created-key", SchemaType: "JSON", Schema: `{"type": "string"}`})
+	if err == nil {
+		t.Errorf("Expected key schemas not to be converted")
+	}
+
+	_, err = ConvertConfluent(ConfluentVersion{Subject: "x", SchemaType: "PROTOBUF"})
+	if err == nil {
+		t.Errorf("Expected protobuf schemas to be unsupported")
+	}
+}
+
+func TestImportConfluentSkipsKeys(t *testing.T) {
+	versions := map[string]ConfluentVersion{
+		"orders-key":   {Subject: "orders-key", Version: 1, SchemaType: "JSON", Schema: `{"type": "string"}`},
+		"orders-value": {Subject: "orders-value", Version: 1, SchemaType: "JSON", Schema: `{"type": "object"}`},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.Split(strings.TrimPrefix(r.URL.Path, "/subjects"), "/")
+		switch {
+		case len(path) == 1:
+			json.NewEncoder(w).Encode([]string{"orders-key", "orders-value"})
+		case len(path) == 3:
+			json.NewEncoder(w).Encode([]int{1})
+		default:
+			json.NewEncoder(w).Encode(versions[path[1]])
+		}
+	}))
+	defer server.Close()
+
+	reg := NewSchemaRegistry(newMemKV(), nil)
+	err := reg.ImportConfluent(&ConfluentClient{URL: server.URL})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	schema, exists, err := reg.current("orders-value")
+	if err != nil || !exists {
+		t.Fatalf("Expected the value schema to be imported, got %v %v", exists, err)
+	}
+	if schema.Subject != "orders" {
+		t.Errorf("Expected the value schema to validate subject orders, got %q", schema.Subject)
+	}
+	_, exists, err = reg.current("orders-key")
+	if err != nil || exists {
+		t.Errorf("Expected the key schema not to be imported, got %v %v", exists, err)
+	}
+}
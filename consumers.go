@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/micro"
+)
+
+// consumerKey returns the kv key a consumer of a schema is registered under.
+// Like version keys it contains dots, so the watcher ignores it.
+func consumerKey(schema, consumer string) string {
+	return fmt.Sprintf("_consumer.%s.%s", nameToken(schema), nameToken(consumer))
+}
+
+// Consumer is a service reading the messages of a schema, and the fields of
+// it that service depends on, as paths like customer.id or items[].sku.
+// Without fields, it depends on all of them.
+type Consumer struct {
+	Name   string    `json:"name"`
+	Schema string    `json:"schema"`
+	Fields []string  `json:"fields,omitempty"`
+	By     string    `json:"by,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+// ConsumerImpact lists the changes of an update breaking a consumer.
+type ConsumerImpact struct {
+	Consumer string   `json:"consumer"`
+	Changes  []string `json:"changes"`
+}
+
+// Consumer subject: $SCHEMA.CONSUMER.<schema_name>
+//
+// The request is the consumer, as {"name": ..., "fields": [...]}. Registering
+// it again replaces its fields.
+func (reg *SchemaRegistry) RegisterConsumer(r micro.Request) {
+	name := subjectName(r.Subject())
+
+	var consumer Consumer
+	err := json.Unmarshal(r.Data(), &consumer)
+	if err != nil {
+		r.Error("400", err.Error(), nil)
+		return
+	}
+
+	if !reg.authorize(r, name) {
+		return
+	}
+
+	consumer.Schema = name
+	consumer.By = RequestIdentity(nats.Header(r.Headers()))
+	consumer, err = reg.registerConsumer(consumer)
+	if err != nil {
+		r.Error(errorCode(err), err.Error(), nil)
+		return
+	}
+
+	r.RespondJSON(consumer)
+}
+
+// Unregister consumer subject: $SCHEMA.UNREGISTER_CONSUMER.<schema_name>
+//
+// The request is the name of the consumer.
+func (reg *SchemaRegistry) UnregisterConsumer(r micro.Request) {
+	name := subjectName(r.Subject())
+
+	if !reg.authorize(r, name) {
+		return
+	}
+
+	err := reg.unregisterConsumer(name, string(r.Data()))
+	if err != nil {
+		r.Error(errorCode(err), err.Error(), nil)
+		return
+	}
+
+	r.Respond(nil)
+}
+
+// Consumers subject: $SCHEMA.CONSUMERS.<schema_name>
+func (reg *SchemaRegistry) GetConsumers(r micro.Request) {
+	name := subjectName(r.Subject())
+
+	consumers, err := reg.consumers(name)
+	if err != nil {
+		r.Error(errorCode(err), err.Error(), nil)
+		return
+	}
+
+	r.RespondJSON(consumers)
+}
+
+// registerConsumer stores a consumer of an existing schema, whose fields must
+// all be in the schema.
+func (reg *SchemaRegistry) registerConsumer(consumer Consumer) (Consumer, error) {
+	if err := reg.writable(); err != nil {
+		return consumer, err
+	}
+	if consumer.Name == "" || strings.ContainsAny(consumer.Name, " */>") {
+		return consumer, newError("400", "invalid consumer name %q", consumer.Name)
+	}
+
+	schema, exists, err := reg.current(consumer.Schema)
+	if err != nil {
+		return consumer, err
+	}
+	if !exists {
+		return consumer, newError("404", "Not found")
+	}
+
+	var root interface{}
+	if err := json.Unmarshal([]byte(schema.Body), &root); err != nil {
+		return consumer, err
+	}
+	known := map[string]bool{}
+	for _, field := range documentFields(asObject(root), "") {
+		known[field.Path] = true
+	}
+	for _, field := range consumer.Fields {
+		if !known[field] {
+			return consumer, newError("400", "schema %q has no field `%s`", consumer.Schema, field)
+		}
+	}
+
+	consumer.Time = time.Now().UTC()
+	data, err := json.Marshal(consumer)
+	if err != nil {
+		return consumer, err
+	}
+	_, err = reg.kv.Put(consumerKey(consumer.Schema, consumer.Name), data)
+	return consumer, err
+}
+
+// unregisterConsumer removes a consumer of a schema.
+func (reg *SchemaRegistry) unregisterConsumer(schema, name string) error {
+	if err := reg.writable(); err != nil {
+		return err
+	}
+	_, err := reg.kv.Get(consumerKey(schema, name))
+	if errors.Is(err, nats.ErrKeyNotFound) {
+		return newError("404", "consumer %q of schema %q not found", name, schema)
+	}
+	if err != nil {
+		return err
+	}
+	return reg.kv.Delete(consumerKey(schema, name))
+}
+
+// consumers returns the consumers registered for a schema, sorted by name.
+func (reg *SchemaRegistry) consumers(schema string) ([]Consumer, error) {
+	watcher, err := reg.kv.Watch(consumerKey(schema, "*"), nats.IgnoreDeletes())
+	if err != nil {
+		return nil, err
+	}
+	defer watcher.Stop()
+
+	consumers := []Consumer{}
+	for entry := range watcher.Updates() {
+		if entry == nil {
+			break
+		}
+		var consumer Consumer
+		if err := json.Unmarshal(entry.Value(), &consumer); err != nil {
+			return nil, fmt.Errorf("corrupt consumer %q: %w", entry.Key(), err)
+		}
+		consumers = append(consumers, consumer)
+	}
+	sort.Slice(consumers, func(i, j int) bool { return consumers[i].Name < consumers[j].Name })
+	return consumers, nil
+}
+
+// consumerImpact returns the consumers changes would break: those depending
+// on a field that changed or changed within, or whose parent became
+// incompatible. Added fields break no one.
+func consumerImpact(consumers []Consumer, changes []Change) []ConsumerImpact {
+	var impact []ConsumerImpact
+	for _, consumer := range consumers {
+		fields := consumer.Fields
+		if len(fields) == 0 {
+			fields = []string{""}
+		}
+
+		var broken []string
+		for _, c := range changes {
+			if c.added {
+				continue
+			}
+			for _, field := range fields {
+				if field == c.Path || withinField(c.Path, field) || (c.Breaking && withinField(field, c.Path)) {
+					broken = append(broken, c.Description)
+					break
+				}
+			}
+		}
+		if len(broken) > 0 {
+			impact = append(impact, ConsumerImpact{Consumer: consumer.Name, Changes: broken})
+		}
+	}
+	return impact
+}
+
+// withinField returns true if path is a field nested in parent, or parent is
+// the root.
+func withinField(path, parent string) bool {
+	return parent == "" || strings.HasPrefix(path, parent+".") || strings.HasPrefix(path, parent+"[]")
+}
+
+// describeImpact joins the consumers of an impact and what breaks them into a
+// single line.
+func describeImpact(impact []ConsumerImpact) string {
+	var descs []string
+	for _, i := range impact {
+		descs = append(descs, fmt.Sprintf("%s (%s)", i.Consumer, strings.Join(i.Changes, ", ")))
+	}
+	return strings.Join(descs, "; ")
+}
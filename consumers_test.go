@@ -0,0 +1,46 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConsumerImpact(t *testing.T) {
+	changes, err := DiffSchemas(
+		`{"type": "object", "properties": {"id": {"type": "string"}, "note": {"type": "string"}, "customer": {"type": "object", "properties": {"id": {"type": "string"}, "email": {"type": "string"}}}}}`,
+		`{"type": "object", "properties": {"id": {"type": "string"}, "customer": {"type": "object", "properties": {"id": {"type": "integer"}, "email": {"type": "string"}, "phone": {"type": "string"}}}}}`,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	consumers := []Consumer{
+		{Name: "billing", Fields: []string{"customer.id"}},
+		{Name: "crm", Fields: []string{"customer"}},
+		{Name: "mailer", Fields: []string{"customer.email"}},
+		{Name: "notes", Fields: []string{"note"}},
+		{Name: "audit"},
+	}
+	impact := consumerImpact(consumers, changes)
+
+	var names []string
+	for _, i := range impact {
+		names = append(names, i.Consumer)
+	}
+	if !reflect.DeepEqual(names, []string{"billing", "crm", "notes", "audit"}) {
+		t.Errorf("Expected the consumers of the changed fields to break, got %v", impact)
+	}
+	if !reflect.DeepEqual(impact[2].Changes, []string{"field `note` was removed"}) {
+		t.Errorf("Expected the removal of a field to break its consumer, got %v", impact[2].Changes)
+	}
+}
+
+func TestConsumerImpactIgnoresAddedFields(t *testing.T) {
+	changes, _ := DiffSchemas(
+		`{"type": "object", "properties": {"id": {"type": "string"}}}`,
+		`{"type": "object", "properties": {"id": {"type": "string"}, "total": {"type": "number"}}}`,
+	)
+	if impact := consumerImpact([]Consumer{{Name: "audit"}}, changes); len(impact) != 0 {
+		t.Errorf("Expected an added field to break no one, got %v", impact)
+	}
+}
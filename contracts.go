@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go/micro"
+)
+
+// contractsDelay is how long the contracts service waits for more schema
+// changes before it is rebuilt, so a burst of changes rebuilds it once.
+const contractsDelay = time.Second
+
+// endpointName matches the characters micro allows in endpoint names.
+var endpointName = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+// Contract is what a registry enforces on the messages of a subject.
+type Contract struct {
+	Name string `json:"name"`
+	// Subject is the subject pattern of the messages, and ValidateSubject
+	// where they are validated.
+	Subject         string `json:"subject"`
+	ValidateSubject string `json:"validate_subject"`
+	Revision        uint64 `json:"revision"`
+	Version         uint64 `json:"version,omitempty"`
+	SemVer          string `json:"semver,omitempty"`
+	State           string `json:"state"`
+	// Body is the schema of the payloads, with its references resolved. With
+	// Batch the payloads are arrays of what it describes.
+	Body         json.RawMessage `json:"body"`
+	Batch        bool            `json:"batch,omitempty"`
+	ResponseBody json.RawMessage `json:"response_body,omitempty"`
+	HeaderBody   json.RawMessage `json:"header_body,omitempty"`
+}
+
+// Contracts advertises the contracts a registry enforces as a micro service
+// of its own, with an endpoint per schema on <prefix>.CONTRACT.<name>. The
+// endpoint's request and response schemas are those of the schema's payloads
+// and replies, so `nats micro schema` describes the real contracts, and
+// requesting the endpoint returns the contract. Endpoints can't be removed
+// from a micro service, so the service is rebuilt whenever schemas change.
+type Contracts struct {
+	reg  *SchemaRegistry
+	name string
+
+	mu  sync.Mutex
+	svc micro.Service
+}
+
+func NewContracts(reg *SchemaRegistry, name string) *Contracts {
+	return &Contracts{reg: reg, name: name}
+}
+
+// Run builds the service once the initial schemas are known, and rebuilds it
+// after changes. It runs this in a goroutine and takes a context for
+// cancelation, which stops the service.
+func (c *Contracts) Run(ctx context.Context) error {
+	watcher, err := c.reg.kv.Watch("*")
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		defer watcher.Stop()
+		defer c.stop()
+
+		initialized := false
+		var timer <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case entry, ok := <-watcher.Updates():
+				if !ok {
+					return
+				}
+				if entry == nil {
+					initialized = true
+				}
+				if initialized && timer == nil {
+					timer = time.After(contractsDelay)
+				}
+			case <-timer:
+				timer = nil
+				err := c.rebuild()
+				if err != nil {
+					logger("contracts").Error("error advertising schema contracts", "error", err)
+				}
+			}
+		}
+	}()
+
+	return nil
+}
+
+// contracts returns the contracts enforced on subjects, sorted by name.
+// Drafts and components aren't enforced on any subject.
+func (c *Contracts) contracts() []Contract {
+	var contracts []Contract
+	for _, schema := range c.reg.list() {
+		if schema.Subject == "" || schema.State == StateDraft || isComponent(schema.Name) {
+			continue
+		}
+		contract, err := c.reg.contract(schema)
+		if err != nil {
+			logger("contracts").Warn("Not advertising the contract of schema", "schema", schema.Name, "error", err)
+			continue
+		}
+		contracts = append(contracts, contract)
+	}
+	sort.Slice(contracts, func(i, j int) bool { return contracts[i].Name < contracts[j].Name })
+	return contracts
+}
+
+// contract returns the contract a schema enforces.
+func (reg *SchemaRegistry) contract(schema Schema) (Contract, error) {
+	body, err := reg.resolvedBody(schema.Body)
+	if err != nil {
+		return Contract{}, err
+	}
+	contract := Contract{
+		Name:            schema.Name,
+		Subject:         schema.Subject,
+		ValidateSubject: reg.validationPrefix() + "." + SubjectPattern(schema.Subject),
+		Revision:        schema.Revision,
+		Version:         schema.Version,
+		SemVer:          schema.SemVer,
+		State:           schema.State,
+		Body:            json.RawMessage(body),
+		Batch:           schema.Batch,
+	}
+	if schema.ResponseBody != "" {
+		contract.ResponseBody = json.RawMessage(schema.ResponseBody)
+	}
+	if schema.HeaderBody != "" {
+		contract.HeaderBody = json.RawMessage(schema.HeaderBody)
+	}
+	return contract, nil
+}
+
+// rebuild replaces the service with one advertising the current contracts.
+func (c *Contracts) rebuild() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.svc != nil {
+		if err := c.svc.Stop(); err != nil {
+			return err
+		}
+		c.svc = nil
+	}
+
+	svc, err := micro.AddService(c.reg.nc, micro.Config{
+		Name:        c.name,
+		Description: "Contracts enforced on the messages validated by the schema registry, an endpoint per schema.",
+		Version:     serviceVersion,
+	})
+	if err != nil {
+		return err
+	}
+	c.svc = svc
+
+	for _, contract := range c.contracts() {
+		contract := contract
+		data, err := json.Marshal(contract)
+		if err != nil {
+			return err
+		}
+		err = svc.AddEndpoint(endpointName.ReplaceAllString(contract.Name, "_"),
+			micro.HandlerFunc(func(r micro.Request) { r.Respond(data) }),
+			micro.WithEndpointSubject(c.reg.prefix+".CONTRACT."+nameToken(contract.Name)),
+			micro.WithEndpointSchema(&micro.Schema{
+				Request:  string(contract.Body),
+				Response: string(contract.ResponseBody),
+			}))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stop stops the service.
+func (c *Contracts) stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.svc != nil {
+		c.svc.Stop()
+		c.svc = nil
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestContracts(t *testing.T) {
+	reg := NewSchemaRegistry(nil, nil)
+	reg.schemas = map[string]Schema{
+		"defs_money": {Name: "defs_money", Body: `{"type": "number"}`},
+		"orders":     {Name: "orders", Subject: "orders.{region}", State: StateActive, Revision: 3, Body: `{"properties": {"total": {"$ref": "schema:defs_money"}}}`, ResponseBody: `{"type": "string"}`},
+		"drafts":     {Name: "drafts", Subject: "drafts", State: StateDraft, Body: `{}`},
+	}
+
+	contracts := NewContracts(reg, "schema_registry_contracts").contracts()
+	if len(contracts) != 1 {
+		t.Fatalf("Expected only orders to have a contract, got %+v", contracts)
+	}
+	contract := contracts[0]
+	if contract.Name != "orders" || contract.ValidateSubject != "$SCHEMA.VALIDATE.orders.*" || contract.Revision != 3 {
+		t.Errorf("Expected the contract of orders, got %+v", contract)
+	}
+	if string(contract.Body) != `{"properties":{"total":{"type":"number"}}}` {
+		t.Errorf("Expected the body with its references resolved, got %s", contract.Body)
+	}
+	if string(contract.ResponseBody) != `{"type": "string"}` {
+		t.Errorf("Expected the response body, got %s", contract.ResponseBody)
+	}
+}
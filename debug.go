@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+
+	"github.com/nats-io/nats.go/micro"
+)
+
+// DebugStats is a snapshot of the registry's runtime state, for diagnosing it
+// under load.
+type DebugStats struct {
+	Goroutines     int          `json:"goroutines"`
+	Schemas        int          `json:"schemas"`
+	CachedResults  int          `json:"cached_results"`
+	FailureSamples int          `json:"failure_samples"`
+	Forwarding     ForwardStats `json:"forwarding"`
+	// DeprecatedFields counts the deprecated fields validated messages had.
+	DeprecatedFields uint64      `json:"deprecated_fields"`
+	Memory           MemoryStats `json:"memory"`
+}
+
+// MemoryStats are the most telling numbers of runtime.MemStats.
+type MemoryStats struct {
+	HeapAlloc   uint64 `json:"heap_alloc"`
+	HeapInuse   uint64 `json:"heap_inuse"`
+	HeapObjects uint64 `json:"heap_objects"`
+	Sys         uint64 `json:"sys"`
+	NumGC       uint32 `json:"num_gc"`
+}
+
+// Debug stats subject: $SCHEMA.DEBUG.STATS
+func (reg *SchemaRegistry) DebugStats(r micro.Request) {
+	r.RespondJSON(reg.debugStats())
+}
+
+func (reg *SchemaRegistry) debugStats() DebugStats {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	reg.schemasMu.RLock()
+	schemas := len(reg.schemas)
+	reg.schemasMu.RUnlock()
+
+	return DebugStats{
+		Goroutines:       runtime.NumGoroutine(),
+		Schemas:          schemas,
+		CachedResults:    reg.results.Len(),
+		FailureSamples:   reg.failures.Len(),
+		Forwarding:       reg.forwarded.Get(),
+		DeprecatedFields: reg.deprecations.Total(),
+		Memory: MemoryStats{
+			HeapAlloc:   mem.HeapAlloc,
+			HeapInuse:   mem.HeapInuse,
+			HeapObjects: mem.HeapObjects,
+			Sys:         mem.Sys,
+			NumGC:       mem.NumGC,
+		},
+	}
+}
+
+// PprofHandler serves the net/http/pprof profiles under /debug/pprof/.
+func PprofHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return mux
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDebugStats(t *testing.T) {
+	reg := NewSchemaRegistry(newMemKV(), nil)
+	reg.results = NewResultCache(10)
+	reg.failures = NewFailureLog(5)
+
+	reg.remember(Schema{Name: "orders", Body: `{"type": "object"}`})
+	reg.remember(Schema{Name: "customers", Body: `{"type": "object"}`})
+	reg.results.Add(resultKeyFor(Schema{Name: "orders"}, false, []byte(`{}`)), nil)
+	reg.failures.Add("orders", Failure{Error: "id is required"})
+	reg.forwarded.retries.Add(3)
+	reg.forwarded.failures.Add(1)
+
+	stats := reg.debugStats()
+	if stats.Schemas != 2 || stats.CachedResults != 1 || stats.FailureSamples != 1 {
+		t.Errorf("Expected 2 schemas, 1 verdict and 1 failure, got %+v", stats)
+	}
+	if stats.Forwarding != (ForwardStats{Retries: 3, Failures: 1}) {
+		t.Errorf("Expected the forwarding counters, got %+v", stats.Forwarding)
+	}
+	if stats.Goroutines == 0 || stats.Memory.HeapAlloc == 0 || stats.Memory.Sys == 0 {
+		t.Errorf("Expected the runtime stats to be filled in, got %+v", stats)
+	}
+}
+
+func TestPprofHandler(t *testing.T) {
+	handler := PprofHandler()
+
+	tests := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/debug/pprof/", http.StatusOK, "goroutine"},
+		{"/debug/pprof/heap?debug=1", http.StatusOK, "heap profile"},
+		{"/debug/pprof/cmdline", http.StatusOK, ""},
+		{"/schemas", http.StatusNotFound, ""},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.path, nil))
+		if w.Code != test.status || !strings.Contains(w.Body.String(), test.body) {
+			t.Errorf("Expected %s to respond %d with %q, got %d: %.100s", test.path, test.status, test.body, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestPprofOffGateway(t *testing.T) {
+	_, err := Connect(context.Background(), Config{HTTPAddr: ":8080", PprofAddr: ":8080"})
+	if err == nil || !strings.Contains(err.Error(), "unauthenticated") {
+		t.Errorf("Expected serving pprof on the gateway's address to fail, got %v", err)
+	}
+}
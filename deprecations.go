@@ -0,0 +1,275 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/micro"
+)
+
+// DeprecatedKeyword marks a property of a schema body as deprecated, and
+// SunsetKeyword the date it's due to be removed, as in
+// {"fax": {"type": "string", "deprecated": true, "x-sunset": "2027-01-31"}}.
+const (
+	DeprecatedKeyword = "deprecated"
+	SunsetKeyword     = "x-sunset"
+)
+
+// SchemaFieldDeprecationHeader warns consumers of a validated message about
+// each deprecated field it has.
+const SchemaFieldDeprecationHeader = "Schema-Field-Deprecation"
+
+// sunsetLayout is the format of sunset dates.
+const sunsetLayout = "2006-01-02"
+
+// DeprecatedField is a deprecated property of a schema, and who still sends
+// it.
+type DeprecatedField struct {
+	// Path is the JSON Pointer of the property, with a * token for array
+	// items.
+	Path   string `json:"path"`
+	Sunset string `json:"sunset,omitempty"`
+	// Seen counts the validated messages that had the field.
+	Seen      uint64          `json:"seen"`
+	Last      *time.Time      `json:"last,omitempty"`
+	Producers []FieldProducer `json:"producers,omitempty"`
+}
+
+// FieldProducer is a producer still sending a deprecated field, as the NATS
+// user and account the server attached to its messages.
+type FieldProducer struct {
+	Producer string    `json:"producer"`
+	Seen     uint64    `json:"seen"`
+	Last     time.Time `json:"last"`
+}
+
+// deprecatedFields returns the sunset dates of the deprecated properties of a
+// body, by path. Properties without a sunset date have an empty one.
+func deprecatedFields(body string) (map[string]string, error) {
+	nodes, err := annotatedNodes(body, DeprecatedKeyword)
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]string{}
+	for path, node := range nodes {
+		fields[path], _ = node[SunsetKeyword].(string)
+	}
+	return fields, nil
+}
+
+// checkSunsets returns an error if a sunset date of a body isn't a date.
+func checkSunsets(body string) error {
+	if !strings.Contains(body, SunsetKeyword) {
+		return nil
+	}
+	fields, err := deprecatedFields(body)
+	if err != nil {
+		return err
+	}
+	for _, path := range sortedKeys(fields) {
+		if sunset := fields[path]; sunset != "" {
+			if _, err := time.Parse(sunsetLayout, sunset); err != nil {
+				return fmt.Errorf("invalid %s of %s: %q isn't a date such as 2027-01-31", SunsetKeyword, path, sunset)
+			}
+		}
+	}
+	return nil
+}
+
+// presentPaths returns the paths, as returned by annotatedPaths, that have a
+// value in a payload.
+func presentPaths(data []byte, paths []string) ([]string, error) {
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	var present func(node interface{}, tokens []string) bool
+	present = func(node interface{}, tokens []string) bool {
+		if len(tokens) == 0 {
+			return true
+		}
+		token := strings.NewReplacer("~1", "/", "~0", "~").Replace(tokens[0])
+		switch n := node.(type) {
+		case map[string]interface{}:
+			value, ok := n[token]
+			return ok && present(value, tokens[1:])
+		case []interface{}:
+			for i, item := range n {
+				if (token == "*" || token == strconv.Itoa(i)) && present(item, tokens[1:]) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	var found []string
+	for _, path := range paths {
+		if present(doc, strings.Split(path, "/")[1:]) {
+			found = append(found, path)
+		}
+	}
+	return found, nil
+}
+
+// fieldUse counts the messages sending a deprecated field.
+type fieldUse struct {
+	seen      uint64
+	last      time.Time
+	producers map[string]*FieldProducer
+}
+
+// DeprecationLog counts the messages sending deprecated fields, per schema,
+// field and producer, since this instance started.
+type DeprecationLog struct {
+	mu     sync.Mutex
+	fields map[string]map[string]*fieldUse
+	total  uint64
+}
+
+func NewDeprecationLog() *DeprecationLog {
+	return &DeprecationLog{fields: map[string]map[string]*fieldUse{}}
+}
+
+// Record counts a message of a producer sending a deprecated field of a
+// schema.
+func (l *DeprecationLog) Record(schema, path, producer string) {
+	now := time.Now().UTC()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.fields[schema] == nil {
+		l.fields[schema] = map[string]*fieldUse{}
+	}
+	use := l.fields[schema][path]
+	if use == nil {
+		use = &fieldUse{producers: map[string]*FieldProducer{}}
+		l.fields[schema][path] = use
+	}
+	use.seen++
+	use.last = now
+	l.total++
+
+	if producer == "" {
+		return
+	}
+	p := use.producers[producer]
+	if p == nil {
+		p = &FieldProducer{Producer: producer}
+		use.producers[producer] = p
+	}
+	p.Seen++
+	p.Last = now
+}
+
+// Total returns the number of deprecated fields seen across all schemas.
+func (l *DeprecationLog) Total() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.total
+}
+
+// Describe fills in who sent a deprecated field of a schema.
+func (l *DeprecationLog) Describe(schema string, field *DeprecatedField) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	use := l.fields[schema][field.Path]
+	if use == nil {
+		return
+	}
+	last := use.last
+	field.Seen = use.seen
+	field.Last = &last
+	for _, p := range use.producers {
+		field.Producers = append(field.Producers, *p)
+	}
+	sort.Slice(field.Producers, func(i, j int) bool { return field.Producers[i].Producer < field.Producers[j].Producer })
+}
+
+// checkDeprecatedFields warns the consumers of a validated message about the
+// deprecated fields it has, and counts them for their producer.
+func (reg *SchemaRegistry) checkDeprecatedFields(msg *nats.Msg, schema Schema, data []byte) {
+	body, err := reg.resolvedBody(schema.Body)
+	if err != nil || !strings.Contains(body, `"`+DeprecatedKeyword+`"`) {
+		return
+	}
+	fields, err := deprecatedFields(body)
+	if err != nil || len(fields) == 0 {
+		return
+	}
+	present, err := presentPaths(data, sortedKeys(fields))
+	if err != nil {
+		return
+	}
+
+	producer := RequestIdentity(msg.Header)
+	for _, path := range present {
+		reg.deprecations.Record(schema.Name, path, producer)
+		msg.Header.Add(SchemaFieldDeprecationHeader, describeDeprecation(path, fields[path], time.Now()))
+	}
+}
+
+// describeDeprecation says a field is deprecated, and when it's removed.
+func describeDeprecation(path, sunset string, now time.Time) string {
+	switch {
+	case sunset == "":
+		return fmt.Sprintf("field %s is deprecated", path)
+	case now.Format(sunsetLayout) > sunset:
+		return fmt.Sprintf("field %s is deprecated, its sunset was %s", path, sunset)
+	default:
+		return fmt.Sprintf("field %s is deprecated, its sunset is %s", path, sunset)
+	}
+}
+
+// Deprecations subject: $SCHEMA.DEPRECATIONS.<schema_name>
+//
+// Returns the deprecated fields of a schema with the producers still sending
+// them, as seen by this instance.
+func (reg *SchemaRegistry) GetDeprecations(r micro.Request) {
+	name := subjectName(r.Subject())
+
+	fields, err := reg.deprecatedFieldUse(name)
+	if err != nil {
+		r.Error(errorCode(err), err.Error(), nil)
+		return
+	}
+
+	r.RespondJSON(fields)
+}
+
+// deprecatedFieldUse returns the deprecated fields of a cached schema, sorted
+// by path, and who sent them.
+func (reg *SchemaRegistry) deprecatedFieldUse(name string) ([]DeprecatedField, error) {
+	reg.schemasMu.RLock()
+	schema, ok := reg.schemas[name]
+	reg.schemasMu.RUnlock()
+	if !ok {
+		return nil, newError("404", "schema %q not found", name)
+	}
+
+	body, err := reg.resolvedBody(schema.Body)
+	if err != nil {
+		return nil, err
+	}
+	sunsets, err := deprecatedFields(body)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := []DeprecatedField{}
+	for _, path := range sortedKeys(sunsets) {
+		field := DeprecatedField{Path: path, Sunset: sunsets[path]}
+		reg.deprecations.Describe(name, &field)
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+const deprecatedBody = `{"type": "object", "properties": {
+	"id": {"type": "string"},
+	"fax": {"type": "string", "deprecated": true, "x-sunset": "2027-01-31"},
+	"lines": {"type": "array", "items": {"type": "object", "properties": {"code": {"type": "string", "deprecated": true}}}}
+}}`
+
+func TestCheckSunsets(t *testing.T) {
+	if err := checkSunsets(deprecatedBody); err != nil {
+		t.Errorf("Expected the sunset to be valid, got %v", err)
+	}
+	invalid := `{"type": "object", "properties": {"fax": {"deprecated": true, "x-sunset": "next year"}}}`
+	if err := checkSunsets(invalid); err == nil {
+		t.Errorf("Expected an invalid sunset date to be rejected")
+	}
+}
+
+func TestCheckDeprecatedFields(t *testing.T) {
+	reg := NewSchemaRegistry(nil, nil)
+	schema := Schema{Name: "orders", Body: deprecatedBody}
+	reg.schemas = map[string]Schema{"orders": schema}
+
+	TrustRequestInfo(true)
+	defer TrustRequestInfo(false)
+	msg := nats.NewMsg("orders")
+	msg.Header.Set("Nats-Request-Info", `{"acc": "SHOP", "user": "legacy"}`)
+	reg.checkDeprecatedFields(msg, schema, []byte(`{"id": "1", "fax": "555", "lines": [{}, {"code": "x"}]}`))
+
+	warnings := msg.Header.Values(SchemaFieldDeprecationHeader)
+	if len(warnings) != 2 || warnings[0] != "field /fax is deprecated, its sunset is 2027-01-31" || warnings[1] != "field /lines/*/code is deprecated" {
+		t.Errorf("Expected a warning per deprecated field, got %q", warnings)
+	}
+
+	fields, err := reg.deprecatedFieldUse("orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fields) != 2 || fields[0].Path != "/fax" || fields[0].Seen != 1 {
+		t.Fatalf("Expected the deprecated fields to be counted, got %+v", fields)
+	}
+	if len(fields[0].Producers) != 1 || fields[0].Producers[0].Producer != "legacy@SHOP" {
+		t.Errorf("Expected the producer to be reported, got %+v", fields[0].Producers)
+	}
+
+	clean := nats.NewMsg("orders")
+	reg.checkDeprecatedFields(clean, schema, []byte(`{"id": "1", "lines": [{}]}`))
+	if len(clean.Header.Values(SchemaFieldDeprecationHeader)) != 0 {
+		t.Errorf("Expected no warning without deprecated fields")
+	}
+	if reg.deprecations.Total() != 2 {
+		t.Errorf("Expected 2 deprecated fields to be counted, got %d", reg.deprecations.Total())
+	}
+}
+
+func TestDescribeDeprecation(t *testing.T) {
+	now := time.Date(2027, 2, 1, 0, 0, 0, 0, time.UTC)
+	if desc := describeDeprecation("/fax", "2027-01-31", now); desc != "field /fax is deprecated, its sunset was 2027-01-31" {
+		t.Errorf("Expected the sunset to be past, got %q", desc)
+	}
+}
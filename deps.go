@@ -0,0 +1,62 @@
+package main
+
+import (
+	"github.com/nats-io/nats.go/micro"
+)
+
+// Dependencies lists the schemas a schema references and the schemas
+// referencing it, directly or through other schemas, which is what changing
+// it can break.
+type Dependencies struct {
+	Name string `json:"name"`
+	// Dependencies are the schemas it references, sorted by name.
+	Dependencies []string `json:"dependencies"`
+	// Dependents are the schemas referencing it, sorted by name.
+	Dependents []string `json:"dependents"`
+}
+
+// Deps subject: $SCHEMA.DEPS.<schema_name>
+func (reg *SchemaRegistry) Deps(r micro.Request) {
+	name := subjectName(r.Subject())
+
+	deps, err := reg.deps(name)
+	if err != nil {
+		r.Error(errorCode(err), err.Error(), nil)
+		return
+	}
+
+	r.RespondJSON(deps)
+}
+
+// deps returns the dependency graph around a cached schema.
+func (reg *SchemaRegistry) deps(name string) (Dependencies, error) {
+	if _, ok := reg.lookupBody(name); !ok {
+		return Dependencies{}, newError("404", "schema %q not found", name)
+	}
+
+	return Dependencies{
+		Name:         name,
+		Dependencies: reg.dependencies(name),
+		Dependents:   dependentNames(reg.dependents(name)),
+	}, nil
+}
+
+// dependencies returns the names of the schemas the named schema references,
+// directly or through other schemas, sorted. References to schemas that
+// aren't registered are included, as changing them matters too.
+func (reg *SchemaRegistry) dependencies(name string) []string {
+	found := map[string]bool{}
+	queue := []string{name}
+	for len(queue) > 0 {
+		body, _ := reg.lookupBody(queue[0])
+		queue = queue[1:]
+		for _, ref := range registryRefs(body) {
+			if found[ref] || ref == name {
+				continue
+			}
+			found[ref] = true
+			queue = append(queue, ref)
+		}
+	}
+	return sortedKeys(found)
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDeps(t *testing.T) {
+	reg := NewSchemaRegistry(nil, nil)
+	reg.schemas = map[string]Schema{
+		"defs_money":   {Name: "defs_money", Body: `{"type": "number"}`},
+		"defs_price":   {Name: "defs_price", Body: `{"type": "object", "properties": {"amount": {"$ref": "schema:defs_money"}}}`},
+		"orders":       {Name: "orders", Subject: "orders", Body: `{"type": "object", "properties": {"price": {"$ref": "schema:defs_price"}, "total": {"$ref": "schema:defs_money"}}}`},
+		"invoices":     {Name: "invoices", Subject: "invoices", Body: `{"type": "object", "properties": {"order": {"$ref": "schema:orders"}}}`},
+		"unrelated_id": {Name: "unrelated_id", Subject: "ids", Body: `{"type": "string"}`},
+	}
+
+	deps, err := reg.deps("orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(deps.Dependencies, []string{"defs_money", "defs_price"}) {
+		t.Errorf("Expected direct and indirect dependencies, got %v", deps.Dependencies)
+	}
+	if !reflect.DeepEqual(deps.Dependents, []string{"invoices"}) {
+		t.Errorf("Expected invoices to depend on orders, got %v", deps.Dependents)
+	}
+
+	deps, _ = reg.deps("unrelated_id")
+	if len(deps.Dependencies) != 0 || len(deps.Dependents) != 0 {
+		t.Errorf("Expected no dependencies, got %+v", deps)
+	}
+
+	if _, err := reg.deps("missing"); errorCode(err) != "404" {
+		t.Errorf("Expected a missing schema to be not found, got %v", err)
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/micro"
+)
+
+// deadLetterPrefix is the subject prefix rejected messages are stored under,
+// as $SCHEMA.DLQ.<schema_name>.<subject>.
+const deadLetterPrefix = "$SCHEMA.DLQ"
+
+// maxReplayErrors caps the errors included in a replay report.
+const maxReplayErrors = 100
+
+// ReplayReport is the outcome of replaying dead-lettered messages.
+type ReplayReport struct {
+	Replayed int      `json:"replayed"`
+	Failed   int      `json:"failed"`
+	Errors   []string `json:"errors,omitempty"`
+}
+
+// CreateDeadLetterStream creates the stream rejected messages are stored in.
+func CreateDeadLetterStream(js nats.JetStreamContext, name string) error {
+	_, err := js.AddStream(&nats.StreamConfig{
+		Name:        name,
+		Description: "Messages rejected by the schema registry.",
+		Subjects:    []string{deadLetterPrefix + ".>"},
+	})
+	if errors.Is(err, nats.ErrStreamNameAlreadyInUse) {
+		return nil
+	}
+	return err
+}
+
+// deadLetterMsg stores a rejected message in the dead-letter stream, along with
+// the subject it was meant for and why it was rejected.
+func (reg *SchemaRegistry) deadLetterMsg(m *nats.Msg, schema Schema, subject string, reason error) error {
+	msg := nats.NewMsg(fmt.Sprintf("%s.%s.%s", deadLetterPrefix, nameToken(schema.Name), subject))
+	msg.Data = m.Data
+	for key, values := range m.Header {
+		msg.Header[key] = append([]string(nil), values...)
+	}
+	msg.Header.Set("Schema-Name", schema.Name)
+	msg.Header.Set("Schema-Revision", fmt.Sprintf("%d", schema.Revision))
+	msg.Header.Set("Schema-Original-Subject", subject)
+	msg.Header.Set("Schema-Error", reason.Error())
+
+	_, err := reg.js.PublishMsg(msg)
+	return err
+}
+
+// Replay subject: $SCHEMA.REPLAY.<schema_name>
+func (reg *SchemaRegistry) Replay(r micro.Request) {
+	name := subjectName(r.Subject())
+
+	if reg.deadLetter == "" {
+		r.Error("409", "no dead-letter stream is configured", nil)
+		return
+	}
+
+	reg.schemasMu.RLock()
+	schema, ok := reg.schemas[name]
+	reg.schemasMu.RUnlock()
+	if !ok {
+		r.Error("404", "Not found", nil)
+		return
+	}
+	if schema.State == StateDraft || schema.State == StateDisabled {
+		r.Error("409", fmt.Sprintf("schema %q is %s", name, schema.State), nil)
+		return
+	}
+
+	// Messages replayed before an error stay replayed, so the report is
+	// returned along with it
+	report, err := reg.replay(schema)
+	if err != nil {
+		data, _ := json.Marshal(report)
+		r.Error("500", err.Error(), data)
+		return
+	}
+	r.RespondJSON(report)
+}
+
+// replay re-validates the dead-lettered messages of a schema against its
+// latest revision. Messages that pass are forwarded to their original subject
+// and removed from the dead-letter stream, the others stay. Only the messages
+// stored when it starts are replayed, not those dead-lettered meanwhile.
+func (reg *SchemaRegistry) replay(schema Schema) (ReplayReport, error) {
+	var report ReplayReport
+	filter := fmt.Sprintf("%s.%s.>", deadLetterPrefix, nameToken(schema.Name))
+
+	info, err := reg.js.StreamInfo(reg.deadLetter, &nats.StreamInfoRequest{SubjectsFilter: filter})
+	if err != nil {
+		return report, err
+	}
+	if len(info.State.Subjects) == 0 {
+		return report, nil
+	}
+	last := info.State.LastSeq
+
+	sub, err := reg.js.SubscribeSync(filter, nats.OrderedConsumer(), nats.BindStream(reg.deadLetter), nats.DeliverAll())
+	if err != nil {
+		return report, err
+	}
+	defer sub.Unsubscribe()
+
+	fail := func(seq uint64, err error) {
+		report.Failed++
+		if len(report.Errors) < maxReplayErrors {
+			report.Errors = append(report.Errors, fmt.Sprintf("message %d: %v", seq, err))
+		}
+	}
+
+	for {
+		msg, err := sub.NextMsg(2 * time.Second)
+		if err != nil {
+			return report, err
+		}
+		meta, err := msg.Metadata()
+		if err != nil {
+			return report, err
+		}
+
+		err = reg.replayMsg(msg, schema)
+		// Messages dead-lettered again were stored anew, so the replayed copy
+		// is removed like those forwarded
+		if err == nil || errors.Is(err, errDeadLettered) {
+			if delErr := reg.js.DeleteMsg(reg.deadLetter, meta.Sequence.Stream); delErr != nil {
+				err = delErr
+			}
+		}
+		if err != nil {
+			fail(meta.Sequence.Stream, err)
+		} else {
+			report.Replayed++
+		}
+
+		if meta.NumPending == 0 || meta.Sequence.Stream >= last {
+			return report, nil
+		}
+	}
+}
+
+// replayMsg re-validates a dead-lettered message and forwards it like a
+// validated one, encrypted, to the schema's stream or its destination, and
+// with its redacted copy. A message failing to forward again is stored anew
+// in the dead-letter stream.
+func (reg *SchemaRegistry) replayMsg(msg *nats.Msg, schema Schema) error {
+	subject := msg.Header.Get("Schema-Original-Subject")
+	msg.Header.Del("Schema-Original-Subject")
+	msg.Header.Del("Schema-Error")
+	// The reply subject is the acknowledgement of the dead-letter stream
+	msg.Reply = ""
+
+	params, err := reg.check(schema, subject, msg.Data, msg.Header)
+	if err != nil {
+		return err
+	}
+	fwd := reg.forwardMsg(RouteSubject(schema, subject, params), schema, subject, params, msg)
+	if err := reg.encryptMsg(fwd, schema, msg.Data); err != nil {
+		return err
+	}
+	return reg.publishValidated(msg, fwd, schema, subject, params)
+}
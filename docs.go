@@ -0,0 +1,387 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/nats-io/nats.go/micro"
+)
+
+// SchemaDocsFormatHeader chooses the format of $SCHEMA.DOCS responses,
+// markdown or html.
+const SchemaDocsFormatHeader = "Schema-Docs-Format"
+
+// Documentation formats.
+const (
+	DocsMarkdown = "markdown"
+	DocsHTML     = "html"
+)
+
+// docConstraints are the keywords listed as constraints of a field, in order.
+var docConstraints = []string{
+	"const", "enum", "format", "pattern",
+	"minimum", "exclusiveMinimum", "maximum", "exclusiveMaximum", "multipleOf",
+	"minLength", "maxLength", "minItems", "maxItems", "uniqueItems",
+	"default", PIIKeyword, EncryptKeyword,
+}
+
+// SchemaDoc is the human-readable documentation of a schema.
+type SchemaDoc struct {
+	Schema      Schema
+	Description string
+	Fields      []FieldDoc
+	Examples    []ExampleDoc
+}
+
+// FieldDoc documents a field of a payload. Nested fields are named by their
+// path, as in customer.address.city, with [] for array items.
+type FieldDoc struct {
+	Path        string
+	Type        string
+	Required    bool
+	Constraints []string
+	Description string
+}
+
+// ExampleDoc is an example payload, indented.
+type ExampleDoc struct {
+	Name    string
+	Payload string
+}
+
+// DocumentSchema builds the documentation of a schema. References to other
+// schemas are resolved with lookup, so their fields are documented too,
+// unless they can't be, such as recursive ones.
+func DocumentSchema(schema Schema, lookup func(name string) (string, bool)) (SchemaDoc, error) {
+	body := schema.Body
+	if hasRegistryRefs(body) {
+		if bundled, err := Bundle(body, lookup); err == nil {
+			body = bundled
+		}
+	}
+
+	var root interface{}
+	if err := json.Unmarshal([]byte(body), &root); err != nil {
+		return SchemaDoc{}, newError("400", "invalid schema: %v", err)
+	}
+
+	doc := SchemaDoc{Schema: schema}
+	doc.Description, _ = asObject(root)["description"].(string)
+	doc.Fields = documentFields(asObject(root), "")
+	for _, name := range sortedKeys(schema.Examples) {
+		var payload bytes.Buffer
+		if err := json.Indent(&payload, schema.Examples[name], "", "  "); err != nil {
+			payload.Reset()
+			payload.Write(schema.Examples[name])
+		}
+		doc.Examples = append(doc.Examples, ExampleDoc{Name: name, Payload: payload.String()})
+	}
+	return doc, nil
+}
+
+// documentFields documents the properties of an object schema and of the
+// objects nested in it.
+func documentFields(node map[string]interface{}, prefix string) []FieldDoc {
+	required := map[string]bool{}
+	if list, ok := node["required"].([]interface{}); ok {
+		for _, name := range list {
+			if name, ok := name.(string); ok {
+				required[name] = true
+			}
+		}
+	}
+
+	var fields []FieldDoc
+	properties := asObject(node["properties"])
+	for _, name := range sortedKeys(properties) {
+		property := asObject(properties[name])
+		field := FieldDoc{
+			Path:     prefix + name,
+			Type:     docType(property),
+			Required: required[name],
+		}
+		field.Description, _ = property["description"].(string)
+		for _, keyword := range docConstraints {
+			if value, ok := property[keyword]; ok {
+				data, _ := json.Marshal(value)
+				field.Constraints = append(field.Constraints, fmt.Sprintf("%s: %s", keyword, data))
+			}
+		}
+		fields = append(fields, field)
+
+		fields = append(fields, documentFields(property, field.Path+".")...)
+		if items := asObject(property["items"]); items != nil {
+			fields = append(fields, documentFields(items, field.Path+"[].")...)
+		}
+	}
+	return fields
+}
+
+// docType describes the type of a schema node.
+func docType(node map[string]interface{}) string {
+	switch t := node["type"].(type) {
+	case string:
+		if items := asObject(node["items"]); t == "array" && items != nil {
+			if itemType := docType(items); itemType != "any" {
+				return itemType + "[]"
+			}
+		}
+		return t
+	case []interface{}:
+		types := make([]string, len(t))
+		for i, v := range t {
+			types[i] = fmt.Sprint(v)
+		}
+		return strings.Join(types, " | ")
+	}
+	if ref, ok := node["$ref"].(string); ok {
+		return ref
+	}
+	for _, keyword := range []string{"oneOf", "anyOf", "allOf"} {
+		if _, ok := node[keyword]; ok {
+			return keyword
+		}
+	}
+	if _, ok := node["enum"]; ok {
+		return "enum"
+	}
+	return "any"
+}
+
+// Markdown renders the documentation as Markdown.
+func (doc SchemaDoc) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", doc.Schema.Name)
+	if doc.Description != "" {
+		fmt.Fprintf(&b, "%s\n\n", doc.Description)
+	}
+	for _, line := range doc.summary() {
+		fmt.Fprintf(&b, "- **%s:** %s\n", line[0], line[1])
+	}
+
+	if len(doc.Fields) > 0 {
+		b.WriteString("\n## Fields\n\n")
+		b.WriteString("| Field | Type | Required | Constraints | Description |\n")
+		b.WriteString("| --- | --- | --- | --- | --- |\n")
+		for _, field := range doc.Fields {
+			required := ""
+			if field.Required {
+				required = "yes"
+			}
+			fmt.Fprintf(&b, "| `%s` | %s | %s | %s | %s |\n", field.Path, markdownCell(field.Type), required,
+				markdownCell(strings.Join(field.Constraints, ", ")), markdownCell(field.Description))
+		}
+	}
+
+	if len(doc.Schema.Rules) > 0 {
+		b.WriteString("\n## Rules\n\n")
+		for _, rule := range doc.Schema.Rules {
+			fmt.Fprintf(&b, "- %s", rule.describe())
+			if rule.Message != "" {
+				fmt.Fprintf(&b, ": %s", rule.Message)
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	for i, example := range doc.Examples {
+		if i == 0 {
+			b.WriteString("\n## Examples\n")
+		}
+		fmt.Fprintf(&b, "\n### %s\n\n```json\n%s\n```\n", example.Name, example.Payload)
+	}
+	return b.String()
+}
+
+// markdownCell escapes text for a Markdown table cell.
+func markdownCell(text string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(text)
+}
+
+// summary returns the labeled facts about a schema listed before its fields.
+func (doc SchemaDoc) summary() [][2]string {
+	schema := doc.Schema
+	var lines [][2]string
+	if schema.Subject != "" {
+		lines = append(lines, [2]string{"Subject", schema.Subject})
+	}
+	if schema.Destination != "" {
+		lines = append(lines, [2]string{"Forwarded to", schema.Destination})
+	}
+	if schema.State != "" {
+		lines = append(lines, [2]string{"State", schema.State})
+	}
+	if schema.Version != 0 {
+		version := fmt.Sprint(schema.Version)
+		if schema.SemVer != "" {
+			version += " (" + schema.SemVer + ")"
+		}
+		lines = append(lines, [2]string{"Version", version})
+	}
+	return lines
+}
+
+var docsTemplate = template.Must(template.New("docs").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Schema.Name}}</title>
+<style>
+body { font-family: sans-serif; max-width: 960px; margin: 2em auto; }
+table { border-collapse: collapse; width: 100%; }
+th, td { border: 1px solid #ddd; padding: 4px 8px; text-align: left; vertical-align: top; }
+pre { background: #f6f8fa; padding: 1em; }
+</style>
+</head>
+<body>
+<h1>{{.Schema.Name}}</h1>
+{{with .Description}}<p>{{.}}</p>{{end}}
+<ul>
+{{range .Summary}}<li><strong>{{index . 0}}:</strong> {{index . 1}}</li>
+{{end}}</ul>
+{{if .Fields}}<h2>Fields</h2>
+<table>
+<tr><th>Field</th><th>Type</th><th>Required</th><th>Constraints</th><th>Description</th></tr>
+{{range .Fields}}<tr><td><code>{{.Path}}</code></td><td>{{.Type}}</td><td>{{if .Required}}yes{{end}}</td><td>{{range $i, $c := .Constraints}}{{if $i}}<br>{{end}}{{$c}}{{end}}</td><td>{{.Description}}</td></tr>
+{{end}}</table>
+{{end}}{{if .Schema.Rules}}<h2>Rules</h2>
+<ul>
+{{range .Rules}}<li>{{.}}</li>
+{{end}}</ul>
+{{end}}{{if .Examples}}<h2>Examples</h2>
+{{range .Examples}}<h3>{{.Name}}</h3>
+<pre>{{.Payload}}</pre>
+{{end}}{{end}}</body>
+</html>
+`))
+
+// HTML renders the documentation as a standalone HTML page.
+func (doc SchemaDoc) HTML() (string, error) {
+	var rules []string
+	for _, rule := range doc.Schema.Rules {
+		line := rule.describe()
+		if rule.Message != "" {
+			line += ": " + rule.Message
+		}
+		rules = append(rules, line)
+	}
+
+	var b strings.Builder
+	err := docsTemplate.Execute(&b, struct {
+		SchemaDoc
+		Summary [][2]string
+		Rules   []string
+	}{doc, doc.summary(), rules})
+	return b.String(), err
+}
+
+// Render renders the documentation in a format.
+func (doc SchemaDoc) Render(format string) (string, error) {
+	switch format {
+	case "", DocsMarkdown:
+		return doc.Markdown(), nil
+	case DocsHTML:
+		return doc.HTML()
+	}
+	return "", newError("400", "unknown documentation format %q, use %s or %s", format, DocsMarkdown, DocsHTML)
+}
+
+// Docs subject: $SCHEMA.DOCS.<schema_name>
+func (reg *SchemaRegistry) Docs(r micro.Request) {
+	name := subjectName(r.Subject())
+
+	reg.schemasMu.RLock()
+	schema, ok := reg.schemas[name]
+	reg.schemasMu.RUnlock()
+	if !ok {
+		r.Error("404", fmt.Sprintf("schema %q not found", name), nil)
+		return
+	}
+
+	doc, err := DocumentSchema(schema, reg.lookupBody)
+	if err != nil {
+		r.Error(errorCode(err), err.Error(), nil)
+		return
+	}
+	out, err := doc.Render(r.Headers().Get(SchemaDocsFormatHeader))
+	if err != nil {
+		r.Error(errorCode(err), err.Error(), nil)
+		return
+	}
+
+	r.Respond([]byte(out))
+}
+
+var docsIndexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Schemas</title>
+<style>body { font-family: sans-serif; max-width: 960px; margin: 2em auto; }</style>
+</head>
+<body>
+<h1>Schemas</h1>
+<ul>
+{{range .}}<li><a href="{{.Name}}.html">{{.Name}}</a>{{with .Subject}} <code>{{.}}</code>{{end}}</li>
+{{end}}</ul>
+</body>
+</html>
+`))
+
+// WriteDocsSite writes a static documentation site of schemas to a
+// directory: an index.html listing them, and a page per schema.
+func WriteDocsSite(schemas []Schema, dir string) error {
+	sort.Slice(schemas, func(i, j int) bool { return schemas[i].Name < schemas[j].Name })
+	bodies := map[string]string{}
+	for _, schema := range schemas {
+		bodies[schema.Name] = schema.Body
+	}
+	lookup := func(name string) (string, bool) {
+		body, ok := bodies[name]
+		return body, ok
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for _, schema := range schemas {
+		doc, err := DocumentSchema(schema, lookup)
+		if err != nil {
+			return fmt.Errorf("schema %q: %w", schema.Name, err)
+		}
+		page, err := doc.HTML()
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dir, schema.Name+".html"), []byte(page), 0o644); err != nil {
+			return err
+		}
+	}
+
+	var index bytes.Buffer
+	if err := docsIndexTemplate.Execute(&index, schemas); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, "index.html"), index.Bytes(), 0o644)
+}
+
+// PrintDocs writes a static documentation site of the registered schemas.
+func PrintDocs(cfg Config, dir string) error {
+	nc, _, kv, err := OpenBucket(cfg)
+	if err != nil {
+		return err
+	}
+	defer nc.Close()
+
+	schemas, err := loadSchemas(kv)
+	if err != nil {
+		return err
+	}
+	return WriteDocsSite(schemas, dir)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDocumentSchema(t *testing.T) {
+	lookup := func(name string) (string, bool) {
+		if name == "defs_address" {
+			return `{"type": "object", "required": ["city"], "properties": {"city": {"type": "string"}}}`, true
+		}
+		return "", false
+	}
+	schema := Schema{
+		Name:    "customers",
+		Subject: "customers.*",
+		State:   StateActive,
+		Version: 2,
+		Body: `{
+			"description": "A customer | account",
+			"type": "object",
+			"required": ["id"],
+			"properties": {
+				"id": {"type": "integer", "minimum": 1, "description": "Unique id"},
+				"email": {"type": "string", "format": "email", "x-pii": true},
+				"address": {"$ref": "schema:defs_address"},
+				"tags": {"type": "array", "items": {"type": "string"}, "maxItems": 5}
+			}
+		}`,
+		Examples: map[string]json.RawMessage{"minimal": json.RawMessage(`{"id":1}`)},
+		Rules:    []Rule{{Name: "vip", Expression: "id > 0", Message: "ids are positive"}},
+	}
+
+	doc, err := DocumentSchema(schema, lookup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := []string{}
+	for _, field := range doc.Fields {
+		paths = append(paths, field.Path)
+	}
+	if strings.Join(paths, " ") != "address address.city email id tags" {
+		t.Errorf("Expected the fields of the referenced schema to be documented, got %v", paths)
+	}
+	id := doc.Fields[3]
+	if id.Type != "integer" || !id.Required || id.Description != "Unique id" || strings.Join(id.Constraints, ",") != "minimum: 1" {
+		t.Errorf("Expected the id field to be documented, got %+v", id)
+	}
+	if tags := doc.Fields[4]; tags.Type != "string[]" {
+		t.Errorf("Expected tags to be a string array, got %+v", tags)
+	}
+
+	markdown := doc.Markdown()
+	for _, want := range []string{
+		"# customers\n\nA customer | account\n",
+		"- **Subject:** customers.*\n",
+		"| `email` | string |  | format: \"email\", x-pii: true |  |\n",
+		"- rule \"vip\": ids are positive\n",
+		"### minimal\n\n```json\n{\n  \"id\": 1\n}\n```\n",
+	} {
+		if !strings.Contains(markdown, want) {
+			t.Errorf("Expected the Markdown to contain %q, got:\n%s", want, markdown)
+		}
+	}
+
+	html, err := doc.Render(DocsHTML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(html, "<td><code>address.city</code></td><td>string</td><td>yes</td>") {
+		t.Errorf("Expected the HTML to have a row per field, got:\n%s", html)
+	}
+
+	if _, err := doc.Render("pdf"); errorCode(err) != "400" {
+		t.Errorf("Expected an unknown format to be rejected, got %v", err)
+	}
+}
+
+func TestWriteDocsSite(t *testing.T) {
+	dir := t.TempDir()
+	schemas := []Schema{
+		{Name: "orders", Subject: "orders", Body: `{"properties": {"total": {"$ref": "schema:defs_money"}}}`},
+		{Name: "defs_money", Body: `{"type": "number"}`},
+	}
+	if err := WriteDocsSite(schemas, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	index, err := os.ReadFile(filepath.Join(dir, "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(index), `<a href="orders.html">orders</a>`) {
+		t.Errorf("Expected the index to link every schema, got:\n%s", index)
+	}
+	page, err := os.ReadFile(filepath.Join(dir, "orders.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(page), "<td><code>total</code></td><td>number</td>") {
+		t.Errorf("Expected references to be resolved across schemas, got:\n%s", page)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/nats-io/nats.go/micro"
+)
+
+// DryRunReport is the verdict of checking a schema without storing it.
+type DryRunReport struct {
+	Name string `json:"name"`
+	// Revision is the current revision the schema was compared against, zero
+	// if the schema isn't registered yet.
+	Revision uint64 `json:"revision,omitempty"`
+	// Version is the version the schema would be stored as.
+	Version    uint64   `json:"version,omitempty"`
+	Valid      bool     `json:"valid"`
+	Compatible bool     `json:"compatible"`
+	Changes    []Change `json:"changes,omitempty"`
+	// Impact lists the registered consumers the changes would break.
+	Impact []ConsumerImpact `json:"impact,omitempty"`
+	Errors []string         `json:"errors,omitempty"`
+}
+
+// Dry run subject: $SCHEMA.DRYRUN.<schema_name>
+func (reg *SchemaRegistry) DryRun(r micro.Request) {
+	var schema Schema
+	err := json.Unmarshal(r.Data(), &schema)
+	if err != nil {
+		r.Error("400", err.Error(), nil)
+		return
+	}
+
+	// Pull out the schema name from the subject
+	schema.Name = subjectName(r.Subject())
+
+	report, err := reg.dryRun(schema)
+	if err != nil {
+		r.Error(errorCode(err), err.Error(), nil)
+		return
+	}
+
+	r.RespondJSON(report)
+}
+
+// dryRun compiles the schema and checks its compatibility with the current
+// revision, without storing anything.
+func (reg *SchemaRegistry) dryRun(schema Schema) (DryRunReport, error) {
+	report := DryRunReport{Name: schema.Name, Valid: true, Compatible: true}
+
+	if err := reg.settings().Limits.CheckSchema(schema); err != nil {
+		report.Valid = false
+		report.Compatible = false
+		report.Errors = append(report.Errors, err.Error())
+		return report, nil
+	}
+	if err := reg.compileBody(schema.Body); err != nil {
+		report.Valid = false
+		report.Compatible = false
+		report.Errors = append(report.Errors, err.Error())
+		return report, nil
+	}
+	if err := reg.checkRules(schema); err != nil {
+		report.Valid = false
+		report.Compatible = false
+		report.Errors = append(report.Errors, err.Error())
+		return report, nil
+	}
+	if err := reg.resolvePlugin(&schema); err != nil {
+		report.Valid = false
+		report.Compatible = false
+		report.Errors = append(report.Errors, err.Error())
+		return report, nil
+	}
+
+	current, exists, err := reg.current(schema.Name)
+	if err != nil {
+		return report, err
+	}
+	if schema.Examples == nil && exists {
+		schema.Examples = current.Examples
+	}
+	report.Version = 1
+	if exists {
+		report.Version = current.Version + 1
+	}
+	for _, broken := range reg.brokenExamples(schema) {
+		if reg.settings().Examples != ExamplesWarn {
+			report.Valid = false
+		}
+		report.Errors = append(report.Errors, broken)
+	}
+	if !exists {
+		return report, nil
+	}
+	report.Revision = current.Revision
+
+	report.Changes, err = DiffSchemas(current.Body, schema.Body)
+	if err != nil {
+		report.Compatible = false
+		report.Errors = append(report.Errors, err.Error())
+		return report, nil
+	}
+
+	for _, c := range BreakingChanges(report.Changes) {
+		report.Compatible = false
+		report.Errors = append(report.Errors, c.Description)
+	}
+
+	consumers, err := reg.consumers(schema.Name)
+	if err != nil {
+		return report, err
+	}
+	report.Impact = consumerImpact(consumers, report.Changes)
+	if len(report.Impact) > 0 {
+		report.Compatible = false
+		report.Errors = append(report.Errors, "breaks consumers "+describeImpact(report.Impact))
+	}
+
+	return report, nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import "testing"
+
+func TestDryRun(t *testing.T) {
+	kv := newMemKV()
+	reg := NewSchemaRegistry(kv, nil)
+
+	report, err := reg.dryRun(Schema{Name: "orders", Subject: "orders", Body: `{"type": "object"}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Valid || !report.Compatible || report.Version != 1 || report.Revision != 0 {
+		t.Errorf("Expected a new schema to be stored as version 1, got %+v", report)
+	}
+	if len(kv.entries) != 0 {
+		t.Fatalf("Expected a dry run to store nothing, got %d entries", len(kv.entries))
+	}
+
+	if _, err := reg.register(Schema{Name: "orders", Subject: "orders", Body: `{"type": "object"}`}); err != nil {
+		t.Fatal(err)
+	}
+	stored := len(kv.entries)
+
+	report, err = reg.dryRun(Schema{Name: "orders", Subject: "orders", Body: `{"type": "object", "required": ["id"]}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Version != 2 || report.Revision == 0 || report.Compatible || len(report.Changes) == 0 {
+		t.Errorf("Expected the breaking change to be reported for version 2, got %+v", report)
+	}
+	if len(kv.entries) != stored {
+		t.Errorf("Expected a dry run to store nothing, got %d new entries", len(kv.entries)-stored)
+	}
+	if schema, _, _ := reg.current("orders"); schema.Version != 1 {
+		t.Errorf("Expected version 1 to stay current, got %d", schema.Version)
+	}
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/micro"
+)
+
+// EncryptKeyword marks a property of a schema body to be encrypted before
+// messages are forwarded, as in {"ssn": {"type": "string", "x-encrypt": true}}.
+const EncryptKeyword = "x-encrypt"
+
+// SchemaEncryptedHeader lists the JSON Pointers of the fields of a forwarded
+// message that were encrypted.
+const SchemaEncryptedHeader = "Schema-Encrypted"
+
+// encryptedPrefix starts the encrypted value of a field, followed by the name
+// and revision of the key and the base64 encoded nonce and ciphertext:
+// enc:<key>:<revision>:<data>.
+const encryptedPrefix = "enc:"
+
+// keyringHistory is the number of revisions of a key kept, so fields
+// encrypted with a rotated key can still be decrypted.
+const keyringHistory = 64
+
+// Keyring holds the AES-256 keys fields are encrypted with, stored base64
+// encoded in a KV bucket. Putting a key again rotates it: new fields are
+// encrypted with the latest revision, while older revisions stay available for
+// decrypting as long as the bucket keeps them. A nil Keyring encrypts nothing.
+type Keyring struct {
+	kv nats.KeyValue
+
+	mu sync.RWMutex
+	// current are the latest revisions of the keys
+	current map[string]uint64
+	// keys are the keys by name and revision
+	keys map[string]map[uint64][]byte
+}
+
+// OpenKeyring opens the key bucket, creating it if it doesn't exist yet, and
+// follows its changes. It returns a nil Keyring if no bucket is configured.
+func OpenKeyring(js nats.JetStreamContext, bucket string) (*Keyring, error) {
+	if bucket == "" {
+		return nil, nil
+	}
+
+	kv, err := js.KeyValue(bucket)
+	if errors.Is(err, nats.ErrBucketNotFound) {
+		kv, err = js.CreateKeyValue(&nats.KeyValueConfig{
+			Bucket:      bucket,
+			Description: "Keys encrypting schema fields.",
+			History:     keyringHistory,
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	watcher, err := kv.WatchAll()
+	if err != nil {
+		return nil, err
+	}
+	k := &Keyring{kv: kv, current: map[string]uint64{}, keys: map[string]map[uint64][]byte{}}
+	// The initial keys are loaded before encrypting anything
+	for entry := range watcher.Updates() {
+		if entry == nil {
+			break
+		}
+		k.apply(entry)
+	}
+	go func() {
+		for entry := range watcher.Updates() {
+			if entry != nil {
+				k.apply(entry)
+			}
+		}
+	}()
+	return k, nil
+}
+
+// apply records a change to the key bucket.
+func (k *Keyring) apply(entry nats.KeyValueEntry) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if entry.Operation() != nats.KeyValuePut {
+		delete(k.current, entry.Key())
+		return
+	}
+	key, err := decodeKey(entry.Value())
+	if err != nil {
+		logger("encryption").Warn("Ignoring encryption key", "key", entry.Key(), "error", err)
+		return
+	}
+	k.store(entry.Key(), entry.Revision(), key)
+	k.current[entry.Key()] = entry.Revision()
+}
+
+func (k *Keyring) store(name string, revision uint64, key []byte) {
+	if k.keys[name] == nil {
+		k.keys[name] = map[uint64][]byte{}
+	}
+	k.keys[name][revision] = key
+}
+
+// decodeKey decodes a base64 encoded AES-256 key.
+func decodeKey(value []byte) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(value)))
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("expected 32 bytes, got %d", len(key))
+	}
+	return key, nil
+}
+
+// Has returns true if the named key exists.
+func (k *Keyring) Has(name string) bool {
+	if k == nil {
+		return false
+	}
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	_, ok := k.current[name]
+	return ok
+}
+
+// key returns a revision of a key, reading revisions from before the keyring
+// was opened from the bucket.
+func (k *Keyring) key(name string, revision uint64) ([]byte, error) {
+	k.mu.RLock()
+	key, ok := k.keys[name][revision]
+	k.mu.RUnlock()
+	if ok {
+		return key, nil
+	}
+
+	entry, err := k.kv.GetRevision(name, revision)
+	if err != nil {
+		return nil, fmt.Errorf("revision %d of key %q: %w", revision, name, err)
+	}
+	key, err = decodeKey(entry.Value())
+	if err != nil {
+		return nil, fmt.Errorf("revision %d of key %q: %w", revision, name, err)
+	}
+	k.mu.Lock()
+	k.store(name, revision, key)
+	k.mu.Unlock()
+	return key, nil
+}
+
+// Encrypt encrypts a value, encoded as JSON, with the latest revision of the
+// named key.
+func (k *Keyring) Encrypt(name string, value interface{}) (string, error) {
+	k.mu.RLock()
+	revision, ok := k.current[name]
+	key := k.keys[name][revision]
+	k.mu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("encryption key %q doesn't exist", name)
+	}
+
+	plaintext, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, plaintext, []byte(name))
+	return fmt.Sprintf("%s%s:%d:%s", encryptedPrefix, name, revision, base64.StdEncoding.EncodeToString(sealed)), nil
+}
+
+// Decrypt returns the value an encrypted field holds.
+func (k *Keyring) Decrypt(field string) (interface{}, error) {
+	parts := strings.SplitN(strings.TrimPrefix(field, encryptedPrefix), ":", 3)
+	if len(parts) != 3 {
+		return nil, errors.New("malformed encrypted value")
+	}
+	revision, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return nil, errors.New("malformed encrypted value")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("malformed encrypted value")
+	}
+
+	key, err := k.key(parts[0], revision)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("malformed encrypted value")
+	}
+	plaintext, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], []byte(parts[0]))
+	if err != nil {
+		return nil, fmt.Errorf("can't decrypt with key %q: %w", parts[0], err)
+	}
+
+	var value interface{}
+	err = json.Unmarshal(plaintext, &value)
+	return value, err
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// checkEncryption returns an error if a schema's body has fields to encrypt
+// without a key to encrypt them with, or the other way around.
+func (reg *SchemaRegistry) checkEncryption(schema Schema) error {
+	body, err := reg.resolvedBody(schema.Body)
+	if err != nil {
+		return err
+	}
+	paths, err := annotatedPaths(body, EncryptKeyword)
+	if err != nil {
+		return newError("400", err.Error())
+	}
+
+	switch {
+	case schema.EncryptionKey == "" && len(paths) > 0:
+		return newError("400", "schema %q has %s properties but no encryption_key", schema.Name, EncryptKeyword)
+	case schema.EncryptionKey == "":
+		return nil
+	case len(paths) == 0:
+		return newError("400", "schema %q has no %s properties to encrypt", schema.Name, EncryptKeyword)
+	case reg.keyring == nil:
+		return newError("400", "encryption isn't enabled, see --encryption-keys")
+	case !reg.keyring.Has(schema.EncryptionKey):
+		return newError("400", "encryption key %q doesn't exist", schema.EncryptionKey)
+	}
+	return nil
+}
+
+// encryptMsg encrypts the annotated fields of a message about to be
+// forwarded, whose payload is data, and lists them in its headers.
+func (reg *SchemaRegistry) encryptMsg(msg *nats.Msg, schema Schema, data []byte) error {
+	if schema.EncryptionKey == "" {
+		return nil
+	}
+	if msg.Header.Get(SchemaObjectHeader) != "" {
+		return newError("400", "payloads of schema %q have encrypted fields, they can't be referenced in an Object Store", schema.Name)
+	}
+	if reg.keyring == nil {
+		return newError("500", "encryption isn't enabled, can't encrypt payloads of schema %q", schema.Name)
+	}
+
+	body, err := reg.resolvedBody(schema.Body)
+	if err != nil {
+		return err
+	}
+	paths, err := annotatedPaths(body, EncryptKeyword)
+	if err != nil {
+		return err
+	}
+	encrypted, fields, err := transformPaths(data, paths, func(value interface{}) (interface{}, error) {
+		return reg.keyring.Encrypt(schema.EncryptionKey, value)
+	})
+	if err != nil {
+		return newError("500", "encrypting payload of schema %q: %v", schema.Name, err)
+	}
+
+	msg.Data = encrypted
+	if len(fields) > 0 {
+		msg.Header.Set(SchemaEncryptedHeader, strings.Join(fields, ","))
+	}
+	return nil
+}
+
+// Decrypt subject: $SCHEMA.DECRYPT.<schema_name>
+func (reg *SchemaRegistry) Decrypt(r micro.Request) {
+	name := subjectName(r.Subject())
+
+	if reg.decrypters == nil || !reg.decrypters.Allowed(name, nats.Header(r.Headers())) {
+		r.Error("403", fmt.Sprintf("not authorized to decrypt payloads of schema %q", name), nil)
+		return
+	}
+	if reg.keyring == nil {
+		r.Error("400", "encryption isn't enabled, see --encryption-keys", nil)
+		return
+	}
+
+	data, err := reg.decrypt(r.Data())
+	if err != nil {
+		r.Error(errorCode(err), err.Error(), nil)
+		return
+	}
+
+	r.Respond(data)
+}
+
+// decrypt returns a payload with every encrypted field decrypted. Fields are
+// found by their value rather than the schema, so payloads encrypted under
+// earlier revisions of it decrypt too.
+func (reg *SchemaRegistry) decrypt(data []byte) ([]byte, error) {
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, newError("400", err.Error())
+	}
+
+	var walk func(node interface{}) (interface{}, error)
+	walk = func(node interface{}) (interface{}, error) {
+		var err error
+		switch n := node.(type) {
+		case string:
+			if strings.HasPrefix(n, encryptedPrefix) {
+				return reg.keyring.Decrypt(n)
+			}
+		case map[string]interface{}:
+			for key, value := range n {
+				if n[key], err = walk(value); err != nil {
+					return nil, err
+				}
+			}
+		case []interface{}:
+			for i, item := range n {
+				if n[i], err = walk(item); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return node, nil
+	}
+	doc, err := walk(doc)
+	if err != nil {
+		return nil, newError("400", err.Error())
+	}
+	return json.Marshal(doc)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+)
+
+const encryptedBody = `{
+	"type": "object",
+	"properties": {
+		"id": {"type": "integer"},
+		"ssn": {"type": "string", "x-encrypt": true},
+		"card": {"type": "object", "x-encrypt": true}
+	}
+}`
+
+func testKeyring() *Keyring {
+	k := &Keyring{current: map[string]uint64{}, keys: map[string]map[uint64][]byte{}}
+	k.store("customers", 3, []byte("0123456789abcdef0123456789abcdef"))
+	k.current["customers"] = 3
+	return k
+}
+
+func TestKeyring(t *testing.T) {
+	k := testKeyring()
+
+	field, err := k.Encrypt("customers", map[string]interface{}{"number": "4111"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(field, "enc:customers:3:") || strings.Contains(field, "4111") {
+		t.Errorf("Expected an encrypted value with its key and revision, got %q", field)
+	}
+	value, err := k.Decrypt(field)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if card, _ := value.(map[string]interface{}); card["number"] != "4111" {
+		t.Errorf("Expected the value back, got %v", value)
+	}
+
+	other, _ := k.Encrypt("customers", map[string]interface{}{"number": "4111"})
+	if other == field {
+		t.Errorf("Expected every encryption to use a new nonce")
+	}
+
+	tampered := field[:len(field)-4] + "AAA="
+	if _, err := k.Decrypt(tampered); err == nil {
+		t.Errorf("Expected a tampered value to fail decrypting")
+	}
+	if _, err := k.Encrypt("unknown", "x"); err == nil {
+		t.Errorf("Expected encrypting with an unknown key to fail")
+	}
+	if _, err := decodeKey([]byte("c2hvcnQ=")); err == nil {
+		t.Errorf("Expected a short key to be rejected")
+	}
+}
+
+func TestCheckEncryption(t *testing.T) {
+	reg := NewSchemaRegistry(nil, nil)
+
+	schema := Schema{Name: "customers", Body: encryptedBody, EncryptionKey: "customers"}
+	if err := reg.checkEncryption(schema); errorCode(err) != "400" {
+		t.Errorf("Expected encryption to need a keyring, got %v", err)
+	}
+
+	reg.keyring = testKeyring()
+	if err := reg.checkEncryption(schema); err != nil {
+		t.Errorf("Expected the schema to be valid, got %v", err)
+	}
+	schema.EncryptionKey = "orders"
+	if err := reg.checkEncryption(schema); errorCode(err) != "400" {
+		t.Errorf("Expected an unknown key to be rejected, got %v", err)
+	}
+	schema.EncryptionKey = ""
+	if err := reg.checkEncryption(schema); errorCode(err) != "400" {
+		t.Errorf("Expected fields to encrypt without a key to be rejected, got %v", err)
+	}
+	if err := reg.checkEncryption(Schema{Name: "plain", Body: `{"type": "object"}`}); err != nil {
+		t.Errorf("Expected a schema without encryption to be valid, got %v", err)
+	}
+}
+
+func TestEncryptMsg(t *testing.T) {
+	reg := NewSchemaRegistry(nil, nil)
+	reg.keyring = testKeyring()
+	schema := Schema{Name: "customers", Body: encryptedBody, EncryptionKey: "customers"}
+
+	data := []byte(`{"id": 7, "ssn": "123-45-6789", "card": {"number": "4111"}}`)
+	msg := nats.NewMsg("customers")
+	msg.Data = data
+	if err := reg.encryptMsg(msg, schema, data); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(msg.Data), "6789") || strings.Contains(string(msg.Data), "4111") {
+		t.Errorf("Expected the annotated fields to be encrypted, got %s", msg.Data)
+	}
+	if msg.Header.Get(SchemaEncryptedHeader) != "/card,/ssn" {
+		t.Errorf("Expected the encrypted fields in the headers, got %q", msg.Header.Get(SchemaEncryptedHeader))
+	}
+
+	decrypted, err := reg.decrypt(msg.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got, want interface{}
+	json.Unmarshal(decrypted, &got)
+	json.Unmarshal(data, &want)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected the original payload back, got %s", decrypted)
+	}
+
+	object := nats.NewMsg("customers")
+	object.Header.Set(SchemaObjectHeader, "payloads/1")
+	if err := reg.encryptMsg(object, schema, data); errorCode(err) != "400" {
+		t.Errorf("Expected payloads in an Object Store to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// enforceIdentity is who schemas are updated by when enforcing a stream.
+const enforceIdentity = "enforce-stream"
+
+// streamConfig is the configuration of a stream as the JetStream API returns
+// it. It's kept as JSON, since the client doesn't know subject transforms yet,
+// and to keep any other field it doesn't know as it is.
+type streamConfig map[string]json.RawMessage
+
+type subjectTransform struct {
+	Source      string `json:"src"`
+	Destination string `json:"dest"`
+}
+
+// jsAPIResponse is what the stream info and update requests are answered with.
+type jsAPIResponse struct {
+	Config streamConfig   `json:"config"`
+	Error  *nats.APIError `json:"error,omitempty"`
+}
+
+// jsAPI makes a request to the JetStream API.
+func jsAPI(nc *nats.Conn, subject string, req interface{}) (streamConfig, error) {
+	var data []byte
+	if req != nil {
+		var err error
+		if data, err = json.Marshal(req); err != nil {
+			return nil, err
+		}
+	}
+	msg, err := nc.Request(subject, data, 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	var resp jsAPIResponse
+	if err := json.Unmarshal(msg.Data, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+	return resp.Config, nil
+}
+
+// enforcedSubjects returns the subjects a stream stores validated messages of,
+// and the subjects its messages are validated on.
+func enforcedSubjects(subjects []string, prefix string) (stored, validated []string) {
+	for _, subject := range subjects {
+		raw := strings.TrimPrefix(subject, prefix+".")
+		stored = append(stored, prefix+"."+raw)
+		validated = append(validated, raw)
+	}
+	return stored, validated
+}
+
+// EnforceStream makes a stream store only messages the registry validated.
+// The stream captures the subjects validated messages are forwarded to, the
+// same subjects under prefix, and transforms them back to the original
+// subjects, so consumers see no difference. Schemas bound to the stream's
+// subjects without a destination get one under prefix, and publish through
+// JetStream so producers get the stream's PubAck. Running it again is
+// harmless.
+func EnforceStream(nc *nats.Conn, reg *SchemaRegistry, stream, prefix string, w io.Writer) error {
+	config, err := jsAPI(nc, "$JS.API.STREAM.INFO."+stream, nil)
+	if err != nil {
+		return fmt.Errorf("stream %q: %w", stream, err)
+	}
+
+	var subjects []string
+	if err := json.Unmarshal(config["subjects"], &subjects); err != nil || len(subjects) == 0 {
+		return fmt.Errorf("stream %q has no subjects to enforce", stream)
+	}
+	transform := subjectTransform{Source: prefix + ".>", Destination: ">"}
+	if raw, ok := config["subject_transform"]; ok {
+		var current subjectTransform
+		json.Unmarshal(raw, &current)
+		if current != transform {
+			return fmt.Errorf("stream %q already transforms subjects from %q to %q", stream, current.Source, current.Destination)
+		}
+	}
+
+	stored, validated := enforcedSubjects(subjects, prefix)
+	config["subjects"], _ = json.Marshal(stored)
+	config["subject_transform"], _ = json.Marshal(transform)
+	_, err = jsAPI(nc, "$JS.API.STREAM.UPDATE."+stream, config)
+	if err != nil {
+		return fmt.Errorf("updating stream %q: %w", stream, err)
+	}
+	fmt.Fprintf(w, "Stream %s stores the messages validated on %s\n", stream, strings.Join(validated, ", "))
+
+	schemas, err := loadSchemas(reg.kv)
+	if err != nil {
+		return err
+	}
+	for _, schema := range schemas {
+		pattern := SubjectPattern(schema.Subject)
+		if schema.Subject == "" || !matchesAny(pattern, validated) {
+			continue
+		}
+
+		if schema.Destination != "" {
+			if !matchesAny(SubjectPattern(schema.Destination), stored) {
+				fmt.Fprintf(w, "Schema %s forwards to %s, which stream %s doesn't store\n", schema.Name, schema.Destination, stream)
+			}
+			continue
+		}
+
+		schema.Destination = prefix + "." + schema.Subject
+		schema.JetStream = true
+		schema.UpdatedBy = enforceIdentity
+		schema.RequestID = ""
+		_, err := reg.update(schema)
+		var regErr *RegistryError
+		if errors.As(err, &regErr) {
+			fmt.Fprintf(w, "Schema %s can't forward to %s: %v\n", schema.Name, schema.Destination, err)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "Schema %s forwards to %s\n", schema.Name, schema.Destination)
+	}
+	return nil
+}
+
+// matchesAny returns true if subject matches any of the wildcard subjects.
+func matchesAny(subject string, wildcards []string) bool {
+	for _, wildcard := range wildcards {
+		if SubjectsMatch(subject, wildcard) {
+			return true
+		}
+	}
+	return false
+}
+
+// PrintEnforceStream enforces a stream with the registered schemas, and
+// describes what changed.
+func PrintEnforceStream(cfg Config, stream string, w io.Writer) error {
+	verifier, err := LoadVerifier(cfg.SigningKeysFile)
+	if err != nil {
+		return err
+	}
+
+	nc, _, kv, err := OpenBucket(cfg)
+	if err != nil {
+		return err
+	}
+	defer nc.Close()
+
+	registry := NewSchemaRegistry(kv, nc)
+	registry.verifier = verifier
+	registry.immutable = cfg.Immutable
+
+	return EnforceStream(nc, registry, stream, cfg.InlinePrefix, w)
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEnforcedSubjects(t *testing.T) {
+	stored, validated := enforcedSubjects([]string{"orders.>", "validated.payments.*"}, "validated")
+	if !reflect.DeepEqual(stored, []string{"validated.orders.>", "validated.payments.*"}) {
+		t.Errorf("Expected the stream to store the validated subjects, got %v", stored)
+	}
+	if !reflect.DeepEqual(validated, []string{"orders.>", "payments.*"}) {
+		t.Errorf("Expected the subjects of an enforced stream to be recovered, got %v", validated)
+	}
+}
+
+func TestMatchesAny(t *testing.T) {
+	if !matchesAny("orders.*", []string{"payments.>", "orders.>"}) {
+		t.Errorf("Expected orders.* to be captured by orders.>")
+	}
+	if matchesAny("audit.*", []string{"orders.>"}) {
+		t.Errorf("Expected audit.* not to be captured by orders.>")
+	}
+}
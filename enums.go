@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/micro"
+)
+
+// EnumNamespace is the namespace of managed enums, such as enum_currency.
+// Enums are components listing the values a field may take, referenced by
+// other schemas as schema:enum_<name>.
+const EnumNamespace = "enum_"
+
+// Enum is a managed list of values shared across schemas, such as country
+// codes or currencies.
+type Enum struct {
+	Name        string        `json:"name"`
+	Description string        `json:"description,omitempty"`
+	Values      []interface{} `json:"values"`
+	Revision    uint64        `json:"revision,omitempty"`
+	Version     uint64        `json:"version,omitempty"`
+	// Dependents are the schemas referencing the enum, directly or through
+	// other schemas. They're only returned, never stored.
+	Dependents []string `json:"dependents,omitempty"`
+}
+
+// PutEnum subject: $SCHEMA.ENUM.<enum_name>
+func (reg *SchemaRegistry) PutEnum(r micro.Request) {
+	var enum Enum
+	err := json.Unmarshal(r.Data(), &enum)
+	if err != nil {
+		r.Error("400", err.Error(), nil)
+		return
+	}
+	enum.Name = subjectName(r.Subject())
+
+	if !reg.authorize(r, EnumNamespace+enum.Name) {
+		return
+	}
+	if reg.requireApproval {
+		r.Error(errorCode(errApprovalRequired), errApprovalRequired.Error(), nil)
+		return
+	}
+
+	enum, err = reg.putEnum(enum, RequestIdentity(nats.Header(r.Headers())))
+	if err != nil {
+		r.Error(errorCode(err), err.Error(), nil)
+		return
+	}
+
+	r.RespondJSON(enum)
+}
+
+// ListEnums subject: $SCHEMA.ENUMS
+func (reg *SchemaRegistry) ListEnums(r micro.Request) {
+	enums := []Enum{}
+	for _, schema := range reg.list() {
+		if !strings.HasPrefix(schema.Name, EnumNamespace) {
+			continue
+		}
+		enum, err := enumFromSchema(schema)
+		if err != nil {
+			continue
+		}
+		enum.Dependents = dependentNames(reg.dependents(schema.Name))
+		enums = append(enums, enum)
+	}
+	r.RespondJSON(enums)
+}
+
+// putEnum stores a new revision of an enum, creating it if it doesn't exist
+// yet. Its dependents are checked like those of any other schema, and are
+// announced as changed along with it.
+func (reg *SchemaRegistry) putEnum(enum Enum, by string) (Enum, error) {
+	schema, err := enumSchema(enum)
+	if err != nil {
+		return enum, err
+	}
+	schema.UpdatedBy = by
+	schema.RequestID = ""
+
+	schema, err = reg.update(schema)
+	if err != nil {
+		return enum, err
+	}
+
+	enum, err = enumFromSchema(schema)
+	if err != nil {
+		return enum, err
+	}
+	enum.Dependents = dependentNames(reg.dependents(schema.Name))
+	return enum, nil
+}
+
+// enumSchema returns the schema an enum is stored as. Values must be unique
+// strings or numbers.
+func enumSchema(enum Enum) (Schema, error) {
+	if len(enum.Values) == 0 {
+		return Schema{}, newError("400", "enum %q needs values", enum.Name)
+	}
+	seen := map[interface{}]bool{}
+	for _, value := range enum.Values {
+		switch value.(type) {
+		case string, float64:
+		default:
+			return Schema{}, newError("400", "enum %q has value %v, values must be strings or numbers", enum.Name, value)
+		}
+		if seen[value] {
+			return Schema{}, newError("400", "enum %q has value %v more than once", enum.Name, value)
+		}
+		seen[value] = true
+	}
+
+	body := map[string]interface{}{"enum": enum.Values}
+	if enum.Description != "" {
+		body["description"] = enum.Description
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return Schema{}, newError("400", err.Error())
+	}
+	return Schema{Name: EnumNamespace + enum.Name, Body: string(data)}, nil
+}
+
+// enumFromSchema returns the enum a schema stores.
+func enumFromSchema(schema Schema) (Enum, error) {
+	var body struct {
+		Description string        `json:"description"`
+		Enum        []interface{} `json:"enum"`
+	}
+	if err := json.Unmarshal([]byte(schema.Body), &body); err != nil {
+		return Enum{}, newError("500", "enum %q: %v", schema.Name, err)
+	}
+	return Enum{
+		Name:        strings.TrimPrefix(schema.Name, EnumNamespace),
+		Description: body.Description,
+		Values:      body.Enum,
+		Revision:    schema.Revision,
+		Version:     schema.Version,
+	}, nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEnumSchema(t *testing.T) {
+	enum := Enum{Name: "currency", Description: "ISO 4217 codes", Values: []interface{}{"EUR", "USD"}}
+	schema, err := enumSchema(enum)
+	if err != nil {
+		t.Fatalf("Expected the enum to be valid, got %v", err)
+	}
+	if schema.Name != "enum_currency" || !isComponent(schema.Name) {
+		t.Errorf("Expected the enum to be stored as a component, got %q", schema.Name)
+	}
+	if err := CompileSchema(schema.Body); err != nil {
+		t.Errorf("Expected the enum body to compile, got %v", err)
+	}
+
+	stored, err := enumFromSchema(schema)
+	if err != nil || !reflect.DeepEqual(stored, enum) {
+		t.Errorf("Expected the enum back, got %+v, %v", stored, err)
+	}
+
+	if _, err := enumSchema(Enum{Name: "currency"}); errorCode(err) != "400" {
+		t.Errorf("Expected an enum without values to be rejected, got %v", err)
+	}
+	if _, err := enumSchema(Enum{Name: "currency", Values: []interface{}{"EUR", "EUR"}}); errorCode(err) != "400" {
+		t.Errorf("Expected duplicate values to be rejected, got %v", err)
+	}
+	if _, err := enumSchema(Enum{Name: "currency", Values: []interface{}{map[string]interface{}{}}}); errorCode(err) != "400" {
+		t.Errorf("Expected an object value to be rejected, got %v", err)
+	}
+}
+
+func TestEnumDependents(t *testing.T) {
+	reg := NewSchemaRegistry(nil, nil)
+	reg.schemas = map[string]Schema{
+		"enum_currency": {Name: "enum_currency", Body: `{"enum": ["EUR", "USD"]}`},
+		"orders":        {Name: "orders", Subject: "orders", Body: `{"type": "object", "properties": {"currency": {"$ref": "schema:enum_currency"}}}`},
+	}
+
+	if err := reg.validate([]byte(`{"currency": "GBP"}`), reg.schemas["orders"].Body); err == nil {
+		t.Errorf("Expected a value missing from the enum to be rejected")
+	}
+
+	reg.schemas["enum_currency"] = Schema{Name: "enum_currency", Body: `{"enum": ["EUR", "GBP", "USD"]}`}
+	if err := reg.validate([]byte(`{"currency": "GBP"}`), reg.schemas["orders"].Body); err != nil {
+		t.Errorf("Expected a value added to the enum to be accepted, got %v", err)
+	}
+
+	names := dependentNames(reg.dependents("enum_currency"))
+	if !reflect.DeepEqual(names, []string{"orders"}) {
+		t.Errorf("Expected orders to depend on the enum, got %v", names)
+	}
+}
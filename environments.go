@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/micro"
+)
+
+// environmentName restricts environment names to lowercase subject tokens, so
+// they never collide with the upper case endpoint verbs.
+var environmentName = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+// PromoteRequest selects the revision of a schema to promote to the next
+// environment. Without a revision the latest one is promoted.
+type PromoteRequest struct {
+	From     string `json:"from"`
+	Revision uint64 `json:"revision,omitempty"`
+}
+
+// Promotion records where a schema revision was promoted from, and by whom.
+type Promotion struct {
+	From     string    `json:"from"`
+	Revision uint64    `json:"revision"`
+	By       string    `json:"by,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+// Environments are the registries of the stages of a deployment pipeline,
+// such as dev, staging and prod, in promotion order. Each is stored in its own
+// bucket and served under $SCHEMA.<env>.
+type Environments struct {
+	names      []string
+	registries map[string]*SchemaRegistry
+}
+
+// ParseEnvironments parses a comma separated list of environment names, in
+// promotion order.
+func ParseEnvironments(list string) ([]string, error) {
+	if list == "" {
+		return nil, nil
+	}
+
+	var names []string
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if !environmentName.MatchString(name) {
+			return nil, fmt.Errorf("invalid environment name %q", name)
+		}
+		if contains(names, name) {
+			return nil, fmt.Errorf("duplicate environment %q", name)
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+func NewEnvironments() *Environments {
+	return &Environments{registries: map[string]*SchemaRegistry{}}
+}
+
+// Add appends an environment to the promotion order.
+func (e *Environments) Add(name string, reg *SchemaRegistry) {
+	e.names = append(e.names, name)
+	e.registries[name] = reg
+}
+
+// next returns the environment after the named one.
+func (e *Environments) next(name string) (string, error) {
+	for i, env := range e.names {
+		if env != name {
+			continue
+		}
+		if i == len(e.names)-1 {
+			return "", newError("400", "%s is the last environment", name)
+		}
+		return e.names[i+1], nil
+	}
+	return "", newError("404", "unknown environment %q", name)
+}
+
+// Promote subject: $SCHEMA.PROMOTE.<schema_name>
+func (e *Environments) Promote(r micro.Request) {
+	var req PromoteRequest
+	err := json.Unmarshal(r.Data(), &req)
+	if err != nil {
+		r.Error("400", err.Error(), nil)
+		return
+	}
+
+	// Pull out the schema name from the subject
+	name := subjectName(r.Subject())
+
+	to, err := e.next(req.From)
+	if err != nil {
+		r.Error(errorCode(err), err.Error(), nil)
+		return
+	}
+	if !e.registries[to].authorize(r, name) {
+		return
+	}
+	if e.registries[to].requireApproval {
+		r.Error(errorCode(errApprovalRequired), errApprovalRequired.Error(), nil)
+		return
+	}
+
+	schema, err := e.promote(name, req, RequestIdentity(nats.Header(r.Headers())))
+	if err != nil {
+		r.Error(errorCode(err), err.Error(), nil)
+		return
+	}
+
+	r.RespondJSON(schema)
+}
+
+// promote copies a revision of a schema into the next environment, going
+// through the same checks as any other change there.
+func (e *Environments) promote(name string, req PromoteRequest, by string) (Schema, error) {
+	to, err := e.next(req.From)
+	if err != nil {
+		return Schema{}, err
+	}
+	source, target := e.registries[req.From], e.registries[to]
+
+	schema, err := source.revision(name, req.Revision)
+	if err != nil {
+		return schema, err
+	}
+	if schema.State == StateDraft || schema.State == StateDisabled {
+		return schema, newError("409", "revision %d of schema %q is %s and can't be promoted", schema.Revision, name, schema.State)
+	}
+
+	promotion := &Promotion{
+		From:     req.From,
+		Revision: schema.Revision,
+		By:       by,
+		Time:     time.Now().UTC(),
+	}
+	assign := func(schema *Schema) {
+		schema.Promotion = promotion
+	}
+	schema.Revision = 0
+	schema.Version = 0
+	schema.Fingerprint = ""
+	schema.ID = 0
+	schema.UpdatedBy = by
+	schema.RequestID = ""
+
+	_, exists, err := target.current(name)
+	if err != nil {
+		return schema, err
+	}
+	if exists {
+		return target.updateAssigned(schema, nil, assign)
+	}
+	return target.registerAssigned(schema, assign)
+}
+
+// revision returns a stored revision of a schema, or the latest one if
+// revision is zero.
+func (reg *SchemaRegistry) revision(name string, revision uint64) (Schema, error) {
+	if revision == 0 {
+		schema, exists, err := reg.current(name)
+		if err == nil && !exists {
+			err = newError("404", "Not found")
+		}
+		return schema, err
+	}
+
+	var schema Schema
+	entry, err := reg.kv.GetRevision(nameToken(name), revision)
+	if errors.Is(err, nats.ErrKeyNotFound) || (err == nil && entry.Operation() != nats.KeyValuePut) {
+		return schema, newError("404", "revision %d of schema %q not found", revision, name)
+	}
+	if err != nil {
+		return schema, err
+	}
+
+	err = json.Unmarshal(entry.Value(), &schema)
+	if err != nil {
+		return schema, err
+	}
+	schema.Revision = entry.Revision()
+	if schema.State == "" {
+		schema.State = StateActive
+	}
+	return schema, nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/nats-io/nats.go/micro"
+)
+
+func TestParseEnvironments(t *testing.T) {
+	envs, err := ParseEnvironments("dev, staging,prod")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(envs) != 3 || envs[0] != "dev" || envs[2] != "prod" {
+		t.Errorf("Expected environments in order, got %v", envs)
+	}
+
+	for _, list := range []string{"dev,dev", "GET", "dev,", "a.b"} {
+		if _, err := ParseEnvironments(list); err == nil {
+			t.Errorf("Expected %q to be invalid", list)
+		}
+	}
+}
+
+func TestEnvironmentsNext(t *testing.T) {
+	envs := NewEnvironments()
+	envs.Add("dev", nil)
+	envs.Add("prod", nil)
+
+	if next, err := envs.next("dev"); err != nil || next != "prod" {
+		t.Errorf("Expected dev to promote to prod, got %q %v", next, err)
+	}
+	if _, err := envs.next("prod"); errorCode(err) != "400" {
+		t.Errorf("Expected prod to be the last environment, got %v", err)
+	}
+	if _, err := envs.next("qa"); errorCode(err) != "404" {
+		t.Errorf("Expected qa to be unknown, got %v", err)
+	}
+}
+
+func TestPromote(t *testing.T) {
+	envs := NewEnvironments()
+	dev, prod := NewSchemaRegistry(newMemKV(), nil), NewSchemaRegistry(newMemKV(), nil)
+	envs.Add("dev", dev)
+	envs.Add("prod", prod)
+
+	forged := &Promotion{From: "qa", By: "mallory"}
+	if _, err := dev.register(Schema{Name: "orders", Subject: "orders", Body: `{"type": "object"}`, Promotion: forged}); err != nil {
+		t.Fatal(err)
+	}
+	if schema, _, _ := dev.current("orders"); schema.Promotion != nil {
+		t.Errorf("Expected the promotion sent on registration to be ignored, got %+v", schema.Promotion)
+	}
+
+	TrustRequestInfo(true)
+	defer TrustRequestInfo(false)
+	promote := func() string {
+		req := &recordingRequest{
+			subject: "$SCHEMA.PROMOTE.orders",
+			headers: micro.Headers{requestInfoHeader: []string{`{"acc":"ORDERS","user":"alice"}`}},
+			data:    []byte(`{"from": "dev"}`),
+		}
+		envs.Promote(req)
+		return req.resp.Header.Get(micro.ErrorCodeHeader)
+	}
+
+	if code := promote(); code != "" {
+		t.Fatalf("Expected the schema to be promoted, got %q", code)
+	}
+	schema, _, _ := prod.current("orders")
+	if schema.Promotion == nil || schema.Promotion.From != "dev" || schema.Promotion.By != "alice@ORDERS" || schema.UpdatedBy != "alice@ORDERS" {
+		t.Errorf("Expected the promotion by alice@ORDERS to be recorded, got %+v", schema.Promotion)
+	}
+
+	schema, err := prod.update(Schema{Name: "orders", Subject: "orders", Body: `{"type": "object"}`, Promotion: forged})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if schema.Promotion != nil {
+		t.Errorf("Expected updates to clear the promotion, got %+v", schema.Promotion)
+	}
+
+	prod.requireApproval = true
+	if code := promote(); code != errorCode(errApprovalRequired) {
+		t.Errorf("Expected promotions to need approval, got %q", code)
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// RegistryError is an error carrying the micro error code it should be
+// reported to the requester with.
+type RegistryError struct {
+	Code        string
+	Description string
+}
+
+func (e *RegistryError) Error() string {
+	return e.Description
+}
+
+// newError returns a RegistryError with a formatted description.
+func newError(code string, format string, args ...interface{}) error {
+	return &RegistryError{Code: code, Description: fmt.Sprintf(format, args...)}
+}
+
+// errorCode returns the micro error code for err, defaulting to 500.
+func errorCode(err error) string {
+	var regErr *RegistryError
+	if errors.As(err, &regErr) {
+		return regErr.Code
+	}
+	var limitErr *LimitError
+	if errors.As(err, &limitErr) {
+		return "413"
+	}
+	return "500"
+}
+
+// errReadOnly is returned for changes to a registry that only serves schemas.
+var errReadOnly = newError("403", "registry is read-only, schemas must be changed on the central registry")
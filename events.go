@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// EventsPrefix is the subject prefix schema changes are published under, as
+// $SCHEMA.EVENTS.<schema_name>. Registries served under another prefix publish
+// under <prefix>.EVENTS instead.
+const EventsPrefix = DefaultPrefix + ".EVENTS"
+
+const (
+	EventPut    = "put"
+	EventDelete = "delete"
+	// EventExpire follows the put of a schema that reached its expiry.
+	EventExpire = "expire"
+	// EventDependency announces a schema whose payloads now validate
+	// differently, because a schema it references was put.
+	EventDependency = "dependency"
+	// EventTransfer follows the put of a schema transferred to a new owner.
+	EventTransfer = "transfer"
+)
+
+// SchemaEvent announces a change to a schema. Schema is only set for puts.
+type SchemaEvent struct {
+	Operation string    `json:"operation"`
+	Name      string    `json:"name"`
+	Time      time.Time `json:"time"`
+	Schema    *Schema   `json:"schema,omitempty"`
+}
+
+// publishEvent announces a change made through this registry, over NATS and
+// to its webhooks. Events are best effort, failing to publish one doesn't fail
+// the change.
+func (reg *SchemaRegistry) publishEvent(operation, name string, schema *Schema) {
+	event := SchemaEvent{
+		Operation: operation,
+		Name:      name,
+		Time:      time.Now().UTC(),
+		Schema:    schema,
+	}
+	reg.webhooks.Notify(event)
+
+	if reg.nc == nil {
+		return
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		logger("events").Error("error encoding event", "schema", name, "error", err)
+		return
+	}
+
+	err = reg.nc.Publish(reg.prefix+".EVENTS."+nameToken(name), data)
+	if err != nil {
+		logger("events").Error("error publishing event", "schema", name, "error", err)
+	}
+}
+
+// publishDependents announces the schemas referencing a schema that was put.
+// Their references are resolved when payloads are validated, so they pick up
+// the change without being stored again.
+func (reg *SchemaRegistry) publishDependents(name string) {
+	for _, dependent := range reg.dependents(name) {
+		dependent := dependent
+		reg.publishEvent(EventDependency, dependent.Name, &dependent)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// What to do when an example no longer matches its schema.
+const (
+	ExamplesReject = "reject"
+	ExamplesWarn   = "warn"
+)
+
+// brokenExamples validates the examples of a schema against its body, and
+// describes every example that doesn't match, sorted by name.
+func (reg *SchemaRegistry) brokenExamples(schema Schema) []string {
+	if len(schema.Examples) == 0 {
+		return nil
+	}
+
+	body, err := reg.payloadBody(schema)
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	var broken []string
+	for _, name := range sortedKeys(schema.Examples) {
+		problems, err := violations(schema.Examples[name], body)
+		if err == nil && len(problems) == 0 {
+			problems, err = reg.extraViolations(schema, schema.Examples[name])
+		}
+		if err != nil {
+			problems = []string{err.Error()}
+		}
+		if len(problems) > 0 {
+			broken = append(broken, fmt.Sprintf("example %q: %s", name, strings.Join(problems, ", ")))
+		}
+	}
+	return broken
+}
+
+// checkExamples returns an error if any example of the schema doesn't match
+// it. In warn mode the broken examples are returned as warnings instead.
+func (reg *SchemaRegistry) checkExamples(schema Schema) ([]string, error) {
+	broken := reg.brokenExamples(schema)
+	if len(broken) == 0 {
+		return nil, nil
+	}
+	if reg.settings().Examples == ExamplesWarn {
+		logger("registry").Warn("Schema breaks its examples", "schema", schema.Name, "examples", strings.Join(broken, "; "))
+		return broken, nil
+	}
+	return nil, newError("400", "schema %q breaks its examples: %s", schema.Name, strings.Join(broken, "; "))
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCheckExamples(t *testing.T) {
+	reg := NewSchemaRegistry(nil, nil)
+	schema := Schema{
+		Name: "orders",
+		Body: `{"type": "object", "required": ["id"], "properties": {"id": {"type": "integer"}}}`,
+		Examples: map[string]json.RawMessage{
+			"minimal": json.RawMessage(`{"id": 1}`),
+			"text_id": json.RawMessage(`{"id": "a1"}`),
+		},
+	}
+
+	broken := reg.brokenExamples(schema)
+	if len(broken) != 1 {
+		t.Fatalf("Expected one broken example, got %v", broken)
+	}
+
+	if _, err := reg.checkExamples(schema); errorCode(err) != "400" {
+		t.Errorf("Expected broken examples to be rejected, got %v", err)
+	}
+
+	reg.settings().Examples = ExamplesWarn
+	warnings, err := reg.checkExamples(schema)
+	if err != nil || len(warnings) != 1 {
+		t.Errorf("Expected broken examples to be warnings, got %v, %v", warnings, err)
+	}
+
+	delete(schema.Examples, "text_id")
+	if warnings, err := reg.checkExamples(schema); err != nil || warnings != nil {
+		t.Errorf("Expected matching examples to pass, got %v, %v", warnings, err)
+	}
+}
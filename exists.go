@@ -0,0 +1,173 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// keyCacheSize is the number of found keys cached.
+const keyCacheSize = 10000
+
+// ExistsRule requires a field of the payload to be a key in a KV bucket, such
+// as the customer_id of an order in the customers bucket. Payloads without the
+// field pass, requiring it is up to the body.
+type ExistsRule struct {
+	// Field is a JSON Pointer to the field, e.g. /customer_id.
+	Field  string `json:"field"`
+	Bucket string `json:"bucket"`
+	// FailOpen accepts payloads when the bucket can't be read, instead of
+	// rejecting them.
+	FailOpen bool `json:"fail_open,omitempty"`
+}
+
+// hasExistsRules returns true if a schema has rules looking keys up.
+func hasExistsRules(schema Schema) bool {
+	for _, rule := range schema.Rules {
+		if rule.Exists != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// checkExists returns an error if an exists rule is incomplete or reads a
+// bucket the registry isn't allowed to.
+func (reg *SchemaRegistry) checkExists(rule Rule) error {
+	if rule.Exists.Field == "" || rule.Exists.Bucket == "" {
+		return newError("400", "%s needs a field and a bucket", rule.describe())
+	}
+	if !contains(reg.referenceBuckets, rule.Exists.Bucket) {
+		return newError("403", "%s can't read bucket %q, see --reference-buckets", rule.describe(), rule.Exists.Bucket)
+	}
+	return nil
+}
+
+// existsViolation checks an exists rule against a decoded payload, and
+// returns a violation if it breaks the rule.
+func (reg *SchemaRegistry) existsViolation(rule Rule, doc interface{}) string {
+	value, err := resolvePointer(doc, rule.Exists.Field)
+	if err != nil {
+		return ""
+	}
+
+	var key string
+	switch v := value.(type) {
+	case string:
+		key = v
+	case float64:
+		key = strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprintf("%s: %s isn't a string or number", rule.describe(), rule.Exists.Field)
+	}
+
+	exists, err := reg.keys.Exists(reg.js, rule.Exists.Bucket, key)
+	if err != nil {
+		if rule.Exists.FailOpen {
+			logger("validation").Warn("Accepting payload despite the rule failing", "rule", rule.describe(), "error", err)
+			return ""
+		}
+		return fmt.Sprintf("%s failed: %v", rule.describe(), err)
+	}
+	if exists {
+		return ""
+	}
+	if rule.Message != "" {
+		return fmt.Sprintf("%s: %s", rule.describe(), rule.Message)
+	}
+	return fmt.Sprintf("%s: %q doesn't exist in bucket %q", rule.describe(), key, rule.Exists.Bucket)
+}
+
+// KeyCache remembers for a while which keys exist in KV buckets, so payloads
+// referencing the same keys don't look them up every time. Missing keys are
+// looked up again every time, as they are typically created right before
+// the first payload referencing them. A nil cache looks every key up.
+type KeyCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	buckets map[string]nats.KeyValue
+	// found are the times keys were last found
+	found map[string]time.Time
+}
+
+// NewKeyCache returns a cache remembering lookups for ttl, or nil if ttl is
+// zero.
+func NewKeyCache(ttl time.Duration) *KeyCache {
+	if ttl <= 0 {
+		return nil
+	}
+	return &KeyCache{ttl: ttl, buckets: map[string]nats.KeyValue{}, found: map[string]time.Time{}}
+}
+
+// Exists returns whether a key exists in a bucket. Keys that aren't valid in
+// a bucket don't exist.
+func (c *KeyCache) Exists(js nats.JetStreamContext, bucket, key string) (bool, error) {
+	id := bucket + "/" + key
+	if c != nil {
+		c.mu.Lock()
+		at, ok := c.found[id]
+		c.mu.Unlock()
+		if ok && time.Since(at) < c.ttl {
+			return true, nil
+		}
+	}
+
+	kv, err := c.bucket(js, bucket)
+	if err != nil {
+		return false, err
+	}
+	_, err = kv.Get(key)
+	exists := err == nil
+	if errors.Is(err, nats.ErrKeyNotFound) || errors.Is(err, nats.ErrInvalidKey) {
+		err = nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if exists && c != nil {
+		c.mu.Lock()
+		if len(c.found) >= keyCacheSize {
+			c.evict()
+		}
+		c.found[id] = time.Now()
+		c.mu.Unlock()
+	}
+	return exists, nil
+}
+
+// bucket returns a handle on a bucket, opening it the first time.
+func (c *KeyCache) bucket(js nats.JetStreamContext, name string) (nats.KeyValue, error) {
+	if c == nil {
+		return js.KeyValue(name)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if kv, ok := c.buckets[name]; ok {
+		return kv, nil
+	}
+	kv, err := js.KeyValue(name)
+	if err != nil {
+		return nil, err
+	}
+	c.buckets[name] = kv
+	return kv, nil
+}
+
+// evict drops the expired lookups, or every lookup if none expired.
+func (c *KeyCache) evict() {
+	for id, at := range c.found {
+		if time.Since(at) >= c.ttl {
+			delete(c.found, id)
+		}
+	}
+	if len(c.found) >= keyCacheSize {
+		c.found = map[string]time.Time{}
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import "testing"
+
+func TestCheckExists(t *testing.T) {
+	reg := NewSchemaRegistry(nil, nil)
+	reg.referenceBuckets = []string{"customers"}
+
+	tests := map[ExistsRule]string{
+		{Field: "/customer_id", Bucket: "customers"}: "",
+		{Field: "/customer_id", Bucket: "secrets"}:   "403",
+		{Bucket: "customers"}:                        "400",
+	}
+	for exists, code := range tests {
+		exists := exists
+		err := reg.checkExists(Rule{Exists: &exists})
+		if code == "" && err != nil {
+			t.Errorf("Expected %+v to be valid, got %v", exists, err)
+		}
+		if code != "" && errorCode(err) != code {
+			t.Errorf("Expected a %s error for %+v, got %v", code, exists, err)
+		}
+	}
+}
+
+func TestExistsViolationWithoutField(t *testing.T) {
+	reg := NewSchemaRegistry(nil, nil)
+	rule := Rule{Exists: &ExistsRule{Field: "/customer_id", Bucket: "customers"}}
+
+	if violation := reg.existsViolation(rule, map[string]interface{}{}); violation != "" {
+		t.Errorf("Expected payloads without the field to pass, got %q", violation)
+	}
+	if violation := reg.existsViolation(rule, map[string]interface{}{"customer_id": true}); violation == "" {
+		t.Errorf("Expected a violation for a boolean key")
+	}
+}
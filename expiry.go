@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// expiryCheckInterval is how often schemas are checked for expiry.
+const expiryCheckInterval = 10 * time.Second
+
+// expiryIdentity is who expired schemas are updated by.
+const expiryIdentity = "expiry"
+
+// Expiry retires a schema at a point in time, for contracts meant to be
+// temporary such as those of experiments.
+type Expiry struct {
+	At time.Time `json:"at"`
+	// State is the state the schema moves to, deprecated or disabled.
+	// Defaults to disabled.
+	State string `json:"state,omitempty"`
+}
+
+// checkExpiry returns an error if a schema's expiry can't be applied, and
+// fills in the default state.
+func checkExpiry(schema *Schema) error {
+	if schema.Expiry == nil {
+		return nil
+	}
+	if schema.Expiry.At.IsZero() {
+		return newError("400", "expiry of schema %q has no time", schema.Name)
+	}
+	if schema.Expiry.State == "" {
+		schema.Expiry.State = StateDisabled
+	}
+	if schema.Expiry.State != StateDeprecated && schema.Expiry.State != StateDisabled {
+		return newError("400", "schema %q can only expire to %s or %s, not %q", schema.Name, StateDeprecated, StateDisabled, schema.Expiry.State)
+	}
+	return nil
+}
+
+// expired returns true if a schema is past its expiry and not yet in the state
+// it expires to, or a later one.
+func expired(schema Schema, now time.Time) bool {
+	if schema.Expiry == nil || now.Before(schema.Expiry.At) {
+		return false
+	}
+	if schema.State == schema.Expiry.State || schema.State == StateDisabled {
+		return false
+	}
+	return CanTransition(schema.State, schema.Expiry.State)
+}
+
+// RunExpiry moves schemas past their expiry to the state they expire to, on
+// the instance leading the expiry job. It runs this in a goroutine and takes a
+// context for cancelation.
+func (reg *SchemaRegistry) RunExpiry(c context.Context) {
+	go func() {
+		ticker := time.NewTicker(expiryCheckInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-c.Done():
+				return
+			case now := <-ticker.C:
+				if reg.elector.Leading(JobExpiry) {
+					reg.expire(now)
+				}
+			}
+		}
+	}()
+}
+
+// stale returns true if a schema hasn't been updated for longer than the
+// registry keeps schemas.
+func (reg *SchemaRegistry) stale(schema Schema, now time.Time) bool {
+	return reg.schemaTTL > 0 && schema.Updated != nil && now.Sub(*schema.Updated) > reg.schemaTTL
+}
+
+// expire updates every expired schema and announces it with an expire event,
+// and unregisters the stale ones. Without a leader, instances race to expire a
+// schema and the losers' updates fail as concurrent modifications.
+func (reg *SchemaRegistry) expire(now time.Time) {
+	for _, schema := range reg.list() {
+		if reg.stale(schema, now) {
+			reg.removeStale(schema)
+			continue
+		}
+		if !expired(schema, now) {
+			continue
+		}
+
+		schema.State = schema.Expiry.State
+		schema.UpdatedBy = expiryIdentity
+		schema.RequestID = ""
+		updated, err := reg.update(schema)
+		// Locked schemas expire once they are unlocked, and every schema once
+		// maintenance ends
+		if code := errorCode(err); code == "409" || code == "423" || code == "503" {
+			continue
+		}
+		if err != nil {
+			logger("expiry").Error("error expiring schema", "schema", schema.Name, "error", err)
+			continue
+		}
+
+		logger("expiry").Info("Expired schema", "schema", schema.Name, "revision", updated.Revision, "state", updated.State)
+		reg.publishEvent(EventExpire, updated.Name, &updated)
+	}
+}
+
+// removeStale unregisters a schema that hasn't been updated for too long.
+// Like expiries, removals of locked and referenced schemas wait.
+func (reg *SchemaRegistry) removeStale(schema Schema) {
+	err := reg.unregister(schema.Name)
+	if code := errorCode(err); code == "409" || code == "423" || code == "503" {
+		return
+	}
+	if err != nil {
+		logger("expiry").Error("error removing stale schema", "schema", schema.Name, "error", err)
+		return
+	}
+	logger("expiry").Info("Removed stale schema", "schema", schema.Name, "revision", schema.Revision, "updated", schema.Updated)
+}
+
+// sameExpiry returns true if two expiries retire a schema at the same time to
+// the same state.
+func sameExpiry(a, b *Expiry) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	state := func(e *Expiry) string {
+		if e.State == "" {
+			return StateDisabled
+		}
+		return e.State
+	}
+	return a.At.Equal(b.At) && state(a) == state(b)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExpired(t *testing.T) {
+	now := time.Now()
+	past := &Expiry{At: now.Add(-time.Minute), State: StateDeprecated}
+	future := &Expiry{At: now.Add(time.Minute), State: StateDisabled}
+
+	tests := []struct {
+		schema  Schema
+		expired bool
+	}{
+		{Schema{State: StateActive}, false},
+		{Schema{State: StateActive, Expiry: future}, false},
+		{Schema{State: StateActive, Expiry: past}, true},
+		{Schema{State: StateDeprecated, Expiry: past}, false},
+		{Schema{State: StateDisabled, Expiry: past}, false},
+		{Schema{State: StateDraft, Expiry: past}, false},
+	}
+	for _, test := range tests {
+		if expired := expired(test.schema, now); expired != test.expired {
+			t.Errorf("Expected %s schema with expiry %+v to be expired: %v, got %v", test.schema.State, test.schema.Expiry, test.expired, expired)
+		}
+	}
+}
+
+func TestCheckExpiry(t *testing.T) {
+	schema := Schema{Name: "experiment", Expiry: &Expiry{At: time.Now()}}
+	if err := checkExpiry(&schema); err != nil || schema.Expiry.State != StateDisabled {
+		t.Errorf("Expected the expiry to default to disabled, got %+v, %v", schema.Expiry, err)
+	}
+	if !sameExpiry(schema.Expiry, &Expiry{At: schema.Expiry.At}) {
+		t.Errorf("Expected the default state to be the same as disabled")
+	}
+
+	schema.Expiry.State = StateActive
+	if err := checkExpiry(&schema); errorCode(err) != "400" {
+		t.Errorf("Expected expiring to active to fail with 400, got %v", err)
+	}
+	schema.Expiry = &Expiry{State: StateDeprecated}
+	if err := checkExpiry(&schema); errorCode(err) != "400" {
+		t.Errorf("Expected an expiry without a time to fail with 400, got %v", err)
+	}
+}
+
+func TestExpireStale(t *testing.T) {
+	reg := NewSchemaRegistry(newMemKV(), nil)
+	reg.schemaTTL = time.Hour
+	now := time.Now()
+
+	for _, name := range []string{"fresh", "stale", "locked"} {
+		schema, err := reg.register(Schema{Name: name, Subject: name, Body: `{"type": "object"}`})
+		if err != nil {
+			t.Fatal(err)
+		}
+		updated := now.Add(-2 * time.Hour)
+		if name == "fresh" {
+			updated = now.Add(-time.Minute)
+		}
+		schema.Updated = &updated
+		reg.remember(schema)
+	}
+	if _, err := reg.lock("locked", Lock{Reason: "freeze"}); err != nil {
+		t.Fatal(err)
+	}
+
+	reg.expire(now)
+	for name, kept := range map[string]bool{"fresh": true, "stale": false, "locked": true} {
+		_, exists, err := reg.current(name)
+		if err != nil || exists != kept {
+			t.Errorf("Expected schema %s to be kept: %v, got %v %v", name, kept, exists, err)
+		}
+	}
+	if _, err := reg.nextID(); err != nil {
+		t.Errorf("Expected the id counter to outlive the schemas, got %v", err)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/micro"
+)
+
+// maxFailurePayload caps how much of a rejected payload is kept.
+const maxFailurePayload = 4096
+
+// Failure is a payload that was rejected by a schema.
+type Failure struct {
+	Time      time.Time `json:"time"`
+	Subject   string    `json:"subject"`
+	Revision  uint64    `json:"revision"`
+	Payload   string    `json:"payload"`
+	Truncated bool      `json:"truncated,omitempty"`
+	Error     string    `json:"error"`
+	// RequestID traces the rejected message back to its producer.
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// FailureLog keeps the most recent rejected payloads of every schema.
+type FailureLog struct {
+	mu       sync.Mutex
+	size     int
+	failures map[string][]Failure
+}
+
+func NewFailureLog(size int) *FailureLog {
+	return &FailureLog{
+		size:     size,
+		failures: map[string][]Failure{},
+	}
+}
+
+// Add records a failure for a schema, dropping the oldest one once the log
+// for that schema is full.
+func (l *FailureLog) Add(name string, f Failure) {
+	if l == nil || l.size <= 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	failures := append(l.failures[name], f)
+	if len(failures) > l.size {
+		failures = failures[len(failures)-l.size:]
+	}
+	l.failures[name] = failures
+}
+
+// List returns the recorded failures of a schema, oldest first.
+func (l *FailureLog) List(name string) []Failure {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return append([]Failure(nil), l.failures[name]...)
+}
+
+// Len returns the number of failures recorded across all schemas.
+func (l *FailureLog) Len() int {
+	if l == nil {
+		return 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	n := 0
+	for _, failures := range l.failures {
+		n += len(failures)
+	}
+	return n
+}
+
+// reject records a validation failure and responds with it.
+func (reg *SchemaRegistry) reject(m *nats.Msg, schema Schema, subject string, err error) {
+	reg.recordRejection(m, schema, subject, err)
+	requestLogger(m, schema, subject).Debug("Rejected message", "error", err)
+
+	code := "400"
+	var regErr *RegistryError
+	if errors.As(err, &regErr) {
+		code = regErr.Code
+	}
+	reg.respondErrorHeader(m, code, reg.renderError(err, m.Header.Get(SchemaErrorsHeader)), failedItemsHeader(err))
+}
+
+// recordRejection counts a validation failure, keeps it in the recent failures
+// and dead-letters the message if a dead-letter stream is configured.
+func (reg *SchemaRegistry) recordRejection(m *nats.Msg, schema Schema, subject string, err error) {
+	payload := m.Data
+	truncated := len(payload) > maxFailurePayload
+	if truncated {
+		payload = payload[:maxFailurePayload]
+	}
+
+	reg.stats.Record(schema.Name, false)
+	reg.failures.Add(schema.Name, Failure{
+		Time:      time.Now().UTC(),
+		Subject:   subject,
+		Revision:  schema.Revision,
+		Payload:   string(payload),
+		Truncated: truncated,
+		Error:     err.Error(),
+		RequestID: m.Header.Get(SchemaRequestIDHeader),
+	})
+
+	if reg.deadLetter != "" {
+		if err := reg.deadLetterMsg(m, schema, subject, err); err != nil {
+			requestLogger(m, schema, subject).Error("error dead-lettering message", "error", err)
+		}
+	}
+}
+
+// Failures subject: $SCHEMA.FAILURES.<schema_name>
+func (reg *SchemaRegistry) GetFailures(r micro.Request) {
+	name := subjectName(r.Subject())
+
+	failures := reg.failures.List(name)
+	if failures == nil {
+		failures = []Failure{}
+	}
+	r.RespondJSON(failures)
+}
@@ -0,0 +1,22 @@
+package main
+
+import "testing"
+
+func TestFailureLogKeepsMostRecent(t *testing.T) {
+	log := NewFailureLog(2)
+	log.Add("orders", Failure{Payload: "1"})
+	log.Add("orders", Failure{Payload: "2"})
+	log.Add("orders", Failure{Payload: "3"})
+
+	failures := log.List("orders")
+	if len(failures) != 2 {
+		t.Fatalf("Expected 2 failures, got %d", len(failures))
+	}
+	if failures[0].Payload != "2" || failures[1].Payload != "3" {
+		t.Errorf("Expected the most recent failures, got %+v", failures)
+	}
+
+	if len(log.List("payments")) != 0 {
+		t.Errorf("Expected no failures for another schema")
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/micro"
+)
+
+// Fingerprint returns the hex encoded SHA-256 hash of a schema body. JSON
+// bodies are hashed in their canonical form, so formatting and key order don't
+// change the fingerprint.
+func Fingerprint(body string) string {
+	canonical := []byte(body)
+
+	var v interface{}
+	if err := json.Unmarshal([]byte(body), &v); err == nil {
+		if data, err := json.Marshal(v); err == nil {
+			canonical = data
+		}
+	}
+
+	sum := sha256.Sum256(canonical)
+	return hex.EncodeToString(sum[:])
+}
+
+// fingerprintKey returns the kv key indexing the schema with a fingerprint.
+func fingerprintKey(fingerprint string) string {
+	return "_fp." + fingerprint
+}
+
+// indexFingerprint records the stored schema under its fingerprint.
+func (reg *SchemaRegistry) indexFingerprint(schema Schema) error {
+	data, err := json.Marshal(schema)
+	if err != nil {
+		return err
+	}
+	_, err = reg.kv.Put(fingerprintKey(schema.Fingerprint), data)
+	return err
+}
+
+// Get by fingerprint subject: $SCHEMA.GETBYFP.<fingerprint>
+func (reg *SchemaRegistry) GetByFingerprint(r micro.Request) {
+	parts := strings.Split(r.Subject(), ".")
+	fingerprint := parts[len(parts)-1]
+
+	entry, err := reg.kv.Get(fingerprintKey(fingerprint))
+	if errors.Is(err, nats.ErrKeyNotFound) {
+		r.Error("404", "Not found", nil)
+		return
+	}
+	if err != nil {
+		r.Error("500", err.Error(), nil)
+		return
+	}
+
+	r.Respond(entry.Value())
+}
@@ -0,0 +1,18 @@
+package main
+
+import "testing"
+
+func TestFingerprint(t *testing.T) {
+	a := Fingerprint(`{"type": "object", "required": ["id"]}`)
+	b := Fingerprint(`{"required":["id"],"type":"object"}`)
+	if a != b {
+		t.Errorf("Expected formatting and key order to not change the fingerprint")
+	}
+
+	if a == Fingerprint(`{"type": "object"}`) {
+		t.Errorf("Expected different bodies to have different fingerprints")
+	}
+	if len(a) != 64 {
+		t.Errorf("Expected a hex encoded SHA-256 fingerprint, got %q", a)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// errDeadLettered marks forwarding failures of messages that were stored in the
+// dead-letter stream instead, so they aren't lost.
+var errDeadLettered = errors.New("stored in the dead-letter stream")
+
+// ForwardStats counts the retries and failures of forwarding validated
+// messages.
+type ForwardStats struct {
+	Retries  uint64 `json:"retries"`
+	Failures uint64 `json:"failures"`
+}
+
+type forwardCounters struct {
+	retries  atomic.Uint64
+	failures atomic.Uint64
+}
+
+func (c *forwardCounters) Get() ForwardStats {
+	return ForwardStats{Retries: c.retries.Load(), Failures: c.failures.Load()}
+}
+
+// forward publishes a validated message, retrying failures with exponential
+// backoff. Once the retries are exhausted the message is stored in the
+// dead-letter stream, if there is one.
+func (reg *SchemaRegistry) forward(m *nats.Msg, schema Schema, subject string, publish func() error) error {
+	backoff := reg.forwardBackoff
+	err := publish()
+	for attempt := 0; err != nil && retryable(err) && attempt < reg.forwardRetries; attempt++ {
+		reg.forwarded.retries.Add(1)
+		requestLogger(m, schema, subject).Warn("error forwarding message, retrying", "backoff", backoff, "error", err)
+		time.Sleep(backoff)
+		backoff *= 2
+		err = publish()
+	}
+	if err == nil {
+		return nil
+	}
+
+	reg.forwarded.failures.Add(1)
+	if reg.deadLetter == "" {
+		return err
+	}
+	dlErr := reg.deadLetterMsg(m, schema, subject, fmt.Errorf("forwarding failed: %w", err))
+	if dlErr != nil {
+		requestLogger(m, schema, subject).Error("error dead-lettering message", "error", dlErr)
+		return err
+	}
+	return fmt.Errorf("forwarding failed, %w: %w", errDeadLettered, err)
+}
+
+// retryable returns false for errors JetStream answered with, such as a
+// rejected duplicate or a full stream, as publishing again gives the same
+// answer.
+func retryable(err error) bool {
+	var jsErr nats.JetStreamError
+	return !errors.As(err, &jsErr) || jsErr.APIError() == nil
+}
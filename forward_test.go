@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+func TestForwardRetries(t *testing.T) {
+	reg := NewSchemaRegistry(nil, nil)
+	reg.forwardRetries = 2
+	reg.forwardBackoff = time.Millisecond
+
+	attempts := 0
+	err := reg.forward(nil, Schema{}, "orders", func() error {
+		attempts++
+		if attempts < 3 {
+			return nats.ErrConnectionReconnecting
+		}
+		return nil
+	})
+	if err != nil || attempts != 3 {
+		t.Errorf("Expected the third attempt to succeed, got %v after %d attempts", err, attempts)
+	}
+
+	attempts = 0
+	err = reg.forward(nil, Schema{}, "orders", func() error {
+		attempts++
+		return errors.New("connection closed")
+	})
+	if err == nil || attempts != 3 {
+		t.Errorf("Expected to give up after 3 attempts, got %v after %d attempts", err, attempts)
+	}
+
+	attempts = 0
+	reg.forward(nil, Schema{}, "orders", func() error {
+		attempts++
+		return &nats.APIError{Code: 503, ErrorCode: nats.JSErrCodeStreamNotFound}
+	})
+	if attempts != 1 {
+		t.Errorf("Expected JetStream errors not to be retried, got %d attempts", attempts)
+	}
+
+	stats := reg.forwarded.Get()
+	if stats.Retries != 4 || stats.Failures != 2 {
+		t.Errorf("Expected 4 retries and 2 failures, got %+v", stats)
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/nats-io/nats.go/micro"
+)
+
+// Graph formats.
+const (
+	GraphDOT     = "dot"
+	GraphMermaid = "mermaid"
+)
+
+// graphEdge connects two nodes of the schema graph. Nodes are schemas, or
+// subjects when the name starts with subjectNode.
+type graphEdge struct {
+	from, to string
+	label    string
+}
+
+// subjectNode prefixes the subject nodes of the schema graph, to tell them
+// apart from schemas.
+const subjectNode = ">"
+
+// schemaGraph returns the nodes and edges of the graph of schemas: the
+// subjects they are bound to, where their messages are forwarded to and the
+// schemas they reference. Everything is sorted, so the same schemas always
+// render the same graph.
+func schemaGraph(schemas []Schema) ([]string, []graphEdge) {
+	nodes := map[string]bool{}
+	var edges []graphEdge
+	for _, schema := range schemas {
+		nodes[schema.Name] = true
+		if schema.Subject != "" {
+			nodes[subjectNode+schema.Subject] = true
+			edges = append(edges, graphEdge{schema.Name, subjectNode + schema.Subject, "validates"})
+			if schema.Destination != "" {
+				nodes[subjectNode+schema.Destination] = true
+				edges = append(edges, graphEdge{subjectNode + schema.Subject, subjectNode + schema.Destination, "forwards"})
+			}
+		}
+		for _, ref := range registryRefs(schema.Body) {
+			nodes[ref] = true
+			edges = append(edges, graphEdge{schema.Name, ref, "$ref"})
+		}
+	}
+
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].from != edges[j].from {
+			return edges[i].from < edges[j].from
+		}
+		return edges[i].to < edges[j].to
+	})
+	return sortedKeys(nodes), edges
+}
+
+// RenderGraph renders the graph of schemas in the DOT or Mermaid format.
+func RenderGraph(schemas []Schema, format string) (string, error) {
+	nodes, edges := schemaGraph(schemas)
+	switch format {
+	case GraphDOT:
+		return renderDOT(nodes, edges), nil
+	case GraphMermaid:
+		return renderMermaid(nodes, edges), nil
+	}
+	return "", newError("400", "unknown graph format %q, use %s or %s", format, GraphDOT, GraphMermaid)
+}
+
+func renderDOT(nodes []string, edges []graphEdge) string {
+	var b strings.Builder
+	b.WriteString("digraph schemas {\n")
+	b.WriteString("  rankdir=LR;\n")
+	for _, node := range nodes {
+		label, subject := graphNode(node)
+		shape := "box"
+		if subject {
+			shape = "ellipse"
+		}
+		fmt.Fprintf(&b, "  %q [label=%q, shape=%s];\n", node, label, shape)
+	}
+	for _, edge := range edges {
+		fmt.Fprintf(&b, "  %q -> %q [label=%q];\n", edge.from, edge.to, edge.label)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+func renderMermaid(nodes []string, edges []graphEdge) string {
+	// Mermaid ids can't contain most punctuation, so nodes are numbered
+	ids := map[string]string{}
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+	for i, node := range nodes {
+		ids[node] = fmt.Sprintf("n%d", i)
+		label, subject := graphNode(node)
+		label = strings.ReplaceAll(label, `"`, "#quot;")
+		if subject {
+			fmt.Fprintf(&b, "  %s([\"%s\"])\n", ids[node], label)
+		} else {
+			fmt.Fprintf(&b, "  %s[\"%s\"]\n", ids[node], label)
+		}
+	}
+	for _, edge := range edges {
+		fmt.Fprintf(&b, "  %s -->|%s| %s\n", ids[edge.from], edge.label, ids[edge.to])
+	}
+	return b.String()
+}
+
+// graphNode returns the label of a node, and whether it is a subject.
+func graphNode(node string) (string, bool) {
+	if subject, ok := strings.CutPrefix(node, subjectNode); ok {
+		return subject, true
+	}
+	return node, false
+}
+
+// Graph subject: $SCHEMA.GRAPH.<format>
+func (reg *SchemaRegistry) Graph(r micro.Request) {
+	format := subjectName(r.Subject())
+
+	graph, err := RenderGraph(reg.list(), format)
+	if err != nil {
+		r.Error(errorCode(err), err.Error(), nil)
+		return
+	}
+
+	r.Respond([]byte(graph))
+}
+
+// PrintGraph writes the graph of the registered schemas in a format.
+func PrintGraph(cfg Config, format string, w io.Writer) error {
+	nc, _, kv, err := OpenBucket(cfg)
+	if err != nil {
+		return err
+	}
+	defer nc.Close()
+
+	schemas, err := loadSchemas(kv)
+	if err != nil {
+		return err
+	}
+
+	graph, err := RenderGraph(schemas, format)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, graph)
+	return err
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderGraph(t *testing.T) {
+	schemas := []Schema{
+		{Name: "orders", Subject: "raw.orders.*", Destination: "orders.*", Body: `{"properties": {"total": {"$ref": "schema:defs_money"}}}`},
+		{Name: "defs_money", Body: `{"type": "number"}`},
+	}
+
+	dot, err := RenderGraph(schemas, GraphDOT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		`">raw.orders.*" [label="raw.orders.*", shape=ellipse];`,
+		`"defs_money" [label="defs_money", shape=box];`,
+		`"orders" -> ">raw.orders.*" [label="validates"];`,
+		`">raw.orders.*" -> ">orders.*" [label="forwards"];`,
+		`"orders" -> "defs_money" [label="$ref"];`,
+	} {
+		if !strings.Contains(dot, line) {
+			t.Errorf("Expected the DOT graph to contain %s, got:\n%s", line, dot)
+		}
+	}
+
+	mermaid, err := RenderGraph(schemas, GraphMermaid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `flowchart LR
+  n0(["orders.*"])
+  n1(["raw.orders.*"])
+  n2["defs_money"]
+  n3["orders"]
+  n1 -->|forwards| n0
+  n3 -->|validates| n1
+  n3 -->|$ref| n2
+`
+	if mermaid != want {
+		t.Errorf("Expected the Mermaid graph\n%s\ngot\n%s", want, mermaid)
+	}
+
+	if _, err := RenderGraph(schemas, "svg"); errorCode(err) != "400" {
+		t.Errorf("Expected an unknown format to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+)
+
+// GraphQLRequest is a GraphQL query posted to the gateway.
+type GraphQLRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+// sourceName returns the name of the schema a field is resolved on, a Schema
+// when queried directly and a *Schema in revisions.
+func sourceName(source interface{}) string {
+	switch schema := source.(type) {
+	case Schema:
+		return schema.Name
+	case *Schema:
+		if schema != nil {
+			return schema.Name
+		}
+	}
+	return ""
+}
+
+// NewGraphQLSchema returns the GraphQL schema of the registry, to query
+// schemas with their revisions, stats and dependencies in one request. Fields
+// are named like in the JSON API. It's read only, changes go through the
+// other endpoints.
+func NewGraphQLSchema(reg *SchemaRegistry) (graphql.Schema, error) {
+	stats := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Stats",
+		Fields: graphql.Fields{
+			"passed": &graphql.Field{Type: graphql.Int},
+			"failed": &graphql.Field{Type: graphql.Int},
+			"last": &graphql.Field{
+				Type: graphql.DateTime,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if last := p.Source.(SchemaStats).Last; !last.IsZero() {
+						return last, nil
+					}
+					return nil, nil
+				},
+			},
+		},
+	})
+
+	schema := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Schema",
+		Fields: graphql.Fields{
+			"name":        &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"subject":     &graphql.Field{Type: graphql.String},
+			"revision":    &graphql.Field{Type: graphql.Int},
+			"type":        &graphql.Field{Type: graphql.String},
+			"body":        &graphql.Field{Type: graphql.String},
+			"state":       &graphql.Field{Type: graphql.String},
+			"version":     &graphql.Field{Type: graphql.Int},
+			"semver":      &graphql.Field{Type: graphql.String},
+			"message":     &graphql.Field{Type: graphql.String},
+			"tags":        &graphql.Field{Type: graphql.NewList(graphql.String)},
+			"destination": &graphql.Field{Type: graphql.String},
+			"jetstream":   &graphql.Field{Type: graphql.Boolean},
+			"fingerprint": &graphql.Field{Type: graphql.String},
+			"id":          &graphql.Field{Type: graphql.Int},
+			"created_by":  &graphql.Field{Type: graphql.String},
+			"updated_by":  &graphql.Field{Type: graphql.String},
+			"request_id":  &graphql.Field{Type: graphql.String},
+			"last_used":   &graphql.Field{Type: graphql.DateTime},
+			"updated":     &graphql.Field{Type: graphql.DateTime},
+			"stats": &graphql.Field{
+				Type: stats,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return reg.stats.Get(sourceName(p.Source)), nil
+				},
+			},
+			"dependencies": &graphql.Field{
+				Type:        graphql.NewList(graphql.String),
+				Description: "The schemas it references, directly or through other schemas.",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return reg.dependencies(sourceName(p.Source)), nil
+				},
+			},
+			"dependents": &graphql.Field{
+				Type:        graphql.NewList(graphql.String),
+				Description: "The schemas referencing it, directly or through other schemas.",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return dependentNames(reg.dependents(sourceName(p.Source))), nil
+				},
+			},
+		},
+	})
+
+	// Revisions hold the schema as of each mutation, so the field is added
+	// once the type exists
+	revision := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Revision",
+		Fields: graphql.Fields{
+			"revision":  &graphql.Field{Type: graphql.Int},
+			"operation": &graphql.Field{Type: graphql.String},
+			"time":      &graphql.Field{Type: graphql.DateTime},
+			"schema":    &graphql.Field{Type: schema},
+		},
+	})
+	schema.AddFieldConfig("revisions", &graphql.Field{
+		Type:        graphql.NewList(revision),
+		Description: "Every mutation of the schema, oldest first.",
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return reg.history(sourceName(p.Source))
+		},
+	})
+
+	hit := graphql.NewObject(graphql.ObjectConfig{
+		Name: "SearchHit",
+		Fields: graphql.Fields{
+			"name":    &graphql.Field{Type: graphql.String},
+			"subject": &graphql.Field{Type: graphql.String},
+			"matches": &graphql.Field{Type: graphql.NewList(graphql.String)},
+		},
+	})
+
+	edge := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Edge",
+		Fields: graphql.Fields{
+			"from":  &graphql.Field{Type: graphql.String},
+			"to":    &graphql.Field{Type: graphql.String},
+			"label": &graphql.Field{Type: graphql.String},
+		},
+	})
+	graph := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Graph",
+		Fields: graphql.Fields{
+			"nodes": &graphql.Field{Type: graphql.NewList(graphql.String)},
+			"edges": &graphql.Field{Type: graphql.NewList(edge)},
+		},
+	})
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"schema": &graphql.Field{
+				Type: schema,
+				Args: graphql.FieldConfigArgument{
+					"name": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					schema, exists, err := reg.current(p.Args["name"].(string))
+					if err != nil || !exists {
+						return nil, err
+					}
+					return reg.withUsage(schema), nil
+				},
+			},
+			"schemas": &graphql.Field{
+				Type:        graphql.NewList(schema),
+				Description: "The schemas sorted by name, optionally those whose names start with prefix, with a tag or in a state.",
+				Args: graphql.FieldConfigArgument{
+					"prefix": &graphql.ArgumentConfig{Type: graphql.String},
+					"tag":    &graphql.ArgumentConfig{Type: graphql.String},
+					"state":  &graphql.ArgumentConfig{Type: graphql.String},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					prefix, _ := p.Args["prefix"].(string)
+					tag, _ := p.Args["tag"].(string)
+					state, _ := p.Args["state"].(string)
+
+					var schemas []Schema
+					for _, schema := range reg.list() {
+						if !strings.HasPrefix(schema.Name, prefix) ||
+							(tag != "" && !contains(schema.Tags, tag)) ||
+							(state != "" && schema.State != state) {
+							continue
+						}
+						schemas = append(schemas, reg.withUsage(schema))
+					}
+					return schemas, nil
+				},
+			},
+			"search": &graphql.Field{
+				Type: graphql.NewList(hit),
+				Args: graphql.FieldConfigArgument{
+					"query": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return reg.search.Search(p.Args["query"].(string)), nil
+				},
+			},
+			"graph": &graphql.Field{
+				Type:        graph,
+				Description: "The subjects schemas are bound to, where they forward to and the schemas they reference.",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					nodes, edges := schemaGraph(reg.list())
+					out := make([]map[string]string, len(edges))
+					for i, e := range edges {
+						out[i] = map[string]string{"from": e.from, "to": e.to, "label": e.label}
+					}
+					return map[string]interface{}{"nodes": nodes, "edges": out}, nil
+				},
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: query})
+}
+
+// POST /api/graphql, or GET with the query as ?query=
+func (gw *Gateway) graphql(w http.ResponseWriter, r *http.Request) {
+	var req GraphQLRequest
+	switch r.Method {
+	case http.MethodGet:
+		req.Query = r.URL.Query().Get("query")
+		req.OperationName = r.URL.Query().Get("operationName")
+		if vars := r.URL.Query().Get("variables"); vars != "" {
+			if err := json.Unmarshal([]byte(vars), &req.Variables); err != nil {
+				writeError(w, newError("400", err.Error()))
+				return
+			}
+		}
+	case http.MethodPost:
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, newError("400", err.Error()))
+			return
+		}
+	default:
+		writeError(w, newError("405", "method not allowed"))
+		return
+	}
+
+	writeJSON(w, graphql.Do(graphql.Params{
+		Schema:         gw.graphqlSchema,
+		RequestString:  req.Query,
+		OperationName:  req.OperationName,
+		VariableValues: req.Variables,
+		Context:        r.Context(),
+	}))
+}
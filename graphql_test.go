@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGraphQLSchemas(t *testing.T) {
+	reg := NewSchemaRegistry(nil, nil)
+	reg.schemas = map[string]Schema{
+		"defs_money": {Name: "defs_money", Body: `{"type": "number"}`, State: StateActive},
+		"orders":     {Name: "orders", Subject: "orders", Body: `{"type": "object", "properties": {"total": {"$ref": "schema:defs_money"}}}`, State: StateActive, Tags: []string{"billing"}},
+		"invoices":   {Name: "invoices", Subject: "invoices", Body: `{"type": "object"}`, State: StateDeprecated},
+	}
+	reg.stats.Record("orders", true)
+	reg.stats.Record("orders", false)
+	gw := NewGateway(reg)
+
+	body := `{"query": "query($tag: String) { schemas(tag: $tag) { name subject dependencies stats { passed failed } } graph { edges { from to label } } }", "variables": {"tag": "billing"}}`
+	rec := httptest.NewRecorder()
+	gw.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/graphql", strings.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+
+	var resp struct {
+		Data struct {
+			Schemas []struct {
+				Name         string   `json:"name"`
+				Subject      string   `json:"subject"`
+				Dependencies []string `json:"dependencies"`
+				Stats        struct {
+					Passed int `json:"passed"`
+					Failed int `json:"failed"`
+				} `json:"stats"`
+			} `json:"schemas"`
+			Graph struct {
+				Edges []map[string]string `json:"edges"`
+			} `json:"graph"`
+		} `json:"data"`
+		Errors []interface{} `json:"errors"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Errors) != 0 {
+		t.Fatalf("Expected no errors, got %v", resp.Errors)
+	}
+	if len(resp.Data.Schemas) != 1 || resp.Data.Schemas[0].Name != "orders" {
+		t.Fatalf("Expected only the tagged schema, got %+v", resp.Data.Schemas)
+	}
+	orders := resp.Data.Schemas[0]
+	if !reflect.DeepEqual(orders.Dependencies, []string{"defs_money"}) {
+		t.Errorf("Expected orders to depend on defs_money, got %v", orders.Dependencies)
+	}
+	if orders.Stats.Passed != 1 || orders.Stats.Failed != 1 {
+		t.Errorf("Expected the validation counts, got %+v", orders.Stats)
+	}
+	if len(resp.Data.Graph.Edges) != 3 {
+		t.Errorf("Expected an edge per subject and reference, got %v", resp.Data.Graph.Edges)
+	}
+}
+
+func TestGraphQLInvalidQuery(t *testing.T) {
+	gw := NewGateway(NewSchemaRegistry(nil, nil))
+
+	rec := httptest.NewRecorder()
+	gw.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/graphql?query={+missing+}", nil))
+	if !strings.Contains(rec.Body.String(), `"errors"`) {
+		t.Errorf("Expected the query to be rejected, got %s", rec.Body)
+	}
+}
+
+func TestGraphQLRevisions(t *testing.T) {
+	reg := NewSchemaRegistry(newMemKV(), nil)
+	for _, schema := range []Schema{
+		{Name: "orders", Subject: "orders", Body: `{"type": "object"}`},
+		{Name: "invoices", Subject: "invoices", Body: `{"type": "object", "properties": {"order": {"$ref": "schema:orders"}}}`},
+	} {
+		schema, err := reg.register(schema)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reg.remember(schema)
+	}
+	reg.stats.Record("orders", true)
+	gw := NewGateway(reg)
+
+	body := `{"query": "{ schema(name: \"orders\") { revisions { schema { stats { passed } dependents } } } }"}`
+	rec := httptest.NewRecorder()
+	gw.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/graphql", strings.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+
+	var resp struct {
+		Data struct {
+			Schema struct {
+				Revisions []struct {
+					Schema struct {
+						Stats struct {
+							Passed int `json:"passed"`
+						} `json:"stats"`
+						Dependents []string `json:"dependents"`
+					} `json:"schema"`
+				} `json:"revisions"`
+			} `json:"schema"`
+		} `json:"data"`
+		Errors []interface{} `json:"errors"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Errors) > 0 {
+		t.Fatalf("Expected no errors, got %v", resp.Errors)
+	}
+	revisions := resp.Data.Schema.Revisions
+	if len(revisions) != 1 {
+		t.Fatalf("Expected one revision, got %s", rec.Body)
+	}
+	if revisions[0].Schema.Stats.Passed != 1 || !reflect.DeepEqual(revisions[0].Schema.Dependents, []string{"invoices"}) {
+		t.Errorf("Expected the revision's stats and dependents, got %s", rec.Body)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/nats-io/nats.go"
+)
+
+// headerDocument converts message headers into a JSON object for validation.
+// Headers with a single value become strings, repeated headers arrays of
+// strings.
+func headerDocument(headers nats.Header) ([]byte, error) {
+	doc := map[string]interface{}{}
+	for name, values := range headers {
+		if len(values) == 1 {
+			doc[name] = values[0]
+		} else {
+			doc[name] = values
+		}
+	}
+	return json.Marshal(doc)
+}
+
+// validateHeaders validates message headers against a header schema body.
+func (reg *SchemaRegistry) validateHeaders(headers nats.Header, body string) error {
+	doc, err := headerDocument(headers)
+	if err != nil {
+		return err
+	}
+
+	violations, err := violations(doc, body)
+	if err != nil {
+		return err
+	}
+	if len(violations) > 0 {
+		return &ValidationError{What: "headers", Violations: violations}
+	}
+
+	return nil
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/nats-io/nats.go"
+)
+
+func TestValidateHeaders(t *testing.T) {
+	reg := &SchemaRegistry{}
+	body := `{
+		"type": "object",
+		"required": ["Idempotency-Key"],
+		"properties": {"Idempotency-Key": {"type": "string", "format": "uuid"}}
+	}`
+
+	headers := nats.Header{}
+	headers.Set("Idempotency-Key", "6f1f1c3e-1b7a-4a8e-9b3a-2f0c6d7e8a9b")
+	if err := reg.validateHeaders(headers, body); err != nil {
+		t.Errorf("Expected headers to be valid: %v", err)
+	}
+
+	headers.Set("Idempotency-Key", "not-a-uuid")
+	if reg.validateHeaders(headers, body) == nil {
+		t.Errorf("Expected malformed header to be rejected")
+	}
+
+	if reg.validateHeaders(nats.Header{}, body) == nil {
+		t.Errorf("Expected missing header to be rejected")
+	}
+}
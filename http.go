@@ -0,0 +1,315 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nuid"
+)
+
+// Gateway exposes the registry over HTTP as a JSON API, for tooling that
+// doesn't speak NATS.
+type Gateway struct {
+	reg           *SchemaRegistry
+	mux           *http.ServeMux
+	graphqlSchema graphql.Schema
+}
+
+func NewGateway(reg *SchemaRegistry) *Gateway {
+	schema, err := NewGraphQLSchema(reg)
+	if err != nil {
+		// The schema is static, so this is a bug
+		panic(err)
+	}
+	gw := &Gateway{
+		reg:           reg,
+		mux:           http.NewServeMux(),
+		graphqlSchema: schema,
+	}
+	gw.mux.HandleFunc("/api/schemas", gw.schemas)
+	gw.mux.HandleFunc("/api/schemas/", gw.schema)
+	gw.mux.HandleFunc("/api/ndjson/", gw.ndjson)
+	gw.mux.HandleFunc("/api/graphql", gw.graphql)
+	return gw
+}
+
+func (gw *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Only NATS servers set the client information header, HTTP clients
+	// authenticate with tokens
+	r.Header.Del(requestInfoHeader)
+	if r.Header.Get(SchemaRequestIDHeader) == "" {
+		r.Header.Set(SchemaRequestIDHeader, nuid.Next())
+	}
+	w.Header().Set(SchemaRequestIDHeader, r.Header.Get(SchemaRequestIDHeader))
+	gw.mux.ServeHTTP(w, r)
+}
+
+// Handle adds a handler to the gateway, for optional extras like the UI.
+func (gw *Gateway) Handle(pattern string, handler http.Handler) {
+	gw.mux.Handle(pattern, handler)
+}
+
+// GET /api/schemas
+func (gw *Gateway) schemas(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, newError("405", "method not allowed"))
+		return
+	}
+
+	schemas := gw.reg.list()
+	for i, schema := range schemas {
+		schemas[i] = gw.reg.withUsage(schema)
+	}
+	writeJSON(w, schemas)
+}
+
+// /api/schemas/<name>[/history|/diff|/changelog|/timeline|/stats|/try]
+func (gw *Gateway) schema(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/schemas/"), "/")
+	name, action, _ := strings.Cut(path, "/")
+	if name == "" {
+		writeError(w, newError("404", "Not found"))
+		return
+	}
+
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		schema, exists, err := gw.reg.current(name)
+		if err == nil && !exists {
+			err = newError("404", "Not found")
+		}
+		if err == nil && r.URL.Query().Get("bundle") == "true" {
+			schema.Body, err = Bundle(schema.Body, gw.reg.lookupBody)
+		}
+		writeResult(w, gw.reg.withUsage(schema), err)
+	case action == "" && (r.Method == http.MethodPost || r.Method == http.MethodPut):
+		gw.store(w, r, name)
+	case action == "" && r.Method == http.MethodDelete:
+		if !gw.authorized(w, r, name) {
+			return
+		}
+		_, err := gw.reg.confirmUnregister(name, r.Header.Get(SchemaConfirmHeader))
+		if err == nil {
+			err = gw.reg.unregister(name)
+		}
+		writeResult(w, nil, err)
+	case action == "history" && r.Method == http.MethodGet:
+		history, err := gw.reg.history(name)
+		writeResult(w, history, err)
+	case action == "diff" && r.Method == http.MethodGet:
+		gw.diff(w, r, name)
+	case action == "changelog" && r.Method == http.MethodGet:
+		gw.changelog(w, r, name)
+	case action == "timeline" && r.Method == http.MethodGet:
+		gw.timeline(w, name)
+	case action == "stats" && r.Method == http.MethodGet:
+		writeJSON(w, gw.reg.stats.Get(name))
+	case action == "try" && r.Method == http.MethodPost:
+		gw.try(w, r, name)
+	default:
+		writeError(w, newError("404", "Not found"))
+	}
+}
+
+// POST registers, PUT updates /api/schemas/<name>
+func (gw *Gateway) store(w http.ResponseWriter, r *http.Request, name string) {
+	if !gw.authorized(w, r, name) {
+		return
+	}
+
+	var schema Schema
+	err := json.NewDecoder(r.Body).Decode(&schema)
+	if err != nil {
+		writeError(w, newError("400", err.Error()))
+		return
+	}
+	schema.Name = name
+
+	if gw.reg.requireApproval {
+		writeError(w, errApprovalRequired)
+		return
+	}
+	schema.UpdatedBy = RequestIdentity(nats.Header(r.Header))
+	schema.RequestID = r.Header.Get(SchemaRequestIDHeader)
+	if r.Method == http.MethodPost {
+		schema, err = gw.reg.register(schema)
+	} else {
+		schema, err = gw.reg.update(schema)
+	}
+	writeResult(w, schema, err)
+}
+
+// GET /api/schemas/<name>/diff?from=<revision>&to=<revision>
+func (gw *Gateway) diff(w http.ResponseWriter, r *http.Request, name string) {
+	from, errFrom := strconv.ParseUint(r.URL.Query().Get("from"), 10, 64)
+	to, errTo := strconv.ParseUint(r.URL.Query().Get("to"), 10, 64)
+	if errFrom != nil || errTo != nil {
+		writeError(w, newError("400", "from and to must be revisions"))
+		return
+	}
+
+	history, err := gw.reg.history(name)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	bodies := map[uint64]string{}
+	for _, entry := range history {
+		if entry.Schema != nil {
+			bodies[entry.Revision] = entry.Schema.Body
+		}
+	}
+	oldBody, okFrom := bodies[from]
+	newBody, okTo := bodies[to]
+	if !okFrom || !okTo {
+		writeError(w, newError("404", "revision not found"))
+		return
+	}
+
+	changes, err := DiffSchemas(oldBody, newBody)
+	if err != nil {
+		writeError(w, newError("400", err.Error()))
+		return
+	}
+	if changes == nil {
+		changes = []Change{}
+	}
+	writeJSON(w, changes)
+}
+
+// GET /api/schemas/<name>/changelog[?from=<revision>&to=<revision>]
+func (gw *Gateway) changelog(w http.ResponseWriter, r *http.Request, name string) {
+	var from, to uint64
+	var err error
+	if v := r.URL.Query().Get("from"); v != "" {
+		from, err = strconv.ParseUint(v, 10, 64)
+	}
+	if v := r.URL.Query().Get("to"); v != "" && err == nil {
+		to, err = strconv.ParseUint(v, 10, 64)
+	}
+	if err != nil {
+		writeError(w, newError("400", "from and to must be revisions"))
+		return
+	}
+
+	history, err := gw.reg.history(name)
+	if err == nil && len(history) == 0 {
+		err = newError("404", "Not found")
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	changelog, err := Changelog(name, history, from, to)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/markdown")
+	io.WriteString(w, changelog)
+}
+
+// GET /api/schemas/<name>/timeline
+func (gw *Gateway) timeline(w http.ResponseWriter, name string) {
+	history, err := gw.reg.history(name)
+	if err == nil && len(history) == 0 {
+		err = newError("404", "Not found")
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, Timeline(history))
+}
+
+// POST /api/schemas/<name>/try[?pointer=<pointer>] validates the request body
+// against the schema, or the part of it the JSON Pointer points to.
+func (gw *Gateway) try(w http.ResponseWriter, r *http.Request, name string) {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, newError("400", err.Error()))
+		return
+	}
+
+	result, err := gw.reg.trySchema(name, data, r.URL.Query().Get("pointer"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, result)
+}
+
+// POST /api/ndjson/<subject>
+//
+// The body is read as it arrives, so a backfill can be streamed in a single
+// request.
+func (gw *Gateway) ndjson(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, newError("405", "method not allowed"))
+		return
+	}
+	subject := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/ndjson/"), "/")
+	if subject == "" {
+		writeError(w, newError("404", "Not found"))
+		return
+	}
+
+	offset := 0
+	if value := r.URL.Query().Get("offset"); value != "" {
+		var err error
+		offset, err = strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			writeError(w, newError("400", "invalid offset %q", value))
+			return
+		}
+	}
+
+	summary, err := gw.reg.validateNDJSON(r.Body, subject, offset, nats.Header(r.Header))
+	writeResult(w, summary, err)
+}
+
+// authorized applies the registry's authorization rules to HTTP requests,
+// which carry the same headers as NATS requests.
+func (gw *Gateway) authorized(w http.ResponseWriter, r *http.Request, name string) bool {
+	if gw.reg.auth.AllowedOwner(name, gw.reg.owner(name), nats.Header(r.Header)) {
+		return true
+	}
+	writeError(w, newError("403", "not authorized to modify schema %q", name))
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeResult(w http.ResponseWriter, v interface{}, err error) {
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if v == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeJSON(w, v)
+}
+
+// writeError responds with the status matching the error's registry code.
+func writeError(w http.ResponseWriter, err error) {
+	status, convErr := strconv.Atoi(errorCode(err))
+	if convErr != nil {
+		status = http.StatusInternalServerError
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
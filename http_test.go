@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGatewayIgnoresRequestInfo(t *testing.T) {
+	TrustRequestInfo(true)
+	defer TrustRequestInfo(false)
+
+	reg := NewSchemaRegistry(nil, nil)
+	reg.auth = &Authorizer{Rules: []AuthRule{{Namespace: "*", Users: []string{"admin"}}}}
+	gw := NewGateway(reg)
+
+	for _, method := range []string{http.MethodPost, http.MethodPut, http.MethodDelete} {
+		r := httptest.NewRequest(method, "/api/schemas/orders", strings.NewReader(`{"subject": "orders", "body": "{}"}`))
+		r.Header.Set(requestInfoHeader, `{"acc":"APP","user":"admin"}`)
+		w := httptest.NewRecorder()
+		gw.ServeHTTP(w, r)
+
+		if w.Code != http.StatusForbidden {
+			t.Errorf("Expected %s with a forged request info header to be forbidden, got %d: %s", method, w.Code, w.Body)
+		}
+	}
+}
+
+func TestGatewayRequestID(t *testing.T) {
+	gw := NewGateway(NewSchemaRegistry(nil, nil))
+
+	r := httptest.NewRequest(http.MethodGet, "/api/schemas/", nil)
+	w := httptest.NewRecorder()
+	gw.ServeHTTP(w, r)
+	if w.Header().Get(SchemaRequestIDHeader) == "" {
+		t.Errorf("Expected a request ID to be generated")
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/api/schemas/", nil)
+	r.Header.Set(SchemaRequestIDHeader, "abc")
+	w = httptest.NewRecorder()
+	gw.ServeHTTP(w, r)
+	if id := w.Header().Get(SchemaRequestIDHeader); id != "abc" {
+		t.Errorf("Expected the request ID to be echoed, got %q", id)
+	}
+}
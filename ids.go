@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/micro"
+)
+
+// idCounterKey holds the last schema ID handed out.
+const idCounterKey = "_id.next"
+
+// idKey returns the kv key indexing the schema version with an ID.
+func idKey(id uint32) string {
+	return fmt.Sprintf("_id.%d", id)
+}
+
+// nextID allocates the next globally unique schema ID. The counter is updated
+// with optimistic concurrency, so registries sharing a bucket never hand out
+// the same ID twice.
+func (reg *SchemaRegistry) nextID() (uint32, error) {
+	for attempt := 0; attempt < 10; attempt++ {
+		var last uint64
+		entry, err := reg.kv.Get(idCounterKey)
+		switch {
+		case errors.Is(err, nats.ErrKeyNotFound):
+		case err != nil:
+			return 0, err
+		default:
+			last, err = strconv.ParseUint(string(entry.Value()), 10, 32)
+			if err != nil {
+				return 0, fmt.Errorf("corrupt schema id counter: %w", err)
+			}
+		}
+
+		next := last + 1
+		value := []byte(strconv.FormatUint(next, 10))
+		if entry == nil {
+			_, err = reg.kv.Create(idCounterKey, value)
+		} else {
+			_, err = reg.kv.Update(idCounterKey, value, entry.Revision())
+		}
+		if errors.Is(err, nats.ErrKeyExists) {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		return uint32(next), nil
+	}
+
+	return 0, errors.New("could not allocate a schema id, too much contention")
+}
+
+// indexID records the stored schema version under its ID.
+func (reg *SchemaRegistry) indexID(schema Schema) error {
+	data, err := json.Marshal(schema)
+	if err != nil {
+		return err
+	}
+	_, err = reg.kv.Create(idKey(schema.ID), data)
+	return err
+}
+
+// Get by ID subject: $SCHEMA.GETBYID.<id>
+func (reg *SchemaRegistry) GetByID(r micro.Request) {
+	parts := strings.Split(r.Subject(), ".")
+	id, err := strconv.ParseUint(parts[len(parts)-1], 10, 32)
+	if err != nil {
+		r.Error("400", fmt.Sprintf("invalid schema id %q", parts[len(parts)-1]), nil)
+		return
+	}
+
+	entry, err := reg.kv.Get(idKey(uint32(id)))
+	if errors.Is(err, nats.ErrKeyNotFound) {
+		r.Error("404", "Not found", nil)
+		return
+	}
+	if err != nil {
+		r.Error("500", err.Error(), nil)
+		return
+	}
+
+	r.Respond(entry.Value())
+}
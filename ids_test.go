@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/micro"
+)
+
+// contendedKV is a bucket another registry updates the schema ID counter of
+// right before every update of it, for a number of times.
+type contendedKV struct {
+	*memKV
+	contention int
+}
+
+func (kv *contendedKV) Update(key string, value []byte, last uint64) (uint64, error) {
+	if key == idCounterKey && kv.contention > 0 {
+		kv.contention--
+		entry, _ := kv.memKV.Get(key)
+		kv.memKV.Update(key, append(entry.Value(), '0'), entry.Revision())
+	}
+	return kv.memKV.Update(key, value, last)
+}
+
+func TestNextID(t *testing.T) {
+	kv := &contendedKV{memKV: newMemKV()}
+	reg := NewSchemaRegistry(kv, nil)
+
+	for want := uint32(1); want <= 2; want++ {
+		id, err := reg.nextID()
+		if err != nil || id != want {
+			t.Errorf("Expected id %d, got %d %v", want, id, err)
+		}
+	}
+
+	// The other registry handed out 20 meanwhile
+	kv.contention = 1
+	id, err := reg.nextID()
+	if err != nil || id != 21 {
+		t.Errorf("Expected the id after the contended one, got %d %v", id, err)
+	}
+
+	kv.contention = 10
+	_, err = reg.nextID()
+	if err == nil {
+		t.Errorf("Expected allocating an id to give up under contention")
+	}
+}
+
+func TestGetByID(t *testing.T) {
+	reg := NewSchemaRegistry(newMemKV(), nil)
+	schema, err := reg.register(Schema{Name: "orders", Subject: "orders", Body: `{"type": "object"}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := &recordingRequest{subject: "$SCHEMA.GETBYID.1"}
+	reg.GetByID(req)
+	var found Schema
+	if err := json.Unmarshal(req.resp.Data, &found); err != nil {
+		t.Fatal(err)
+	}
+	if found.Name != "orders" || found.ID != schema.ID || found.Revision != schema.Revision {
+		t.Errorf("Expected the registered schema, got %+v", found)
+	}
+
+	for subject, code := range map[string]string{"$SCHEMA.GETBYID.2": "404", "$SCHEMA.GETBYID.x": "400"} {
+		req := &recordingRequest{subject: subject}
+		reg.GetByID(req)
+		if got := req.resp.Header.Get(micro.ErrorCodeHeader); got != code {
+			t.Errorf("Expected %s to fail with %s, got %q", subject, code, got)
+		}
+	}
+}
+
+func TestUpdateStoresVersionWithID(t *testing.T) {
+	reg := NewSchemaRegistry(newMemKV(), nil)
+	reg.immutable = true
+
+	_, err := reg.register(Schema{Name: "orders", Subject: "orders", Body: `{"type": "object"}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	updated, err := reg.update(Schema{Name: "orders", Subject: "orders", Body: `{"type": "object", "required": ["id"]}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	version, err := reg.loadVersion("orders", "2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version.ID == 0 || version.ID != updated.ID {
+		t.Errorf("Expected the stored version to have id %d, got %d", updated.ID, version.ID)
+	}
+}
+
+// failingIndexKV is a bucket failing to index schemas by ID.
+type failingIndexKV struct {
+	*memKV
+}
+
+func (kv failingIndexKV) Create(key string, value []byte) (uint64, error) {
+	if strings.HasPrefix(key, "_id.") && key != idCounterKey {
+		return 0, errors.New("index unavailable")
+	}
+	return kv.memKV.Create(key, value)
+}
+
+func TestUpdateSucceedsWhenIndexingFails(t *testing.T) {
+	kv := failingIndexKV{newMemKV()}
+	reg := NewSchemaRegistry(kv, nil)
+
+	_, err := reg.register(Schema{Name: "orders", Subject: "orders", Body: `{"type": "object"}`})
+	if err != nil {
+		t.Errorf("Expected registering to succeed once stored, got %v", err)
+	}
+	updated, err := reg.update(Schema{Name: "orders", Subject: "orders", Body: `{"type": "object", "required": ["id"]}`})
+	if err != nil {
+		t.Errorf("Expected updating to succeed once stored, got %v", err)
+	}
+
+	entry, err := kv.Get(nameToken("orders"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Revision() != updated.Revision {
+		t.Errorf("Expected the update to be stored at revision %d, got %d", updated.Revision, entry.Revision())
+	}
+	if _, err := kv.Get(idKey(updated.ID)); !errors.Is(err, nats.ErrKeyNotFound) {
+		t.Errorf("Expected the update not to be indexed, got %v", err)
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go/micro"
+)
+
+// InferRequest holds the example payloads a schema is inferred from.
+type InferRequest struct {
+	Samples []json.RawMessage `json:"samples"`
+}
+
+// Infer subject: $SCHEMA.INFER
+func (reg *SchemaRegistry) Infer(r micro.Request) {
+	var req InferRequest
+	err := json.Unmarshal(r.Data(), &req)
+	if err != nil {
+		r.Error("400", err.Error(), nil)
+		return
+	}
+
+	body, err := InferSchema(req.Samples)
+	if err != nil {
+		r.Error("400", err.Error(), nil)
+		return
+	}
+
+	r.Respond([]byte(body))
+}
+
+// InferSchema generates a JSON schema every sample matches, as a starting
+// point for a schema to register. Properties are required if every sample
+// has them, and strings get a format if every value has it.
+func InferSchema(samples []json.RawMessage) (string, error) {
+	if len(samples) == 0 {
+		return "", fmt.Errorf("no samples to infer a schema from")
+	}
+
+	root := &shape{}
+	for i, sample := range samples {
+		decoder := json.NewDecoder(bytes.NewReader(sample))
+		decoder.UseNumber()
+		var v interface{}
+		if err := decoder.Decode(&v); err != nil {
+			return "", fmt.Errorf("sample %d: %w", i, err)
+		}
+		root.add(v)
+	}
+
+	doc := root.schema()
+	doc["$schema"] = "http://json-schema.org/draft-07/schema#"
+
+	encoded, err := json.Marshal(doc)
+	return string(encoded), err
+}
+
+// shape sums up the values seen at the same place in the samples.
+type shape struct {
+	types map[string]bool
+	// objects is the number of objects seen, and present the number of them
+	// each property was in
+	objects    int
+	properties map[string]*shape
+	present    map[string]int
+	items      *shape
+	// formats are the formats every string seen so far has, nil before the
+	// first string
+	formats []string
+}
+
+func (s *shape) add(v interface{}) {
+	if s.types == nil {
+		s.types = map[string]bool{}
+	}
+
+	switch v := v.(type) {
+	case nil:
+		s.types["null"] = true
+	case bool:
+		s.types["boolean"] = true
+	case json.Number:
+		if strings.ContainsAny(v.String(), ".eE") {
+			s.types["number"] = true
+		} else {
+			s.types["integer"] = true
+		}
+	case string:
+		formats := stringFormats(v)
+		if s.types["string"] {
+			formats = intersect(s.formats, formats)
+		}
+		s.types["string"] = true
+		s.formats = formats
+	case []interface{}:
+		s.types["array"] = true
+		for _, item := range v {
+			if s.items == nil {
+				s.items = &shape{}
+			}
+			s.items.add(item)
+		}
+	case map[string]interface{}:
+		s.types["object"] = true
+		if s.properties == nil {
+			s.properties = map[string]*shape{}
+			s.present = map[string]int{}
+		}
+		s.objects++
+		for name, value := range v {
+			if s.properties[name] == nil {
+				s.properties[name] = &shape{}
+			}
+			s.properties[name].add(value)
+			s.present[name]++
+		}
+	}
+}
+
+func (s *shape) schema() map[string]interface{} {
+	doc := map[string]interface{}{}
+
+	// Integers are numbers too
+	if s.types["integer"] && s.types["number"] {
+		delete(s.types, "integer")
+	}
+	types := sortedKeys(s.types)
+	switch len(types) {
+	case 0:
+	case 1:
+		doc["type"] = types[0]
+	default:
+		doc["type"] = types
+	}
+
+	if s.types["string"] && len(s.formats) > 0 {
+		doc["format"] = s.formats[0]
+	}
+	if s.types["array"] && s.items != nil {
+		doc["items"] = s.items.schema()
+	}
+	if s.types["object"] {
+		properties := map[string]interface{}{}
+		var required []string
+		for _, name := range sortedKeys(s.properties) {
+			properties[name] = s.properties[name].schema()
+			if s.present[name] == s.objects {
+				required = append(required, name)
+			}
+		}
+		doc["properties"] = properties
+		if len(required) > 0 {
+			doc["required"] = required
+		}
+	}
+	return doc
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// stringFormats returns the formats a string has, most specific first.
+func stringFormats(s string) []string {
+	var formats []string
+	if _, err := time.Parse(time.RFC3339, s); err == nil {
+		formats = append(formats, "date-time")
+	}
+	if _, err := time.Parse("2006-01-02", s); err == nil {
+		formats = append(formats, "date")
+	}
+	if uuidPattern.MatchString(s) {
+		formats = append(formats, "uuid")
+	}
+	if addr, err := mail.ParseAddress(s); err == nil && addr.Address == s {
+		formats = append(formats, "email")
+	}
+	if ip := net.ParseIP(s); ip != nil {
+		if ip.To4() != nil && strings.Contains(s, ".") {
+			formats = append(formats, "ipv4")
+		} else {
+			formats = append(formats, "ipv6")
+		}
+	}
+	if u, err := url.Parse(s); err == nil && u.Scheme != "" && u.Host != "" {
+		formats = append(formats, "uri")
+	}
+	return formats
+}
+
+// intersect returns the values of a that are in b, keeping their order.
+func intersect(a, b []string) []string {
+	var both []string
+	for _, v := range a {
+		if contains(b, v) {
+			both = append(both, v)
+		}
+	}
+	return both
+}
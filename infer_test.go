@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestInferSchema(t *testing.T) {
+	samples := []json.RawMessage{
+		json.RawMessage(`{"id": 1, "email": "a@example.com", "created": "2024-01-02T03:04:05Z", "tags": ["a"], "total": 10}`),
+		json.RawMessage(`{"id": 2, "email": "b@example.com", "created": "2024-01-03T00:00:00+01:00", "day": "2024-01-03", "total": 9.5, "note": null}`),
+	}
+
+	body, err := InferSchema(samples)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal([]byte(body), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	if doc["type"] != "object" {
+		t.Errorf("Expected an object, got %v", doc["type"])
+	}
+	required := doc["required"].([]interface{})
+	if !reflect.DeepEqual(required, []interface{}{"created", "email", "id", "total"}) {
+		t.Errorf("Expected the properties of every sample to be required, got %v", required)
+	}
+
+	properties := doc["properties"].(map[string]interface{})
+	expected := map[string]map[string]interface{}{
+		"id":      {"type": "integer"},
+		"email":   {"type": "string", "format": "email"},
+		"created": {"type": "string", "format": "date-time"},
+		"day":     {"type": "string", "format": "date"},
+		"total":   {"type": "number"},
+		"note":    {"type": "null"},
+		"tags":    {"type": "array", "items": map[string]interface{}{"type": "string"}},
+	}
+	for name, schema := range expected {
+		if !reflect.DeepEqual(properties[name], map[string]interface{}(schema)) {
+			t.Errorf("Expected %q to be inferred as %v, got %v", name, schema, properties[name])
+		}
+	}
+
+	if err := CompileSchema(body); err != nil {
+		t.Errorf("Expected the inferred schema to compile, got %v", err)
+	}
+}
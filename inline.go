@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// Inline validates messages on the schemas' own subjects, so producers don't
+// need to publish to $SCHEMA.VALIDATE. Validated messages are forwarded to the
+// schema's destination or, without one, to the same subject under a prefix.
+type Inline struct {
+	reg *SchemaRegistry
+	// prefix is prepended to the subject of validated messages
+	prefix string
+	queue  string
+	pool   *WorkerPool
+
+	mu sync.Mutex
+	// subjects are the subject patterns of the schemas, by name
+	subjects map[string]string
+	// destinations are the subjects the schemas forward to, by name
+	destinations map[string]string
+	// looping are the schemas not validated inline, as their validated
+	// messages would come back to them
+	looping map[string]bool
+	subs    map[string]*nats.Subscription
+}
+
+func NewInline(reg *SchemaRegistry, prefix, queue string, workers int) *Inline {
+	in := &Inline{
+		reg:          reg,
+		prefix:       prefix,
+		queue:        queue,
+		subjects:     map[string]string{},
+		destinations: map[string]string{},
+		looping:      map[string]bool{},
+		subs:         map[string]*nats.Subscription{},
+	}
+	in.pool = NewWorkerPool(workers, in.handle)
+	return in
+}
+
+// Run watches the schemas and subscribes to their subjects once the initial
+// schemas are known, and again on every change. It runs this in a goroutine
+// and takes a context for cancelation.
+func (in *Inline) Run(c context.Context) error {
+	watcher, err := in.reg.kv.Watch("*")
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		defer watcher.Stop()
+
+		initialized := false
+		for {
+			select {
+			case <-c.Done():
+				return
+			case entry, ok := <-watcher.Updates():
+				if !ok {
+					return
+				}
+				if entry == nil {
+					initialized = true
+				} else {
+					in.apply(entry)
+				}
+				if !initialized {
+					continue
+				}
+				in.Sync()
+			}
+		}
+	}()
+
+	return nil
+}
+
+// apply records the subject and destination of a changed schema.
+func (in *Inline) apply(entry nats.KeyValueEntry) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	var schema Schema
+	if entry.Operation() != nats.KeyValuePut || json.Unmarshal(entry.Value(), &schema) != nil || schema.State == StateDraft || schema.Subject == "" {
+		delete(in.subjects, entry.Key())
+		delete(in.destinations, entry.Key())
+		return
+	}
+	pattern := SubjectPattern(schema.Subject)
+	in.subjects[entry.Key()] = pattern
+	in.destinations[entry.Key()] = in.prefix + "." + pattern
+	if schema.Destination != "" {
+		in.destinations[entry.Key()] = SubjectPattern(schema.Destination)
+	}
+}
+
+// loops returns the schemas whose validated messages could come back to
+// them, directly or through the destinations of other schemas, such as a
+// schema forwarding to the subject of one forwarding back to it. It must be
+// called with the lock held.
+func (in *Inline) loops() map[string]bool {
+	next := map[string][]string{}
+	for name, destination := range in.destinations {
+		for other, pattern := range in.subjects {
+			if SubjectsMatch(destination, pattern) || SubjectsMatch(pattern, destination) {
+				next[name] = append(next[name], other)
+			}
+		}
+	}
+
+	looping := map[string]bool{}
+	for name := range in.subjects {
+		seen := map[string]bool{}
+		queue := append([]string{}, next[name]...)
+		for len(queue) > 0 {
+			other := queue[0]
+			queue = queue[1:]
+			if other == name {
+				looping[name] = true
+				break
+			}
+			if seen[other] {
+				continue
+			}
+			seen[other] = true
+			queue = append(queue, next[other]...)
+		}
+	}
+	return looping
+}
+
+// Sync subscribes to the subjects of the schemas and unsubscribes from the
+// ones no schema uses anymore. Subjects covered by a wider one aren't
+// subscribed to, so no message is validated twice, and neither are the
+// subjects of schemas whose messages would loop.
+func (in *Inline) Sync() {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	looping := in.loops()
+	for _, name := range sortedKeys(looping) {
+		if !in.looping[name] {
+			logger("inline").Warn("Not validating schema inline, its validated messages would be validated again", "schema", name)
+		}
+	}
+	in.looping = looping
+
+	wanted := map[string]bool{}
+	for name, pattern := range in.subjects {
+		if !looping[name] {
+			wanted[pattern] = true
+		}
+	}
+	for pattern := range wanted {
+		for other := range wanted {
+			if other != pattern && SubjectsMatch(pattern, other) {
+				delete(wanted, pattern)
+				break
+			}
+		}
+	}
+
+	for pattern, sub := range in.subs {
+		if wanted[pattern] {
+			continue
+		}
+		err := sub.Unsubscribe()
+		if err != nil {
+			logger("inline").Error("error unsubscribing", "subject", pattern, "error", err)
+		}
+		delete(in.subs, pattern)
+		logger("inline").Info("Stopped validating inline", "subject", pattern)
+	}
+	for _, pattern := range sortedKeys(wanted) {
+		if _, ok := in.subs[pattern]; ok {
+			continue
+		}
+		sub, err := in.reg.nc.QueueSubscribe(pattern, in.queue, in.pool.Handle)
+		if err != nil {
+			logger("inline").Error("error subscribing", "subject", pattern, "error", err)
+			continue
+		}
+		in.subs[pattern] = sub
+		logger("inline").Info("Validating inline", "subject", pattern)
+	}
+}
+
+func (in *Inline) handle(m *nats.Msg) {
+	in.reg.validateMsg(m, m.Subject, in.route)
+}
+
+// route returns the subject a validated message is forwarded to.
+func (in *Inline) route(schema Schema, subject string, params map[string]string) string {
+	if schema.Destination != "" {
+		return RouteSubject(schema, subject, params)
+	}
+	return in.prefix + "." + subject
+}
+
+// Stop stops taking new messages and lets the ones already received finish
+// until the deadline.
+func (in *Inline) Stop(deadline time.Time) error {
+	in.mu.Lock()
+	subs := in.subs
+	in.subs = map[string]*nats.Subscription{}
+	in.mu.Unlock()
+
+	for _, sub := range subs {
+		err := sub.Drain()
+		if err != nil {
+			return err
+		}
+	}
+	for _, sub := range subs {
+		for sub.IsValid() && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	if err := in.pool.Wait(deadline); err != nil {
+		return fmt.Errorf("stopping inline validation: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+)
+
+func TestInlineRouting(t *testing.T) {
+	in := NewInline(NewSchemaRegistry(nil, nil), "validated", "schema_registry", 1)
+
+	tests := []struct {
+		schema Schema
+		route  string
+	}{
+		{Schema{Subject: "orders.*"}, "validated.orders.new"},
+		{Schema{Subject: "orders.*", Destination: "clean.orders.*"}, "clean.orders.new"},
+		{Schema{Subject: "orders.*", Destination: "orders.*"}, "orders.new"},
+		{Schema{Subject: ">"}, "validated.orders.new"},
+	}
+	for _, test := range tests {
+		if route := in.route(test.schema, "orders.new", nil); route != test.route {
+			t.Errorf("Expected %+v to forward to %q, got %q", test.schema, test.route, route)
+		}
+	}
+}
+
+func TestInlineLoops(t *testing.T) {
+	tests := []struct {
+		schemas []Schema
+		looping []string
+	}{
+		{[]Schema{{Name: "orders", Subject: "orders.*"}}, []string{}},
+		{[]Schema{{Name: "orders", Subject: "orders.*", Destination: "clean.orders.*"}}, []string{}},
+		{[]Schema{{Name: "orders", Subject: "orders.*", Destination: "orders.*"}}, []string{"orders"}},
+		{[]Schema{{Name: "all", Subject: ">"}}, []string{"all"}},
+		// Chained schemas are fine, as long as nothing comes back
+		{[]Schema{
+			{Name: "orders", Subject: "orders.*", Destination: "clean.orders.*"},
+			{Name: "clean", Subject: "clean.orders.*", Destination: "billing.orders.*"},
+		}, []string{}},
+		{[]Schema{
+			{Name: "orders", Subject: "orders.*", Destination: "clean.orders.*"},
+			{Name: "clean", Subject: "clean.orders.*", Destination: "orders.*"},
+		}, []string{"clean", "orders"}},
+		{[]Schema{
+			{Name: "orders", Subject: "orders.*", Destination: "clean.orders.*"},
+			{Name: "clean", Subject: "clean.>", Destination: "billing.orders.*"},
+			{Name: "billing", Subject: "billing.orders.new", Destination: "orders.*"},
+			{Name: "audit", Subject: "audit.*", Destination: "orders.*"},
+		}, []string{"billing", "clean", "orders"}},
+		// Drafts aren't validated inline
+		{[]Schema{
+			{Name: "orders", Subject: "orders.*", Destination: "clean.orders.*"},
+			{Name: "clean", Subject: "clean.orders.*", Destination: "orders.*", State: StateDraft},
+		}, []string{}},
+	}
+	for _, test := range tests {
+		in := NewInline(NewSchemaRegistry(nil, nil), "validated", "schema_registry", 1)
+		for _, schema := range test.schemas {
+			data, err := json.Marshal(schema)
+			if err != nil {
+				t.Fatal(err)
+			}
+			in.apply(&memEntry{key: schema.Name, value: data, op: nats.KeyValuePut})
+		}
+		if looping := sortedKeys(in.loops()); !reflect.DeepEqual(looping, test.looping) {
+			t.Errorf("Expected %+v to loop through %v, got %v", test.schemas, test.looping, looping)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/nats-io/nats.go"
+)
+
+// publishJetStream forwards a validated message with the JetStream publish
+// API and relays the stream's acknowledgement to the original publisher, so
+// producers get the same PubAck as when publishing to the stream directly.
+// The Nats-Msg-Id header is carried over with the other headers, letting the
+// stream deduplicate retried messages. The error is returned if the message
+// wasn't stored.
+func (reg *SchemaRegistry) publishJetStream(m *nats.Msg, msg *nats.Msg, schema Schema, subject string) error {
+	msg.Reply = ""
+	var ack *nats.PubAck
+	err := reg.forward(m, schema, subject, func() error {
+		var err error
+		ack, err = reg.js.PublishMsg(msg)
+		return err
+	})
+	if err != nil {
+		requestLogger(m, schema, subject).Error("error publishing message to JetStream", "destination", msg.Subject, "error", err)
+		code := "500"
+		var jsErr nats.JetStreamError
+		switch {
+		case errors.As(err, &jsErr) && jsErr.APIError() != nil:
+			code = fmt.Sprintf("%d", jsErr.APIError().Code)
+		case errors.Is(err, nats.ErrNoStreamResponse), errors.Is(err, nats.ErrNoResponders):
+			code = "503"
+		}
+		reg.respondError(m, code, fmt.Sprintf("publishing to %q failed: %v", msg.Subject, err))
+		return err
+	}
+
+	if m.Reply == "" {
+		return nil
+	}
+	data, err := json.Marshal(ack)
+	if err != nil {
+		requestLogger(m, schema, subject).Error("error encoding PubAck", "error", err)
+		return nil
+	}
+	resp := nats.NewMsg(m.Reply)
+	resp.Data = data
+	resp.Header.Set(SchemaRequestIDHeader, m.Header.Get(SchemaRequestIDHeader))
+	err = m.RespondMsg(resp)
+	if err != nil {
+		requestLogger(m, schema, subject).Error("error relaying PubAck", "error", err)
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// memKV is an in-memory bucket for tests that don't need a NATS server. Its
+// watchers deliver the latest value of every matching key that isn't
+// deleted, as with nats.IgnoreDeletes, whatever the options.
+type memKV struct {
+	mu       sync.Mutex
+	revision uint64
+	entries  []*memEntry
+	// history caps the revisions History returns per key, 0 keeps them all
+	history int
+}
+
+func newMemKV() *memKV {
+	return &memKV{}
+}
+
+type memEntry struct {
+	key      string
+	value    []byte
+	revision uint64
+	created  time.Time
+	op       nats.KeyValueOp
+}
+
+func (e *memEntry) Bucket() string             { return "test" }
+func (e *memEntry) Key() string                { return e.key }
+func (e *memEntry) Value() []byte              { return e.value }
+func (e *memEntry) Revision() uint64           { return e.revision }
+func (e *memEntry) Created() time.Time         { return e.created }
+func (e *memEntry) Delta() uint64              { return 0 }
+func (e *memEntry) Operation() nats.KeyValueOp { return e.op }
+
+// latest returns the latest entry of a key, deleted or not. It must be called
+// with the lock held.
+func (kv *memKV) latest(key string) *memEntry {
+	for i := len(kv.entries) - 1; i >= 0; i-- {
+		if kv.entries[i].key == key {
+			return kv.entries[i]
+		}
+	}
+	return nil
+}
+
+func (kv *memKV) append(key string, value []byte, op nats.KeyValueOp) uint64 {
+	kv.revision++
+	kv.entries = append(kv.entries, &memEntry{key: key, value: append([]byte{}, value...), revision: kv.revision, created: time.Now(), op: op})
+	return kv.revision
+}
+
+func (kv *memKV) Get(key string) (nats.KeyValueEntry, error) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	entry := kv.latest(key)
+	if entry == nil || entry.op != nats.KeyValuePut {
+		return nil, nats.ErrKeyNotFound
+	}
+	return entry, nil
+}
+
+func (kv *memKV) GetRevision(key string, revision uint64) (nats.KeyValueEntry, error) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	for _, entry := range kv.entries {
+		if entry.key == key && entry.revision == revision && entry.op == nats.KeyValuePut {
+			return entry, nil
+		}
+	}
+	return nil, nats.ErrKeyNotFound
+}
+
+func (kv *memKV) Put(key string, value []byte) (uint64, error) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	return kv.append(key, value, nats.KeyValuePut), nil
+}
+
+func (kv *memKV) PutString(key string, value string) (uint64, error) {
+	return kv.Put(key, []byte(value))
+}
+
+func (kv *memKV) Create(key string, value []byte) (uint64, error) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	if entry := kv.latest(key); entry != nil && entry.op == nats.KeyValuePut {
+		return 0, nats.ErrKeyExists
+	}
+	return kv.append(key, value, nats.KeyValuePut), nil
+}
+
+func (kv *memKV) Update(key string, value []byte, last uint64) (uint64, error) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	entry := kv.latest(key)
+	if entry == nil || entry.revision != last {
+		return 0, nats.ErrKeyExists
+	}
+	return kv.append(key, value, nats.KeyValuePut), nil
+}
+
+func (kv *memKV) Delete(key string, opts ...nats.DeleteOpt) error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	kv.append(key, nil, nats.KeyValueDelete)
+	return nil
+}
+
+func (kv *memKV) Purge(key string, opts ...nats.DeleteOpt) error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	kept := kv.entries[:0]
+	for _, entry := range kv.entries {
+		if entry.key != key {
+			kept = append(kept, entry)
+		}
+	}
+	kv.entries = kept
+	kv.append(key, nil, nats.KeyValuePurge)
+	return nil
+}
+
+// matchKey returns true if a key matches a pattern with NATS wildcards.
+func matchKey(pattern, key string) bool {
+	patterns, keys := strings.Split(pattern, "."), strings.Split(key, ".")
+	for i, p := range patterns {
+		if p == ">" {
+			return len(keys) > i
+		}
+		if i >= len(keys) || (p != "*" && p != keys[i]) {
+			return false
+		}
+	}
+	return len(patterns) == len(keys)
+}
+
+type memWatcher struct {
+	updates chan nats.KeyValueEntry
+}
+
+func (w *memWatcher) Context() context.Context           { return context.Background() }
+func (w *memWatcher) Updates() <-chan nats.KeyValueEntry { return w.updates }
+func (w *memWatcher) Stop() error                        { return nil }
+
+func (kv *memKV) Watch(keys string, opts ...nats.WatchOpt) (nats.KeyWatcher, error) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	var latest []nats.KeyValueEntry
+	seen := map[string]bool{}
+	for i := len(kv.entries) - 1; i >= 0; i-- {
+		entry := kv.entries[i]
+		if seen[entry.key] || !matchKey(keys, entry.key) {
+			continue
+		}
+		seen[entry.key] = true
+		if entry.op == nats.KeyValuePut {
+			latest = append([]nats.KeyValueEntry{entry}, latest...)
+		}
+	}
+
+	w := &memWatcher{updates: make(chan nats.KeyValueEntry, len(latest)+1)}
+	for _, entry := range latest {
+		w.updates <- entry
+	}
+	w.updates <- nil
+	return w, nil
+}
+
+func (kv *memKV) WatchAll(opts ...nats.WatchOpt) (nats.KeyWatcher, error) {
+	return kv.Watch(">", opts...)
+}
+
+func (kv *memKV) Keys(opts ...nats.WatchOpt) ([]string, error) {
+	w, _ := kv.Watch(">")
+	var keys []string
+	for entry := range w.Updates() {
+		if entry == nil {
+			break
+		}
+		keys = append(keys, entry.Key())
+	}
+	if len(keys) == 0 {
+		return nil, nats.ErrNoKeysFound
+	}
+	return keys, nil
+}
+
+func (kv *memKV) History(key string, opts ...nats.WatchOpt) ([]nats.KeyValueEntry, error) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	var history []nats.KeyValueEntry
+	for _, entry := range kv.entries {
+		if entry.key == key {
+			history = append(history, entry)
+		}
+	}
+	if kv.history > 0 && len(history) > kv.history {
+		history = history[len(history)-kv.history:]
+	}
+	if len(history) == 0 {
+		return nil, nats.ErrKeyNotFound
+	}
+	return history, nil
+}
+
+func (kv *memKV) Bucket() string { return "test" }
+
+func (kv *memKV) PurgeDeletes(opts ...nats.PurgeOpt) error { return nil }
+
+func (kv *memKV) Status() (nats.KeyValueStatus, error) { return nil, nats.ErrNotJSMessage }
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nuid"
+)
+
+// Singleton jobs, run by the leader of each among the instances sharing a
+// bucket.
+const (
+	JobExpiry = "expiry"
+	JobAudit  = "audit"
+)
+
+// leaderKey returns the kv key holding the lease of a job. Like lock keys, it
+// never matches the head keys the watcher loads.
+func leaderKey(job string) string {
+	return "_leader." + nameToken(job)
+}
+
+// Lease is held by the instance leading a job until it expires.
+type Lease struct {
+	Holder  string    `json:"holder"`
+	Expires time.Time `json:"expires"`
+}
+
+// Elector elects, per job, one of the instances sharing a bucket to run it.
+// Instances campaign for a lease stored in the bucket, and the holder renews
+// it a few times per lease. Leases are only taken over once expired, and
+// replaced on the revision they were read at, so two instances never hold the
+// same lease. When the leader stops or loses the bucket, another instance
+// takes over once its lease expires. A nil Elector leads every job, as a
+// single instance does.
+type Elector struct {
+	kv    nats.KeyValue
+	id    string
+	lease time.Duration
+
+	mu sync.Mutex
+	// held are the leases of the jobs this instance leads, by revision
+	held map[string]heldLease
+}
+
+type heldLease struct {
+	revision uint64
+	expires  time.Time
+}
+
+func NewElector(kv nats.KeyValue, lease time.Duration) *Elector {
+	return &Elector{kv: kv, id: nuid.Next(), lease: lease, held: map[string]heldLease{}}
+}
+
+// Leading returns true while this instance holds the lease of a job. A leader
+// cut off from the bucket stops leading when its lease expires, before anyone
+// else can take over.
+func (e *Elector) Leading(job string) bool {
+	if e == nil {
+		return true
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return time.Now().Before(e.held[job].expires)
+}
+
+// Run campaigns for the leases of jobs three times per lease. It runs this in
+// a goroutine and takes a context for cancelation, which gives up the leases
+// so another instance takes over right away.
+func (e *Elector) Run(c context.Context, jobs ...string) {
+	for _, job := range jobs {
+		e.campaign(job, time.Now())
+	}
+
+	go func() {
+		ticker := time.NewTicker(e.lease / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-c.Done():
+				for _, job := range jobs {
+					e.resign(job)
+				}
+				return
+			case now := <-ticker.C:
+				for _, job := range jobs {
+					e.campaign(job, now)
+				}
+			}
+		}
+	}()
+}
+
+// campaign takes or renews the lease of a job, unless another instance holds
+// it.
+func (e *Elector) campaign(job string, now time.Time) {
+	lease := Lease{Holder: e.id, Expires: now.Add(e.lease)}
+	data, err := json.Marshal(lease)
+	if err != nil {
+		logger("leader").Error("error campaigning", "job", job, "error", err)
+		return
+	}
+
+	var revision uint64
+	entry, err := e.kv.Get(leaderKey(job))
+	switch {
+	case errors.Is(err, nats.ErrKeyNotFound):
+		revision, err = e.kv.Create(leaderKey(job), data)
+	case err != nil:
+	default:
+		var current Lease
+		if json.Unmarshal(entry.Value(), &current) == nil && current.Holder != e.id && now.Before(current.Expires) {
+			e.lose(job)
+			return
+		}
+		revision, err = e.kv.Update(leaderKey(job), data, entry.Revision())
+	}
+	// Losing a race to take over means someone else leads now
+	if err != nil {
+		if e.Leading(job) {
+			logger("leader").Error("error renewing the lease", "job", job, "error", err)
+		}
+		e.lose(job)
+		return
+	}
+
+	e.mu.Lock()
+	_, leading := e.held[job]
+	e.held[job] = heldLease{revision: revision, expires: lease.Expires}
+	e.mu.Unlock()
+	if !leading {
+		logger("leader").Info("Leading job", "job", job)
+	}
+}
+
+// lose forgets the lease of a job.
+func (e *Elector) lose(job string) {
+	e.mu.Lock()
+	_, leading := e.held[job]
+	delete(e.held, job)
+	e.mu.Unlock()
+	if leading {
+		logger("leader").Info("No longer leading job", "job", job)
+	}
+}
+
+// resign gives up the lease of a job, unless it was taken over already.
+func (e *Elector) resign(job string) {
+	e.mu.Lock()
+	held, leading := e.held[job]
+	delete(e.held, job)
+	e.mu.Unlock()
+	if !leading {
+		return
+	}
+
+	err := e.kv.Delete(leaderKey(job), nats.LastRevision(held.revision))
+	if err != nil {
+		logger("leader").Error("error resigning the lease", "job", job, "error", err)
+	}
+}
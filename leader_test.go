@@ -0,0 +1,116 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+func TestElectorLeading(t *testing.T) {
+	var none *Elector
+	if !none.Leading(JobExpiry) {
+		t.Errorf("Expected a nil elector to lead every job")
+	}
+
+	e := NewElector(nil, time.Minute)
+	if e.Leading(JobExpiry) {
+		t.Errorf("Expected an elector without leases to lead nothing")
+	}
+
+	e.held[JobExpiry] = heldLease{revision: 1, expires: time.Now().Add(time.Minute)}
+	e.held[JobAudit] = heldLease{revision: 2, expires: time.Now().Add(-time.Second)}
+	if !e.Leading(JobExpiry) {
+		t.Errorf("Expected the elector to lead the job it holds the lease of")
+	}
+	if e.Leading(JobAudit) {
+		t.Errorf("Expected the elector to stop leading once its lease expired")
+	}
+}
+
+// racingKV runs race once after reading a key, as if another instance wrote
+// to the bucket in between.
+type racingKV struct {
+	nats.KeyValue
+	race func()
+}
+
+func (kv *racingKV) Get(key string) (nats.KeyValueEntry, error) {
+	entry, err := kv.KeyValue.Get(key)
+	if race := kv.race; race != nil {
+		kv.race = nil
+		race()
+	}
+	return entry, err
+}
+
+func TestElectorCampaign(t *testing.T) {
+	kv := newMemKV()
+	a, b := NewElector(kv, time.Minute), NewElector(kv, time.Minute)
+	now := time.Now()
+
+	a.campaign(JobExpiry, now)
+	b.campaign(JobExpiry, now)
+	if !a.Leading(JobExpiry) || b.Leading(JobExpiry) {
+		t.Fatalf("Expected the first instance to campaign to lead")
+	}
+
+	// Renewing keeps the lease
+	a.campaign(JobExpiry, now.Add(20*time.Second))
+	b.campaign(JobExpiry, now.Add(time.Minute+time.Second))
+	if !a.Leading(JobExpiry) || b.Leading(JobExpiry) {
+		t.Fatalf("Expected the leader to keep a renewed lease")
+	}
+
+	// Leases are taken over once they expire
+	later := now.Add(2 * time.Minute)
+	b.campaign(JobExpiry, later)
+	a.campaign(JobExpiry, later)
+	if a.Leading(JobExpiry) || !b.Leading(JobExpiry) {
+		t.Errorf("Expected the expired lease to be taken over")
+	}
+}
+
+func TestElectorLosesRace(t *testing.T) {
+	kv := newMemKV()
+	b := NewElector(kv, time.Minute)
+	a := NewElector(&racingKV{KeyValue: kv}, time.Minute)
+	now := time.Now()
+
+	// Both find the job without a leader, and b creates the lease first
+	a.kv.(*racingKV).race = func() { b.campaign(JobExpiry, now) }
+	a.campaign(JobExpiry, now)
+	if a.Leading(JobExpiry) || !b.Leading(JobExpiry) {
+		t.Errorf("Expected the instance losing the race to create the lease not to lead")
+	}
+
+	// Both find the lease expired, and b takes it over first
+	later := now.Add(2 * time.Minute)
+	a.campaign(JobAudit, now)
+	a.kv.(*racingKV).race = func() { b.campaign(JobAudit, later) }
+	a.campaign(JobAudit, later)
+	if a.Leading(JobAudit) || !b.Leading(JobAudit) {
+		t.Errorf("Expected the instance losing the race to take over the lease not to lead")
+	}
+}
+
+func TestElectorResign(t *testing.T) {
+	kv := newMemKV()
+	a, b := NewElector(kv, time.Minute), NewElector(kv, time.Minute)
+	now := time.Now()
+
+	a.campaign(JobExpiry, now)
+	a.resign(JobExpiry)
+	if a.Leading(JobExpiry) {
+		t.Errorf("Expected the instance to stop leading once it resigned")
+	}
+	if _, err := kv.Get(leaderKey(JobExpiry)); err == nil {
+		t.Errorf("Expected the lease to be given up")
+	}
+
+	// Another instance takes over right away, rather than once the lease expires
+	b.campaign(JobExpiry, now)
+	if !b.Leading(JobExpiry) {
+		t.Errorf("Expected the lease to be taken over after the leader resigned")
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+// Lifecycle states a schema can be in. Drafts are stored but never used for
+// validation, deprecated schemas still validate but flag forwarded messages,
+// and disabled schemas reject every payload for their subject.
+const (
+	StateDraft      = "draft"
+	StateActive     = "active"
+	StateDeprecated = "deprecated"
+	StateDisabled   = "disabled"
+)
+
+// stateTransitions lists the states each state is allowed to move to.
+var stateTransitions = map[string][]string{
+	StateDraft:      {StateActive, StateDisabled},
+	StateActive:     {StateDeprecated, StateDisabled},
+	StateDeprecated: {StateActive, StateDisabled},
+	StateDisabled:   {StateActive, StateDeprecated},
+}
+
+// ValidState returns true if state is a known lifecycle state.
+func ValidState(state string) bool {
+	_, ok := stateTransitions[state]
+	return ok
+}
+
+// CanTransition returns true if a schema may move from one lifecycle state to
+// another. Staying in the same state is always allowed.
+func CanTransition(from, to string) bool {
+	if from == to {
+		return ValidState(to)
+	}
+	for _, next := range stateTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
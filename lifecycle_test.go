@@ -0,0 +1,22 @@
+package main
+
+import "testing"
+
+func TestCanTransition(t *testing.T) {
+	if CanTransition(StateDraft, StateActive) == false {
+		t.Errorf("Expected draft to be promotable to active")
+	}
+	if CanTransition(StateActive, StateDeprecated) == false {
+		t.Errorf("Expected active to be deprecatable")
+	}
+	if CanTransition(StateActive, StateActive) == false {
+		t.Errorf("Expected staying in the same state to be allowed")
+	}
+
+	if CanTransition(StateActive, StateDraft) == true {
+		t.Errorf("Expected active to not move back to draft")
+	}
+	if CanTransition(StateActive, "bogus") == true {
+		t.Errorf("Expected unknown state to be rejected")
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Limits caps the size of payloads and the size and complexity of schemas, so
+// a single message or schema can't exhaust the registry. Zero means no limit.
+type Limits struct {
+	MaxPayload        int `json:"max_payload"`
+	MaxSchemaSize     int `json:"max_schema_size"`
+	MaxSchemaDepth    int `json:"max_schema_depth"`
+	MaxSchemaPatterns int `json:"max_schema_patterns"`
+	MaxObjectSize     int `json:"max_object_size"`
+}
+
+// LimitError is returned when a limit is exceeded. It's reported with a 413
+// error code.
+type LimitError struct {
+	Limit  string `json:"limit"`
+	Max    int    `json:"max"`
+	Actual int    `json:"actual"`
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s exceeded: %d, the limit is %d", e.Limit, e.Actual, e.Max)
+}
+
+// exceeds returns a LimitError if a limit is set and value is over it.
+func exceeds(limit string, max, value int) error {
+	if max > 0 && value > max {
+		return &LimitError{Limit: limit, Max: max, Actual: value}
+	}
+	return nil
+}
+
+// CheckPayload returns an error if a payload is too large to validate.
+func (l Limits) CheckPayload(data []byte) error {
+	return exceeds("max_payload", l.MaxPayload, len(data))
+}
+
+// CheckObject returns an error if a payload referenced in an Object Store is
+// too large to validate.
+func (l Limits) CheckObject(size uint64) error {
+	if l.MaxObjectSize > 0 && size > uint64(l.MaxObjectSize) {
+		return &LimitError{Limit: "max_object_size", Max: l.MaxObjectSize, Actual: int(size)}
+	}
+	return nil
+}
+
+// CheckSchema returns an error if any of a schema's bodies is too large or
+// too complex.
+func (l Limits) CheckSchema(schema Schema) error {
+	for _, body := range []string{schema.Body, schema.ResponseBody, schema.HeaderBody} {
+		if body == "" {
+			continue
+		}
+		if err := exceeds("max_schema_size", l.MaxSchemaSize, len(body)); err != nil {
+			return err
+		}
+
+		var doc interface{}
+		if err := json.Unmarshal([]byte(body), &doc); err != nil {
+			// Left for compiling the schema to report
+			continue
+		}
+		depth, patterns := schemaComplexity(doc)
+		if err := exceeds("max_schema_depth", l.MaxSchemaDepth, depth); err != nil {
+			return err
+		}
+		if err := exceeds("max_schema_patterns", l.MaxSchemaPatterns, patterns); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// schemaComplexity returns the nesting depth of a schema document and the
+// number of regular expressions it contains.
+func schemaComplexity(doc interface{}) (depth int, patterns int) {
+	switch v := doc.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if _, ok := child.(string); ok && key == "pattern" {
+				patterns++
+			}
+			if props, ok := child.(map[string]interface{}); ok && key == "patternProperties" {
+				patterns += len(props)
+			}
+			d, p := schemaComplexity(child)
+			if d > depth {
+				depth = d
+			}
+			patterns += p
+		}
+		depth++
+	case []interface{}:
+		for _, child := range v {
+			d, p := schemaComplexity(child)
+			if d > depth {
+				depth = d
+			}
+			patterns += p
+		}
+		depth++
+	}
+	return depth, patterns
+}
@@ -0,0 +1,37 @@
+package main
+
+import "testing"
+
+func TestLimits(t *testing.T) {
+	limits := Limits{MaxPayload: 4, MaxSchemaSize: 200, MaxSchemaDepth: 3, MaxSchemaPatterns: 1}
+
+	if err := limits.CheckPayload([]byte("1234")); err != nil {
+		t.Errorf("Expected payload to be within limits, got %v", err)
+	}
+	err := limits.CheckPayload([]byte("12345"))
+	if errorCode(err) != "413" {
+		t.Errorf("Expected a 413 error, got %v", err)
+	}
+
+	valid := Schema{Body: `{"type": "object", "properties": {"id": {"type": "string", "pattern": "^[a-z]+$"}}}`}
+	if err := limits.CheckSchema(valid); err != nil {
+		t.Errorf("Expected schema to be within limits, got %v", err)
+	}
+
+	invalid := map[string]Schema{
+		"max_schema_size":     {Body: `{"description": "` + string(make([]byte, 200)) + `"}`},
+		"max_schema_depth":    {Body: `{"properties": {"a": {"properties": {"b": {"type": "string"}}}}}`},
+		"max_schema_patterns": {Body: `{"patternProperties": {"^a": {}, "^b": {}}}`},
+	}
+	for limit, schema := range invalid {
+		err := limits.CheckSchema(schema)
+		limitErr, ok := err.(*LimitError)
+		if !ok || limitErr.Limit != limit {
+			t.Errorf("Expected %s to be exceeded, got %v", limit, err)
+		}
+	}
+
+	if err := (Limits{}).CheckSchema(invalid["max_schema_depth"]); err != nil {
+		t.Errorf("Expected no limits by default, got %v", err)
+	}
+}
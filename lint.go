@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Lint severities. Errors fail a CI check, warnings are only reported.
+const (
+	LintError   = "error"
+	LintWarning = "warning"
+)
+
+// LintIssue is a style or consistency problem in a schema body, that doesn't
+// stop it from compiling.
+type LintIssue struct {
+	Rule     string `json:"rule"`
+	Path     string `json:"path,omitempty"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+func (i LintIssue) String() string {
+	if i.Path == "" {
+		return fmt.Sprintf("%s: %s", i.Rule, i.Message)
+	}
+	return fmt.Sprintf("%s: `%s` %s", i.Rule, i.Path, i.Message)
+}
+
+// Lint checks a schema body against the registry's rules:
+//
+//   - root-type: the root declares a type
+//   - required-defined: required properties are defined in properties
+//   - enum-values: enums have at least one value
+//   - description: properties have a description
+//   - property-case: property names don't mix snake_case and camelCase
+func Lint(body string) ([]LintIssue, error) {
+	var doc interface{}
+	if err := json.Unmarshal([]byte(body), &doc); err != nil {
+		return nil, err
+	}
+	root := asObject(doc)
+
+	var issues []LintIssue
+	add := func(rule, path, severity, format string, args ...interface{}) {
+		issues = append(issues, LintIssue{Rule: rule, Path: path, Severity: severity, Message: fmt.Sprintf(format, args...)})
+	}
+
+	_, hasType := root["type"]
+	composed := false
+	for _, keyword := range []string{"$ref", "allOf", "anyOf", "oneOf", "enum", "const"} {
+		if _, ok := root[keyword]; ok {
+			composed = true
+		}
+	}
+	if !hasType && !composed {
+		add("root-type", "", LintError, "schema doesn't declare a type")
+	}
+
+	lintNode("", root, add)
+	return issues, nil
+}
+
+// overrideSeverities changes the severity of the issues of rules, and drops
+// those of rules turned off.
+func overrideSeverities(issues []LintIssue, severities map[string]string) []LintIssue {
+	var out []LintIssue
+	for _, issue := range issues {
+		if severity, ok := severities[issue.Rule]; ok {
+			if severity == LintOff {
+				continue
+			}
+			issue.Severity = severity
+		}
+		out = append(out, issue)
+	}
+	return out
+}
+
+func lintNode(path string, node map[string]interface{}, add func(rule, path, severity, format string, args ...interface{})) {
+	if enum, ok := node["enum"].([]interface{}); ok && len(enum) == 0 {
+		add("enum-values", path, LintError, "has an empty enum")
+	}
+
+	properties, hasProperties := node["properties"].(map[string]interface{})
+	if hasProperties {
+		for _, name := range sortedKeys(stringSet(node["required"])) {
+			if _, ok := properties[name]; !ok {
+				add("required-defined", path, LintError, "requires %q, which isn't defined", name)
+			}
+		}
+
+		var snake, camel []string
+		for _, name := range sortedKeys(properties) {
+			switch {
+			case strings.Contains(name, "_"):
+				snake = append(snake, name)
+			case strings.ToLower(name) != name:
+				camel = append(camel, name)
+			}
+
+			child := asObject(properties[name])
+			if _, ok := child["description"]; !ok {
+				add("description", joinPath(path, name), LintWarning, "has no description")
+			}
+			lintNode(joinPath(path, name), child, add)
+		}
+		if len(snake) > 0 && len(camel) > 0 {
+			add("property-case", path, LintWarning, "mixes snake_case (%s) and camelCase (%s) property names", strings.Join(snake, ", "), strings.Join(camel, ", "))
+		}
+	}
+
+	if items, ok := node["items"].(map[string]interface{}); ok {
+		lintNode(joinPath(path, "[]"), items, add)
+	}
+	if additional, ok := node["additionalProperties"].(map[string]interface{}); ok {
+		lintNode(joinPath(path, "*"), additional, add)
+	}
+	for _, keyword := range []string{"allOf", "anyOf", "oneOf"} {
+		list, _ := node[keyword].([]interface{})
+		for _, item := range list {
+			lintNode(path, asObject(item), add)
+		}
+	}
+	for _, keyword := range []string{"definitions", "$defs"} {
+		defs, _ := node[keyword].(map[string]interface{})
+		for _, name := range sortedKeys(defs) {
+			lintNode(keyword+"."+name, asObject(defs[name]), add)
+		}
+	}
+}
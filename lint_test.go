@@ -0,0 +1,32 @@
+package main
+
+import "testing"
+
+func TestLint(t *testing.T) {
+	issues, err := Lint(`{
+		"properties": {
+			"order_id": {"type": "string", "description": "The order"},
+			"customerId": {"type": "string"},
+			"status": {"enum": []}
+		},
+		"required": ["order_id", "total"]
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rules := map[string]int{}
+	for _, issue := range issues {
+		rules[issue.Rule]++
+	}
+	for rule, count := range map[string]int{"root-type": 1, "required-defined": 1, "enum-values": 1, "description": 2, "property-case": 1} {
+		if rules[rule] != count {
+			t.Errorf("Expected %d %s issues, got %v", count, rule, issues)
+		}
+	}
+
+	issues, err = Lint(`{"type": "object", "properties": {"id": {"type": "integer", "description": "The id"}}}`)
+	if err != nil || len(issues) != 0 {
+		t.Errorf("Expected a clean schema to pass, got %v, %v", issues, err)
+	}
+}
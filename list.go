@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+// Orders LIST can return schemas in.
+const (
+	// SortName orders schemas by name.
+	SortName = "name"
+	// SortUpdated orders schemas by when their current revision was stored,
+	// most recent first.
+	SortUpdated = "updated"
+	// SortUsed orders schemas by when a payload was last validated against
+	// them, most recent first and unused schemas last.
+	SortUsed = "used"
+)
+
+// Page sizes of LIST.
+const (
+	DefaultPageSize = 100
+	MaxPageSize     = 1000
+)
+
+// SchemaNextCursorHeader holds the cursor of the next page of a LIST
+// response. It's missing on the last page.
+const SchemaNextCursorHeader = "Schema-Next-Cursor"
+
+// ListRequest asks LIST for a page of schemas. Every field is optional.
+type ListRequest struct {
+	// Limit is the number of schemas of the page, DefaultPageSize if zero.
+	Limit int `json:"limit,omitempty"`
+	// Cursor is the Schema-Next-Cursor of the previous page.
+	Cursor string `json:"cursor,omitempty"`
+	// Sort is SortName, SortUpdated or SortUsed. It must stay the same for
+	// every page.
+	Sort string `json:"sort,omitempty"`
+}
+
+// listCursor is the position after the last schema of a page. Schemas are
+// sorted by name after time, so the position is unique.
+type listCursor struct {
+	Sort string    `json:"sort"`
+	Time time.Time `json:"time,omitempty"`
+	Name string    `json:"name"`
+}
+
+// listPage returns a page of the cached schemas with their usage, and the
+// cursor of the next page if there is one.
+func (reg *SchemaRegistry) listPage(req ListRequest) ([]Schema, string, error) {
+	if req.Sort == "" {
+		req.Sort = SortName
+	}
+	if req.Sort != SortName && req.Sort != SortUpdated && req.Sort != SortUsed {
+		return nil, "", newError("400", "unknown sort %q, use %s, %s or %s", req.Sort, SortName, SortUpdated, SortUsed)
+	}
+	if req.Limit == 0 {
+		req.Limit = DefaultPageSize
+	}
+	if req.Limit < 0 || req.Limit > MaxPageSize {
+		return nil, "", newError("400", "limit must be between 1 and %d", MaxPageSize)
+	}
+
+	var after *listCursor
+	if req.Cursor != "" {
+		after = &listCursor{}
+		data, err := base64.RawURLEncoding.DecodeString(req.Cursor)
+		if err == nil {
+			err = json.Unmarshal(data, after)
+		}
+		if err != nil || after.Sort != req.Sort {
+			return nil, "", newError("400", "invalid cursor for sort %q", req.Sort)
+		}
+	}
+
+	schemas := reg.list()
+	for i, schema := range schemas {
+		schemas[i] = reg.withUsage(schema)
+	}
+	if req.Sort != SortName {
+		// The list is sorted by name already
+		sort.SliceStable(schemas, func(i, j int) bool {
+			return sortTime(schemas[i], req.Sort).After(sortTime(schemas[j], req.Sort))
+		})
+	}
+
+	page := []Schema{}
+	for i, schema := range schemas {
+		if after != nil && !afterCursor(schema, *after) {
+			continue
+		}
+		if len(page) == req.Limit {
+			last := page[len(page)-1]
+			return page, encodeCursor(listCursor{Sort: req.Sort, Time: sortTime(last, req.Sort), Name: last.Name}), nil
+		}
+		page = append(page, schemas[i])
+	}
+	return page, "", nil
+}
+
+// sortTime returns the time a schema is sorted by, zero when sorting by name
+// or the schema has none.
+func sortTime(schema Schema, by string) time.Time {
+	var t *time.Time
+	switch by {
+	case SortUpdated:
+		t = schema.Updated
+	case SortUsed:
+		t = schema.LastUsed
+	}
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
+}
+
+// afterCursor returns true if the schema comes after the cursor.
+func afterCursor(schema Schema, cursor listCursor) bool {
+	t := sortTime(schema, cursor.Sort)
+	if !t.Equal(cursor.Time) {
+		return t.Before(cursor.Time)
+	}
+	return schema.Name > cursor.Name
+}
+
+func encodeCursor(cursor listCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
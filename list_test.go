@@ -0,0 +1,55 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestListPage(t *testing.T) {
+	reg := NewSchemaRegistry(nil, nil)
+	at := func(minutes int) *time.Time {
+		t := time.Date(2024, 1, 1, 0, minutes, 0, 0, time.UTC)
+		return &t
+	}
+	reg.schemas = map[string]Schema{
+		"a": {Name: "a", Updated: at(1)},
+		"b": {Name: "b", Updated: at(3)},
+		"c": {Name: "c", Updated: at(2)},
+		"d": {Name: "d", Updated: at(3)},
+		"e": {Name: "e", Updated: at(0)},
+	}
+
+	var names []string
+	req := ListRequest{Limit: 2, Sort: SortUpdated}
+	for {
+		page, next, err := reg.listPage(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page) > 2 {
+			t.Errorf("Expected at most 2 schemas per page, got %d", len(page))
+		}
+		names = append(names, dependentNames(page)...)
+		if next == "" {
+			break
+		}
+		req.Cursor = next
+	}
+	if !reflect.DeepEqual(names, []string{"b", "d", "c", "a", "e"}) {
+		t.Errorf("Expected every schema once, most recently updated first, got %v", names)
+	}
+
+	page, next, err := reg.listPage(ListRequest{})
+	if err != nil || next != "" || len(page) != 5 || page[0].Name != "a" {
+		t.Errorf("Expected a single page sorted by name, got %v, %q, %v", dependentNames(page), next, err)
+	}
+
+	_, next, _ = reg.listPage(ListRequest{Limit: 1})
+	if _, _, err := reg.listPage(ListRequest{Cursor: next, Sort: SortUsed}); errorCode(err) != "400" {
+		t.Errorf("Expected a cursor of another sort to be rejected, got %v", err)
+	}
+	if _, _, err := reg.listPage(ListRequest{Limit: MaxPageSize + 1}); errorCode(err) != "400" {
+		t.Errorf("Expected a page too large to be rejected, got %v", err)
+	}
+}
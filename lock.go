@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/micro"
+)
+
+// lockKey returns the kv key holding the lock of a schema.
+func lockKey(name string) string {
+	return "_lock." + nameToken(name)
+}
+
+// Lock freezes a schema: it can't be updated or unregistered until unlocked.
+type Lock struct {
+	By     string    `json:"by,omitempty"`
+	Time   time.Time `json:"time"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+// Lock subject: $SCHEMA.LOCK.<schema_name>
+func (reg *SchemaRegistry) Lock(r micro.Request) {
+	name := subjectName(r.Subject())
+
+	var lock Lock
+	if len(r.Data()) > 0 {
+		err := json.Unmarshal(r.Data(), &lock)
+		if err != nil {
+			r.Error("400", err.Error(), nil)
+			return
+		}
+	}
+
+	if !reg.authorize(r, name) {
+		return
+	}
+
+	lock.By = RequestIdentity(nats.Header(r.Headers()))
+	lock, err := reg.lock(name, lock)
+	if err != nil {
+		r.Error(errorCode(err), err.Error(), nil)
+		return
+	}
+
+	r.RespondJSON(lock)
+}
+
+// Unlock subject: $SCHEMA.UNLOCK.<schema_name>
+//
+// Unlocking takes the same authorization as reviewing proposals, so those
+// allowed to change a schema can't unfreeze it on their own.
+func (reg *SchemaRegistry) Unlock(r micro.Request) {
+	name := subjectName(r.Subject())
+
+	if !reg.authorizeReview(r, name) {
+		return
+	}
+
+	err := reg.unlock(name)
+	if err != nil {
+		r.Error(errorCode(err), err.Error(), nil)
+		return
+	}
+
+	r.Respond(nil)
+}
+
+// lock stores a lock on an existing schema.
+func (reg *SchemaRegistry) lock(name string, lock Lock) (Lock, error) {
+	if err := reg.writable(); err != nil {
+		return lock, err
+	}
+	_, exists, err := reg.current(name)
+	if err != nil {
+		return lock, err
+	}
+	if !exists {
+		return lock, newError("404", "Not found")
+	}
+
+	lock.Time = time.Now().UTC()
+	data, err := json.Marshal(lock)
+	if err != nil {
+		return lock, err
+	}
+	_, err = reg.kv.Create(lockKey(name), data)
+	if errors.Is(err, nats.ErrKeyExists) {
+		return lock, newError("409", "schema %q is already locked", name)
+	}
+	return lock, err
+}
+
+// unlock removes the lock of a schema.
+func (reg *SchemaRegistry) unlock(name string) error {
+	if err := reg.writable(); err != nil {
+		return err
+	}
+	_, err := reg.kv.Get(lockKey(name))
+	if errors.Is(err, nats.ErrKeyNotFound) {
+		return newError("409", "schema %q isn't locked", name)
+	}
+	if err != nil {
+		return err
+	}
+	return reg.kv.Delete(lockKey(name))
+}
+
+// checkLock returns a 423 error if the named schema is locked.
+func (reg *SchemaRegistry) checkLock(name string) error {
+	entry, err := reg.kv.Get(lockKey(name))
+	if errors.Is(err, nats.ErrKeyNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var lock Lock
+	if err := json.Unmarshal(entry.Value(), &lock); err != nil {
+		return err
+	}
+	return newError("423", "schema %q is locked%s, unlock it with %s.UNLOCK.%s first", name, lock.describe(), reg.prefix, name)
+}
+
+func (l Lock) describe() string {
+	var s string
+	if l.By != "" {
+		s += " by " + l.By
+	}
+	s += " since " + l.Time.Format(time.RFC3339)
+	if l.Reason != "" {
+		s += " (" + l.Reason + ")"
+	}
+	return s
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLockDescription(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := map[Lock]string{
+		{Time: at}: " since 2024-05-01T12:00:00Z",
+		{By: "alice@ops", Time: at, Reason: "release freeze"}: " by alice@ops since 2024-05-01T12:00:00Z (release freeze)",
+	}
+	for lock, expected := range tests {
+		if description := lock.describe(); description != expected {
+			t.Errorf("Expected %q, got %q", expected, description)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+)
+
+// Formats of the log output.
+const (
+	LogText = "text"
+	LogJSON = "json"
+)
+
+// NewLogHandler returns the handler of the log records of the level or above,
+// written to w in the format.
+func NewLogHandler(level, format string, w io.Writer) (slog.Handler, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("unknown log level %q, use debug, info, warn or error", level)
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl}
+	switch strings.ToLower(format) {
+	case LogText:
+		return slog.NewTextHandler(w, opts), nil
+	case LogJSON:
+		return slog.NewJSONHandler(w, opts), nil
+	}
+	return nil, fmt.Errorf("unknown log format %q, use %s or %s", format, LogText, LogJSON)
+}
+
+// SetupLogging makes the configured handler the default logger's, which the
+// standard log package writes to as well.
+func SetupLogging(cfg Config, w io.Writer) error {
+	handler, err := NewLogHandler(cfg.LogLevel, cfg.LogFormat, w)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// logger returns the logger of a component of the registry, such as the
+// watcher or the mirror. Its records say which one in the component field.
+func logger(component string) *slog.Logger {
+	return slog.Default().With("component", component)
+}
+
+// fatal logs an error and exits.
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+// requestLogger returns the logger of validating a message on subject against
+// a schema, whose records carry the subject, schema and revision, and the
+// message's request ID.
+func requestLogger(m *nats.Msg, schema Schema, subject string) *slog.Logger {
+	l := logger("validation").With("subject", subject, "schema", schema.Name, "revision", schema.Revision)
+	if m != nil {
+		l = l.With("request_id", m.Header.Get(SchemaRequestIDHeader))
+	}
+	return l
+}
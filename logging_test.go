@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestNewLogHandler(t *testing.T) {
+	tests := []struct {
+		level, format string
+		logged        []string
+		err           bool
+	}{
+		{"info", "text", []string{"level=INFO", "level=WARN"}, false},
+		{"DEBUG", "TEXT", []string{"level=DEBUG", "level=INFO", "level=WARN"}, false},
+		{"warn", "json", []string{`"level":"WARN"`}, false},
+		{"error", "json", nil, false},
+		{"verbose", "text", nil, true},
+		{"info", "logfmt", nil, true},
+	}
+	for _, test := range tests {
+		var out strings.Builder
+		handler, err := NewLogHandler(test.level, test.format, &out)
+		if (err != nil) != test.err {
+			t.Errorf("Expected level %q and format %q to fail: %v, got %v", test.level, test.format, test.err, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		l := slog.New(handler)
+		l.Debug("debugging")
+		l.Info("informing")
+		l.Warn("warning")
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		if out.Len() == 0 {
+			lines = nil
+		}
+		if len(lines) != len(test.logged) {
+			t.Errorf("Expected level %q to log %d records, got %q", test.level, len(test.logged), out.String())
+			continue
+		}
+		for i, line := range lines {
+			if !strings.Contains(line, test.logged[i]) {
+				t.Errorf("Expected record %d to contain %s, got %s", i, test.logged[i], line)
+			}
+			if test.format == "json" && !json.Valid([]byte(line)) {
+				t.Errorf("Expected a JSON record, got %s", line)
+			}
+		}
+	}
+}
+
+func TestSetupLogging(t *testing.T) {
+	defer slog.SetDefault(slog.Default())
+
+	var out strings.Builder
+	if err := SetupLogging(Config{LogLevel: "info", LogFormat: "json"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	logger("watcher").Info("Watching")
+	// The standard log package writes through the same handler
+	log.Print("standard")
+
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var record map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("Expected JSON records, got %s", line)
+		}
+		records = append(records, record)
+	}
+	if len(records) != 2 || records[0]["component"] != "watcher" || records[1]["msg"] != "standard" {
+		t.Errorf("Expected the component and standard records, got %v", records)
+	}
+
+	if err := SetupLogging(Config{LogLevel: "loud", LogFormat: "json"}, &out); err == nil {
+		t.Errorf("Expected an unknown level to fail")
+	}
+}
@@ -2,102 +2,522 @@ package main
 
 import (
 	"context"
-	"log"
-	"runtime"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
-	"github.com/invopop/jsonschema"
 	"github.com/nats-io/nats.go"
 	"github.com/nats-io/nats.go/micro"
 )
 
+// serviceVersion is the version the registry service advertises.
+const serviceVersion = "0.0.1"
+
 func main() {
-	err := Connect()
+	cfg := ParseConfig()
+	if err := SetupLogging(cfg, os.Stderr); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	if cfg.AsyncAPI {
+		err := PrintAsyncAPI(cfg, os.Stdout)
+		if err != nil {
+			fatal("error generating the AsyncAPI document", err)
+		}
+		return
+	}
+
+	if cfg.Graph != "" {
+		err := PrintGraph(cfg, cfg.Graph, os.Stdout)
+		if err != nil {
+			fatal("error rendering the schema graph", err)
+		}
+		return
+	}
+
+	if cfg.DocsDir != "" {
+		err := PrintDocs(cfg, cfg.DocsDir)
+		if err != nil {
+			fatal("error generating documentation", err)
+		}
+		return
+	}
+
+	if cfg.EnforceStream != "" {
+		err := PrintEnforceStream(cfg, cfg.EnforceStream, os.Stdout)
+		if err != nil {
+			fatal("error enforcing the stream", err)
+		}
+		return
+	}
+
+	if cfg.ImportConfluent != "" {
+		err := Import(cfg)
+		if err != nil {
+			fatal("error importing schemas", err)
+		}
+		return
+	}
+
+	if cfg.ImportOpenAPI != "" {
+		err := ImportOpenAPIFile(cfg)
+		if err != nil {
+			fatal("error importing schemas", err)
+		}
+		return
+	}
+
+	if cfg.MigrateTo != "" {
+		err := Migrate(cfg, os.Stdout)
+		if err != nil {
+			fatal("error migrating the bucket", err)
+		}
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	shutdown, err := Connect(ctx, cfg)
 	if err != nil {
-		panic(err)
+		fatal("error starting", err)
 	}
 
-	runtime.Goexit()
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-signals
+	slog.Info("Shutting down", "signal", sig.String())
+
+	// Stop the background watchers before draining
+	cancel()
+	err = shutdown()
+	if err != nil {
+		fatal("error shutting down", err)
+	}
+	slog.Info("Shut down schema_registry")
 }
 
-func Connect() error {
-	nc, err := nats.Connect(nats.DefaultURL)
+// Connect sets up the registry and its service endpoints. It returns a function
+// that gracefully shuts them down again, letting in-flight validations finish.
+func Connect(ctx context.Context, cfg Config) (func() error, error) {
+	if cfg.Mirror != "" && (cfg.SeedDir != "" || cfg.SyncBucket != "") {
+		return nil, errors.New("mirrors can't be seeded or synced, schemas come from upstream")
+	}
+	if cfg.PprofAddr != "" && cfg.PprofAddr == cfg.HTTPAddr {
+		return nil, errors.New("pprof is unauthenticated and can't be served on the address of the HTTP gateway")
+	}
+	if cfg.Inline && (cfg.InlinePrefix == "" || strings.ContainsAny(cfg.InlinePrefix, "*> ")) {
+		return nil, fmt.Errorf("invalid inline subject prefix %q", cfg.InlinePrefix)
+	}
+	if cfg.ValidateSubscriptions < 1 {
+		return nil, fmt.Errorf("need at least one validation subscription, got %d", cfg.ValidateSubscriptions)
+	}
+	if strings.ContainsAny(cfg.ValidatePrefix, "*> ") || strings.HasSuffix(cfg.ValidatePrefix, ".") {
+		return nil, fmt.Errorf("invalid validation subject prefix %q", cfg.ValidatePrefix)
+	}
+	if cfg.AuditStreams != "" && (cfg.AuditInterval <= 0 || cfg.AuditSample < 1 || cfg.AuditSample > maxSampleCount) {
+		return nil, fmt.Errorf("auditing needs a positive interval and a sample of 1 to %d messages", maxSampleCount)
+	}
+	if cfg.RequireApproval && cfg.ApproversFile == "" {
+		return nil, errors.New("requiring approval needs --approvers to say who may approve")
+	}
+	if cfg.Plugins.Bucket != "" && (cfg.Plugins.Memory < 1 || cfg.Plugins.Timeout <= 0) {
+		return nil, errors.New("plugins need a positive memory limit and timeout")
+	}
+	if cfg.ReadOnly && (cfg.SeedDir != "" || cfg.SyncBucket != "") {
+		return nil, errors.New("read-only registries can't be seeded or synced")
+	}
+
+	envs, err := ParseEnvironments(cfg.Environments)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	js, err := nc.JetStream()
+	TrustRequestInfo(cfg.TrustRequestInfo)
+	auth, err := LoadAuthorizer(cfg.AuthFile)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	kv, err := js.CreateKeyValue(&nats.KeyValueConfig{
-		Bucket:      "schema_registry",
-		Description: "Register and manages schemas.",
-		History:     10,
-	})
+	approvers, err := LoadAuthorizer(cfg.ApproversFile)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// Create our schema registry
-	registry := NewSchemaRegistry(kv, nc)
-	err = registry.Watch(context.Background())
+	verifier, err := LoadVerifier(cfg.SigningKeysFile)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	svc, err := micro.AddService(nc, micro.Config{
-		Name:        "schema_registry",
-		Description: "Register and manage schemas. Validate payloads against schemas.",
-		Version:     "0.0.1",
-	})
+	webhooks, err := LoadWebhooks(cfg.WebhooksFile)
+	if err != nil {
+		return nil, err
+	}
+
+	opts, err := loadSettings(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	decrypters, err := LoadAuthorizer(cfg.DecryptersFile)
+	if err != nil {
+		return nil, err
+	}
+
+	nc, js, kv, err := OpenBucket(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	plugins, err := OpenPlugins(js, cfg.Plugins)
+	if err != nil {
+		return nil, err
+	}
+
+	keyring, err := OpenKeyring(js, cfg.EncryptionKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.ArchiveStream != "" {
+		err = CreateArchive(js, kv, cfg.ArchiveStream)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.DeadLetterStream != "" {
+		err = CreateDeadLetterStream(js, cfg.DeadLetterStream)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Create our schema registry
+	registry := newRegistry(cfg, kv, nc, js, auth, approvers, verifier)
+	registry.archive = cfg.ArchiveStream
+	registry.deadLetter = cfg.DeadLetterStream
+	registry.readOnly = cfg.ReadOnly || cfg.Mirror != ""
+	registry.webhooks = webhooks
+	registry.opts.Store(opts)
+	registry.plugins = plugins
+	registry.keyring = keyring
+	registry.decrypters = decrypters
+
+	if cfg.SeedDir != "" {
+		schemas, err := LoadSeedDir(cfg.SeedDir)
+		if err != nil {
+			return nil, err
+		}
+		err = registry.Seed(schemas)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = registry.Watch(ctx)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	// Read-only registries can't write to the bucket, they only know their own
+	// usage and leave expiring schemas to upstream
+	if !registry.readOnly {
+		if cfg.LeaderLease > 0 {
+			registry.elector = NewElector(registry.kv, cfg.LeaderLease)
+			registry.elector.Run(ctx, JobExpiry, JobAudit)
+		}
+		registry.usage = NewUsageLog(registry)
+		registry.usage.Run(ctx)
+		registry.RunExpiry(ctx)
 	}
+	registries := []*SchemaRegistry{registry}
 
-	reflector := jsonschema.Reflector{
-		DoNotReference: true,
+	if cfg.Mirror != "" {
+		mirror, err := NewMirror(registry, cfg.Mirror)
+		if err != nil {
+			return nil, err
+		}
+		err = mirror.Run(ctx)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	schema, err := reflector.Reflect(&Schema{}).MarshalJSON()
+	// Validation requests are consumed from the work queue instead
+	subscriptions := cfg.ValidateSubscriptions
+	if cfg.WorkQueue != "" {
+		subscriptions = 0
+	}
+	service, err := serve(nc, registry, "schema_registry", cfg.QueueGroup, cfg.ValidationWorkers, subscriptions)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	var workQueue *WorkQueue
+	if cfg.WorkQueue != "" {
+		err = CreateWorkQueue(js, cfg.WorkQueue, registry.validateSubject())
+		if err != nil {
+			return nil, err
+		}
+		workQueue, err = NewWorkQueue(registry, cfg.WorkQueue, cfg.QueueGroup, cfg.ValidationWorkers)
+		if err != nil {
+			return nil, err
+		}
+		workQueue.Run(ctx)
+	}
+	svc := service.svc
+	services := []*registryService{service}
+
+	if cfg.Contracts {
+		err = NewContracts(registry, "schema_registry_contracts").Run(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var inline *Inline
+	if cfg.Inline {
+		inline = NewInline(registry, cfg.InlinePrefix, cfg.QueueGroup, cfg.ValidationWorkers)
+		err = inline.Run(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(envs) > 0 {
+		environments := NewEnvironments()
+		for _, env := range envs {
+			bucket := cfg.Bucket
+			bucket.Name = cfg.Bucket.Name + "_" + env
+			kv, err := openBucket(js, bucket)
+			if err != nil {
+				return nil, err
+			}
+
+			envRegistry := newRegistry(cfg, kv, nc, js, auth, approvers, verifier)
+			envRegistry.prefix = DefaultPrefix + "." + env
+			if cfg.ValidatePrefix != "" {
+				envRegistry.validatePrefix = cfg.ValidatePrefix + "." + env
+			}
+			envRegistry.readOnly = cfg.ReadOnly
+			envRegistry.webhooks = webhooks
+			envRegistry.plugins = plugins
+			envRegistry.keyring = keyring
+			envRegistry.decrypters = decrypters
+			// Every environment has its own schemas and validation rates
+			envOpts, err := loadSettings(cfg)
+			if err != nil {
+				return nil, err
+			}
+			envRegistry.opts.Store(envOpts)
+			err = envRegistry.Watch(ctx)
+			if err != nil {
+				return nil, err
+			}
+			if !envRegistry.readOnly {
+				if cfg.LeaderLease > 0 {
+					envRegistry.elector = NewElector(envRegistry.kv, cfg.LeaderLease)
+					envRegistry.elector.Run(ctx, JobExpiry)
+				}
+				envRegistry.usage = NewUsageLog(envRegistry)
+				envRegistry.usage.Run(ctx)
+				envRegistry.RunExpiry(ctx)
+			}
+
+			envService, err := serve(nc, envRegistry, "schema_registry_"+env, cfg.QueueGroup+"_"+env, cfg.ValidationWorkers, cfg.ValidateSubscriptions)
+			if err != nil {
+				return nil, err
+			}
+			if cfg.Contracts {
+				err = NewContracts(envRegistry, "schema_registry_"+env+"_contracts").Run(ctx)
+				if err != nil {
+					return nil, err
+				}
+			}
+			environments.Add(env, envRegistry)
+			registries = append(registries, envRegistry)
+			services = append(services, envService)
+		}
+
+		svc.AddEndpoint("promote", withRequestID(environments.Promote),
+			micro.WithEndpointSubject("$SCHEMA.PROMOTE.*"))
+	}
+
+	reloader := NewReloader(cfg, registries)
+	reloader.Run(ctx)
+	svc.AddEndpoint("reload", withRequestID(reloader.Handle),
+		micro.WithEndpointSubject("$SCHEMA.RELOAD"))
+
+	// The watch can silently stop when the server restarts, so start over
+	// whenever we reconnect
+	nc.SetReconnectHandler(func(nc *nats.Conn) {
+		slog.Info("Reconnected to NATS", "url", nc.ConnectedUrl())
+		for _, registry := range registries {
+			registry.Resync()
+		}
+	})
+
+	if cfg.SyncBucket != "" {
+		store, err := js.ObjectStore(cfg.SyncBucket)
+		if errors.Is(err, nats.ErrStreamNotFound) {
+			store, err = js.CreateObjectStore(&nats.ObjectStoreConfig{
+				Bucket:      cfg.SyncBucket,
+				Description: "Schema definitions synced into the registry.",
+			})
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		syncer := NewSyncer(registry, store, cfg.SyncPrune)
+		err = syncer.Run(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		svc.AddEndpoint("sync_status", withRequestID(syncer.Status),
+			micro.WithEndpointSubject("$SCHEMA.SYNC.STATUS"))
+	}
+
+	if streams := ParseList(cfg.AuditStreams); len(streams) > 0 {
+		auditor := NewAuditor(registry, streams, cfg.AuditInterval, cfg.AuditSample)
+		auditor.Run(ctx)
+
+		svc.AddEndpoint("drift_status", withRequestID(auditor.Status),
+			micro.WithEndpointSubject("$SCHEMA.DRIFT.STATUS"))
+	}
+
+	var servers []*http.Server
+	if cfg.HTTPAddr != "" {
+		gateway := NewGateway(registry)
+		if cfg.UI {
+			gateway.Handle("/", UIHandler())
+		}
+		servers = append(servers, serveHTTP(cfg.HTTPAddr, gateway))
+		logger("http").Info("Serving HTTP gateway", "addr", cfg.HTTPAddr)
+	}
+	// The profiles aren't authenticated, so they're kept off the gateway
+	if cfg.PprofAddr != "" {
+		servers = append(servers, serveHTTP(cfg.PprofAddr, PprofHandler()))
+		logger("http").Info("Serving pprof profiles", "addr", cfg.PprofAddr)
+	}
+
+	slog.Info("Connected to NATS for schema_registry", "url", nc.ConnectedUrl())
+
+	shutdown := func() error {
+		defer nc.Close()
+
+		// Every step takes from the same shutdown timeout
+		deadline := time.Now().Add(cfg.ShutdownTimeout)
+
+		shutdownCtx, cancel := context.WithDeadline(context.Background(), deadline)
+		defer cancel()
+		for _, server := range servers {
+			err := server.Shutdown(shutdownCtx)
+			if err != nil {
+				return err
+			}
+		}
+
+		// Stop taking new validations, and let the ones already received finish
+		for _, service := range services {
+			err := service.stop(deadline)
+			if err != nil {
+				return err
+			}
+		}
+		if inline != nil {
+			err := inline.Stop(deadline)
+			if err != nil {
+				return err
+			}
+		}
+		if workQueue != nil {
+			err := workQueue.Wait(deadline)
+			if err != nil {
+				return err
+			}
+		}
+
+		// Make sure forwarded messages and responses reach the server
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return errors.New("shutdown timed out before flushing")
+		}
+		return nc.FlushTimeout(remaining)
 	}
 
-	svc.AddEndpoint("register", micro.HandlerFunc(registry.RegisterSchema),
-		micro.WithEndpointSubject("$SCHEMA.REGISTER.*"),
-		micro.WithEndpointSchema(&micro.Schema{
-			Request:  string(schema),
-			Response: string(schema),
-		}))
+	return shutdown, nil
+}
 
-	svc.AddEndpoint("get", micro.HandlerFunc(registry.GetSchema),
-		micro.WithEndpointSubject("$SCHEMA.GET.*"),
-		micro.WithEndpointSchema(&micro.Schema{
-			Response: string(schema),
-		}))
+// serveHTTP serves a handler on an address in a goroutine, logging errors.
+func serveHTTP(addr string, handler http.Handler) *http.Server {
+	server := &http.Server{Addr: addr, Handler: handler}
+	go func() {
+		err := server.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger("http").Error("error serving HTTP", "addr", addr, "error", err)
+		}
+	}()
+	return server
+}
 
-	svc.AddEndpoint("unregister", micro.HandlerFunc(registry.UnregisterSchema),
-		micro.WithEndpointSubject("$SCHEMA.UNREGISTER.*"))
+// newRegistry creates a registry with the settings shared by the main registry
+// and every environment.
+func newRegistry(cfg Config, kv nats.KeyValue, nc *nats.Conn, js nats.JetStreamContext, auth, approvers *Authorizer, verifier *Verifier) *SchemaRegistry {
+	registry := NewSchemaRegistry(kv, nc)
+	registry.js = js
+	registry.responseTimeout = cfg.ResponseTimeout
+	registry.failures = NewFailureLog(cfg.FailureSamples)
+	registry.auth = auth
+	registry.approvers = approvers
+	registry.requireApproval = cfg.RequireApproval
+	registry.verifier = verifier
+	registry.immutable = cfg.Immutable
+	registry.schemaTTL = cfg.SchemaTTL
+	registry.validationTimeout = cfg.ValidationTimeout
+	registry.results = NewResultCache(cfg.ResultCacheSize)
+	registry.objectBuckets = ParseList(cfg.ObjectBuckets)
+	registry.referenceBuckets = ParseList(cfg.ReferenceBuckets)
+	registry.keys = NewKeyCache(cfg.ReferenceCacheTTL)
+	registry.validatePrefix = cfg.ValidatePrefix
+	registry.forwardRetries = cfg.ForwardRetries
+	registry.forwardBackoff = cfg.ForwardBackoff
+	return registry
+}
 
-	svc.AddEndpoint("update", micro.HandlerFunc(registry.UpdateSchema),
-		micro.WithEndpointSubject("$SCHEMA.UPDATE.*"),
-		micro.WithEndpointSchema(&micro.Schema{
-			Request:  string(schema),
-			Response: string(schema),
-		}))
+// OpenBucket connects to NATS and opens the schema bucket, in the configured
+// JetStream domain, creating it if it doesn't exist yet and reconciling its
+// settings with the config otherwise.
+func OpenBucket(cfg Config) (*nats.Conn, nats.JetStreamContext, nats.KeyValue, error) {
+	opts, err := jetStreamOptions(cfg.JSDomain, cfg.JSAPIPrefix)
+	if err != nil {
+		return nil, nil, nil, err
+	}
 
-	svc.AddEndpoint("validate", micro.HandlerFunc(func(r micro.Request) {}),
-		micro.WithEndpointSubject("$SCHEMA.VALIDATE.>"))
+	nc, err := nats.Connect(cfg.Server)
+	if err != nil {
+		return nil, nil, nil, err
+	}
 
-	// Schema validation needs to have more access to the NATS message, namely the reply subject,
-	// so we need to use a raw subscription instead of the service API.
-	_, err = nc.QueueSubscribe("$SCHEMA.VALIDATE.>", "schema_registry", registry.ValidatePayload)
+	js, err := nc.JetStream(opts...)
 	if err != nil {
-		return err
+		nc.Close()
+		return nil, nil, nil, err
 	}
 
-	log.Println("Connected to NATS for schema_registry", nc.ConnectedUrl())
+	kv, err := openBucket(js, cfg.Bucket)
+	if err != nil {
+		nc.Close()
+		return nil, nil, nil, err
+	}
 
-	return nil
+	return nc, js, kv, nil
 }
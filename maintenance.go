@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/micro"
+)
+
+// maintenanceKey is the kv key maintenance mode is stored under, so every
+// instance serving the bucket is in maintenance at once.
+const maintenanceKey = "_maintenance.registry"
+
+// Maintenance is whether the registry is in maintenance mode, rejecting every
+// change to the schemas while payloads are still validated against the
+// cached ones, such as during a bucket migration.
+type Maintenance struct {
+	Enabled bool       `json:"enabled"`
+	Reason  string     `json:"reason,omitempty"`
+	By      string     `json:"by,omitempty"`
+	Since   *time.Time `json:"since,omitempty"`
+}
+
+// maintenanceError returns the error changes are rejected with during
+// maintenance.
+func maintenanceError(m Maintenance) error {
+	reason := ""
+	if m.Reason != "" {
+		reason = fmt.Sprintf(" (%s)", m.Reason)
+	}
+	return newError("503", "registry is in maintenance%s since %s, schemas can't be changed until it ends", reason, m.Since.Format(time.RFC3339))
+}
+
+// maintenance returns the maintenance mode of the registry.
+func (reg *SchemaRegistry) maintenance() (Maintenance, error) {
+	entry, err := reg.kv.Get(maintenanceKey)
+	if errors.Is(err, nats.ErrKeyNotFound) {
+		return Maintenance{}, nil
+	}
+	if err != nil {
+		return Maintenance{}, err
+	}
+
+	var m Maintenance
+	if err := json.Unmarshal(entry.Value(), &m); err != nil {
+		return m, fmt.Errorf("corrupt maintenance mode: %w", err)
+	}
+	return m, nil
+}
+
+// writable returns an error if the schemas can't be changed, because the
+// registry is read-only or in maintenance.
+func (reg *SchemaRegistry) writable() error {
+	if reg.readOnly {
+		return errReadOnly
+	}
+	m, err := reg.maintenance()
+	if err != nil {
+		return err
+	}
+	if m.Enabled {
+		return maintenanceError(m)
+	}
+	return nil
+}
+
+// setMaintenance puts the registry in maintenance mode, or takes it out.
+func (reg *SchemaRegistry) setMaintenance(m Maintenance) (Maintenance, error) {
+	if reg.readOnly {
+		return m, errReadOnly
+	}
+	if !m.Enabled {
+		err := reg.kv.Delete(maintenanceKey)
+		return Maintenance{}, err
+	}
+
+	since := time.Now().UTC()
+	m.Since = &since
+	data, err := json.Marshal(m)
+	if err != nil {
+		return m, err
+	}
+	_, err = reg.kv.Put(maintenanceKey, data)
+	return m, err
+}
+
+// Maintenance subject: $SCHEMA.MAINTENANCE
+//
+// An empty request returns the maintenance mode. Otherwise the request is the
+// mode to switch to, as {"enabled": true, "reason": "migrating the bucket"},
+// which takes the right to modify every schema.
+func (reg *SchemaRegistry) Maintenance(r micro.Request) {
+	if len(r.Data()) == 0 {
+		m, err := reg.maintenance()
+		if err != nil {
+			r.Error("500", err.Error(), nil)
+			return
+		}
+		r.RespondJSON(m)
+		return
+	}
+
+	var m Maintenance
+	err := json.Unmarshal(r.Data(), &m)
+	if err != nil {
+		r.Error("400", err.Error(), nil)
+		return
+	}
+
+	if !reg.auth.Allowed("*", nats.Header(r.Headers())) {
+		r.Error("403", "not authorized to change the maintenance mode", nil)
+		return
+	}
+
+	m.By = RequestIdentity(nats.Header(r.Headers()))
+	m, err = reg.setMaintenance(m)
+	if err != nil {
+		r.Error(errorCode(err), err.Error(), nil)
+		return
+	}
+	logger("maintenance").Info("Changed maintenance mode", "enabled", m.Enabled, "reason", m.Reason, "by", m.By)
+
+	r.RespondJSON(m)
+}
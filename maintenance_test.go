@@ -0,0 +1,23 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMaintenanceError(t *testing.T) {
+	since := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	err := maintenanceError(Maintenance{Enabled: true, Reason: "migrating the bucket", Since: &since})
+	if errorCode(err) != "503" {
+		t.Errorf("Expected a 503, got %v", errorCode(err))
+	}
+	if !strings.Contains(err.Error(), "(migrating the bucket) since 2026-03-01T12:00:00Z") {
+		t.Errorf("Expected the reason and time in the error, got %q", err.Error())
+	}
+
+	reg := &SchemaRegistry{readOnly: true}
+	if err := reg.writable(); err != errReadOnly {
+		t.Errorf("Expected a read-only registry to not be writable, got %v", err)
+	}
+}
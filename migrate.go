@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+)
+
+// migratedKey returns true if a key is copied when migrating a bucket. Leader
+// leases belong to the instances of the old bucket, and the maintenance mode
+// the migration typically runs under isn't meant to carry over.
+func migratedKey(key string) bool {
+	return !strings.HasPrefix(key, "_leader.") && key != maintenanceKey
+}
+
+// migratedValue returns the value of a key as it's copied to a new bucket.
+// Revisions are numbered anew there, so the previous revision of a canary is
+// renumbered along.
+func migratedValue(key string, value []byte, revisions map[uint64]uint64) ([]byte, error) {
+	if strings.HasPrefix(key, "_") || strings.Contains(key, ".") || !bytes.Contains(value, []byte(`"canary"`)) {
+		return value, nil
+	}
+
+	var schema Schema
+	if err := json.Unmarshal(value, &schema); err != nil {
+		return nil, fmt.Errorf("corrupt schema %q: %w", key, err)
+	}
+	if schema.Canary == nil {
+		return value, nil
+	}
+	if revision, ok := revisions[schema.Canary.Previous]; ok {
+		schema.Canary.Previous = revision
+	}
+	return json.Marshal(schema)
+}
+
+// MigrationReport describes a copied bucket.
+type MigrationReport struct {
+	// Keys counts the keys of the copied bucket, Entries the revisions and
+	// deletes of them copied.
+	Keys    int
+	Entries int
+}
+
+// MigrateBucket copies every key of a bucket into an empty one, with the
+// history the source still has and the target keeps, in the order they were
+// written. It then verifies the latest value of every key was copied.
+func MigrateBucket(source, target nats.KeyValue) (MigrationReport, error) {
+	var report MigrationReport
+
+	_, err := target.Keys()
+	if err == nil {
+		return report, fmt.Errorf("bucket %q isn't empty", target.Bucket())
+	}
+	if !errors.Is(err, nats.ErrNoKeysFound) {
+		return report, err
+	}
+
+	watcher, err := source.WatchAll(nats.IncludeHistory())
+	if err != nil {
+		return report, err
+	}
+	defer watcher.Stop()
+
+	// revisions maps those of the source to those of the target
+	revisions := map[uint64]uint64{}
+	for entry := range watcher.Updates() {
+		if entry == nil {
+			break
+		}
+		key := entry.Key()
+		if !migratedKey(key) {
+			continue
+		}
+
+		switch entry.Operation() {
+		case nats.KeyValuePut:
+			value, err := migratedValue(key, entry.Value(), revisions)
+			if err != nil {
+				return report, err
+			}
+			revision, err := target.Put(key, value)
+			if err != nil {
+				return report, fmt.Errorf("copying %q: %w", key, err)
+			}
+			revisions[entry.Revision()] = revision
+		case nats.KeyValueDelete:
+			err = target.Delete(key)
+		case nats.KeyValuePurge:
+			err = target.Purge(key)
+		}
+		if err != nil {
+			return report, fmt.Errorf("copying %q: %w", key, err)
+		}
+		report.Entries++
+	}
+
+	report.Keys, err = verifyMigration(source, target, revisions)
+	return report, err
+}
+
+// verifyMigration compares the latest value of every key of a bucket with
+// that of its copy, and returns how many keys there are.
+func verifyMigration(source, target nats.KeyValue, revisions map[uint64]uint64) (int, error) {
+	latest := func(kv nats.KeyValue) (map[string]nats.KeyValueEntry, error) {
+		watcher, err := kv.WatchAll()
+		if err != nil {
+			return nil, err
+		}
+		defer watcher.Stop()
+
+		entries := map[string]nats.KeyValueEntry{}
+		for entry := range watcher.Updates() {
+			if entry == nil {
+				break
+			}
+			if migratedKey(entry.Key()) {
+				entries[entry.Key()] = entry
+			}
+		}
+		return entries, nil
+	}
+
+	sourceEntries, err := latest(source)
+	if err != nil {
+		return 0, err
+	}
+	targetEntries, err := latest(target)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(sourceEntries) != len(targetEntries) {
+		return 0, fmt.Errorf("verification failed: bucket %q has %d keys, its copy %d", source.Bucket(), len(sourceEntries), len(targetEntries))
+	}
+	for _, key := range sortedKeys(sourceEntries) {
+		s, t := sourceEntries[key], targetEntries[key]
+		if t == nil || s.Operation() != t.Operation() {
+			return 0, fmt.Errorf("verification failed: key %q wasn't copied", key)
+		}
+		if s.Operation() != nats.KeyValuePut {
+			continue
+		}
+		value, err := migratedValue(key, s.Value(), revisions)
+		if err != nil {
+			return 0, err
+		}
+		if !bytes.Equal(value, t.Value()) {
+			return 0, fmt.Errorf("verification failed: key %q differs from its copy", key)
+		}
+	}
+	return len(sourceEntries), nil
+}
+
+// Migrate copies the schema bucket given in the config into a new bucket,
+// in the same JetStream domain unless another one is given, and describes the
+// copy.
+func Migrate(cfg Config, w io.Writer) error {
+	domain, apiPrefix := cfg.MigrateDomain, ""
+	if domain == "" {
+		domain, apiPrefix = cfg.JSDomain, cfg.JSAPIPrefix
+	}
+	if cfg.MigrateTo == cfg.Bucket.Name && domain == cfg.JSDomain && apiPrefix == cfg.JSAPIPrefix {
+		return fmt.Errorf("can't migrate bucket %q onto itself", cfg.Bucket.Name)
+	}
+	opts, err := jetStreamOptions(domain, apiPrefix)
+	if err != nil {
+		return err
+	}
+
+	nc, _, source, err := OpenBucket(cfg)
+	if err != nil {
+		return err
+	}
+	defer nc.Close()
+
+	js, err := nc.JetStream(opts...)
+	if err != nil {
+		return err
+	}
+	bucket := cfg.Bucket
+	bucket.Name = cfg.MigrateTo
+	target, err := openBucket(js, bucket)
+	if err != nil {
+		return err
+	}
+
+	registry := NewSchemaRegistry(source, nc)
+	if m, err := registry.maintenance(); err == nil && !m.Enabled {
+		logger("migrate").Warn("The registry isn't in maintenance, changes made while migrating may be missed", "bucket", cfg.Bucket.Name)
+	}
+
+	report, err := MigrateBucket(source, target)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "Copied %d keys of bucket %s to %s with %d revisions and deletes, and verified them\n", report.Keys, cfg.Bucket.Name, cfg.MigrateTo, report.Entries)
+	fmt.Fprintln(w, "Revisions are numbered anew, pin validation requests to the revisions of the new bucket")
+	return nil
+}
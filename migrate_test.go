@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMigratedKey(t *testing.T) {
+	for key, want := range map[string]bool{
+		"orders":            true,
+		"_fp.abc":           true,
+		"_version.orders.2": true,
+		"_leader.expiry":    false,
+		maintenanceKey:      false,
+	} {
+		if got := migratedKey(key); got != want {
+			t.Errorf("Expected migratedKey(%q) to be %v, got %v", key, want, got)
+		}
+	}
+}
+
+func TestMigratedValue(t *testing.T) {
+	revisions := map[uint64]uint64{4: 2}
+
+	plain := []byte(`{"name":"orders","body":"{}"}`)
+	if value, err := migratedValue("orders", plain, revisions); err != nil || string(value) != string(plain) {
+		t.Errorf("Expected a schema without a canary to be copied as is, got %s, %v", value, err)
+	}
+
+	data, _ := json.Marshal(Schema{Name: "orders", Body: "{}", Canary: &Canary{Period: "1h", Previous: 4}})
+	value, err := migratedValue("orders", data, revisions)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	var schema Schema
+	json.Unmarshal(value, &schema)
+	if schema.Canary.Previous != 2 {
+		t.Errorf("Expected the canary's previous revision to be renumbered to 2, got %d", schema.Canary.Previous)
+	}
+
+	if value, _ := migratedValue("_version.orders.2", data, revisions); string(value) != string(data) {
+		t.Errorf("Expected stored versions to be copied as is, got %s", value)
+	}
+}
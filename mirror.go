@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// mirrorSubject is the subscription to the upstream change events.
+const mirrorSubject = EventsPrefix + ".>"
+
+// Mirror keeps a read-only replica of the schemas of a registry in another
+// cluster. It follows the upstream change events and writes them to the local
+// bucket, so the local registry keeps validating when the link is down.
+type Mirror struct {
+	reg      *SchemaRegistry
+	upstream *nats.Conn
+	resync   chan struct{}
+}
+
+// NewMirror connects to the upstream cluster at url. The connection is retried
+// forever, so a mirror can start while the link is down.
+func NewMirror(reg *SchemaRegistry, url string) (*Mirror, error) {
+	m := &Mirror{reg: reg, resync: make(chan struct{}, 1)}
+
+	// Catch up on whatever was missed whenever the link comes back
+	catchUp := func(nc *nats.Conn) {
+		logger("mirror").Info("Connected to upstream registry", "url", nc.ConnectedUrl())
+		m.Resync()
+	}
+
+	upstream, err := nats.Connect(url,
+		nats.Name("schema_registry mirror"),
+		nats.RetryOnFailedConnect(true),
+		nats.MaxReconnects(-1),
+		nats.ConnectHandler(catchUp),
+		nats.ReconnectHandler(catchUp),
+		nats.DisconnectErrHandler(func(nc *nats.Conn, err error) {
+			logger("mirror").Warn("Disconnected from upstream registry", "error", err)
+		}),
+		nats.ErrorHandler(m.handleError),
+	)
+	if err != nil {
+		return nil, err
+	}
+	m.upstream = upstream
+
+	if upstream.IsConnected() {
+		m.Resync()
+	}
+	return m, nil
+}
+
+// Resync asks the mirror to reconcile with a full snapshot of the upstream
+// registry.
+func (m *Mirror) Resync() {
+	select {
+	case m.resync <- struct{}{}:
+	default:
+	}
+}
+
+// Run applies upstream changes until the context is canceled. Events are
+// subscribed to before taking a snapshot, so nothing in between is lost, and
+// events dropped for coming in faster than they're applied are caught up on
+// with a new snapshot.
+func (m *Mirror) Run(ctx context.Context) error {
+	events := make(chan *nats.Msg, 256)
+	sub, err := m.upstream.ChanSubscribe(mirrorSubject, events)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		defer m.upstream.Close()
+		defer sub.Unsubscribe()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-m.resync:
+				err := m.snapshot()
+				if err != nil {
+					logger("mirror").Error("error mirroring upstream registry", "error", err)
+					// Try again once the link is back
+					time.AfterFunc(time.Second, m.retry)
+				}
+			case msg := <-events:
+				var event SchemaEvent
+				err := json.Unmarshal(msg.Data, &event)
+				if err != nil {
+					logger("mirror").Error("error decoding schema event", "error", err)
+					continue
+				}
+				err = m.apply(event)
+				if err != nil {
+					logger("mirror").Error("error mirroring schema", "schema", event.Name, "error", err)
+				}
+			}
+		}
+	}()
+
+	return nil
+}
+
+// handleError takes a new snapshot when events were dropped because the
+// mirror couldn't keep up with them, as the local schemas may have missed
+// some changes.
+func (m *Mirror) handleError(nc *nats.Conn, sub *nats.Subscription, err error) {
+	if !errors.Is(err, nats.ErrSlowConsumer) || sub == nil || sub.Subject != mirrorSubject {
+		logger("mirror").Error("error from upstream registry", "error", err)
+		return
+	}
+	logger("mirror").Warn("Dropped upstream events, resyncing", "error", err)
+	m.Resync()
+}
+
+func (m *Mirror) retry() {
+	if m.upstream.IsConnected() {
+		m.Resync()
+	}
+}
+
+// snapshot replaces the local schemas with the ones registered upstream.
+func (m *Mirror) snapshot() error {
+	var schemas []Schema
+	req := ListRequest{Limit: MaxPageSize}
+	for {
+		data, err := json.Marshal(req)
+		if err != nil {
+			return err
+		}
+		msg, err := m.upstream.Request("$SCHEMA.LIST", data, m.reg.responseTimeout)
+		if err != nil {
+			return err
+		}
+
+		var page []Schema
+		err = json.Unmarshal(msg.Data, &page)
+		if err != nil {
+			return err
+		}
+		schemas = append(schemas, page...)
+
+		req.Cursor = msg.Header.Get(SchemaNextCursorHeader)
+		if req.Cursor == "" {
+			break
+		}
+	}
+
+	upstream := map[string]bool{}
+	for _, schema := range schemas {
+		schema := schema
+		upstream[schema.Name] = true
+		err := m.apply(SchemaEvent{Operation: EventPut, Name: schema.Name, Schema: &schema})
+		if err != nil {
+			return err
+		}
+	}
+
+	local, err := loadSchemas(m.reg.kv)
+	if err != nil {
+		return err
+	}
+	for _, schema := range local {
+		if upstream[schema.Name] {
+			continue
+		}
+		err = m.apply(SchemaEvent{Operation: EventDelete, Name: schema.Name})
+		if err != nil {
+			return err
+		}
+	}
+
+	logger("mirror").Info("Mirrored schemas from upstream registry", "schemas", len(schemas))
+	return nil
+}
+
+// apply writes a single upstream change to the local bucket, bypassing the
+// checks upstream already made.
+func (m *Mirror) apply(event SchemaEvent) error {
+	kv := m.reg.kv
+
+	if event.Operation == EventDelete {
+		err := kv.Delete(nameToken(event.Name))
+		if err != nil {
+			return err
+		}
+		logger("mirror").Info("Mirrored schema deletion", "schema", event.Name)
+		return nil
+	}
+
+	if event.Schema == nil {
+		return nil
+	}
+	schema := *event.Schema
+	// Usage is per registry, upstream's isn't stored
+	schema.LastUsed = nil
+	schema.Updated = nil
+
+	current, exists, err := m.reg.current(schema.Name)
+	if err != nil {
+		return err
+	}
+	// Every stored revision upstream has its own ID, increasing with every
+	// revision, so events older than a snapshot are skipped
+	if exists && current.ID >= schema.ID {
+		return nil
+	}
+
+	data, err := json.Marshal(schema)
+	if err != nil {
+		return err
+	}
+
+	_, err = kv.Put(nameToken(schema.Name), data)
+	if err != nil {
+		return err
+	}
+	_, err = kv.Put(fingerprintKey(schema.Fingerprint), data)
+	if err != nil {
+		return err
+	}
+	_, err = kv.Put(idKey(schema.ID), data)
+	if err != nil {
+		return err
+	}
+
+	logger("mirror").Info("Mirrored schema", "schema", schema.Name, "revision", schema.Revision)
+	return nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+)
+
+func TestMirrorApply(t *testing.T) {
+	kv := newMemKV()
+	m := &Mirror{reg: NewSchemaRegistry(kv, nil), resync: make(chan struct{}, 1)}
+
+	put := func(id uint32, body string) {
+		t.Helper()
+		schema := Schema{Name: "orders", Subject: "orders.*", Body: body, ID: id, Fingerprint: body}
+		if err := m.apply(SchemaEvent{Operation: EventPut, Name: "orders", Schema: &schema}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	put(2, `{"type": "object"}`)
+	// Older revisions, as queued before a snapshot, don't replace newer ones
+	put(1, `{"type": "array"}`)
+	current, exists, err := m.reg.current("orders")
+	if err != nil || !exists || current.ID != 2 {
+		t.Errorf("Expected the newer revision to be kept, got %+v, %v", current, err)
+	}
+	if _, err := kv.Get(idKey(2)); err != nil {
+		t.Errorf("Expected the revision to be indexed by ID: %v", err)
+	}
+
+	put(3, `{"type": "string"}`)
+	current, _, _ = m.reg.current("orders")
+	if current.ID != 3 {
+		t.Errorf("Expected the newer revision to be applied, got %+v", current)
+	}
+
+	if err := m.apply(SchemaEvent{Operation: EventDelete, Name: "orders"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, exists, _ := m.reg.current("orders"); exists {
+		t.Errorf("Expected the schema to be deleted")
+	}
+}
+
+func TestMirrorResyncsDroppedEvents(t *testing.T) {
+	m := &Mirror{resync: make(chan struct{}, 1)}
+
+	m.handleError(nil, &nats.Subscription{Subject: "orders.*"}, nats.ErrSlowConsumer)
+	m.handleError(nil, &nats.Subscription{Subject: mirrorSubject}, errors.New("permissions violation"))
+	if len(m.resync) != 0 {
+		t.Errorf("Expected only dropped events to resync")
+	}
+
+	m.handleError(nil, &nats.Subscription{Subject: mirrorSubject}, nats.ErrSlowConsumer)
+	if len(m.resync) != 1 {
+		t.Errorf("Expected dropped events to resync")
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import "strings"
+
+// nameSeparator stands in for the dots of hierarchical schema names such as
+// com.acme.order, in the subject tokens and kv keys naming a schema. The
+// schema com.acme.order is fetched from $SCHEMA.GET.com/acme/order, since a
+// name is the last token of the subjects addressing it.
+const nameSeparator = "/"
+
+// nameToken returns the subject token and kv key of a schema name.
+func nameToken(name string) string {
+	return strings.ReplaceAll(name, ".", nameSeparator)
+}
+
+// tokenName returns the schema name a subject token or kv key stands for.
+func tokenName(token string) string {
+	return strings.ReplaceAll(token, nameSeparator, ".")
+}
+
+// subjectName returns the schema name addressed by the last token of a
+// subject.
+func subjectName(subject string) string {
+	return tokenName(subject[strings.LastIndex(subject, ".")+1:])
+}
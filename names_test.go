@@ -0,0 +1,18 @@
+package main
+
+import "testing"
+
+func TestNameTokens(t *testing.T) {
+	tests := map[string]string{
+		"orders":         "orders",
+		"com.acme.order": "com/acme/order",
+	}
+	for name, token := range tests {
+		if got := nameToken(name); got != token {
+			t.Errorf("Expected token %q for %q, got %q", token, name, got)
+		}
+		if got := subjectName("$SCHEMA.GET." + token); got != name {
+			t.Errorf("Expected name %q for %q, got %q", name, token, got)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/micro"
+)
+
+// SchemaOffsetHeader holds the index of the first record of an NDJSON batch,
+// so a backfill sent in several requests gets indexes across all of them.
+const SchemaOffsetHeader = "Schema-Offset"
+
+// maxBatchFailures caps the failures listed in a batch summary.
+const maxBatchFailures = 1000
+
+// maxRecordSize is the longest line an NDJSON batch may hold. Records are
+// further held to the payload limit.
+const maxRecordSize = 16 * 1024 * 1024
+
+// RecordFailure is a record of a batch that wasn't forwarded, by its index.
+type RecordFailure struct {
+	Index int    `json:"index"`
+	Error string `json:"error"`
+}
+
+// BatchSummary is the outcome of validating a batch of records.
+type BatchSummary struct {
+	Records  int             `json:"records"`
+	Passed   int             `json:"passed"`
+	Failed   int             `json:"failed"`
+	Failures []RecordFailure `json:"failures,omitempty"`
+	// Truncated is set when more records failed than are listed
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// NDJSON subject: $SCHEMA.NDJSON.<subject>
+func (reg *SchemaRegistry) ValidateNDJSON(r micro.Request) {
+	subject := strings.TrimPrefix(r.Subject(), reg.prefix+".NDJSON.")
+	headers := nats.Header(r.Headers())
+
+	offset := 0
+	if value := headers.Get(SchemaOffsetHeader); value != "" {
+		var err error
+		offset, err = strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			r.Error("400", fmt.Sprintf("invalid %s header %q", SchemaOffsetHeader, value), nil)
+			return
+		}
+	}
+
+	summary, err := reg.validateNDJSON(bytes.NewReader(r.Data()), subject, offset, headers)
+	if err != nil {
+		r.Error(errorCode(err), err.Error(), nil)
+		return
+	}
+	r.RespondJSON(summary)
+}
+
+// validateNDJSON validates every line of in as a payload published on the
+// subject, and forwards those that pass like the validate endpoint would.
+// Failures are recorded and dead-lettered like rejected messages. The headers
+// apply to every record.
+func (reg *SchemaRegistry) validateNDJSON(in io.Reader, subject string, offset int, headers nats.Header) (BatchSummary, error) {
+	var summary BatchSummary
+
+	schema, ok := reg.match(subject)
+	if !ok {
+		return summary, newError("404", "could not find schema for subject %q", subject)
+	}
+	if schema.State == StateDisabled {
+		return summary, newError("409", "schema %q is disabled", schema.Name)
+	}
+	schema, err := reg.pin(schema, headers)
+	if err != nil {
+		return summary, err
+	}
+
+	header := nats.Header{}
+	for key, values := range headers {
+		if key != SchemaOffsetHeader {
+			header[key] = values
+		}
+	}
+	detail := header.Get(SchemaErrorsHeader)
+
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), maxRecordSize)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		index := offset + summary.Records
+		summary.Records++
+		err := reg.validateRecord(schema, subject, append([]byte(nil), line...), header)
+		if err == nil {
+			summary.Passed++
+			continue
+		}
+
+		summary.Failed++
+		if len(summary.Failures) < maxBatchFailures {
+			summary.Failures = append(summary.Failures, RecordFailure{Index: index, Error: reg.renderError(err, detail)})
+		} else {
+			summary.Truncated = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return summary, newError("400", "reading record %d: %v", offset+summary.Records, err)
+	}
+	return summary, nil
+}
+
+// validateRecord validates and forwards a single record of a batch.
+func (reg *SchemaRegistry) validateRecord(schema Schema, subject string, data []byte, header nats.Header) error {
+	if err := reg.settings().Limits.CheckPayload(data); err != nil {
+		return err
+	}
+	if err := reg.settings().quotas.AllowValidation(schema.Name); err != nil {
+		return err
+	}
+
+	// Forwarding annotates the headers, which every record starts from
+	m := &nats.Msg{Subject: subject, Data: data, Header: nats.Header{}}
+	for key, values := range header {
+		m.Header[key] = append([]string(nil), values...)
+	}
+	params, err := reg.check(schema, subject, data, m.Header)
+	if err != nil {
+		reg.recordRejection(m, schema, subject, err)
+		return err
+	}
+	reg.stats.Record(schema.Name, true)
+
+	msg := reg.forwardMsg(RouteSubject(schema, subject, params), schema, subject, params, m)
+	if err := reg.encryptMsg(msg, schema, data); err != nil {
+		return err
+	}
+	if schema.JetStream {
+		err = reg.forward(m, schema, subject, func() error {
+			_, err := reg.js.PublishMsg(msg)
+			return err
+		})
+	} else {
+		err = reg.forward(m, schema, subject, func() error { return reg.nc.PublishMsg(msg) })
+	}
+	if err == nil {
+		reg.publishRedacted(msg, schema, subject, params, msg.Data)
+	}
+	return err
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateNDJSONFailures(t *testing.T) {
+	reg := NewSchemaRegistry(nil, nil)
+	reg.schemas["orders"] = Schema{
+		Name:    "orders",
+		Subject: "orders.*",
+		Body:    `{"type": "object", "required": ["id"]}`,
+	}
+
+	in := strings.NewReader("{}\n\n{\"name\": \"a\"}\nnot json\n")
+	summary, err := reg.validateNDJSON(in, "orders.new", 10, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Records != 3 || summary.Passed != 0 || summary.Failed != 3 {
+		t.Errorf("Expected 3 failed records, got %+v", summary)
+	}
+	for i, failure := range summary.Failures {
+		if failure.Index != 10+i {
+			t.Errorf("Expected failure %d to have index %d, got %d", i, 10+i, failure.Index)
+		}
+	}
+
+	_, err = reg.validateNDJSON(strings.NewReader("{}"), "invoices.new", 0, nil)
+	if errorCode(err) != "404" {
+		t.Errorf("Expected an unknown subject to fail with 404, got %v", err)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+)
+
+// SchemaObjectHeader references the payload of a validation request in an
+// Object Store bucket, as <bucket>/<key>, for payloads over the NATS payload
+// limit. The message itself is forwarded as is, reference included.
+const SchemaObjectHeader = "Schema-Object"
+
+// objectPayload reads the payload an Object Store reference points to. Only
+// the buckets the registry is configured with may be read.
+func (reg *SchemaRegistry) objectPayload(ref string) ([]byte, error) {
+	bucket, key, _ := strings.Cut(ref, "/")
+	if bucket == "" || key == "" {
+		return nil, newError("400", "invalid %s header %q, expected <bucket>/<key>", SchemaObjectHeader, ref)
+	}
+	if !contains(reg.objectBuckets, bucket) {
+		return nil, newError("403", "payloads can't be read from bucket %q", bucket)
+	}
+
+	store, err := reg.js.ObjectStore(bucket)
+	if errors.Is(err, nats.ErrStreamNotFound) {
+		return nil, newError("404", "bucket %q not found", bucket)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := store.GetInfo(key)
+	if errors.Is(err, nats.ErrObjectNotFound) {
+		return nil, newError("404", "object %q not found in bucket %q", key, bucket)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := reg.settings().Limits.CheckObject(info.Size); err != nil {
+		return nil, err
+	}
+
+	result, err := store.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close()
+	return io.ReadAll(result)
+}
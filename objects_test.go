@@ -0,0 +1,23 @@
+package main
+
+import "testing"
+
+func TestObjectPayloadReference(t *testing.T) {
+	reg := NewSchemaRegistry(nil, nil)
+	reg.objectBuckets = ParseList(" exports, ,archive")
+	if len(reg.objectBuckets) != 2 || reg.objectBuckets[0] != "exports" || reg.objectBuckets[1] != "archive" {
+		t.Fatalf("Expected exports and archive, got %v", reg.objectBuckets)
+	}
+
+	tests := map[string]string{
+		"exports":      "400",
+		"exports/":     "400",
+		"/report.json": "400",
+		"other/a.json": "403",
+	}
+	for ref, code := range tests {
+		if _, err := reg.objectPayload(ref); errorCode(err) != code {
+			t.Errorf("Expected reference %q to fail with %s, got %v", ref, code, err)
+		}
+	}
+}
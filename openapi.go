@@ -0,0 +1,268 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// openAPIComponentRef prefixes the references to the component schemas of an
+// OpenAPI document.
+const openAPIComponentRef = "#/components/schemas/"
+
+// ConvertOpenAPI turns the component schemas of an OpenAPI 3 document, in JSON
+// or YAML, into schema definitions sorted by name. Each is named and bound to
+// the subject <prefix>.<component>, the component name in snake case. The
+// prefix defaults to the document's title in snake case. References between
+// components become registry references, so shared components are
+// registered once and referenced.
+func ConvertOpenAPI(data []byte, prefix string) ([]Schema, error) {
+	var doc struct {
+		OpenAPI string `yaml:"openapi"`
+		Info    struct {
+			Title string `yaml:"title"`
+		} `yaml:"info"`
+		Components struct {
+			Schemas map[string]interface{} `yaml:"schemas"`
+		} `yaml:"components"`
+	}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI document: %w", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		return nil, fmt.Errorf("unsupported OpenAPI version %q, only 3.x documents can be imported", doc.OpenAPI)
+	}
+	if prefix == "" {
+		prefix = snakeCase(doc.Info.Title)
+	}
+	if prefix == "" {
+		return nil, fmt.Errorf("the document has no title, a prefix is required")
+	}
+
+	names := map[string]string{}
+	for component := range doc.Components.Schemas {
+		names[component] = prefix + "." + snakeCase(component)
+	}
+
+	var schemas []Schema
+	for component, node := range doc.Components.Schemas {
+		converted, err := convertOpenAPINode(node, names)
+		if err != nil {
+			return nil, fmt.Errorf("component %q: %w", component, err)
+		}
+		body, ok := converted.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("component %q: not a schema object", component)
+		}
+		body["$schema"] = "http://json-schema.org/draft-07/schema#"
+		if _, ok := body["title"]; !ok {
+			body["title"] = component
+		}
+
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("component %q: %w", component, err)
+		}
+		schemas = append(schemas, Schema{
+			Name:    names[component],
+			Subject: names[component],
+			Type:    "jsonschema",
+			Body:    string(encoded),
+		})
+	}
+
+	sort.Slice(schemas, func(i, j int) bool { return schemas[i].Name < schemas[j].Name })
+	return schemas, nil
+}
+
+// convertOpenAPINode translates an OpenAPI schema object into JSON Schema.
+// OpenAPI 3.0 extends and restricts JSON Schema: nullable becomes a null type,
+// example becomes examples, boolean exclusive bounds become numeric ones and
+// discriminator, xml and externalDocs are dropped. 3.1 schemas are already
+// JSON Schema, so this only rewrites their references.
+func convertOpenAPINode(node interface{}, names map[string]string) (interface{}, error) {
+	switch n := node.(type) {
+	case []interface{}:
+		out := make([]interface{}, len(n))
+		for i, item := range n {
+			converted, err := convertOpenAPINode(item, names)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = converted
+		}
+		return out, nil
+	case map[string]interface{}:
+		out := map[string]interface{}{}
+		for key, value := range n {
+			switch key {
+			case "discriminator", "xml", "externalDocs", "nullable":
+				continue
+			case "example":
+				out["examples"] = []interface{}{value}
+				continue
+			case "$ref":
+				ref, err := openAPIRef(value, names)
+				if err != nil {
+					return nil, err
+				}
+				out[key] = ref
+				continue
+			case "properties", "patternProperties", "definitions", "$defs":
+				// Keys of these are names, not keywords
+				props, ok := value.(map[string]interface{})
+				if !ok {
+					break
+				}
+				converted := map[string]interface{}{}
+				for name, prop := range props {
+					c, err := convertOpenAPINode(prop, names)
+					if err != nil {
+						return nil, err
+					}
+					converted[name] = c
+				}
+				out[key] = converted
+				continue
+			}
+			converted, err := convertOpenAPINode(value, names)
+			if err != nil {
+				return nil, err
+			}
+			out[key] = converted
+		}
+
+		for _, bound := range []string{"Maximum", "Minimum"} {
+			exclusive := "exclusive" + bound
+			limit := strings.ToLower(bound)
+			if b, ok := out[exclusive].(bool); ok {
+				delete(out, exclusive)
+				if b && out[limit] != nil {
+					out[exclusive] = out[limit]
+					delete(out, limit)
+				}
+			}
+		}
+
+		if nullable, _ := n["nullable"].(bool); nullable {
+			if t, ok := out["type"].(string); ok {
+				out["type"] = []interface{}{t, "null"}
+			}
+			if enum, ok := out["enum"].([]interface{}); ok {
+				out["enum"] = append(enum, nil)
+			}
+		}
+		return out, nil
+	default:
+		return node, nil
+	}
+}
+
+// openAPIRef returns the registry reference for a reference to a component
+// schema. Other references can't be resolved once components are registered
+// on their own.
+func openAPIRef(value interface{}, names map[string]string) (string, error) {
+	ref, _ := value.(string)
+	if !strings.HasPrefix(ref, openAPIComponentRef) {
+		return "", fmt.Errorf("unsupported reference %q, only components/schemas can be referenced", ref)
+	}
+	component, pointer, _ := strings.Cut(strings.TrimPrefix(ref, openAPIComponentRef), "/")
+	name, ok := names[component]
+	if !ok {
+		return "", fmt.Errorf("reference to unknown component %q", component)
+	}
+	if pointer != "" {
+		return RegistryRefPrefix + name + "#/" + pointer, nil
+	}
+	return RegistryRefPrefix + name, nil
+}
+
+// snakeCase turns a name such as PetOrder or Pet Store API into pet_order or
+// pet_store_api.
+func snakeCase(s string) string {
+	var b strings.Builder
+	runes := []rune(s)
+	for i, r := range runes {
+		switch {
+		case unicode.IsUpper(r):
+			// A new word starts at an upper case letter after a lower case
+			// one, or before one as in the Order of HTTPOrder
+			if i > 0 && b.Len() > 0 && !strings.HasSuffix(b.String(), "_") &&
+				(unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+				b.WriteByte('_')
+			}
+			b.WriteRune(unicode.ToLower(r))
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		default:
+			if b.Len() > 0 && !strings.HasSuffix(b.String(), "_") {
+				b.WriteByte('_')
+			}
+		}
+	}
+	return strings.TrimSuffix(b.String(), "_")
+}
+
+// ImportOpenAPI registers the component schemas of an OpenAPI document, after
+// those they reference. Schemas already matching their component are left
+// as they are, so an import can safely be run again.
+func (reg *SchemaRegistry) ImportOpenAPI(schemas []Schema) error {
+	order, err := bulkOrder(schemas)
+	if err != nil {
+		return err
+	}
+
+	for _, i := range order {
+		action, err := reg.apply(schemas[i])
+		if err != nil {
+			return fmt.Errorf("importing schema %q: %w", schemas[i].Name, err)
+		}
+		if action != "" {
+			logger("import").Info("Imported schema", "schema", schemas[i].Name, "action", action)
+		}
+
+		// Without a watcher, the components have to be cached for the
+		// schemas referencing them to be checked
+		schema, _, err := reg.current(schemas[i].Name)
+		if err != nil {
+			return err
+		}
+		reg.remember(schema)
+	}
+	return nil
+}
+
+// ImportOpenAPIFile imports the OpenAPI document given in the config into the
+// schema bucket.
+func ImportOpenAPIFile(cfg Config) error {
+	data, err := os.ReadFile(cfg.ImportOpenAPI)
+	if err != nil {
+		return err
+	}
+	schemas, err := ConvertOpenAPI(data, cfg.OpenAPIPrefix)
+	if err != nil {
+		return err
+	}
+
+	verifier, err := LoadVerifier(cfg.SigningKeysFile)
+	if err != nil {
+		return err
+	}
+
+	nc, _, kv, err := OpenBucket(cfg)
+	if err != nil {
+		return err
+	}
+	defer nc.Close()
+
+	registry := NewSchemaRegistry(kv, nc)
+	registry.verifier = verifier
+	registry.immutable = cfg.Immutable
+
+	return registry.ImportOpenAPI(schemas)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+const petstore = `
+openapi: 3.0.3
+info:
+  title: Pet Store
+  version: 1.0.0
+components:
+  schemas:
+    Pet:
+      type: object
+      required: [id]
+      discriminator:
+        propertyName: kind
+      properties:
+        id:
+          type: integer
+          example: 7
+        nickname:
+          type: string
+          nullable: true
+        owner:
+          $ref: '#/components/schemas/PetOwner'
+        age:
+          type: integer
+          minimum: 0
+          exclusiveMinimum: true
+    PetOwner:
+      type: object
+      properties:
+        name:
+          type: string
+`
+
+func TestConvertOpenAPI(t *testing.T) {
+	schemas, err := ConvertOpenAPI([]byte(petstore), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(schemas) != 2 {
+		t.Fatalf("Expected a schema per component, got %d", len(schemas))
+	}
+
+	pet := schemas[0]
+	if pet.Name != "pet_store.pet" || pet.Subject != "pet_store.pet" {
+		t.Errorf("Expected the component to be named after the title, got %q on %q", pet.Name, pet.Subject)
+	}
+	if schemas[1].Name != "pet_store.pet_owner" {
+		t.Errorf("Expected the component name in snake case, got %q", schemas[1].Name)
+	}
+	if !reflect.DeepEqual(registryRefs(pet.Body), []string{"pet_store.pet_owner"}) {
+		t.Errorf("Expected the component reference to become a registry reference, got %v", registryRefs(pet.Body))
+	}
+
+	var body map[string]interface{}
+	if err := json.Unmarshal([]byte(pet.Body), &body); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := body["discriminator"]; ok {
+		t.Errorf("Expected the discriminator to be dropped")
+	}
+	props := body["properties"].(map[string]interface{})
+	nickname := props["nickname"].(map[string]interface{})
+	if !reflect.DeepEqual(nickname["type"], []interface{}{"string", "null"}) {
+		t.Errorf("Expected nullable to allow null, got %v", nickname["type"])
+	}
+	id := props["id"].(map[string]interface{})
+	if !reflect.DeepEqual(id["examples"], []interface{}{float64(7)}) {
+		t.Errorf("Expected the example to become examples, got %v", id["examples"])
+	}
+	age := props["age"].(map[string]interface{})
+	if age["exclusiveMinimum"] != float64(0) || age["minimum"] != nil {
+		t.Errorf("Expected a numeric exclusive minimum, got %v", age)
+	}
+}
+
+func TestConvertOpenAPIRejects(t *testing.T) {
+	if _, err := ConvertOpenAPI([]byte("swagger: '2.0'\ninfo:\n  title: Old\n"), ""); err == nil {
+		t.Errorf("Expected Swagger 2.0 documents to be rejected")
+	}
+
+	doc := "openapi: 3.1.0\ninfo:\n  title: Pets\ncomponents:\n  schemas:\n    Pet:\n      $ref: '#/components/parameters/Id'\n"
+	if _, err := ConvertOpenAPI([]byte(doc), ""); err == nil {
+		t.Errorf("Expected references outside the component schemas to be rejected")
+	}
+}
+
+func TestSnakeCase(t *testing.T) {
+	for in, expected := range map[string]string{
+		"PetOrder":      "pet_order",
+		"HTTPOrder":     "http_order",
+		"Pet Store API": "pet_store_api",
+		"order_item":    "order_item",
+		"v2Pet":         "v2_pet",
+	} {
+		if out := snakeCase(in); out != expected {
+			t.Errorf("Expected %q to become %q, got %q", in, expected, out)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Parameter constrains the value of a {name} token in a schema's subject
+// template.
+type Parameter struct {
+	Enum    []string `json:"enum,omitempty"`
+	Pattern string   `json:"pattern,omitempty"`
+}
+
+// isParam returns the parameter name if a subject token is a {name} template.
+func isParam(token string) (string, bool) {
+	if len(token) > 2 && strings.HasPrefix(token, "{") && strings.HasSuffix(token, "}") {
+		return token[1 : len(token)-1], true
+	}
+	return "", false
+}
+
+// SubjectPattern converts a subject template like orders.{region}.{event} into
+// the wildcard subject orders.*.* it matches.
+func SubjectPattern(template string) string {
+	tokens := strings.Split(template, ".")
+	for i, token := range tokens {
+		if _, ok := isParam(token); ok {
+			tokens[i] = "*"
+		}
+	}
+	return strings.Join(tokens, ".")
+}
+
+// SubjectParams extracts the values of the {name} tokens of a subject template
+// from a literal subject matching it.
+func SubjectParams(literal, template string) map[string]string {
+	params := map[string]string{}
+	lparts := strings.Split(literal, ".")
+	for i, token := range strings.Split(template, ".") {
+		name, ok := isParam(token)
+		if !ok || i >= len(lparts) {
+			continue
+		}
+		params[name] = lparts[i]
+	}
+	return params
+}
+
+// checkParameters returns an error if a parameter isn't part of
+// the subject template or has an invalid pattern.
+func checkParameters(schema Schema) error {
+	for name, param := range schema.Parameters {
+		if !strings.Contains(schema.Subject, "{"+name+"}") {
+			return fmt.Errorf("parameter %q is not part of subject %q", name, schema.Subject)
+		}
+		if param.Pattern != "" {
+			if _, err := regexp.Compile(param.Pattern); err != nil {
+				return fmt.Errorf("parameter %q: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// validateParams checks extracted subject parameter values against their
+// constraints.
+func validateParams(values map[string]string, params map[string]Parameter) error {
+	for _, name := range sortedKeys(params) {
+		param := params[name]
+		value := values[name]
+		if len(param.Enum) > 0 && !contains(param.Enum, value) {
+			return fmt.Errorf("invalid subject parameter %s: %q is not one of %s", name, value, strings.Join(param.Enum, ", "))
+		}
+		if param.Pattern != "" {
+			re, err := regexp.Compile(param.Pattern)
+			if err != nil {
+				return err
+			}
+			if !re.MatchString(value) {
+				return fmt.Errorf("invalid subject parameter %s: %q does not match %s", name, value, param.Pattern)
+			}
+		}
+	}
+	return nil
+}
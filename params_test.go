@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestSubjectParams(t *testing.T) {
+	if SubjectPattern("orders.{region}.{event}") != "orders.*.*" {
+		t.Errorf("Expected template tokens to become wildcards")
+	}
+
+	params := SubjectParams("orders.eu.created", "orders.{region}.{event}")
+	if params["region"] != "eu" || params["event"] != "created" {
+		t.Errorf("Expected parameters to be extracted, got %v", params)
+	}
+}
+
+func TestValidateParams(t *testing.T) {
+	params := map[string]Parameter{
+		"region": {Enum: []string{"eu", "us"}},
+		"event":  {Pattern: "^[a-z]+$"},
+	}
+
+	if err := validateParams(map[string]string{"region": "eu", "event": "created"}, params); err != nil {
+		t.Errorf("Expected parameters to be valid: %v", err)
+	}
+	if validateParams(map[string]string{"region": "apac", "event": "created"}, params) == nil {
+		t.Errorf("Expected value outside the enum to be rejected")
+	}
+	if validateParams(map[string]string{"region": "eu", "event": "Created!"}, params) == nil {
+		t.Errorf("Expected value not matching the pattern to be rejected")
+	}
+}
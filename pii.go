@@ -0,0 +1,260 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+)
+
+// PIIKeyword marks a property of a schema body as personally identifiable
+// information, as in {"email": {"type": "string", "x-pii": true}}.
+const PIIKeyword = "x-pii"
+
+// SchemaRedactionHeader and SchemaRedactedHeader record how a message was
+// redacted, and the JSON Pointers of the fields that were.
+const (
+	SchemaRedactionHeader = "Schema-Redaction"
+	SchemaRedactedHeader  = "Schema-Redacted"
+)
+
+// Redaction modes.
+const (
+	// RedactionRedact replaces PII values with a placeholder.
+	RedactionRedact = "redact"
+	// RedactionHash replaces PII values with their SHA-256 hash, so they can
+	// still be joined on without being revealed.
+	RedactionHash = "hash"
+)
+
+// redactedValue replaces PII values in the redact mode.
+const redactedValue = "[redacted]"
+
+// Redaction republishes validated messages with their PII removed, for
+// subjects with a broader audience than the one they were published to.
+type Redaction struct {
+	// Destination is the subject the redacted copy is published to. Its
+	// wildcards are filled in like those of the schema's destination.
+	Destination string `json:"destination"`
+	// Mode is redact or hash, redact by default.
+	Mode string `json:"mode,omitempty"`
+}
+
+// checkRedaction returns an error if a schema's redaction is invalid, or its
+// body has no PII to redact.
+func (reg *SchemaRegistry) checkRedaction(schema Schema) error {
+	if schema.Redaction == nil {
+		return nil
+	}
+	switch schema.Redaction.Mode {
+	case "", RedactionRedact, RedactionHash:
+	default:
+		return newError("400", "invalid redaction mode %q", schema.Redaction.Mode)
+	}
+	if schema.Redaction.Destination == "" {
+		return newError("400", "redaction needs a destination")
+	}
+	if err := checkDestination(Schema{Subject: schema.Subject, Destination: schema.Redaction.Destination}); err != nil {
+		return newError("400", "redaction: %v", err)
+	}
+
+	body, err := reg.resolvedBody(schema.Body)
+	if err != nil {
+		return err
+	}
+	paths, err := piiPaths(body)
+	if err != nil {
+		return newError("400", err.Error())
+	}
+	if len(paths) == 0 {
+		return newError("400", "schema %q has no %s properties to redact", schema.Name, PIIKeyword)
+	}
+	return nil
+}
+
+// piiPaths returns the JSON Pointers of the PII properties of a body, sorted.
+func piiPaths(body string) ([]string, error) {
+	return annotatedPaths(body, PIIKeyword)
+}
+
+// annotatedPaths returns the JSON Pointers of the properties of a body whose
+// keyword is true, sorted. Array items are matched by a * token, as in
+// /customers/*/email.
+func annotatedPaths(body, keyword string) ([]string, error) {
+	nodes, err := annotatedNodes(body, keyword)
+	return sortedKeys(nodes), err
+}
+
+// annotatedNodes returns the properties of a body whose keyword is true, by
+// their JSON Pointers as annotatedPaths returns them.
+func annotatedNodes(body, keyword string) (map[string]map[string]interface{}, error) {
+	var doc interface{}
+	if err := json.Unmarshal([]byte(body), &doc); err != nil {
+		return nil, err
+	}
+
+	paths := map[string]map[string]interface{}{}
+	var walk func(node interface{}, path string)
+	walk = func(node interface{}, path string) {
+		n := asObject(node)
+		if n == nil {
+			return
+		}
+		if annotated, _ := n[keyword].(bool); annotated && path != "" {
+			paths[path] = n
+			return
+		}
+		for name, property := range asObject(n["properties"]) {
+			walk(property, path+"/"+escapePointer(name))
+		}
+		switch items := n["items"].(type) {
+		case map[string]interface{}:
+			walk(items, path+"/*")
+		case []interface{}:
+			for i, item := range items {
+				walk(item, path+"/"+strconv.Itoa(i))
+			}
+		}
+		for _, keyword := range []string{"allOf", "anyOf", "oneOf"} {
+			if subschemas, ok := n[keyword].([]interface{}); ok {
+				for _, subschema := range subschemas {
+					walk(subschema, path)
+				}
+			}
+		}
+	}
+	walk(doc, "")
+	return paths, nil
+}
+
+// escapePointer escapes a property name as a JSON Pointer token.
+func escapePointer(name string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(name)
+}
+
+// Redact returns a payload with the values at paths redacted or hashed, and
+// the pointers of the values that were. Payloads without PII are returned as
+// they are.
+func Redact(data []byte, paths []string, mode string) ([]byte, []string, error) {
+	return transformPaths(data, paths, func(value interface{}) (interface{}, error) {
+		return redactValue(value, mode), nil
+	})
+}
+
+// transformPaths replaces the values of a payload at paths, as returned by
+// annotatedPaths, with what transform returns for them. It returns the new
+// payload and the pointers of the values that were replaced, or the payload
+// as it is if none were.
+func transformPaths(data []byte, paths []string, transform func(value interface{}) (interface{}, error)) ([]byte, []string, error) {
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, nil, err
+	}
+
+	var replaced []string
+	var walk func(node interface{}, tokens []string, path string) (interface{}, error)
+	walk = func(node interface{}, tokens []string, path string) (interface{}, error) {
+		if len(tokens) == 0 {
+			replaced = append(replaced, path)
+			return transform(node)
+		}
+		token := strings.NewReplacer("~1", "/", "~0", "~").Replace(tokens[0])
+		var err error
+		switch n := node.(type) {
+		case map[string]interface{}:
+			if value, ok := n[token]; ok {
+				n[token], err = walk(value, tokens[1:], path+"/"+tokens[0])
+			}
+		case []interface{}:
+			for i := range n {
+				if err == nil && (token == "*" || token == strconv.Itoa(i)) {
+					n[i], err = walk(n[i], tokens[1:], path+"/"+strconv.Itoa(i))
+				}
+			}
+		}
+		return node, err
+	}
+	for _, path := range paths {
+		var err error
+		doc, err = walk(doc, strings.Split(path, "/")[1:], "")
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if len(replaced) == 0 {
+		return data, nil, nil
+	}
+
+	out, err := json.Marshal(doc)
+	sort.Strings(replaced)
+	return out, replaced, err
+}
+
+// redactValue redacts or hashes a single value. Strings are hashed as they
+// are, other values as JSON.
+func redactValue(value interface{}, mode string) interface{} {
+	if value == nil || mode != RedactionHash {
+		return redactedValue
+	}
+	data, ok := value.(string)
+	if !ok {
+		encoded, _ := json.Marshal(value)
+		data = string(encoded)
+	}
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+// publishRedacted publishes the redacted copy of a validated message, if its
+// schema has a redaction. The copy is best effort, failing to publish it
+// doesn't fail the message.
+func (reg *SchemaRegistry) publishRedacted(msg *nats.Msg, schema Schema, subject string, params map[string]string, data []byte) {
+	if schema.Redaction == nil {
+		return
+	}
+
+	out, err := reg.redactedMsg(msg, schema, subject, params, data)
+	if err == nil {
+		err = reg.nc.PublishMsg(out)
+	}
+	if err != nil {
+		requestLogger(msg, schema, subject).Error("error publishing redacted message", "error", err)
+	}
+}
+
+// redactedMsg builds the redacted copy of a forwarded message, to the
+// redaction's destination.
+func (reg *SchemaRegistry) redactedMsg(msg *nats.Msg, schema Schema, subject string, params map[string]string, data []byte) (*nats.Msg, error) {
+	body, err := reg.resolvedBody(schema.Body)
+	if err != nil {
+		return nil, err
+	}
+	paths, err := piiPaths(body)
+	if err != nil {
+		return nil, err
+	}
+	mode := schema.Redaction.Mode
+	if mode == "" {
+		mode = RedactionRedact
+	}
+	redacted, fields, err := Redact(data, paths, mode)
+	if err != nil {
+		return nil, err
+	}
+
+	destination := RouteSubject(Schema{Subject: schema.Subject, Destination: schema.Redaction.Destination}, subject, params)
+	out := nats.NewMsg(destination)
+	out.Data = redacted
+	for key, values := range msg.Header {
+		out.Header[key] = append([]string(nil), values...)
+	}
+	// The copy carries the payload itself, not a reference to it
+	out.Header.Del(SchemaObjectHeader)
+	out.Header.Set(SchemaRedactionHeader, mode)
+	out.Header.Set(SchemaRedactedHeader, strings.Join(fields, ","))
+	return out, nil
+}
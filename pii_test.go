@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+)
+
+const piiBody = `{
+	"type": "object",
+	"properties": {
+		"id": {"type": "integer"},
+		"email": {"type": "string", "x-pii": true},
+		"customers": {"type": "array", "items": {"type": "object", "properties": {"name": {"x-pii": true}, "tier": {}}}}
+	}
+}`
+
+func TestPIIPaths(t *testing.T) {
+	paths, err := piiPaths(piiBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(paths, []string{"/customers/*/name", "/email"}) {
+		t.Errorf("Expected the PII properties, got %v", paths)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	paths := []string{"/customers/*/name", "/email"}
+	data := []byte(`{"id": 1, "email": "a@example.com", "customers": [{"name": "Ada", "tier": 1}, {"tier": 2}]}`)
+
+	out, fields, err := Redact(data, paths, RedactionRedact)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]interface{}
+	json.Unmarshal(out, &doc)
+	if doc["email"] != redactedValue || doc["id"] != float64(1) {
+		t.Errorf("Expected only the email to be redacted, got %s", out)
+	}
+	if !reflect.DeepEqual(fields, []string{"/customers/0/name", "/email"}) {
+		t.Errorf("Expected the redacted fields to be reported, got %v", fields)
+	}
+
+	out, _, err = Redact(data, paths, RedactionHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	json.Unmarshal(out, &doc)
+	if doc["email"] != "08168cd80dfd534ab0f10af10f1303fe00af2d43ab5c1432360d137f8197e17a" {
+		t.Errorf("Expected the email to be hashed, got %v", doc["email"])
+	}
+
+	clean := []byte(`{"id": 2}`)
+	out, fields, _ = Redact(clean, paths, RedactionRedact)
+	if string(out) != string(clean) || fields != nil {
+		t.Errorf("Expected a payload without PII to be left alone, got %s %v", out, fields)
+	}
+}
+
+func TestCheckRedaction(t *testing.T) {
+	reg := NewSchemaRegistry(nil, nil)
+
+	schema := Schema{Name: "orders", Subject: "orders.*", Body: piiBody, Redaction: &Redaction{Destination: "public.orders.*", Mode: RedactionHash}}
+	if err := reg.checkRedaction(schema); err != nil {
+		t.Errorf("Expected the redaction to be valid, got %v", err)
+	}
+
+	schema.Redaction.Mode = "encrypt"
+	if err := reg.checkRedaction(schema); errorCode(err) != "400" {
+		t.Errorf("Expected an unknown mode to be rejected, got %v", err)
+	}
+
+	schema.Redaction = &Redaction{Destination: "public.orders.*.*"}
+	if err := reg.checkRedaction(schema); errorCode(err) != "400" {
+		t.Errorf("Expected a destination with too many wildcards to be rejected, got %v", err)
+	}
+
+	schema.Redaction = &Redaction{Destination: "public.orders"}
+	schema.Body = `{"type": "object"}`
+	if err := reg.checkRedaction(schema); errorCode(err) != "400" {
+		t.Errorf("Expected a body without PII to be rejected, got %v", err)
+	}
+}
+
+func TestRedactedMsg(t *testing.T) {
+	reg := NewSchemaRegistry(nil, nil)
+	schema := Schema{Name: "orders", Subject: "orders.*", Body: piiBody, Redaction: &Redaction{Destination: "public.orders.*"}}
+
+	msg := nats.NewMsg("orders.eu")
+	msg.Header.Set("Schema-Name", "orders")
+	out, err := reg.redactedMsg(msg, schema, "orders.eu", nil, []byte(`{"email": "a@example.com"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Subject != "public.orders.eu" {
+		t.Errorf("Expected the copy to be routed to public.orders.eu, got %q", out.Subject)
+	}
+	if out.Header.Get(SchemaRedactionHeader) != RedactionRedact || out.Header.Get(SchemaRedactedHeader) != "/email" {
+		t.Errorf("Expected the redaction to be recorded in the headers, got %v", out.Header)
+	}
+	if out.Header.Get("Schema-Name") != "orders" || string(out.Data) != `{"email":"[redacted]"}` {
+		t.Errorf("Expected the redacted payload with the original headers, got %s %v", out.Data, out.Header)
+	}
+}
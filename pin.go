@@ -0,0 +1,64 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/nats-io/nats.go"
+)
+
+// SchemaRevisionHeader pins a validation request to a stored revision of the
+// matching schema, so producers still on an old contract keep validating
+// against it while they migrate. Forwarded messages carry the revision they
+// were validated against in the same header.
+const SchemaRevisionHeader = "Schema-Revision"
+
+// pinKey identifies a stored revision of a schema.
+type pinKey struct {
+	name     string
+	revision uint64
+}
+
+// pin returns the revision of the matched schema the headers pin, or the
+// schema itself if they don't pin one. Pinned revisions keep the lifecycle
+// state of the head, so disabling or deprecating a schema covers them too.
+func (reg *SchemaRegistry) pin(schema Schema, headers nats.Header) (Schema, error) {
+	value := headers.Get(SchemaRevisionHeader)
+	if value == "" {
+		return schema, nil
+	}
+	revision, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return schema, newError("400", "invalid %s header %q", SchemaRevisionHeader, value)
+	}
+	if revision == schema.Revision {
+		return schema, nil
+	}
+
+	pinned, err := reg.storedRevision(schema.Name, revision)
+	if err != nil {
+		return schema, err
+	}
+	pinned.State = schema.State
+	return pinned, nil
+}
+
+// storedRevision returns a stored revision of a schema. Revisions never
+// change, so they are cached once read.
+func (reg *SchemaRegistry) storedRevision(name string, revision uint64) (Schema, error) {
+	key := pinKey{name: name, revision: revision}
+	if cached, ok := reg.revisions.Load(key); ok {
+		return cached.(Schema), nil
+	}
+
+	revisions, err := reg.storedVersions(name)
+	if err != nil {
+		return Schema{}, err
+	}
+	for _, schema := range revisions {
+		if schema.Revision == revision {
+			reg.revisions.Store(key, schema)
+			return schema, nil
+		}
+	}
+	return Schema{}, newError("404", "revision %d of schema %q isn't stored", revision, name)
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/nats-io/nats.go"
+)
+
+func TestPinHead(t *testing.T) {
+	reg := NewSchemaRegistry(nil, nil)
+	head := Schema{Name: "orders", Revision: 6}
+
+	tests := map[string]uint64{
+		"":  6,
+		"6": 6,
+	}
+	for value, expected := range tests {
+		headers := nats.Header{}
+		if value != "" {
+			headers.Set(SchemaRevisionHeader, value)
+		}
+		schema, err := reg.pin(head, headers)
+		if err != nil {
+			t.Errorf("Expected no error pinning %q, got %v", value, err)
+		}
+		if schema.Revision != expected {
+			t.Errorf("Expected revision %d pinning %q, got %d", expected, value, schema.Revision)
+		}
+	}
+
+	headers := nats.Header{}
+	headers.Set(SchemaRevisionHeader, "latest")
+	if _, err := reg.pin(head, headers); errorCode(err) != "400" {
+		t.Errorf("Expected a 400 error for an invalid revision, got %v", err)
+	}
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+// wasmPageSize is the size of a page of WebAssembly memory.
+const wasmPageSize = 64 * 1024
+
+// Plugin is a WebAssembly module validating the payloads of a schema with
+// rules JSON Schema can't express. It is stored in the plugin bucket under
+// Name. Digest pins the module the schema was registered with, so replacing
+// the object doesn't change the rules of existing revisions.
+//
+// The module exports its memory as "memory", along with:
+//
+//	alloc(size i32) i32
+//	validate(ptr i32, len i32) i64
+//
+// The payload is written to the memory alloc returns, and validate returns
+// the pointer to a JSON array of violations in the high 32 bits and its length
+// in the low ones. A length of zero accepts the payload.
+type Plugin struct {
+	Name   string `json:"name"`
+	Digest string `json:"digest,omitempty"`
+}
+
+// PluginConfig configures the plugin runtime.
+type PluginConfig struct {
+	// Bucket is the Object Store bucket plugins are stored in. Plugins are
+	// disabled when it's empty.
+	Bucket string
+	// Memory is the memory in MiB a plugin may use while validating a payload.
+	Memory int
+	// Timeout is how long a plugin may take to validate a payload.
+	Timeout time.Duration
+}
+
+// Plugins runs plugins in a sandbox: they can't access files, the network or
+// the environment, and are stopped when they exceed their memory or time. A
+// nil Plugins runs no plugins.
+type Plugins struct {
+	store   nats.ObjectStore
+	runtime wazero.Runtime
+	timeout time.Duration
+
+	mu sync.Mutex
+	// modules are the compiled modules by digest
+	modules map[string]wazero.CompiledModule
+}
+
+// OpenPlugins opens the plugin bucket, creating it if it doesn't exist yet.
+// It returns nil Plugins if no bucket is configured.
+func OpenPlugins(js nats.JetStreamContext, cfg PluginConfig) (*Plugins, error) {
+	if cfg.Bucket == "" {
+		return nil, nil
+	}
+
+	store, err := js.ObjectStore(cfg.Bucket)
+	if errors.Is(err, nats.ErrStreamNotFound) {
+		store, err = js.CreateObjectStore(&nats.ObjectStoreConfig{
+			Bucket:      cfg.Bucket,
+			Description: "WebAssembly plugins validating schema payloads.",
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	runtime := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().
+		WithMemoryLimitPages(uint32(cfg.Memory*1024*1024/wasmPageSize)).
+		WithCloseOnContextDone(true))
+	// Modules built for WASI get its functions, without access to anything
+	_, err = wasi_snapshot_preview1.Instantiate(ctx, runtime)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Plugins{
+		store:   store,
+		runtime: runtime,
+		timeout: cfg.Timeout,
+		modules: map[string]wazero.CompiledModule{},
+	}, nil
+}
+
+// Resolve compiles the named plugin and returns it pinned to its current
+// module.
+func (p *Plugins) Resolve(name string) (Plugin, error) {
+	if p == nil {
+		return Plugin{}, newError("400", "plugins aren't enabled, see --plugin-bucket")
+	}
+
+	info, err := p.store.GetInfo(name)
+	if errors.Is(err, nats.ErrObjectNotFound) {
+		return Plugin{}, newError("400", "plugin %q doesn't exist", name)
+	}
+	if err != nil {
+		return Plugin{}, err
+	}
+
+	plugin := Plugin{Name: name, Digest: info.Digest}
+	_, err = p.module(plugin)
+	return plugin, err
+}
+
+// module returns the compiled module of a plugin, reading it from the bucket
+// the first time.
+func (p *Plugins) module(plugin Plugin) (wazero.CompiledModule, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if module, ok := p.modules[plugin.Digest]; ok {
+		return module, nil
+	}
+
+	result, err := p.store.Get(plugin.Name)
+	if errors.Is(err, nats.ErrObjectNotFound) {
+		return nil, newError("500", "plugin %q doesn't exist anymore", plugin.Name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close()
+
+	info, err := result.Info()
+	if err != nil {
+		return nil, err
+	}
+	if info.Digest != plugin.Digest {
+		return nil, newError("500", "plugin %q changed since the schema was registered, update the schema to use it", plugin.Name)
+	}
+	code, err := io.ReadAll(result)
+	if err != nil {
+		return nil, err
+	}
+
+	module, err := p.runtime.CompileModule(context.Background(), code)
+	if err != nil {
+		return nil, newError("400", "invalid plugin %q: %v", plugin.Name, err)
+	}
+	if err := checkPluginExports(module); err != nil {
+		module.Close(context.Background())
+		return nil, newError("400", "invalid plugin %q: %v", plugin.Name, err)
+	}
+
+	p.modules[plugin.Digest] = module
+	return module, nil
+}
+
+// checkPluginExports returns an error if a module doesn't export what a plugin
+// must.
+func checkPluginExports(module wazero.CompiledModule) error {
+	if _, ok := module.ExportedMemories()["memory"]; !ok {
+		return errors.New(`doesn't export its memory as "memory"`)
+	}
+
+	functions := module.ExportedFunctions()
+	signatures := map[string][2][]api.ValueType{
+		"alloc":    {{api.ValueTypeI32}, {api.ValueTypeI32}},
+		"validate": {{api.ValueTypeI32, api.ValueTypeI32}, {api.ValueTypeI64}},
+	}
+	for name, signature := range signatures {
+		function, ok := functions[name]
+		if !ok {
+			return errors.New("doesn't export " + name)
+		}
+		if !sameTypes(function.ParamTypes(), signature[0]) || !sameTypes(function.ResultTypes(), signature[1]) {
+			return errors.New(name + " has the wrong signature")
+		}
+	}
+	return nil
+}
+
+func sameTypes(a, b []api.ValueType) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Validate runs a plugin on a payload and returns its violations. Every
+// payload gets a fresh instance, so plugins can't keep state between them.
+func (p *Plugins) Validate(plugin Plugin, data []byte) ([]string, error) {
+	if p == nil {
+		return nil, newError("500", "plugins aren't enabled, can't run plugin %q", plugin.Name)
+	}
+	module, err := p.module(plugin)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	if p.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.timeout)
+		defer cancel()
+	}
+
+	// Reactors built for WASI initialize themselves in _initialize, while
+	// commands would run and exit in _start
+	instance, err := p.runtime.InstantiateModule(ctx, module, wazero.NewModuleConfig().
+		WithName("").
+		WithStartFunctions("_initialize"))
+	if err != nil {
+		return nil, p.failed(ctx, plugin, err)
+	}
+	defer instance.Close(context.Background())
+
+	results, err := instance.ExportedFunction("alloc").Call(ctx, uint64(len(data)))
+	if err != nil {
+		return nil, p.failed(ctx, plugin, err)
+	}
+	ptr := uint32(results[0])
+	if !instance.Memory().Write(ptr, data) {
+		return nil, newError("500", "plugin %q allocated memory out of range", plugin.Name)
+	}
+
+	results, err = instance.ExportedFunction("validate").Call(ctx, uint64(ptr), uint64(len(data)))
+	if err != nil {
+		return nil, p.failed(ctx, plugin, err)
+	}
+	ptr, size := uint32(results[0]>>32), uint32(results[0])
+	if size == 0 {
+		return nil, nil
+	}
+	out, ok := instance.Memory().Read(ptr, size)
+	if !ok {
+		return nil, newError("500", "plugin %q returned memory out of range", plugin.Name)
+	}
+
+	var violations []string
+	if err := json.Unmarshal(out, &violations); err != nil {
+		return nil, newError("500", "plugin %q returned invalid violations: %v", plugin.Name, err)
+	}
+	return violations, nil
+}
+
+// failed describes a plugin that trapped or ran out of time.
+func (p *Plugins) failed(ctx context.Context, plugin Plugin, err error) error {
+	if ctx.Err() != nil {
+		return newError("408", "plugin %q timed out after %v", plugin.Name, p.timeout)
+	}
+	return newError("500", "plugin %q failed: %v", plugin.Name, err)
+}
+
+// resolvePlugin pins the plugin of a schema to its current module.
+func (reg *SchemaRegistry) resolvePlugin(schema *Schema) error {
+	if schema.Plugin == nil {
+		return nil
+	}
+	plugin, err := reg.plugins.Resolve(schema.Plugin.Name)
+	if err != nil {
+		return err
+	}
+	schema.Plugin = &plugin
+	return nil
+}
+
+// pluginViolations runs the plugin of a schema, if it has one, on a payload.
+func (reg *SchemaRegistry) pluginViolations(schema Schema, data []byte) ([]string, error) {
+	if schema.Plugin == nil {
+		return nil, nil
+	}
+	return reg.plugins.Validate(*schema.Plugin, data)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+)
+
+// rejectPlugin rejects payloads longer than two bytes:
+//
+//	(module
+//	  (memory (export "memory") 1)
+//	  (data (i32.const 16) "[\"plugin says no\"]")
+//	  (func (export "alloc") (param i32) (result i32) i32.const 1024)
+//	  (func (export "validate") (param i32 i32) (result i64)
+//	    local.get 1
+//	    i32.const 2
+//	    i32.gt_u
+//	    if (result i64) i64.const 0x1000000012 else i64.const 0 end))
+var rejectPlugin = []byte{
+	0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00, 0x01, 0x0c, 0x02, 0x60, 0x01, 0x7f, 0x01, 0x7f,
+	0x60, 0x02, 0x7f, 0x7f, 0x01, 0x7e, 0x03, 0x03, 0x02, 0x00, 0x01, 0x05, 0x03, 0x01, 0x00, 0x01,
+	0x07, 0x1d, 0x03, 0x06, 0x6d, 0x65, 0x6d, 0x6f, 0x72, 0x79, 0x02, 0x00, 0x05, 0x61, 0x6c, 0x6c,
+	0x6f, 0x63, 0x00, 0x00, 0x08, 0x76, 0x61, 0x6c, 0x69, 0x64, 0x61, 0x74, 0x65, 0x00, 0x01, 0x0a,
+	0x1c, 0x02, 0x05, 0x00, 0x41, 0x80, 0x08, 0x0b, 0x14, 0x00, 0x20, 0x01, 0x41, 0x02, 0x4b, 0x04,
+	0x7e, 0x42, 0x92, 0x80, 0x80, 0x80, 0x80, 0x02, 0x05, 0x42, 0x00, 0x0b, 0x0b, 0x0b, 0x18, 0x01,
+	0x00, 0x41, 0x10, 0x0b, 0x12, 0x5b, 0x22, 0x70, 0x6c, 0x75, 0x67, 0x69, 0x6e, 0x20, 0x73, 0x61,
+	0x79, 0x73, 0x20, 0x6e, 0x6f, 0x22, 0x5d,
+}
+
+// loopPlugin never returns from validate.
+var loopPlugin = []byte{
+	0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00, 0x01, 0x0c, 0x02, 0x60, 0x01, 0x7f, 0x01, 0x7f,
+	0x60, 0x02, 0x7f, 0x7f, 0x01, 0x7e, 0x03, 0x03, 0x02, 0x00, 0x01, 0x05, 0x03, 0x01, 0x00, 0x01,
+	0x07, 0x1d, 0x03, 0x06, 0x6d, 0x65, 0x6d, 0x6f, 0x72, 0x79, 0x02, 0x00, 0x05, 0x61, 0x6c, 0x6c,
+	0x6f, 0x63, 0x00, 0x00, 0x08, 0x76, 0x61, 0x6c, 0x69, 0x64, 0x61, 0x74, 0x65, 0x00, 0x01, 0x0a,
+	0x10, 0x02, 0x05, 0x00, 0x41, 0x80, 0x08, 0x0b, 0x08, 0x00, 0x03, 0x40, 0x0c, 0x00, 0x0b, 0x00,
+	0x0b,
+}
+
+func testPlugins(t *testing.T, code []byte) (*Plugins, Plugin) {
+	ctx := context.Background()
+	runtime := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().WithCloseOnContextDone(true))
+	t.Cleanup(func() { runtime.Close(ctx) })
+
+	module, err := runtime.CompileModule(ctx, code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkPluginExports(module); err != nil {
+		t.Fatal(err)
+	}
+
+	plugin := Plugin{Name: "test", Digest: "SHA-256=test"}
+	plugins := &Plugins{runtime: runtime, timeout: 50 * time.Millisecond, modules: map[string]wazero.CompiledModule{plugin.Digest: module}}
+	return plugins, plugin
+}
+
+func TestPluginValidate(t *testing.T) {
+	plugins, plugin := testPlugins(t, rejectPlugin)
+
+	violations, err := plugins.Validate(plugin, []byte("{}"))
+	if err != nil || len(violations) != 0 {
+		t.Errorf("Expected no violations, got %v, %v", violations, err)
+	}
+
+	violations, err = plugins.Validate(plugin, []byte(`{"id": 1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(violations) != 1 || violations[0] != "plugin says no" {
+		t.Errorf("Expected the plugin's violation, got %v", violations)
+	}
+}
+
+func TestPluginTimeout(t *testing.T) {
+	plugins, plugin := testPlugins(t, loopPlugin)
+
+	_, err := plugins.Validate(plugin, []byte("{}"))
+	if errorCode(err) != "408" {
+		t.Errorf("Expected a 408 error, got %v", err)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// SchemaPointerHeader validates a payload against just the part of the schema
+// a JSON Pointer points to, such as /properties/items, for partial updates.
+const SchemaPointerHeader = "Schema-Pointer"
+
+// fragmentSchema returns a schema accepting what the sub-schema at pointer in
+// body accepts. References within the sub-schema keep resolving against the
+// whole document.
+func fragmentSchema(body, pointer string) (string, error) {
+	pointer = strings.TrimPrefix(pointer, "#")
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal([]byte(body), &doc); err != nil {
+		return "", newError("400", "invalid schema: %v", err)
+	}
+	if _, err := resolvePointer(doc, pointer); err != nil {
+		return "", newError("400", "invalid schema pointer %q: %v", pointer, err)
+	}
+	if pointer == "" {
+		return body, nil
+	}
+
+	// Keywords next to a $ref are ignored, so only the fragment applies
+	doc["$ref"] = "#" + pointer
+	data, err := json.Marshal(doc)
+	return string(data), err
+}
+
+// validatePointer validates a payload against the sub-schema at pointer.
+func (reg *SchemaRegistry) validatePointer(data []byte, body, pointer string) error {
+	body, err := reg.resolvedBody(body)
+	if err != nil {
+		return err
+	}
+	fragment, err := fragmentSchema(body, pointer)
+	if err != nil {
+		return err
+	}
+	return reg.validate(data, fragment)
+}
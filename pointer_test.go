@@ -0,0 +1,26 @@
+package main
+
+import "testing"
+
+func TestValidatePointer(t *testing.T) {
+	reg := NewSchemaRegistry(nil, nil)
+	body := `{
+		"type": "object",
+		"required": ["id", "items"],
+		"properties": {
+			"id": {"type": "integer"},
+			"items": {"type": "array", "items": {"$ref": "#/definitions/item"}}
+		},
+		"definitions": {"item": {"type": "object", "required": ["sku"]}}
+	}`
+
+	if err := reg.validatePointer([]byte(`[{"sku": "a1"}]`), body, "/properties/items"); err != nil {
+		t.Errorf("Expected a valid fragment to pass, got %v", err)
+	}
+	if err := reg.validatePointer([]byte(`[{}]`), body, "#/properties/items"); err == nil {
+		t.Errorf("Expected references within the fragment to be resolved")
+	}
+	if err := reg.validatePointer([]byte(`[]`), body, "/properties/missing"); errorCode(err) != "400" {
+		t.Errorf("Expected an unresolvable pointer to fail, got %v", err)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// WorkerPool runs a message handler on its own goroutine per message, with at
+// most a fixed number running at once. When every worker is busy the
+// subscription callback blocks, pushing back on the subscription's pending
+// buffer instead of spawning unbounded goroutines.
+type WorkerPool struct {
+	handler nats.MsgHandler
+	slots   chan struct{}
+	wg      sync.WaitGroup
+}
+
+func NewWorkerPool(size int, handler nats.MsgHandler) *WorkerPool {
+	if size < 1 {
+		size = 1
+	}
+	return &WorkerPool{
+		handler: handler,
+		slots:   make(chan struct{}, size),
+	}
+}
+
+// Handle is a nats.MsgHandler dispatching the message to a worker.
+func (p *WorkerPool) Handle(m *nats.Msg) {
+	p.slots <- struct{}{}
+	p.wg.Add(1)
+	go func() {
+		defer func() {
+			<-p.slots
+			p.wg.Done()
+		}()
+		p.handler(m)
+	}()
+}
+
+// Wait blocks until every dispatched message has been handled, or returns an
+// error once the deadline passes.
+func (p *WorkerPool) Wait(deadline time.Time) error {
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	select {
+	case <-done:
+		return nil
+	case <-timer.C:
+		return fmt.Errorf("%d messages still being handled", len(p.slots))
+	}
+}
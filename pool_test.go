@@ -0,0 +1,54 @@
+package main
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+func TestWorkerPoolBoundsConcurrency(t *testing.T) {
+	var running, peak, handled int32
+	pool := NewWorkerPool(2, func(m *nats.Msg) {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		atomic.AddInt32(&handled, 1)
+	})
+
+	for i := 0; i < 10; i++ {
+		pool.Handle(&nats.Msg{})
+	}
+	if err := pool.Wait(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	if handled != 10 {
+		t.Errorf("Expected every message to be handled, got %d", handled)
+	}
+	if peak > 2 {
+		t.Errorf("Expected at most 2 concurrent workers, got %d", peak)
+	}
+}
+
+func TestWorkerPoolWaitDeadline(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	pool := NewWorkerPool(2, func(m *nats.Msg) { <-release })
+	pool.Handle(&nats.Msg{})
+
+	start := time.Now()
+	if err := pool.Wait(start.Add(20 * time.Millisecond)); err == nil {
+		t.Errorf("Expected waiting for a stuck worker to time out")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected waiting to stop at the deadline, took %v", elapsed)
+	}
+}
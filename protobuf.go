@@ -0,0 +1,816 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/nats-io/nats.go/micro"
+)
+
+// TypeProtobuf marks schemas defined by a .proto file. Their Source holds the
+// file and their Body the JSON Schema of Message in the proto3 JSON mapping,
+// which payloads are validated against.
+const TypeProtobuf = "protobuf"
+
+// ConvertRequest is a .proto file to derive the JSON Schema of a message from.
+type ConvertRequest struct {
+	Source  string `json:"source"`
+	Message string `json:"message,omitempty"`
+}
+
+// Convert subject: $SCHEMA.CONVERT.PROTO
+func (reg *SchemaRegistry) ConvertProto(r micro.Request) {
+	var req ConvertRequest
+	err := json.Unmarshal(r.Data(), &req)
+	if err != nil {
+		r.Error("400", err.Error(), nil)
+		return
+	}
+
+	body, err := ProtoToJSONSchema(req.Source, req.Message)
+	if err != nil {
+		r.Error("400", err.Error(), nil)
+		return
+	}
+
+	r.Respond([]byte(body))
+}
+
+// Proto subject: $SCHEMA.PROTO.<schema_name>
+func (reg *SchemaRegistry) GetProto(r micro.Request) {
+	name := subjectName(r.Subject())
+
+	reg.schemasMu.RLock()
+	schema, ok := reg.schemas[name]
+	reg.schemasMu.RUnlock()
+	if !ok {
+		r.Error("404", "Not found", nil)
+		return
+	}
+
+	if schema.Type == TypeProtobuf {
+		r.Respond([]byte(schema.Source))
+		return
+	}
+
+	body, err := reg.resolvedBody(schema.Body)
+	if err != nil {
+		r.Error(errorCode(err), err.Error(), nil)
+		return
+	}
+	source, err := JSONSchemaToProto(name, body)
+	if err != nil {
+		r.Error("400", err.Error(), nil)
+		return
+	}
+
+	r.Respond([]byte(source))
+}
+
+// deriveBody sets the body of a protobuf schema from its source.
+func deriveBody(schema *Schema) error {
+	if schema.Type != TypeProtobuf {
+		return nil
+	}
+	if schema.Source == "" {
+		return newError("400", "protobuf schema %q needs a source", schema.Name)
+	}
+
+	body, err := ProtoToJSONSchema(schema.Source, schema.Message)
+	if err != nil {
+		return newError("400", "invalid protobuf schema: %v", err)
+	}
+	schema.Body = body
+	return nil
+}
+
+// ProtoToJSONSchema translates a message of a .proto file into a JSON Schema
+// of its proto3 JSON encoding, with lowerCamelCase field names. The message may
+// be left out if the file defines a single top level message.
+func ProtoToJSONSchema(source string, message string) (string, error) {
+	file, err := parseProto(source)
+	if err != nil {
+		return "", err
+	}
+
+	var msg *protoMessage
+	switch {
+	case message != "":
+		msg = file.lookupMessage(message, file.pkg)
+		if msg == nil {
+			return "", fmt.Errorf("unknown message %q", message)
+		}
+	case len(file.topLevel) == 1:
+		msg = file.messages[file.topLevel[0]]
+	default:
+		return "", fmt.Errorf("the file defines %d messages, pick one", len(file.topLevel))
+	}
+
+	converter := protoConverter{file: file, pending: map[string]bool{}}
+	doc, err := converter.message(msg)
+	if err != nil {
+		return "", err
+	}
+	doc["$schema"] = "http://json-schema.org/draft-07/schema#"
+
+	encoded, err := json.Marshal(doc)
+	return string(encoded), err
+}
+
+type protoFile struct {
+	pkg string
+	// messages and enums are keyed by their full name, without a leading dot
+	messages map[string]*protoMessage
+	enums    map[string][]string
+	topLevel []string
+}
+
+type protoMessage struct {
+	fullName string
+	fields   []protoField
+}
+
+type protoField struct {
+	name     string
+	jsonName string
+	typ      string
+	// key is the key type of map fields
+	key      string
+	repeated bool
+	required bool
+}
+
+// lookupMessage resolves a type name used in scope the way protoc does,
+// from the innermost scope outwards.
+func (f *protoFile) lookupMessage(name, scope string) *protoMessage {
+	full := f.resolve(name, scope, func(full string) bool { return f.messages[full] != nil })
+	return f.messages[full]
+}
+
+func (f *protoFile) resolve(name, scope string, exists func(string) bool) string {
+	if strings.HasPrefix(name, ".") {
+		return strings.TrimPrefix(name, ".")
+	}
+	for {
+		candidate := name
+		if scope != "" {
+			candidate = scope + "." + name
+		}
+		if exists(candidate) {
+			return candidate
+		}
+		if scope == "" {
+			return name
+		}
+		if i := strings.LastIndex(scope, "."); i >= 0 {
+			scope = scope[:i]
+		} else {
+			scope = ""
+		}
+	}
+}
+
+// protoScalars maps the scalar types to their proto3 JSON encoding. 64 bit
+// integers are encoded as strings, but parsers accept numbers too.
+var protoScalars = map[string]map[string]interface{}{
+	"double":   {"type": "number"},
+	"float":    {"type": "number"},
+	"int32":    {"type": "integer"},
+	"uint32":   {"type": "integer", "minimum": 0},
+	"sint32":   {"type": "integer"},
+	"fixed32":  {"type": "integer", "minimum": 0},
+	"sfixed32": {"type": "integer"},
+	"int64":    {"type": []interface{}{"integer", "string"}},
+	"uint64":   {"type": []interface{}{"integer", "string"}},
+	"sint64":   {"type": []interface{}{"integer", "string"}},
+	"fixed64":  {"type": []interface{}{"integer", "string"}},
+	"sfixed64": {"type": []interface{}{"integer", "string"}},
+	"bool":     {"type": "boolean"},
+	"string":   {"type": "string"},
+	"bytes":    {"type": "string", "contentEncoding": "base64"},
+}
+
+// protoWellKnown maps the well-known types to their special JSON encodings.
+var protoWellKnown = map[string]map[string]interface{}{
+	"google.protobuf.Timestamp":   {"type": "string", "format": "date-time"},
+	"google.protobuf.Duration":    {"type": "string", "pattern": `^-?[0-9]+(\.[0-9]+)?s$`},
+	"google.protobuf.FieldMask":   {"type": "string"},
+	"google.protobuf.Struct":      {"type": "object"},
+	"google.protobuf.Value":       {},
+	"google.protobuf.ListValue":   {"type": "array"},
+	"google.protobuf.Any":         {"type": "object", "properties": map[string]interface{}{"@type": map[string]interface{}{"type": "string"}}},
+	"google.protobuf.Empty":       {"type": "object"},
+	"google.protobuf.DoubleValue": {"type": []interface{}{"number", "null"}},
+	"google.protobuf.FloatValue":  {"type": []interface{}{"number", "null"}},
+	"google.protobuf.Int64Value":  {"type": []interface{}{"integer", "string", "null"}},
+	"google.protobuf.UInt64Value": {"type": []interface{}{"integer", "string", "null"}},
+	"google.protobuf.Int32Value":  {"type": []interface{}{"integer", "null"}},
+	"google.protobuf.UInt32Value": {"type": []interface{}{"integer", "null"}},
+	"google.protobuf.BoolValue":   {"type": []interface{}{"boolean", "null"}},
+	"google.protobuf.StringValue": {"type": []interface{}{"string", "null"}},
+	"google.protobuf.BytesValue":  {"type": []interface{}{"string", "null"}},
+}
+
+// protoConverter keeps track of the messages being converted, as recursive
+// messages can't be expressed without $refs.
+type protoConverter struct {
+	file    *protoFile
+	pending map[string]bool
+}
+
+func (c protoConverter) message(msg *protoMessage) (map[string]interface{}, error) {
+	properties := map[string]interface{}{}
+	required := []interface{}{}
+	doc := map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+
+	c.pending[msg.fullName] = true
+	defer delete(c.pending, msg.fullName)
+
+	for _, field := range msg.fields {
+		value, err := c.fieldType(field.typ, msg.fullName)
+		if err != nil {
+			return nil, fmt.Errorf("field %q: %w", field.name, err)
+		}
+
+		switch {
+		case field.key != "":
+			value = map[string]interface{}{"type": "object", "additionalProperties": value}
+		case field.repeated:
+			value = map[string]interface{}{"type": "array", "items": value}
+		}
+		properties[field.jsonName] = value
+		if field.required {
+			required = append(required, field.jsonName)
+		}
+	}
+	if len(required) > 0 {
+		doc["required"] = required
+	}
+	return doc, nil
+}
+
+func (c protoConverter) fieldType(typ, scope string) (map[string]interface{}, error) {
+	if scalar, ok := protoScalars[typ]; ok {
+		return copyNode(scalar), nil
+	}
+	if known, ok := protoWellKnown[strings.TrimPrefix(typ, ".")]; ok {
+		return copyNode(known), nil
+	}
+
+	full := c.file.resolve(typ, scope, func(full string) bool {
+		return c.file.messages[full] != nil || c.file.enums[full] != nil
+	})
+	if values, ok := c.file.enums[full]; ok {
+		enum := make([]interface{}, len(values))
+		for i, v := range values {
+			enum[i] = v
+		}
+		return map[string]interface{}{"type": "string", "enum": enum}, nil
+	}
+	if msg, ok := c.file.messages[full]; ok {
+		if c.pending[full] {
+			return map[string]interface{}{}, nil
+		}
+		return c.message(msg)
+	}
+	return nil, fmt.Errorf("unknown type %q", typ)
+}
+
+// copyNode copies a shared type, so generated documents can't modify it.
+func copyNode(node map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(node))
+	for k, v := range node {
+		copied[k] = v
+	}
+	return copied
+}
+
+// protoParser reads the subset of the .proto language that defines the shape
+// of messages. Services, options and extensions are skipped.
+type protoParser struct {
+	tokens []string
+	pos    int
+	file   *protoFile
+}
+
+func parseProto(source string) (*protoFile, error) {
+	tokens, err := tokenizeProto(source)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &protoParser{
+		tokens: tokens,
+		file:   &protoFile{messages: map[string]*protoMessage{}, enums: map[string][]string{}},
+	}
+	for !p.done() {
+		switch tok := p.next(); tok {
+		case "syntax", "edition", "import", "option":
+			p.skipStatement()
+		case "package":
+			p.file.pkg = p.next()
+			if err := p.expect(";"); err != nil {
+				return nil, err
+			}
+		case "message":
+			name := p.next()
+			if err := p.parseMessage(name, p.file.pkg); err != nil {
+				return nil, err
+			}
+			p.file.topLevel = append(p.file.topLevel, join(p.file.pkg, name))
+		case "enum":
+			if err := p.parseEnum(p.file.pkg); err != nil {
+				return nil, err
+			}
+		case "service", "extend":
+			p.next()
+			if err := p.skipBlock(); err != nil {
+				return nil, err
+			}
+		case ";":
+		default:
+			return nil, fmt.Errorf("unexpected %q", tok)
+		}
+	}
+	return p.file, nil
+}
+
+func (p *protoParser) parseMessage(name, scope string) error {
+	msg := &protoMessage{fullName: join(scope, name)}
+	p.file.messages[msg.fullName] = msg
+
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+	for {
+		if p.done() {
+			return fmt.Errorf("message %s is not closed", name)
+		}
+		switch tok := p.next(); tok {
+		case "}":
+			return nil
+		case ";":
+		case "message":
+			if err := p.parseMessage(p.next(), msg.fullName); err != nil {
+				return err
+			}
+		case "enum":
+			if err := p.parseEnum(msg.fullName); err != nil {
+				return err
+			}
+		case "option", "reserved", "extensions":
+			p.skipStatement()
+		case "extend":
+			p.next()
+			if err := p.skipBlock(); err != nil {
+				return err
+			}
+		case "oneof":
+			// Every field of a oneof is optional
+			p.next()
+			if err := p.expect("{"); err != nil {
+				return err
+			}
+			for p.peek() != "}" && !p.done() {
+				if p.peek() == "option" {
+					p.skipStatement()
+					continue
+				}
+				field, err := p.parseField(p.next())
+				if err != nil {
+					return err
+				}
+				msg.fields = append(msg.fields, field)
+			}
+			p.next()
+		case "map":
+			field, err := p.parseMap()
+			if err != nil {
+				return err
+			}
+			msg.fields = append(msg.fields, field)
+		case "group":
+			return fmt.Errorf("groups are not supported")
+		default:
+			field, err := p.parseField(tok)
+			if err != nil {
+				return err
+			}
+			msg.fields = append(msg.fields, field)
+		}
+	}
+}
+
+// parseField parses a field starting at its label or type.
+func (p *protoParser) parseField(tok string) (protoField, error) {
+	var field protoField
+	switch tok {
+	case "repeated":
+		field.repeated = true
+		tok = p.next()
+	case "required":
+		field.required = true
+		tok = p.next()
+	case "optional":
+		tok = p.next()
+	}
+	if tok == "map" {
+		return p.parseMap()
+	}
+
+	field.typ = tok
+	field.name = p.next()
+	return field, p.parseFieldEnd(&field)
+}
+
+// parseMap parses a map field after the map keyword.
+func (p *protoParser) parseMap() (protoField, error) {
+	var field protoField
+	if err := p.expect("<"); err != nil {
+		return field, err
+	}
+	field.key = p.next()
+	if err := p.expect(","); err != nil {
+		return field, err
+	}
+	field.typ = p.next()
+	if err := p.expect(">"); err != nil {
+		return field, err
+	}
+	field.name = p.next()
+	return field, p.parseFieldEnd(&field)
+}
+
+// parseFieldEnd parses the number and options of a field, honoring json_name.
+func (p *protoParser) parseFieldEnd(field *protoField) error {
+	if !isProtoIdent(field.name) {
+		return fmt.Errorf("invalid field name %q", field.name)
+	}
+	field.jsonName = lowerCamel(field.name)
+
+	if err := p.expect("="); err != nil {
+		return err
+	}
+	if _, err := strconv.ParseUint(p.next(), 0, 32); err != nil {
+		return fmt.Errorf("invalid number for field %q", field.name)
+	}
+
+	if p.peek() == "[" {
+		p.next()
+		for p.peek() != "]" && !p.done() {
+			option := p.next()
+			if option == "json_name" && p.peek() == "=" {
+				p.next()
+				name, err := strconv.Unquote(p.next())
+				if err != nil {
+					return fmt.Errorf("invalid json_name for field %q", field.name)
+				}
+				field.jsonName = name
+			}
+		}
+		p.next()
+	}
+	return p.expect(";")
+}
+
+func (p *protoParser) parseEnum(scope string) error {
+	name := join(scope, p.next())
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+
+	var values []string
+	for {
+		if p.done() {
+			return fmt.Errorf("enum %s is not closed", name)
+		}
+		switch tok := p.next(); tok {
+		case "}":
+			p.file.enums[name] = values
+			return nil
+		case ";":
+		case "option", "reserved":
+			p.skipStatement()
+		default:
+			values = append(values, tok)
+			p.skipStatement()
+		}
+	}
+}
+
+func (p *protoParser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *protoParser) peek() string {
+	if p.done() {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *protoParser) next() string {
+	tok := p.peek()
+	p.pos++
+	return tok
+}
+
+func (p *protoParser) expect(tok string) error {
+	if got := p.next(); got != tok {
+		return fmt.Errorf("expected %q, got %q", tok, got)
+	}
+	return nil
+}
+
+// skipStatement skips to the end of the current statement.
+func (p *protoParser) skipStatement() {
+	for !p.done() {
+		switch p.next() {
+		case ";":
+			return
+		case "{":
+			p.pos--
+			p.skipBlock()
+			return
+		}
+	}
+}
+
+// skipBlock skips a block and everything in it.
+func (p *protoParser) skipBlock() error {
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+	depth := 1
+	for depth > 0 {
+		if p.done() {
+			return fmt.Errorf("block is not closed")
+		}
+		switch p.next() {
+		case "{":
+			depth++
+		case "}":
+			depth--
+		}
+	}
+	return nil
+}
+
+// tokenizeProto splits a .proto file into identifiers, numbers, quoted
+// strings and symbols, dropping comments.
+func tokenizeProto(source string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(source); {
+		c := source[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case strings.HasPrefix(source[i:], "//"):
+			for i < len(source) && source[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(source[i:], "/*"):
+			end := strings.Index(source[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated comment")
+			}
+			i += end + 4
+		case c == '"' || c == '\'':
+			j := i + 1
+			for j < len(source) && source[j] != c {
+				if source[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(source) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			// Quote with double quotes so strconv.Unquote handles both
+			tokens = append(tokens, `"`+source[i+1:j]+`"`)
+			i = j + 1
+		case c == '_' || c == '.' || c == '-' || c == '+' || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c)):
+			j := i
+			for j < len(source) && (source[j] == '_' || source[j] == '.' || unicode.IsLetter(rune(source[j])) || unicode.IsDigit(rune(source[j])) || (j == i && (source[j] == '-' || source[j] == '+'))) {
+				j++
+			}
+			tokens = append(tokens, source[i:j])
+			i = j
+		default:
+			tokens = append(tokens, string(c))
+			i++
+		}
+	}
+	return tokens, nil
+}
+
+func isProtoIdent(s string) bool {
+	if s == "" || unicode.IsDigit(rune(s[0])) {
+		return false
+	}
+	for _, r := range s {
+		if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// lowerCamel returns the JSON name protoc gives a field.
+func lowerCamel(name string) string {
+	var b strings.Builder
+	upper := false
+	for _, r := range name {
+		if r == '_' {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func join(scope, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "." + name
+}
+
+// JSONSchemaToProto generates a proto3 message named after a schema from its
+// JSON Schema body, where possible. Objects become nested messages, objects of
+// arbitrary keys maps, arrays repeated fields and string enums enums. Anything
+// protobuf can't express, such as unions, falls back to google.protobuf.Value.
+func JSONSchemaToProto(name string, body string) (string, error) {
+	var doc map[string]interface{}
+	err := json.Unmarshal([]byte(body), &doc)
+	if err != nil {
+		return "", fmt.Errorf("invalid schema: %w", err)
+	}
+
+	g := &protoGenerator{}
+	var b strings.Builder
+	g.message(&b, upperCamel(name), doc, "")
+
+	header := "syntax = \"proto3\";\n\n"
+	if g.imports["struct"] {
+		header += "import \"google/protobuf/struct.proto\";\n"
+	}
+	if g.imports["timestamp"] {
+		header += "import \"google/protobuf/timestamp.proto\";\n"
+	}
+	if len(g.imports) > 0 {
+		header += "\n"
+	}
+	return header + b.String(), nil
+}
+
+type protoGenerator struct {
+	imports map[string]bool
+}
+
+func (g *protoGenerator) use(file string) {
+	if g.imports == nil {
+		g.imports = map[string]bool{}
+	}
+	g.imports[file] = true
+}
+
+// message writes a message for an object schema, with its nested types.
+func (g *protoGenerator) message(b *strings.Builder, name string, doc map[string]interface{}, indent string) {
+	fmt.Fprintf(b, "%smessage %s {\n", indent, name)
+
+	properties := asObject(doc["properties"])
+	for i, prop := range sortedKeys(properties) {
+		field := protoFieldName(prop)
+		typ := g.fieldType(b, upperCamel(field), asObject(properties[prop]), indent+"  ", true)
+
+		options := ""
+		if lowerCamel(field) != prop {
+			options = fmt.Sprintf(" [json_name = %q]", prop)
+		}
+		fmt.Fprintf(b, "%s  %s %s = %d%s;\n", indent, typ, field, i+1, options)
+	}
+
+	fmt.Fprintf(b, "%s}\n", indent)
+}
+
+// fieldType returns the proto type of a property, writing the nested message
+// or enum it needs first.
+func (g *protoGenerator) fieldType(b *strings.Builder, name string, prop map[string]interface{}, indent string, allowRepeated bool) string {
+	types := typeSet(prop)
+	delete(types, "null")
+	typ := ""
+	if len(types) == 1 {
+		for t := range types {
+			typ = t
+		}
+	}
+
+	if enum, ok := prop["enum"].([]interface{}); ok && (typ == "" || typ == "string") {
+		if values, ok := enumValues(enum); ok {
+			fmt.Fprintf(b, "%senum %s {\n", indent, name)
+			fmt.Fprintf(b, "%s  %s_UNSPECIFIED = 0;\n", indent, upperSnake(name))
+			for i, v := range values {
+				fmt.Fprintf(b, "%s  %s = %d;\n", indent, v, i+1)
+			}
+			fmt.Fprintf(b, "%s}\n", indent)
+			return name
+		}
+	}
+
+	switch typ {
+	case "string":
+		if prop["format"] == "date-time" {
+			g.use("timestamp")
+			return "google.protobuf.Timestamp"
+		}
+		return "string"
+	case "integer":
+		return "int64"
+	case "number":
+		return "double"
+	case "boolean":
+		return "bool"
+	case "object":
+		if _, ok := prop["properties"]; !ok {
+			if values, ok := prop["additionalProperties"].(map[string]interface{}); ok {
+				return "map<string, " + g.fieldType(b, name+"Value", values, indent, false) + ">"
+			}
+			g.use("struct")
+			return "google.protobuf.Struct"
+		}
+		g.message(b, name, prop, indent)
+		return name
+	case "array":
+		items := asObject(prop["items"])
+		if allowRepeated && len(items) > 0 {
+			return "repeated " + g.fieldType(b, name+"Item", items, indent, false)
+		}
+		g.use("struct")
+		return "google.protobuf.ListValue"
+	}
+
+	g.use("struct")
+	return "google.protobuf.Value"
+}
+
+// enumValues returns the values of a string enum if they are all usable as
+// enum value names.
+func enumValues(enum []interface{}) ([]string, bool) {
+	var values []string
+	for _, v := range enum {
+		s, ok := v.(string)
+		if !ok || !isProtoIdent(s) || s != strings.ToUpper(s) {
+			return nil, false
+		}
+		values = append(values, s)
+	}
+	return values, len(values) > 0
+}
+
+// protoFieldName turns a JSON property name into a snake_case field name.
+func protoFieldName(prop string) string {
+	var b strings.Builder
+	for i, r := range prop {
+		switch {
+		case unicode.IsUpper(r):
+			if i > 0 {
+				b.WriteRune('_')
+			}
+			b.WriteRune(unicode.ToLower(r))
+		case r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		default:
+			b.WriteRune('_')
+		}
+	}
+	name := b.String()
+	if name == "" || unicode.IsDigit(rune(name[0])) {
+		name = "f_" + name
+	}
+	return name
+}
+
+func upperCamel(name string) string {
+	camel := lowerCamel(strings.NewReplacer("-", "_", ".", "_").Replace(name))
+	if camel == "" {
+		return "Message"
+	}
+	return strings.ToUpper(camel[:1]) + camel[1:]
+}
+
+func upperSnake(name string) string {
+	return strings.ToUpper(protoFieldName(name))
+}
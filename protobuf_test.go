@@ -0,0 +1,94 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const orderProto = `
+syntax = "proto3";
+package shop;
+
+import "google/protobuf/timestamp.proto";
+
+// An order
+message Order {
+  string order_id = 1;
+  repeated Item items = 2;
+  map<string, string> labels = 3 [deprecated = true];
+  Status status = 4;
+  google.protobuf.Timestamp created_at = 5;
+  Order parent = 6;
+  oneof payment {
+    string card = 7;
+    string iban = 8 [json_name = "IBAN"];
+  }
+
+  message Item {
+    string sku = 1;
+    int64 quantity = 2;
+  }
+}
+
+enum Status {
+  STATUS_UNSPECIFIED = 0;
+  OPEN = 1;
+}
+`
+
+func TestProtoToJSONSchema(t *testing.T) {
+	body, err := ProtoToJSONSchema(orderProto, "")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := CompileSchema(body); err != nil {
+		t.Fatalf("Expected a usable schema, got %v", err)
+	}
+
+	valid := `{"orderId": "1", "items": [{"sku": "a", "quantity": "2"}], "labels": {"a": "b"}, "status": "OPEN", "createdAt": "2024-01-01T00:00:00Z", "parent": {}, "IBAN": "x"}`
+	if problems, _ := violations([]byte(valid), body); len(problems) > 0 {
+		t.Errorf("Expected the proto3 JSON encoding to be valid, got %v", problems)
+	}
+	invalid := `{"order_id": "1", "status": "CLOSED"}`
+	if problems, _ := violations([]byte(invalid), body); len(problems) != 2 {
+		t.Errorf("Expected unknown fields and enum values to be invalid, got %v", problems)
+	}
+
+	if _, err := ProtoToJSONSchema(orderProto, "Missing"); err == nil {
+		t.Errorf("Expected an unknown message to fail")
+	}
+}
+
+func TestJSONSchemaToProto(t *testing.T) {
+	body := `{"type": "object", "properties": {
+		"orderId": {"type": "string"},
+		"total": {"type": "number"},
+		"tags": {"type": "array", "items": {"type": "string"}},
+		"status": {"enum": ["OPEN", "CLOSED"]},
+		"customer": {"type": "object", "properties": {"name": {"type": "string"}}},
+		"extra": {"anyOf": [{"type": "string"}, {"type": "integer"}]}
+	}}`
+
+	source, err := JSONSchemaToProto("order_placed", body)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	for _, line := range []string{
+		"message OrderPlaced {",
+		"string order_id = ",
+		"repeated string tags = ",
+		"STATUS_UNSPECIFIED = 0;",
+		"message Customer {",
+		"google.protobuf.Value extra = ",
+		`import "google/protobuf/struct.proto";`,
+	} {
+		if !strings.Contains(source, line) {
+			t.Errorf("Expected %q in:\n%s", line, source)
+		}
+	}
+
+	// The generated file converts back
+	if _, err := ProtoToJSONSchema(source, "OrderPlaced"); err != nil {
+		t.Errorf("Expected the generated proto to parse, got %v", err)
+	}
+}
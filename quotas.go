@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sync"
+	"time"
+)
+
+// Quota caps what the schemas whose names match Namespace, typically those of
+// a team, may use together.
+type Quota struct {
+	// Namespace is a glob matched against the schema name, e.g. "orders_*".
+	Namespace string `json:"namespace"`
+	// MaxSchemas is the number of schemas the namespace may hold.
+	MaxSchemas int `json:"max_schemas,omitempty"`
+	// MaxValidationsPerSecond is the rate at which this instance validates
+	// payloads for the namespace. Bursts of up to a second's worth are allowed.
+	MaxValidationsPerSecond float64 `json:"max_validations_per_second,omitempty"`
+
+	limiter *rateLimiter
+}
+
+// Quotas are the quotas of every namespace. A nil Quotas allows everything.
+type Quotas struct {
+	Quotas []*Quota `json:"quotas"`
+}
+
+// LoadQuotas reads quotas from a JSON file. An empty path returns nil Quotas.
+func LoadQuotas(file string) (*Quotas, error) {
+	if file == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var q Quotas
+	err = json.Unmarshal(data, &q)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, quota := range q.Quotas {
+		if _, err := path.Match(quota.Namespace, ""); err != nil {
+			return nil, fmt.Errorf("invalid namespace %q: %w", quota.Namespace, err)
+		}
+		if quota.MaxSchemas < 0 || quota.MaxValidationsPerSecond < 0 {
+			return nil, fmt.Errorf("quota of namespace %q can't be negative", quota.Namespace)
+		}
+		if quota.MaxValidationsPerSecond > 0 {
+			quota.limiter = newRateLimiter(quota.MaxValidationsPerSecond)
+		}
+	}
+
+	return &q, nil
+}
+
+// matching returns the quotas that apply to the named schema.
+func (q *Quotas) matching(name string) []*Quota {
+	if q == nil {
+		return nil
+	}
+	var quotas []*Quota
+	for _, quota := range q.Quotas {
+		if ok, _ := path.Match(quota.Namespace, name); ok {
+			quotas = append(quotas, quota)
+		}
+	}
+	return quotas
+}
+
+// CheckSchemas returns an error if registering the named schema would exceed
+// the number of schemas of its namespace. count returns the number of schemas
+// a namespace currently holds.
+func (q *Quotas) CheckSchemas(name string, count func(namespace string) int) error {
+	for _, quota := range q.matching(name) {
+		if quota.MaxSchemas == 0 {
+			continue
+		}
+		if n := count(quota.Namespace); n >= quota.MaxSchemas {
+			return newError("429", "quota exceeded: namespace %q already holds %d of %d schemas", quota.Namespace, n, quota.MaxSchemas)
+		}
+	}
+	return nil
+}
+
+// AllowValidation returns an error if validating a payload for the named
+// schema would exceed the validation rate of its namespace.
+func (q *Quotas) AllowValidation(name string) error {
+	for _, quota := range q.matching(name) {
+		if quota.limiter != nil && !quota.limiter.Allow() {
+			return newError("429", "quota exceeded: namespace %q may validate %g payloads per second", quota.Namespace, quota.MaxValidationsPerSecond)
+		}
+	}
+	return nil
+}
+
+// rateLimiter is a token bucket refilled at rate tokens per second, holding up
+// to a second's worth.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+func newRateLimiter(rate float64) *rateLimiter {
+	burst := rate
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{rate: rate, burst: burst, tokens: burst, last: time.Now(), now: time.Now}
+}
+
+// Allow takes a token if there is one.
+func (l *rateLimiter) Allow() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
+
+// countSchemas returns the number of cached schemas in a namespace.
+func (reg *SchemaRegistry) countSchemas(namespace string) int {
+	reg.schemasMu.RLock()
+	defer reg.schemasMu.RUnlock()
+
+	n := 0
+	for name := range reg.schemas {
+		if ok, _ := path.Match(namespace, name); ok {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQuotas(t *testing.T) {
+	limiter := newRateLimiter(2)
+	now := time.Now()
+	limiter.now = func() time.Time { return now }
+	quotas := &Quotas{Quotas: []*Quota{
+		{Namespace: "orders_*", MaxSchemas: 2, MaxValidationsPerSecond: 2, limiter: limiter},
+	}}
+
+	count := func(namespace string) int { return 2 }
+	if err := quotas.CheckSchemas("orders_created", count); errorCode(err) != "429" {
+		t.Errorf("Expected a full namespace to be over quota, got %v", err)
+	}
+	if err := quotas.CheckSchemas("payments", count); err != nil {
+		t.Errorf("Expected schemas outside of the namespace to have no quota, got %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := quotas.AllowValidation("orders_created"); err != nil {
+			t.Errorf("Expected a burst of 2 validations to be allowed, got %v", err)
+		}
+	}
+	if err := quotas.AllowValidation("orders_created"); errorCode(err) != "429" {
+		t.Errorf("Expected the third validation to be over quota, got %v", err)
+	}
+	now = now.Add(500 * time.Millisecond)
+	if err := quotas.AllowValidation("orders_created"); err != nil {
+		t.Errorf("Expected the rate to refill, got %v", err)
+	}
+
+	var none *Quotas
+	if err := none.AllowValidation("orders_created"); err != nil {
+		t.Errorf("Expected no quotas to allow everything, got %v", err)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SchemaErrorsHeader chooses how much of a validation error a rejected
+// request is answered with: compact, full, or the first N violations.
+const SchemaErrorsHeader = "Schema-Errors"
+
+// Error detail levels, besides a number of violations.
+const (
+	ErrorsCompact = "compact"
+	ErrorsFull    = "full"
+)
+
+// ValidationError lists why a message doesn't match a schema.
+type ValidationError struct {
+	// What was validated, such as payload or headers
+	What       string
+	Violations []string
+	// Items are the indexes of the items of a batch that failed validation
+	Items []int
+}
+
+func (e *ValidationError) Error() string {
+	return e.Render(0)
+}
+
+// Render describes the error with at most max violations, or all of them if
+// max is zero. Left out violations are counted.
+func (e *ValidationError) Render(max int) string {
+	violations := e.Violations
+	more := 0
+	if max > 0 && len(violations) > max {
+		violations, more = violations[:max], len(violations)-max
+	}
+
+	s := fmt.Sprintf("invalid %s: %s", e.What, strings.Join(violations, ", "))
+	if more > 0 {
+		s += fmt.Sprintf(" (and %d more)", more)
+	}
+	return s
+}
+
+// ParseErrorDetail returns the number of violations an error detail level
+// renders, zero meaning all of them.
+func ParseErrorDetail(detail string) (int, error) {
+	switch detail {
+	case ErrorsCompact:
+		return 1, nil
+	case ErrorsFull:
+		return 0, nil
+	}
+	n, err := strconv.Atoi(detail)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid error detail %q, use %s, %s or a number of violations", detail, ErrorsCompact, ErrorsFull)
+	}
+	return n, nil
+}
+
+// renderError describes err at the detail level requested, falling back to
+// the registry's default. Only validation errors have any detail to leave out.
+func (reg *SchemaRegistry) renderError(err error, detail string) string {
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		return err.Error()
+	}
+
+	max, parseErr := ParseErrorDetail(detail)
+	if detail == "" || parseErr != nil {
+		max = reg.settings().errorDetail
+	}
+	return verr.Render(max)
+}
@@ -0,0 +1,26 @@
+package main
+
+import "testing"
+
+func TestRenderError(t *testing.T) {
+	reg := NewSchemaRegistry(nil, nil)
+	err := &ValidationError{What: "payload", Violations: []string{"a: required", "b: required", "c: required"}}
+
+	tests := map[string]string{
+		"":        "invalid payload: a: required, b: required, c: required",
+		"full":    "invalid payload: a: required, b: required, c: required",
+		"compact": "invalid payload: a: required (and 2 more)",
+		"2":       "invalid payload: a: required, b: required (and 1 more)",
+		"5":       "invalid payload: a: required, b: required, c: required",
+	}
+	for detail, expected := range tests {
+		if rendered := reg.renderError(err, detail); rendered != expected {
+			t.Errorf("Expected %q to render %q, got %q", detail, expected, rendered)
+		}
+	}
+
+	reg.settings().errorDetail = 1
+	if rendered := reg.renderError(err, "bogus"); rendered != "invalid payload: a: required (and 2 more)" {
+		t.Errorf("Expected an invalid detail level to fall back to the default, got %q", rendered)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/nats-io/nats.go"
+)
+
+// proxyRequest forwards a validated request, validates the service's reply
+// against the schema's response body and relays it to the original requester.
+// Invalid replies are never relayed, the requester gets the validation error
+// instead.
+func (reg *SchemaRegistry) proxyRequest(m *nats.Msg, msg *nats.Msg, schema Schema) {
+	msg.Reply = ""
+	resp, err := reg.nc.RequestMsg(msg, reg.responseTimeout)
+	if err != nil {
+		requestLogger(m, schema, msg.Subject).Error("error requesting", "error", err)
+		code := "502"
+		switch {
+		case errors.Is(err, nats.ErrTimeout):
+			code = "504"
+		case errors.Is(err, nats.ErrNoResponders):
+			code = "503"
+		}
+		reg.respondError(m, code, fmt.Sprintf("request to %q failed: %v", msg.Subject, err))
+		return
+	}
+
+	err = reg.validate(resp.Data, schema.ResponseBody)
+	if err != nil {
+		reg.respondError(m, "502", fmt.Sprintf("invalid response from %q: %s", msg.Subject, reg.renderError(err, m.Header.Get(SchemaErrorsHeader))))
+		return
+	}
+
+	reply := nats.NewMsg(m.Reply)
+	reply.Data = resp.Data
+	reply.Header = resp.Header
+	if reply.Header == nil {
+		reply.Header = nats.Header{}
+	}
+	reply.Header.Set("Schema-Name", schema.Name)
+	reply.Header.Set("Schema-Revision", fmt.Sprintf("%d", schema.Revision))
+	reply.Header.Set("Schema-Response-Validated", "true")
+	reply.Header.Set(SchemaRequestIDHeader, m.Header.Get(SchemaRequestIDHeader))
+
+	err = m.RespondMsg(reply)
+	if err != nil {
+		requestLogger(m, schema, msg.Subject).Error("error relaying response", "error", err)
+	}
+}
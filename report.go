@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/nats-io/nats.go/micro"
+)
+
+// ReportRequest tunes the thresholds of a registry report. Zero values take
+// the defaults.
+type ReportRequest struct {
+	// IdleDays is how long a schema must go without validations to be idle,
+	// 30 days by default.
+	IdleDays int `json:"idle_days,omitempty"`
+	// FailureRate is the share of failed validations above which a schema is
+	// failing, 0.1 by default.
+	FailureRate float64 `json:"failure_rate,omitempty"`
+	// Largest is the number of largest schemas listed, 10 by default.
+	Largest int `json:"largest,omitempty"`
+}
+
+// FailingSchema is a schema that fails more validations than it should.
+type FailingSchema struct {
+	Name        string  `json:"name"`
+	Validations uint64  `json:"validations"`
+	FailureRate float64 `json:"failure_rate"`
+}
+
+// SchemaSize is the size in bytes of a schema's bodies.
+type SchemaSize struct {
+	Name string `json:"name"`
+	Size int    `json:"size"`
+}
+
+// RegistryReport sums up the registered schemas and how they are used, for
+// governance reviews.
+type RegistryReport struct {
+	Time time.Time `json:"time"`
+	// Since is when this instance started counting validations. Schemas
+	// validated before, or by other instances, aren't accounted for.
+	Since   time.Time       `json:"since"`
+	Schemas int             `json:"schemas"`
+	ByType  map[string]int  `json:"by_type"`
+	ByState map[string]int  `json:"by_state"`
+	Idle    []string        `json:"idle"`
+	Failing []FailingSchema `json:"failing"`
+	Largest []SchemaSize    `json:"largest"`
+}
+
+// Report subject: $SCHEMA.REPORT
+func (reg *SchemaRegistry) Report(r micro.Request) {
+	var req ReportRequest
+	if len(r.Data()) > 0 {
+		err := json.Unmarshal(r.Data(), &req)
+		if err != nil {
+			r.Error("400", err.Error(), nil)
+			return
+		}
+	}
+
+	r.RespondJSON(reg.report(req, time.Now().UTC()))
+}
+
+// report sums up the cached schemas and their validation stats.
+func (reg *SchemaRegistry) report(req ReportRequest, now time.Time) RegistryReport {
+	if req.IdleDays <= 0 {
+		req.IdleDays = 30
+	}
+	if req.FailureRate <= 0 {
+		req.FailureRate = 0.1
+	}
+	if req.Largest <= 0 {
+		req.Largest = 10
+	}
+	idleSince := now.AddDate(0, 0, -req.IdleDays)
+
+	report := RegistryReport{
+		Time:    now,
+		Since:   reg.stats.Since(),
+		ByType:  map[string]int{},
+		ByState: map[string]int{},
+		Idle:    []string{},
+		Failing: []FailingSchema{},
+		Largest: []SchemaSize{},
+	}
+	for _, schema := range reg.list() {
+		report.Schemas++
+		schemaType := schema.Type
+		if schemaType == "" {
+			schemaType = "jsonschema"
+		}
+		report.ByType[schemaType]++
+		report.ByState[schema.State]++
+
+		stats := reg.stats.Get(schema.Name)
+		last := reg.lastUsed(schema.Name)
+		if last.IsZero() {
+			last = report.Since
+		}
+		if last.Before(idleSince) {
+			report.Idle = append(report.Idle, schema.Name)
+		}
+
+		if validations := stats.Passed + stats.Failed; validations > 0 {
+			rate := float64(stats.Failed) / float64(validations)
+			if rate > req.FailureRate {
+				report.Failing = append(report.Failing, FailingSchema{Name: schema.Name, Validations: validations, FailureRate: rate})
+			}
+		}
+
+		size := len(schema.Body) + len(schema.ResponseBody) + len(schema.HeaderBody) + len(schema.Source)
+		report.Largest = append(report.Largest, SchemaSize{Name: schema.Name, Size: size})
+	}
+
+	sort.SliceStable(report.Failing, func(i, j int) bool { return report.Failing[i].FailureRate > report.Failing[j].FailureRate })
+	sort.SliceStable(report.Largest, func(i, j int) bool { return report.Largest[i].Size > report.Largest[j].Size })
+	if len(report.Largest) > req.Largest {
+		report.Largest = report.Largest[:req.Largest]
+	}
+	return report
+}
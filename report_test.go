@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReport(t *testing.T) {
+	reg := NewSchemaRegistry(nil, nil)
+	reg.schemas["orders"] = Schema{Name: "orders", State: StateActive, Body: `{"type": "object"}`}
+	reg.schemas["users"] = Schema{Name: "users", State: StateDeprecated, Type: TypeProtobuf, Body: `{}`}
+	reg.schemas["legacy"] = Schema{Name: "legacy", State: StateActive, Body: `{"type": "object", "description": "big"}`}
+
+	for i := 0; i < 3; i++ {
+		reg.stats.Record("orders", i > 0)
+	}
+	reg.stats.Record("users", true)
+
+	report := reg.report(ReportRequest{IdleDays: 30, Largest: 2}, time.Now().AddDate(0, 0, 31))
+	if report.Schemas != 3 || report.ByType["jsonschema"] != 2 || report.ByType[TypeProtobuf] != 1 || report.ByState[StateActive] != 2 {
+		t.Errorf("Unexpected counts %+v", report)
+	}
+	if len(report.Idle) != 3 {
+		t.Errorf("Expected every schema to be idle a month later, got %v", report.Idle)
+	}
+	if len(report.Failing) != 1 || report.Failing[0].Name != "orders" || report.Failing[0].Validations != 3 {
+		t.Errorf("Expected orders to be failing, got %+v", report.Failing)
+	}
+	if len(report.Largest) != 2 || report.Largest[0].Name != "legacy" {
+		t.Errorf("Expected legacy to be the largest of two, got %+v", report.Largest)
+	}
+
+	report = reg.report(ReportRequest{}, time.Now())
+	if len(report.Idle) != 0 {
+		t.Errorf("Expected no idle schemas right after starting, got %v", report.Idle)
+	}
+}
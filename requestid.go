@@ -0,0 +1,83 @@
+package main
+
+import (
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/micro"
+	"github.com/nats-io/nuid"
+)
+
+// SchemaRequestIDHeader identifies a request across the producer, the registry
+// and the consumer. Requests without one are given one, and it's echoed in
+// every response, forwarded with validated messages and recorded with
+// failures and schema revisions.
+const SchemaRequestIDHeader = "Schema-Request-Id"
+
+// ensureRequestID returns the request ID of a message, setting a new one if it
+// has none.
+func ensureRequestID(m *nats.Msg) string {
+	if m.Header == nil {
+		m.Header = nats.Header{}
+	}
+	id := m.Header.Get(SchemaRequestIDHeader)
+	if id == "" {
+		id = nuid.Next()
+		m.Header.Set(SchemaRequestIDHeader, id)
+	}
+	return id
+}
+
+// RequestID returns the request ID of an endpoint request.
+func RequestID(r micro.Request) string {
+	if r, ok := r.(*idRequest); ok {
+		return r.id
+	}
+	return r.Headers().Get(SchemaRequestIDHeader)
+}
+
+// withRequestID wraps an endpoint handler so every response and error carries
+// the request ID.
+func withRequestID(handler micro.HandlerFunc) micro.Handler {
+	return micro.HandlerFunc(func(r micro.Request) {
+		id := r.Headers().Get(SchemaRequestIDHeader)
+		if id == "" {
+			id = nuid.Next()
+		}
+		logger("service").Debug("Handling request", "subject", r.Subject(), "request_id", id)
+		handler(&idRequest{Request: r, id: id})
+	})
+}
+
+// idRequest is an endpoint request responding with its request ID.
+type idRequest struct {
+	micro.Request
+	id string
+}
+
+func (r *idRequest) Respond(data []byte, opts ...micro.RespondOpt) error {
+	return r.Request.Respond(data, append(opts, r.header)...)
+}
+
+func (r *idRequest) RespondJSON(v interface{}, opts ...micro.RespondOpt) error {
+	return r.Request.RespondJSON(v, append(opts, r.header)...)
+}
+
+func (r *idRequest) Error(code, description string, data []byte, opts ...micro.RespondOpt) error {
+	return r.Request.Error(code, description, data, append(opts, r.header)...)
+}
+
+func (r *idRequest) Headers() micro.Headers {
+	headers := nats.Header{}
+	for key, values := range r.Request.Headers() {
+		headers[key] = values
+	}
+	headers.Set(SchemaRequestIDHeader, r.id)
+	return micro.Headers(headers)
+}
+
+// header sets the request ID header of a response.
+func (r *idRequest) header(m *nats.Msg) {
+	if m.Header == nil {
+		m.Header = nats.Header{}
+	}
+	m.Header.Set(SchemaRequestIDHeader, r.id)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/micro"
+)
+
+// recordingRequest is an endpoint request keeping its response.
+type recordingRequest struct {
+	subject string
+	headers micro.Headers
+	data    []byte
+	resp    *nats.Msg
+}
+
+func (r *recordingRequest) respond(m *nats.Msg, opts []micro.RespondOpt) error {
+	for _, opt := range opts {
+		opt(m)
+	}
+	r.resp = m
+	return nil
+}
+
+func (r *recordingRequest) Respond(data []byte, opts ...micro.RespondOpt) error {
+	return r.respond(&nats.Msg{Data: data}, opts)
+}
+
+func (r *recordingRequest) RespondJSON(v interface{}, opts ...micro.RespondOpt) error {
+	return r.respond(&nats.Msg{}, opts)
+}
+
+func (r *recordingRequest) Error(code, description string, data []byte, opts ...micro.RespondOpt) error {
+	return r.respond(&nats.Msg{Header: nats.Header{micro.ErrorCodeHeader: []string{code}}}, opts)
+}
+
+func (r *recordingRequest) Data() []byte           { return r.data }
+func (r *recordingRequest) Headers() micro.Headers { return r.headers }
+func (r *recordingRequest) Subject() string        { return r.subject }
+
+func TestWithRequestIDEchoesID(t *testing.T) {
+	var seen string
+	handler := withRequestID(func(r micro.Request) {
+		seen = RequestID(r)
+		r.Error("404", "not found", nil)
+	})
+
+	req := &recordingRequest{headers: micro.Headers{SchemaRequestIDHeader: []string{"abc"}}}
+	handler.Handle(req)
+	if seen != "abc" {
+		t.Errorf("Expected the handler to see request ID abc, got %q", seen)
+	}
+	if id := req.resp.Header.Get(SchemaRequestIDHeader); id != "abc" {
+		t.Errorf("Expected the error to carry request ID abc, got %q", id)
+	}
+	if code := req.resp.Header.Get(micro.ErrorCodeHeader); code != "404" {
+		t.Errorf("Expected the error code to be kept, got %q", code)
+	}
+}
+
+func TestWithRequestIDGeneratesID(t *testing.T) {
+	var seen string
+	handler := withRequestID(func(r micro.Request) {
+		seen = r.Headers().Get(SchemaRequestIDHeader)
+		r.Respond([]byte("ok"))
+	})
+
+	req := &recordingRequest{}
+	handler.Handle(req)
+	if seen == "" {
+		t.Fatalf("Expected a request ID to be generated")
+	}
+	if id := req.resp.Header.Get(SchemaRequestIDHeader); id != seen {
+		t.Errorf("Expected the response to carry request ID %q, got %q", seen, id)
+	}
+}
+
+func TestEnsureRequestID(t *testing.T) {
+	m := nats.NewMsg("orders.created")
+	m.Header = nil
+	id := ensureRequestID(m)
+	if id == "" || m.Header.Get(SchemaRequestIDHeader) != id {
+		t.Errorf("Expected a request ID to be set, got %q", id)
+	}
+	if again := ensureRequestID(m); again != id {
+		t.Errorf("Expected the request ID to be kept, got %q", again)
+	}
+}
+
+func TestRejectionRecordsRequestID(t *testing.T) {
+	reg := &SchemaRegistry{failures: NewFailureLog(10), stats: NewValidationStats()}
+	m := nats.NewMsg("orders.created")
+	m.Header.Set(SchemaRequestIDHeader, "abc")
+
+	reg.recordRejection(m, Schema{Name: "orders"}, "orders.created", newError("400", "invalid"))
+	failures := reg.failures.List("orders")
+	if len(failures) != 1 || failures[0].RequestID != "abc" {
+		t.Errorf("Expected the failure to record request ID abc, got %+v", failures)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RouteSubject returns the subject a validated message is forwarded to. With
+// no destination configured messages keep their subject. Otherwise the
+// destination's wildcards are filled in from the literal subject: each * takes
+// the next token matched by a * or {name} in the schema's subject, > takes the
+// tokens matched by >, and {name} takes the named parameter.
+func RouteSubject(schema Schema, subject string, params map[string]string) string {
+	if schema.Destination == "" {
+		return subject
+	}
+
+	literal := strings.Split(subject, ".")
+	var wildcards []string
+	var rest string
+	for i, token := range strings.Split(schema.Subject, ".") {
+		if i >= len(literal) {
+			break
+		}
+		if token == ">" {
+			rest = strings.Join(literal[i:], ".")
+			break
+		}
+		if _, ok := isParam(token); ok || token == "*" {
+			wildcards = append(wildcards, literal[i])
+		}
+	}
+
+	tokens := strings.Split(schema.Destination, ".")
+	for i, token := range tokens {
+		switch {
+		case token == "*" && len(wildcards) > 0:
+			tokens[i] = wildcards[0]
+			wildcards = wildcards[1:]
+		case token == ">":
+			tokens[i] = rest
+		default:
+			if name, ok := isParam(token); ok {
+				tokens[i] = params[name]
+			}
+		}
+	}
+	return strings.Join(tokens, ".")
+}
+
+// checkDestination returns an error if a schema's destination can't be filled
+// in from every subject the schema matches.
+func checkDestination(schema Schema) error {
+	if schema.Destination == "" {
+		return nil
+	}
+
+	var single int
+	var full bool
+	for _, token := range strings.Split(schema.Subject, ".") {
+		if _, ok := isParam(token); ok || token == "*" {
+			single++
+		}
+		full = full || token == ">"
+	}
+
+	tokens := strings.Split(schema.Destination, ".")
+	for i, token := range tokens {
+		switch {
+		case token == "":
+			return fmt.Errorf("invalid destination %q", schema.Destination)
+		case token == "*":
+			single--
+			if single < 0 {
+				return fmt.Errorf("destination %q has more * wildcards than subject %q", schema.Destination, schema.Subject)
+			}
+		case token == ">":
+			if !full || i != len(tokens)-1 {
+				return fmt.Errorf("destination %q can only end with > if subject %q does", schema.Destination, schema.Subject)
+			}
+		default:
+			if name, ok := isParam(token); ok && !strings.Contains(schema.Subject, "{"+name+"}") {
+				return fmt.Errorf("destination parameter %q is not part of subject %q", name, schema.Subject)
+			}
+		}
+	}
+	return nil
+}
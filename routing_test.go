@@ -0,0 +1,54 @@
+package main
+
+import "testing"
+
+func TestRouteSubject(t *testing.T) {
+	cases := []struct {
+		subject     string
+		destination string
+		literal     string
+		expected    string
+	}{
+		{"raw.orders.*", "", "raw.orders.eu", "raw.orders.eu"},
+		{"raw.orders.*", "validated.orders.*", "raw.orders.eu", "validated.orders.eu"},
+		{"raw.*.*", "validated.*.*", "raw.orders.eu", "validated.orders.eu"},
+		{"raw.>", "validated.>", "raw.orders.eu.new", "validated.orders.eu.new"},
+		{"raw.{region}.{event}", "validated.{event}.{region}", "raw.eu.new", "validated.new.eu"},
+		{"raw.{region}.*", "validated.*", "raw.eu.new", "validated.eu"},
+	}
+
+	for _, c := range cases {
+		schema := Schema{Subject: c.subject, Destination: c.destination}
+		params := SubjectParams(c.literal, c.subject)
+		if got := RouteSubject(schema, c.literal, params); got != c.expected {
+			t.Errorf("Expected %q to route to %q, got %q", c.literal, c.expected, got)
+		}
+	}
+}
+
+func TestCheckDestination(t *testing.T) {
+	valid := []Schema{
+		{Subject: "raw.orders.*"},
+		{Subject: "raw.orders.*", Destination: "validated.orders.*"},
+		{Subject: "raw.{region}", Destination: "validated.{region}"},
+		{Subject: "raw.>", Destination: "validated.>"},
+	}
+	for _, schema := range valid {
+		if err := checkDestination(schema); err != nil {
+			t.Errorf("Expected destination %q to be valid, got %v", schema.Destination, err)
+		}
+	}
+
+	invalid := []Schema{
+		{Subject: "raw.orders", Destination: "validated.*"},
+		{Subject: "raw.*", Destination: "validated.>"},
+		{Subject: "raw.>", Destination: "validated.>.x"},
+		{Subject: "raw.*", Destination: "validated.{region}"},
+		{Subject: "raw.*", Destination: "validated..*"},
+	}
+	for _, schema := range invalid {
+		if err := checkDestination(schema); err == nil {
+			t.Errorf("Expected destination %q for subject %q to be invalid", schema.Destination, schema.Subject)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+)
+
+// ruleCostLimit bounds the work evaluating a single rule may take, so a rule
+// iterating over a huge payload can't stall validation.
+const ruleCostLimit = 1000000
+
+// Rule is a constraint payloads must satisfy that JSON Schema can't express.
+// It is either a CEL expression, such as "endDate > startDate", or an exists
+// rule looking a field up in a KV bucket. Expressions have the payload
+// available as payload, and each property the schema body declares at its
+// root as a variable of its own.
+type Rule struct {
+	Name       string      `json:"name,omitempty"`
+	Expression string      `json:"expression,omitempty"`
+	Exists     *ExistsRule `json:"exists,omitempty"`
+	// Message describes a payload breaking the rule
+	Message string `json:"message,omitempty"`
+}
+
+// describe names a rule in violations and errors.
+func (r Rule) describe() string {
+	switch {
+	case r.Name != "":
+		return fmt.Sprintf("rule %q", r.Name)
+	case r.Exists != nil:
+		return fmt.Sprintf("rule `%s exists in %s`", r.Exists.Field, r.Exists.Bucket)
+	}
+	return fmt.Sprintf("rule `%s`", r.Expression)
+}
+
+// celIdentifier matches the property names that can be declared as variables.
+var celIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// celReserved are identifiers CEL doesn't allow as variable names.
+var celReserved = map[string]bool{
+	"as": true, "break": true, "const": true, "continue": true, "else": true,
+	"false": true, "for": true, "function": true, "if": true, "import": true,
+	"in": true, "let": true, "loop": true, "package": true, "namespace": true,
+	"null": true, "return": true, "true": true, "var": true, "void": true,
+	"while": true, "payload": true,
+}
+
+// ruleEnv returns the CEL environment rules of a schema body are compiled in.
+func ruleEnv(body string) (*cel.Env, error) {
+	var doc interface{}
+	if err := json.Unmarshal([]byte(body), &doc); err != nil {
+		return nil, err
+	}
+
+	options := []cel.EnvOption{
+		cel.CrossTypeNumericComparisons(true),
+		cel.Variable("payload", cel.DynType),
+		cel.Function("sum", cel.Overload("sum_list", []*cel.Type{cel.ListType(cel.DynType)}, cel.DoubleType, cel.UnaryBinding(sumList))),
+	}
+	for _, name := range sortedKeys(asObject(asObject(doc)["properties"])) {
+		if celIdentifier.MatchString(name) && !celReserved[name] {
+			options = append(options, cel.Variable(name, cel.DynType))
+		}
+	}
+	return cel.NewEnv(options...)
+}
+
+// sumList adds up a list of numbers.
+func sumList(value ref.Val) ref.Val {
+	list, ok := value.(traits.Lister)
+	if !ok {
+		return types.MaybeNoSuchOverloadErr(value)
+	}
+
+	var total float64
+	for it := list.Iterator(); it.HasNext() == types.True; {
+		switch n := it.Next().(type) {
+		case types.Double:
+			total += float64(n)
+		case types.Int:
+			total += float64(n)
+		case types.Uint:
+			total += float64(n)
+		default:
+			return types.NewErr("sum of a non-numeric value %v", n)
+		}
+	}
+	return types.Double(total)
+}
+
+// checkRules returns an error if a rule of a schema is invalid.
+func (reg *SchemaRegistry) checkRules(schema Schema) error {
+	for _, rule := range schema.Rules {
+		if rule.Exists != nil {
+			if err := reg.checkExists(rule); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := compileRules(schema)
+	return err
+}
+
+// compileRules compiles the expressions of the rules of a schema, which must
+// be boolean. Exists rules have no program.
+func compileRules(schema Schema) ([]cel.Program, error) {
+	if len(schema.Rules) == 0 {
+		return nil, nil
+	}
+	env, err := ruleEnv(schema.Body)
+	if err != nil {
+		return nil, newError("400", err.Error())
+	}
+
+	programs := make([]cel.Program, len(schema.Rules))
+	for i, rule := range schema.Rules {
+		if (rule.Expression == "") == (rule.Exists == nil) {
+			return nil, newError("400", "%s needs either an expression or exists", rule.describe())
+		}
+		if rule.Exists != nil {
+			continue
+		}
+		ast, issues := env.Compile(rule.Expression)
+		if issues.Err() != nil {
+			return nil, newError("400", "%s doesn't compile: %v", rule.describe(), issues.Err())
+		}
+		if !ast.OutputType().IsExactType(cel.BoolType) && !ast.OutputType().IsExactType(cel.DynType) {
+			return nil, newError("400", "%s is a %s expression, not a bool", rule.describe(), ast.OutputType())
+		}
+		programs[i], err = env.Program(ast, cel.CostLimit(ruleCostLimit))
+		if err != nil {
+			return nil, newError("400", "%s: %v", rule.describe(), err)
+		}
+	}
+	return programs, nil
+}
+
+// rulePrograms returns the compiled rules of a schema. Those of stored
+// revisions never change, so they are only compiled once.
+func (reg *SchemaRegistry) rulePrograms(schema Schema) ([]cel.Program, error) {
+	if schema.Revision == 0 {
+		return compileRules(schema)
+	}
+
+	key := pinKey{name: schema.Name, revision: schema.Revision}
+	if cached, ok := reg.rules.Load(key); ok {
+		return cached.([]cel.Program), nil
+	}
+	programs, err := compileRules(schema)
+	if err != nil {
+		return nil, err
+	}
+	reg.rules.Store(key, programs)
+	return programs, nil
+}
+
+// ruleViolations evaluates the rules of a schema against a payload, and
+// returns a violation for every rule it breaks.
+func (reg *SchemaRegistry) ruleViolations(schema Schema, data []byte) ([]string, error) {
+	if len(schema.Rules) == 0 {
+		return nil, nil
+	}
+	programs, err := reg.rulePrograms(schema)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, newError("400", err.Error())
+	}
+	vars := map[string]interface{}{"payload": doc}
+	for name, value := range asObject(doc) {
+		if celIdentifier.MatchString(name) && !celReserved[name] {
+			vars[name] = value
+		}
+	}
+
+	var violations []string
+	for i, program := range programs {
+		rule := schema.Rules[i]
+		if rule.Exists != nil {
+			if violation := reg.existsViolation(rule, doc); violation != "" {
+				violations = append(violations, violation)
+			}
+			continue
+		}
+		out, _, err := program.Eval(vars)
+		if err != nil {
+			violations = append(violations, fmt.Sprintf("%s failed: %v", rule.describe(), err))
+			continue
+		}
+		if out != types.True {
+			message := rule.Message
+			if message == "" {
+				message = rule.Expression
+			}
+			violations = append(violations, fmt.Sprintf("%s: %s", rule.describe(), message))
+		}
+	}
+	return violations, nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRuleViolations(t *testing.T) {
+	reg := NewSchemaRegistry(nil, nil)
+	schema := Schema{
+		Name: "booking",
+		Body: `{"type": "object", "properties": {"startDate": {"type": "string"}, "endDate": {"type": "string"}, "total": {"type": "number"}, "items": {"type": "array"}}}`,
+		Rules: []Rule{
+			{Name: "dates", Expression: "endDate > startDate", Message: "endDate must be after startDate"},
+			{Expression: "total == sum(items.map(i, i.price))"},
+		},
+	}
+
+	valid := `{"startDate": "2024-01-01", "endDate": "2024-01-05", "total": 30, "items": [{"price": 10}, {"price": 20}]}`
+	violations, err := reg.ruleViolations(schema, []byte(valid))
+	if err != nil || len(violations) != 0 {
+		t.Errorf("Expected no violations, got %v, %v", violations, err)
+	}
+
+	invalid := `{"startDate": "2024-01-05", "endDate": "2024-01-01", "total": 25, "items": [{"price": 10}, {"price": 20}]}`
+	violations, err = reg.ruleViolations(schema, []byte(invalid))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		`rule "dates": endDate must be after startDate`,
+		"rule `total == sum(items.map(i, i.price))`: total == sum(items.map(i, i.price))",
+	}
+	if strings.Join(violations, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected %q, got %q", expected, violations)
+	}
+}
+
+func TestCompileRules(t *testing.T) {
+	body := `{"type": "object", "properties": {"total": {"type": "number"}}}`
+
+	tests := map[string]bool{
+		"total > 0":     true,
+		"payload.total": true,
+		"total +":       false,
+		"total + 1":     false,
+		"'total'":       false,
+		"unknown > 0":   false,
+	}
+	for expression, ok := range tests {
+		_, err := compileRules(Schema{Body: body, Rules: []Rule{{Expression: expression}}})
+		if ok && err != nil {
+			t.Errorf("Expected %q to compile, got %v", expression, err)
+		}
+		if !ok && err == nil {
+			t.Errorf("Expected %q not to compile", expression)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/micro"
+)
+
+// Bounds of the number of messages sampled from a stream.
+const (
+	defaultSampleCount = 100
+	maxSampleCount     = 10000
+)
+
+// SampleRequest picks the messages of a stream a schema is inferred from.
+type SampleRequest struct {
+	Stream string `json:"stream"`
+	// Filter only samples messages on matching subjects of the stream.
+	Filter string `json:"filter,omitempty"`
+	// Count is the number of messages sampled, the most recent ones.
+	Count int `json:"count,omitempty"`
+	// Register registers the inferred schema as a draft.
+	Register bool `json:"register,omitempty"`
+	// Subject is the subject of the registered draft. It defaults to the
+	// filter, or the subject of a stream with just one.
+	Subject string `json:"subject,omitempty"`
+}
+
+// SampleResult is a schema inferred from the messages of a stream.
+type SampleResult struct {
+	Sampled int `json:"sampled"`
+	// Skipped counts the sampled messages that weren't JSON.
+	Skipped int    `json:"skipped,omitempty"`
+	Body    string `json:"body"`
+	// Schema is the registered draft, if any.
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+// Sample subject: $SCHEMA.SAMPLE.<schema_name>
+func (reg *SchemaRegistry) Sample(r micro.Request) {
+	var req SampleRequest
+	err := json.Unmarshal(r.Data(), &req)
+	if err != nil {
+		r.Error("400", err.Error(), nil)
+		return
+	}
+	if req.Stream == "" {
+		r.Error("400", "missing stream", nil)
+		return
+	}
+
+	name := subjectName(r.Subject())
+
+	if req.Register {
+		if !reg.authorize(r, name) {
+			return
+		}
+		if reg.requireApproval {
+			r.Error(errorCode(errApprovalRequired), errApprovalRequired.Error(), nil)
+			return
+		}
+	}
+
+	result, err := reg.sample(name, req, RequestIdentity(nats.Header(r.Headers())))
+	if err != nil {
+		r.Error(errorCode(err), err.Error(), nil)
+		return
+	}
+	r.RespondJSON(result)
+}
+
+// sample infers a schema from the most recent messages of a stream, and
+// registers it as a draft of the named schema if asked to.
+func (reg *SchemaRegistry) sample(name string, req SampleRequest, by string) (SampleResult, error) {
+	var result SampleResult
+	if req.Count <= 0 {
+		req.Count = defaultSampleCount
+	}
+	if req.Count > maxSampleCount {
+		return result, newError("400", "can't sample more than %d messages", maxSampleCount)
+	}
+
+	if req.Register && req.Subject == "" {
+		req.Subject = req.Filter
+	}
+	if req.Register && req.Subject == "" {
+		subjects, err := reg.streamSubjects(req.Stream)
+		if err != nil {
+			return result, err
+		}
+		if len(subjects) != 1 {
+			return result, newError("400", "stream %q has several subjects, pick the subject of the schema", req.Stream)
+		}
+		req.Subject = subjects[0]
+	}
+
+	msgs, err := reg.recentMessages(req.Stream, req.Filter, req.Count)
+	if err != nil {
+		return result, err
+	}
+	if len(msgs) == 0 {
+		return result, newError("404", "no messages to sample in stream %q", req.Stream)
+	}
+
+	var payloads []json.RawMessage
+	for _, msg := range msgs {
+		if json.Valid(msg.Data) {
+			payloads = append(payloads, msg.Data)
+		} else {
+			result.Skipped++
+		}
+	}
+	result.Sampled = len(msgs)
+	if len(payloads) == 0 {
+		return result, newError("400", "none of the %d sampled messages are JSON", len(msgs))
+	}
+
+	result.Body, err = InferSchema(payloads)
+	if err != nil {
+		return result, err
+	}
+	if !req.Register {
+		return result, nil
+	}
+
+	schema, err := reg.register(Schema{
+		Name:      name,
+		Subject:   req.Subject,
+		Type:      "jsonschema",
+		Body:      result.Body,
+		State:     StateDraft,
+		UpdatedBy: by,
+	})
+	if err != nil {
+		return result, err
+	}
+	result.Schema = &schema
+	return result, nil
+}
+
+// streamSubjects returns the subjects a stream is configured with.
+func (reg *SchemaRegistry) streamSubjects(stream string) ([]string, error) {
+	info, err := reg.js.StreamInfo(stream)
+	if errors.Is(err, nats.ErrStreamNotFound) {
+		return nil, newError("404", "stream %q not found", stream)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return info.Config.Subjects, nil
+}
+
+// recentMessages returns up to count of the most recent messages of a stream,
+// oldest first, optionally only those on subjects matching filter.
+func (reg *SchemaRegistry) recentMessages(stream, filter string, count int) ([]*nats.Msg, error) {
+	info, err := reg.js.StreamInfo(stream, &nats.StreamInfoRequest{SubjectsFilter: filter})
+	if errors.Is(err, nats.ErrStreamNotFound) {
+		return nil, newError("404", "stream %q not found", stream)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if info.State.Msgs == 0 || (filter != "" && len(info.State.Subjects) == 0) {
+		return nil, nil
+	}
+
+	// Without a filter the most recent messages are known by sequence,
+	// otherwise every matching message is read to keep the last ones
+	start := nats.DeliverAll()
+	if filter == "" && info.State.LastSeq-info.State.FirstSeq >= uint64(count) {
+		start = nats.StartSequence(info.State.LastSeq - uint64(count) + 1)
+	}
+	sub, err := reg.js.SubscribeSync(filter, nats.OrderedConsumer(), nats.BindStream(stream), start)
+	if err != nil {
+		return nil, err
+	}
+	defer sub.Unsubscribe()
+
+	var msgs []*nats.Msg
+	for {
+		msg, err := sub.NextMsg(2 * time.Second)
+		if err != nil {
+			return nil, err
+		}
+		meta, err := msg.Metadata()
+		if err != nil {
+			return nil, err
+		}
+
+		if len(msgs) == count {
+			msgs = msgs[1:]
+		}
+		msgs = append(msgs, msg)
+
+		if meta.NumPending == 0 {
+			return msgs, nil
+		}
+	}
+}
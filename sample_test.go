@@ -0,0 +1,11 @@
+package main
+
+import "testing"
+
+func TestSampleCount(t *testing.T) {
+	reg := NewSchemaRegistry(nil, nil)
+	_, err := reg.sample("events", SampleRequest{Stream: "EVENTS", Count: maxSampleCount + 1}, "")
+	if errorCode(err) != "400" {
+		t.Errorf("Expected sampling more than %d messages to fail with 400, got %v", maxSampleCount, err)
+	}
+}
@@ -3,47 +3,255 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/nats-io/nats.go"
 	"github.com/nats-io/nats.go/micro"
 	"github.com/xeipuuv/gojsonschema"
 )
 
+// DefaultPrefix is the subject prefix the registry is served under.
+const DefaultPrefix = "$SCHEMA"
+
 type Schema struct {
 	Name     string `json:"name"`
 	Subject  string `json:"subject"`
 	Revision uint64 `json:"revision,omitempty"`
 	Type     string `json:"type"`
 	Body     string `json:"body"`
+	State    string `json:"state,omitempty"`
+	Version  uint64 `json:"version,omitempty"`
+	// Source is the .proto file of protobuf schemas, and Message the message
+	// in it the body is derived from.
+	Source  string `json:"source,omitempty"`
+	Message string `json:"message,omitempty"`
+	// SemVer is an optional semantic version such as 2.1.0, stating the
+	// compatibility intent of a revision. It must increase with every update.
+	SemVer string `json:"semver,omitempty"`
+	// Tags are free form labels schemas can be searched by, such as the team
+	// owning them.
+	Tags []string `json:"tags,omitempty"`
+	// Annotations are forwarded as Schema-Annotation-<name> headers with
+	// every validated message, for downstream policies such as
+	// Classification: confidential or Retention: 90d.
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// Parameters constrains the {name} tokens of a subject template such as
+	// orders.{region}.{event}. Their values are forwarded as headers.
+	Parameters map[string]Parameter `json:"parameters,omitempty"`
+
+	// ResponseBody optionally describes the replies to requests on Subject.
+	ResponseBody string `json:"response_body,omitempty"`
+	// HeaderBody optionally describes the message headers as an object of
+	// header names to values.
+	HeaderBody string `json:"header_body,omitempty"`
+	// Destination routes validated messages to a different subject, such as
+	// validated.orders.* for a subject of raw.orders.*.
+	Destination string `json:"destination,omitempty"`
+	// JetStream forwards validated messages with the JetStream publish API
+	// and relays the stream's PubAck to the publisher.
+	JetStream bool `json:"jetstream,omitempty"`
+
+	// Signature is the base64 encoded Ed25519 signature of Body, required
+	// when the schema's namespace has signing keys configured.
+	Signature string `json:"signature,omitempty"`
+
+	// Fingerprint is the SHA-256 hash of the canonical body.
+	Fingerprint string `json:"fingerprint,omitempty"`
+
+	// ID is a globally unique number identifying this version of the schema,
+	// for wire formats that reference schemas compactly.
+	ID uint32 `json:"id,omitempty"`
+
+	// Promotion records the environment this revision was promoted from.
+	Promotion *Promotion `json:"promotion,omitempty"`
+	// Owner is the team owning the schema, whose authorization rules apply to
+	// it. Transfer records the owner this revision was transferred from.
+	Owner    string    `json:"owner,omitempty"`
+	Transfer *Transfer `json:"transfer,omitempty"`
+	// CreatedBy identifies who registered the schema, UpdatedBy who made this
+	// revision, as the NATS server reported them.
+	CreatedBy string `json:"created_by,omitempty"`
+	UpdatedBy string `json:"updated_by,omitempty"`
+	// RequestID is the request ID of the request that made this revision.
+	RequestID string `json:"request_id,omitempty"`
+
+	// Expiry optionally deprecates or disables the schema at a point in time.
+	Expiry *Expiry `json:"expiry,omitempty"`
+	// Canary optionally keeps validating payloads against the previous
+	// revision after an update, until this one agrees with it.
+	Canary *Canary `json:"canary,omitempty"`
+	// Compare names another schema payloads are also validated against,
+	// reporting where the verdicts differ, to migrate between two ways of
+	// authoring a contract such as a handwritten and a generated schema.
+	Compare string `json:"compare,omitempty"`
+
+	// Examples are named payloads every revision must accept.
+	Examples map[string]json.RawMessage `json:"examples,omitempty"`
+	// Rules are CEL expressions payloads must satisfy besides the body.
+	Rules []Rule `json:"rules,omitempty"`
+	// Plugin optionally validates payloads further, with a WebAssembly module.
+	Plugin *Plugin `json:"plugin,omitempty"`
+	// Redaction optionally republishes validated messages without the
+	// properties the body marks as PII.
+	Redaction *Redaction `json:"redaction,omitempty"`
+	// EncryptionKey names the key the properties the body marks for
+	// encryption are encrypted with before messages are forwarded.
+	EncryptionKey string `json:"encryption_key,omitempty"`
+	// Strict rejects payloads with properties the body doesn't declare, as if
+	// it set additionalProperties to false. When unset the registry's
+	// --strict applies.
+	Strict *bool `json:"strict,omitempty"`
+	// Batch says the payloads are arrays of items the body describes, which
+	// are validated one by one.
+	Batch bool `json:"batch,omitempty"`
+
+	// Warnings lists the problems a change was accepted with. They are only
+	// returned in the response, never stored.
+	Warnings []string `json:"warnings,omitempty"`
+	// LastUsed is when a payload was last validated against the schema. It's
+	// only returned by GET and LIST, never stored.
+	LastUsed *time.Time `json:"last_used,omitempty"`
+	// Updated is when the current revision was stored. It's only returned,
+	// never stored.
+	Updated *time.Time `json:"updated,omitempty"`
 }
 
 type SchemaRegistry struct {
 	// Contain nats kv and have methods for schema crud and validation
 	kv nats.KeyValue
 	nc *nats.Conn
+	js nats.JetStreamContext
 
 	schemas   map[string]Schema
 	schemasMu sync.RWMutex
+	// healthy is true while the watcher keeps the cache up to date
+	healthy atomic.Bool
+	// resync asks the watcher to restart and reconcile the cache
+	resync chan struct{}
+
+	// auth gates the mutating endpoints, nil allows everything
+	auth *Authorizer
+	// approvers may approve and reject proposals, nil allows everyone
+	approvers *Authorizer
+	// requireApproval only accepts changes through approved proposals
+	requireApproval bool
+	// verifier checks schema signatures, nil accepts unsigned schemas
+	verifier *Verifier
+	// webhooks are notified of schema changes, nil notifies nobody
+	webhooks *Webhooks
+	// opts are the options that are reloaded while serving, see settings
+	opts atomic.Pointer[settings]
+	// immutable stores every version of a schema under its own key instead of
+	// relying on the kv history of the head
+	immutable bool
+	// schemaTTL unregisters schemas that haven't been updated for this long
+	schemaTTL time.Duration
+	// archive is the name of the stream mirroring every schema mutation
+	archive string
+	// responseTimeout bounds how long a proxied request waits for its reply
+	responseTimeout time.Duration
+	// failures keeps samples of recently rejected payloads
+	failures *FailureLog
+	// deadLetter is the name of the stream rejected messages are stored in
+	deadLetter string
+	// forwardRetries is how often publishing a validated message is retried,
+	// waiting forwardBackoff and doubling it in between
+	forwardRetries int
+	forwardBackoff time.Duration
+	// forwarded counts the retries and failures of forwarding
+	forwarded forwardCounters
+	// stats counts validations per schema
+	stats *ValidationStats
+	// search indexes the cached schemas for SEARCH
+	search *SearchIndex
+	// deprecations counts the deprecated fields validated messages have
+	deprecations *DeprecationLog
+	// canaries compares the verdicts of revisions in canary with those of
+	// the revisions before them
+	canaries *CanaryLog
+	// comparisons records where the verdicts of schemas and the schemas they
+	// are compared with differ
+	comparisons *ComparisonLog
+	// validations counts the requests of the raw validation subscription
+	validations *EndpointStats
+	// validationTimeout bounds how long validating a message may take
+	validationTimeout time.Duration
+	// overdue counts the validations finishing in the background after
+	// timing out, up to maxOverdue
+	overdue    atomic.Int64
+	maxOverdue int64
+	// results caches the verdicts of recent payload validations
+	results *ResultCache
+	// revisions caches the stored revisions validation requests are pinned to
+	revisions sync.Map
+	// rules caches the compiled rules of schema revisions
+	rules sync.Map
+	// referenceBuckets are the KV buckets exists rules may look keys up in
+	referenceBuckets []string
+	// keys caches the lookups of exists rules
+	keys *KeyCache
+	// usage shares when schemas were last used with the other instances
+	usage *UsageLog
+	// elector elects the instance running singleton jobs, nil runs them on
+	// every instance
+	elector *Elector
+	// plugins runs the WebAssembly plugins of schemas, nil runs none
+	plugins *Plugins
+	// keyring encrypts the annotated fields of forwarded messages, nil
+	// encrypts nothing
+	keyring *Keyring
+	// decrypters may decrypt payloads, nil allows no one
+	decrypters *Authorizer
+	// objectBuckets are the Object Store buckets payloads may be referenced in
+	objectBuckets []string
+	// readOnly rejects every change to the registered schemas, for mirrors
+	readOnly bool
+	// prefix is the subject prefix the registry is served under
+	prefix string
+	// validatePrefix is the subject prefix validation requests are received
+	// under, <prefix>.VALIDATE when empty
+	validatePrefix string
 }
 
 func NewSchemaRegistry(kv nats.KeyValue, nc *nats.Conn) *SchemaRegistry {
-	return &SchemaRegistry{
+	reg := &SchemaRegistry{
 		nc:      nc,
 		kv:      kv,
 		schemas: map[string]Schema{},
+		resync:  make(chan struct{}, 1),
+		stats:   NewValidationStats(),
+		search:  NewSearchIndex(),
+
+		deprecations: NewDeprecationLog(),
+		canaries:     NewCanaryLog(),
+		comparisons:  NewComparisonLog(),
+
+		validations: &EndpointStats{},
+		prefix:      DefaultPrefix,
+
+		responseTimeout: 5 * time.Second,
+		forwardBackoff:  100 * time.Millisecond,
+		maxOverdue:      maxOverdueValidations,
 	}
+	reg.opts.Store(defaultSettings())
+	return reg
 }
 
 // Watch watches the kv store for changes and adds them to a
 // local cache of schemas. It runs this in a goroutine and takes a context for
-// cancelation.
+// cancelation. If the watch dies, for example because the server restarted, it
+// is re-established and the cache reconciled with the bucket.
 func (reg *SchemaRegistry) Watch(c context.Context) error {
-	// Watch the kv store for changes
-	watcher, err := reg.kv.WatchAll()
+	// Watch the kv store for changes to the schema heads
+	watcher, err := reg.kv.Watch("*")
 	if err != nil {
 		return err
 	}
@@ -51,35 +259,151 @@ func (reg *SchemaRegistry) Watch(c context.Context) error {
 	// Run this in a goroutine
 	go func() {
 		for {
-			select {
-			case <-c.Done():
+			reg.consume(c, watcher)
+			watcher.Stop()
+			reg.healthy.Store(false)
+
+			if c.Err() != nil {
 				return
-			case entry, ok := <-watcher.Updates():
-				if !ok {
-					return
-				}
-				if entry == nil {
-					log.Println("Loaded initial schemas")
-					continue
-				}
+			}
+			logger("watcher").Warn("Schema watcher stopped, restarting")
+
+			watcher = reg.rewatch(c)
+			if watcher == nil {
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+// consume applies watcher updates to the cache until the watcher dies or the
+// context is canceled. The entries a watch starts with are collected and then
+// replace the cache as a whole, so schemas deleted while a previous watch was
+// down don't linger.
+func (reg *SchemaRegistry) consume(c context.Context, watcher nats.KeyWatcher) {
+	initial := map[string]Schema{}
+	loading := true
 
-				var schema Schema
-				err := json.Unmarshal(entry.Value(), &schema)
-				if err != nil {
-					log.Printf("error unmarshaling schema: %v", err)
-					continue
+	for {
+		select {
+		case <-c.Done():
+			return
+		case <-reg.resync:
+			return
+		case entry, ok := <-watcher.Updates():
+			if !ok {
+				return
+			}
+			if entry == nil {
+				reg.schemasMu.Lock()
+				reg.schemas = initial
+				reg.schemasMu.Unlock()
+				reg.search.Replace(initial)
+				loading = false
+				reg.healthy.Store(true)
+				logger("watcher").Info("Loaded initial schemas", "schemas", len(initial))
+				continue
+			}
+
+			if entry.Operation() != nats.KeyValuePut {
+				if !loading {
+					reg.schemasMu.Lock()
+					delete(reg.schemas, tokenName(entry.Key()))
+					reg.schemasMu.Unlock()
+					reg.search.Remove(tokenName(entry.Key()))
+					logger("watcher").Info("Removed schema", "schema", tokenName(entry.Key()))
 				}
-				schema.Revision = entry.Revision()
+				continue
+			}
 
+			var schema Schema
+			err := json.Unmarshal(entry.Value(), &schema)
+			if err != nil {
+				logger("watcher").Error("error unmarshaling schema", "key", entry.Key(), "error", err)
+				continue
+			}
+			schema.Revision = entry.Revision()
+			updated := entry.Created()
+			schema.Updated = &updated
+			if schema.State == "" {
+				schema.State = StateActive
+			}
+
+			if loading {
+				initial[schema.Name] = schema
+			} else {
 				reg.schemasMu.Lock()
 				reg.schemas[schema.Name] = schema
 				reg.schemasMu.Unlock()
-				log.Printf("Loaded schema: %q revision %d", schema.Name, schema.Revision)
+				reg.search.Put(schema)
 			}
+			logger("watcher").Info("Loaded schema", "schema", schema.Name, "revision", schema.Revision)
 		}
-	}()
+	}
+}
 
-	return nil
+// rewatch re-establishes the watch, backing off between attempts. It returns
+// nil if the context is canceled first.
+func (reg *SchemaRegistry) rewatch(c context.Context) nats.KeyWatcher {
+	backoff := 100 * time.Millisecond
+	for {
+		select {
+		case <-c.Done():
+			return nil
+		case <-time.After(backoff):
+		}
+
+		watcher, err := reg.kv.Watch("*")
+		if err == nil {
+			return watcher
+		}
+		logger("watcher").Error("error restarting schema watcher", "error", err)
+
+		backoff *= 2
+		if backoff > 30*time.Second {
+			backoff = 30 * time.Second
+		}
+	}
+}
+
+// Resync restarts the watch and reconciles the cache with the bucket. Call it
+// when the watch may have silently stopped, for example after reconnecting to
+// a restarted server.
+func (reg *SchemaRegistry) Resync() {
+	select {
+	case reg.resync <- struct{}{}:
+	default:
+	}
+}
+
+// Healthy returns true while the schema cache is being kept up to date.
+func (reg *SchemaRegistry) Healthy() bool {
+	return reg.healthy.Load()
+}
+
+// Health subject: $SCHEMA.HEALTH
+func (reg *SchemaRegistry) Health(r micro.Request) {
+	reg.schemasMu.RLock()
+	count := len(reg.schemas)
+	reg.schemasMu.RUnlock()
+
+	// Validation goes on during maintenance, so it doesn't make the registry
+	// unhealthy
+	maintenance, _ := reg.maintenance()
+	health := struct {
+		Healthy     bool `json:"healthy"`
+		Schemas     int  `json:"schemas"`
+		Maintenance bool `json:"maintenance,omitempty"`
+	}{reg.Healthy(), count, maintenance.Enabled}
+
+	if !health.Healthy {
+		data, _ := json.Marshal(health)
+		r.Error("503", "schema cache is stale, watcher is restarting", data)
+		return
+	}
+	r.RespondJSON(health)
 }
 
 // Register subject: $SCHEMA.REGISTER.<schema_name>
@@ -92,53 +416,211 @@ func (reg *SchemaRegistry) RegisterSchema(r micro.Request) {
 	}
 
 	// Pull out the schema name from the subject
-	parts := strings.Split(r.Subject(), ".")
-	schema.Name = parts[len(parts)-1]
+	schema.Name = subjectName(r.Subject())
 
-	// Put the schema in the kv store
-	data, err := json.Marshal(schema)
-	if err != nil {
-		r.Error("400", err.Error(), nil)
+	if !reg.authorize(r, schema.Name) {
+		return
+	}
+	if reg.requireApproval {
+		r.Error(errorCode(errApprovalRequired), errApprovalRequired.Error(), nil)
 		return
 	}
 
-	rev, err := reg.kv.Create(schema.Name, data)
+	schema.UpdatedBy = RequestIdentity(nats.Header(r.Headers()))
+	schema.RequestID = RequestID(r)
+	schema, err = reg.register(schema)
 	if err != nil {
-		r.Error("500", err.Error(), nil)
+		r.Error(errorCode(err), err.Error(), nil)
 		return
 	}
 
-	schema.Revision = rev
 	r.RespondJSON(schema)
 }
 
+// register stores a schema that does not exist yet.
+func (reg *SchemaRegistry) register(schema Schema) (Schema, error) {
+	return reg.registerAssigned(schema, nil)
+}
+
+// registerAssigned registers a schema, letting assign set the fields only the
+// registry assigns. See assignOrigin.
+func (reg *SchemaRegistry) registerAssigned(schema Schema, assign func(*Schema)) (Schema, error) {
+	if err := reg.writable(); err != nil {
+		return schema, err
+	}
+	if err := deriveBody(&schema); err != nil {
+		return schema, err
+	}
+	if err := reg.verifier.Verify(schema); err != nil {
+		return schema, newError("403", err.Error())
+	}
+
+	if schema.State == "" {
+		schema.State = StateActive
+	}
+	schema.CreatedBy = schema.UpdatedBy
+	schema.LastUsed = nil
+	schema.Updated = nil
+	assignOrigin(&schema, Schema{}, assign)
+	if !ValidState(schema.State) {
+		return schema, newError("400", "invalid state %q", schema.State)
+	}
+	if err := checkExpiry(&schema); err != nil {
+		return schema, err
+	}
+	if err := checkCanary(&schema, Schema{}, false); err != nil {
+		return schema, err
+	}
+	if err := reg.checkDefinition(&schema); err != nil {
+		return schema, err
+	}
+	if err := checkSemVer(Schema{}, false, schema); err != nil {
+		return schema, err
+	}
+	if err := reg.settings().quotas.CheckSchemas(schema.Name, reg.countSchemas); err != nil {
+		return schema, err
+	}
+	warnings, err := reg.checkExamples(schema)
+	if err != nil {
+		return schema, err
+	}
+
+	schema.Fingerprint = Fingerprint(schema.Body)
+	schema.Version = 1
+	if reg.immutable {
+		last, err := reg.lastVersion(schema.Name)
+		if err != nil {
+			return schema, err
+		}
+		schema.Version = last + 1
+	}
+
+	id, err := reg.nextID()
+	if err != nil {
+		return schema, err
+	}
+	schema.ID = id
+
+	// Put the schema in the kv store
+	data, err := json.Marshal(schema)
+	if err != nil {
+		return schema, newError("400", err.Error())
+	}
+
+	rev, err := reg.kv.Create(nameToken(schema.Name), data)
+	if errors.Is(err, nats.ErrKeyExists) {
+		return schema, newError("409", "schema %q already exists", schema.Name)
+	}
+	if err != nil {
+		return schema, err
+	}
+	schema.Revision = rev
+	schema.Warnings = warnings
+
+	if reg.immutable {
+		err = reg.storeVersion(schema)
+		if err != nil {
+			return schema, err
+		}
+	}
+
+	reg.index(schema)
+
+	reg.publishEvent(EventPut, schema.Name, &schema)
+	return schema, nil
+}
+
 // Register subject: $SCHEMA.UNREGISTER.<schema_name>
 func (reg *SchemaRegistry) UnregisterSchema(r micro.Request) {
-	parts := strings.Split(r.Subject(), ".")
-	name := parts[len(parts)-1]
+	name := subjectName(r.Subject())
+
+	if !reg.authorize(r, name) {
+		return
+	}
+
+	confirmation, err := reg.confirmUnregister(name, r.Headers().Get(SchemaConfirmHeader))
+	if err != nil {
+		var data []byte
+		if confirmation != nil {
+			data, _ = json.Marshal(confirmation)
+		}
+		r.Error(errorCode(err), err.Error(), data)
+		return
+	}
 
-	// remove the schema from the kv store
-	err := reg.kv.Delete(name)
+	err = reg.unregister(name)
 	if err != nil {
-		r.Error("500", err.Error(), nil)
+		r.Error(errorCode(err), err.Error(), nil)
 		return
 	}
 
 	r.Respond(nil)
 }
 
+// unregister removes a schema from the kv store. Its history is kept. Schemas
+// still referenced by others can't be removed.
+func (reg *SchemaRegistry) unregister(name string) error {
+	if err := reg.checkUnregister(name); err != nil {
+		return err
+	}
+
+	err := reg.kv.Delete(nameToken(name))
+	if err != nil {
+		return err
+	}
+
+	reg.publishEvent(EventDelete, name, nil)
+	return nil
+}
+
+// checkUnregister returns an error if the named schema can't be unregistered
+// at all, whether or not it's confirmed.
+func (reg *SchemaRegistry) checkUnregister(name string) error {
+	if err := reg.writable(); err != nil {
+		return err
+	}
+	if err := reg.checkLock(name); err != nil {
+		return err
+	}
+	if dependents := reg.dependents(name); len(dependents) > 0 {
+		return newError("409", "schema %q is still referenced by %s", name, strings.Join(dependentNames(dependents), ", "))
+	}
+	return nil
+}
+
 // Get subject: $SCHEMA.GET.<schema_name>
 func (reg *SchemaRegistry) GetSchema(r micro.Request) {
-	parts := strings.Split(r.Subject(), ".")
-	name := parts[len(parts)-1]
+	name := subjectName(r.Subject())
 
-	// Get the schema from the kv store
-	schema, ok := reg.schemas[name]
-	if !ok {
-		r.Error("404", "Not found", nil)
-		return
+	var schema Schema
+	if version := r.Headers().Get(SchemaVersionHeader); version != "" {
+		var err error
+		schema, err = reg.loadVersion(name, version)
+		if err != nil {
+			r.Error("404", err.Error(), nil)
+			return
+		}
+	} else {
+		// Get the schema from the kv store
+		var ok bool
+		reg.schemasMu.RLock()
+		schema, ok = reg.schemas[name]
+		reg.schemasMu.RUnlock()
+		if !ok {
+			r.Error("404", "Not found", nil)
+			return
+		}
 	}
-	r.RespondJSON(schema)
+
+	if r.Headers().Get(SchemaBundleHeader) == "true" {
+		body, err := Bundle(schema.Body, reg.lookupBody)
+		if err != nil {
+			r.Error(errorCode(err), err.Error(), nil)
+			return
+		}
+		schema.Body = body
+	}
+	r.RespondJSON(reg.withUsage(schema))
 }
 
 // Update subject: $SCHEMA.UPDATE.<schema_name>
@@ -151,95 +633,762 @@ func (reg *SchemaRegistry) UpdateSchema(r micro.Request) {
 	}
 
 	// Pull out the schema name from the subject
-	parts := strings.Split(r.Subject(), ".")
-	schema.Name = parts[len(parts)-1]
+	schema.Name = subjectName(r.Subject())
 
-	// Put the schema in the kv store
-	data, err := json.Marshal(schema)
-	if err != nil {
-		r.Error("400", err.Error(), nil)
+	if !reg.authorize(r, schema.Name) {
+		return
+	}
+	if reg.requireApproval {
+		r.Error(errorCode(errApprovalRequired), errApprovalRequired.Error(), nil)
 		return
 	}
 
-	rev, err := reg.kv.Put(schema.Name, data)
+	schema.UpdatedBy = RequestIdentity(nats.Header(r.Headers()))
+	schema.RequestID = RequestID(r)
+	schema, err = reg.update(schema)
 	if err != nil {
-		r.Error("500", err.Error(), nil)
+		r.Error(errorCode(err), err.Error(), nil)
 		return
 	}
 
-	schema.Revision = rev
 	r.RespondJSON(schema)
 }
 
-// Validate subject: $SCHEMA.VALIDATE.<subject>
-func (reg *SchemaRegistry) ValidatePayload(m *nats.Msg) {
-	reg.schemasMu.RLock()
-	defer reg.schemasMu.RUnlock()
+// checkDefinition runs the checks every stored schema goes through, whether
+// it's registered, updated or restored: its limits, bodies, rules, plugin,
+// parameters and the settings referring to other schemas.
+func (reg *SchemaRegistry) checkDefinition(schema *Schema) error {
+	if err := reg.settings().Limits.CheckSchema(*schema); err != nil {
+		return err
+	}
+	if err := reg.compileBody(schema.Body); err != nil {
+		return err
+	}
+	if err := reg.checkRules(*schema); err != nil {
+		return err
+	}
+	if err := reg.resolvePlugin(schema); err != nil {
+		return err
+	}
+	if schema.ResponseBody != "" {
+		if err := CompileSchema(schema.ResponseBody); err != nil {
+			return newError("400", "invalid response schema: %v", err)
+		}
+	}
+	if schema.HeaderBody != "" {
+		if err := CompileSchema(schema.HeaderBody); err != nil {
+			return newError("400", "invalid header schema: %v", err)
+		}
+	}
+	if err := checkParameters(*schema); err != nil {
+		return newError("400", err.Error())
+	}
+	if err := checkAnnotations(*schema); err != nil {
+		return newError("400", err.Error())
+	}
+	if err := checkSunsets(schema.Body); err != nil {
+		return newError("400", err.Error())
+	}
+	if err := checkDestination(*schema); err != nil {
+		return newError("400", err.Error())
+	}
+	if err := reg.checkCompare(*schema); err != nil {
+		return err
+	}
+	if err := reg.checkRedaction(*schema); err != nil {
+		return err
+	}
+	if err := reg.checkEncryption(*schema); err != nil {
+		return err
+	}
+	if err := checkComponent(*schema); err != nil {
+		return err
+	}
+	return nil
+}
 
-	// Pull out the subject from the request subject
-	parts := strings.Split(m.Subject, ".")
-	subject := strings.Join(parts[2:], ".")
+// assignOrigin sets the fields of a revision that are never taken from the
+// schema being stored: the owner is kept from the current revision, and the
+// transfer and promotion are cleared. Only transfer and promote set them,
+// through assign.
+func assignOrigin(schema *Schema, current Schema, assign func(*Schema)) {
+	schema.Owner = current.Owner
+	schema.Transfer = nil
+	schema.Promotion = nil
+	if assign != nil {
+		assign(schema)
+	}
+}
 
-	// find a schema that matches the subject
-	for _, schema := range reg.schemas {
-		if !SubjectsMatch(subject, schema.Subject) {
-			continue
+// update stores a new revision of a schema, creating it if it does not exist
+// yet. Lifecycle transitions are enforced against the current revision.
+func (reg *SchemaRegistry) update(schema Schema) (Schema, error) {
+	return reg.updatePending(schema, nil)
+}
+
+// updatePending updates a schema as part of a transaction, checking its
+// dependents against the pending bodies of the other schemas of the
+// transaction.
+func (reg *SchemaRegistry) updatePending(schema Schema, pending map[string]string) (Schema, error) {
+	return reg.updateAssigned(schema, pending, nil)
+}
+
+// updateAssigned updates a schema, letting assign set the fields only the
+// registry assigns. See assignOrigin.
+func (reg *SchemaRegistry) updateAssigned(schema Schema, pending map[string]string, assign func(*Schema)) (Schema, error) {
+	if err := reg.writable(); err != nil {
+		return schema, err
+	}
+	if err := deriveBody(&schema); err != nil {
+		return schema, err
+	}
+	if err := reg.verifier.Verify(schema); err != nil {
+		return schema, newError("403", err.Error())
+	}
+
+	current, exists, err := reg.current(schema.Name)
+	if err != nil {
+		return schema, err
+	}
+	if exists {
+		if err := reg.checkLock(schema.Name); err != nil {
+			return schema, err
+		}
+	}
+
+	if schema.State == "" {
+		schema.State = StateActive
+		if exists {
+			schema.State = current.State
 		}
+	}
+	// Examples, tags and annotations are kept until they are replaced
+	if schema.Examples == nil && exists {
+		schema.Examples = current.Examples
+	}
+	if schema.Tags == nil && exists {
+		schema.Tags = current.Tags
+	}
+	if schema.Annotations == nil && exists {
+		schema.Annotations = current.Annotations
+	}
+	schema.CreatedBy = schema.UpdatedBy
+	schema.LastUsed = nil
+	schema.Updated = nil
+	if exists {
+		schema.CreatedBy = current.CreatedBy
+	}
+	assignOrigin(&schema, current, assign)
+	if !ValidState(schema.State) {
+		return schema, newError("400", "invalid state %q", schema.State)
+	}
+	if exists && !CanTransition(current.State, schema.State) {
+		return schema, newError("409", "cannot transition schema %q from %s to %s", schema.Name, current.State, schema.State)
+	}
+	if err := checkExpiry(&schema); err != nil {
+		return schema, err
+	}
+	if err := checkCanary(&schema, current, exists); err != nil {
+		return schema, err
+	}
+	if err := reg.checkDefinition(&schema); err != nil {
+		return schema, err
+	}
 
-		// validate the payload
-		err := reg.validate(m.Data, schema)
+	if exists && reg.settings().Compatibility == CompatibilityBackward {
+		changes, err := DiffSchemas(current.Body, schema.Body)
 		if err != nil {
-			m.Respond([]byte(err.Error()))
-			return
+			return schema, newError("400", err.Error())
+		}
+		consumers, err := reg.consumers(schema.Name)
+		if err != nil {
+			return schema, err
+		}
+		breaking, impact := BreakingChanges(changes), consumerImpact(consumers, changes)
+		switch {
+		case len(breaking) > 0 && len(impact) > 0:
+			return schema, newError("409", "incompatible with revision %d: %s, breaking consumers %s", current.Revision, describeChanges(breaking), describeImpact(impact))
+		case len(breaking) > 0:
+			return schema, newError("409", "incompatible with revision %d: %s", current.Revision, describeChanges(breaking))
+		case len(impact) > 0:
+			return schema, newError("409", "incompatible with the consumers of revision %d: %s", current.Revision, describeImpact(impact))
+		}
+	}
+	if err := checkSemVer(current, exists, schema); err != nil {
+		return schema, err
+	}
+	if !exists {
+		if err := reg.settings().quotas.CheckSchemas(schema.Name, reg.countSchemas); err != nil {
+			return schema, err
 		}
+	}
+	if err := reg.checkDependents(schema, pending); err != nil {
+		return schema, err
+	}
+	warnings, err := reg.checkExamples(schema)
+	if err != nil {
+		return schema, err
+	}
+
+	schema.Fingerprint = Fingerprint(schema.Body)
+	schema.Version = 1
+	if exists {
+		schema.Version = current.Version + 1
+	}
 
-		msg := nats.NewMsg(subject)
-		msg.Reply = m.Reply
-		msg.Data = m.Data
-		msg.Header = m.Header
-		if msg.Header == nil {
-			msg.Header = nats.Header{}
+	id, err := reg.nextID()
+	if err != nil {
+		return schema, err
+	}
+	schema.ID = id
+
+	// Store the new version before moving the head to it, so concurrent
+	// updates can't both claim the same version
+	if reg.immutable {
+		err = reg.storeVersion(schema)
+		if err != nil {
+			return schema, newError("409", err.Error())
 		}
-		msg.Header.Set("Schema-Name", schema.Name)
-		msg.Header.Set("Schema-Revision", fmt.Sprintf("%d", schema.Revision))
-		msg.Header.Set("Schema-Subject", schema.Subject)
-		msg.Header.Set("Schema-Type", schema.Type)
-		msg.Header.Set("Schema-Validated", "true")
-		err = reg.nc.PublishMsg(msg)
+	}
+
+	// Put the schema in the kv store
+	data, err := json.Marshal(schema)
+	if err != nil {
+		return schema, newError("400", err.Error())
+	}
+
+	var rev uint64
+	if exists {
+		rev, err = reg.kv.Update(nameToken(schema.Name), data, current.Revision)
+	} else {
+		rev, err = reg.kv.Put(nameToken(schema.Name), data)
+	}
+	if errors.Is(err, nats.ErrKeyExists) {
+		return schema, newError("409", "schema %q was modified concurrently", schema.Name)
+	}
+	if err != nil {
+		return schema, err
+	}
+	schema.Revision = rev
+	schema.Warnings = warnings
+
+	reg.index(schema)
+
+	reg.publishEvent(EventPut, schema.Name, &schema)
+	reg.publishDependents(schema.Name)
+	return schema, nil
+}
+
+// index records a stored schema under its fingerprint and ID. The schema is
+// stored already, so failing to index it is logged rather than failing the
+// change: it's still served by name, only not found by fingerprint or ID.
+func (reg *SchemaRegistry) index(schema Schema) {
+	if err := reg.indexFingerprint(schema); err != nil {
+		logger("registry").Error("error indexing schema by fingerprint", "schema", schema.Name, "revision", schema.Revision, "error", err)
+	}
+	if err := reg.indexID(schema); err != nil {
+		logger("registry").Error("error indexing schema by id", "schema", schema.Name, "revision", schema.Revision, "id", schema.ID, "error", err)
+	}
+}
 
+// List subject: $SCHEMA.LIST
+//
+// The request is an optional ListRequest, the response a page of schemas.
+func (reg *SchemaRegistry) List(r micro.Request) {
+	var req ListRequest
+	if len(r.Data()) > 0 {
+		err := json.Unmarshal(r.Data(), &req)
 		if err != nil {
-			log.Printf("error publishing message: %v", err)
-			m.Respond([]byte(err.Error()))
+			r.Error("400", err.Error(), nil)
 			return
 		}
+	}
 
+	schemas, next, err := reg.listPage(req)
+	if err != nil {
+		r.Error(errorCode(err), err.Error(), nil)
+		return
+	}
+	if next != "" {
+		r.RespondJSON(schemas, micro.WithHeaders(micro.Headers{SchemaNextCursorHeader: []string{next}}))
 		return
 	}
+	r.RespondJSON(schemas)
+}
 
-	errorMessage := fmt.Sprintf("could not find schema for subject %q", subject)
-	fmt.Println(errorMessage)
-	m.Respond([]byte(errorMessage))
+// list returns the cached schemas sorted by name.
+func (reg *SchemaRegistry) list() []Schema {
+	reg.schemasMu.RLock()
+	schemas := make([]Schema, 0, len(reg.schemas))
+	for _, schema := range reg.schemas {
+		schemas = append(schemas, schema)
+	}
+	reg.schemasMu.RUnlock()
+
+	sort.Slice(schemas, func(i, j int) bool { return schemas[i].Name < schemas[j].Name })
+	return schemas
 }
 
-func (reg *SchemaRegistry) validate(data []byte, schema Schema) error {
-	dataBody := gojsonschema.NewStringLoader(string(data))
-	schemaBody := gojsonschema.NewStringLoader(schema.Body)
+// loadSchemas reads the latest revision of every schema straight from the kv
+// store, for one-off commands that don't keep a watched cache.
+func loadSchemas(kv nats.KeyValue) ([]Schema, error) {
+	keys, err := kv.Keys()
+	if errors.Is(err, nats.ErrNoKeysFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
 
-	result, err := gojsonschema.Validate(schemaBody, dataBody)
+	reg := NewSchemaRegistry(kv, nil)
+	var schemas []Schema
+	for _, key := range keys {
+		// Schemas live under single token keys, the rest are indexes
+		if strings.Contains(key, ".") {
+			continue
+		}
+		schema, exists, err := reg.current(tokenName(key))
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			schemas = append(schemas, schema)
+		}
+	}
+	return schemas, nil
+}
+
+// current reads the latest revision of a schema straight from the kv store.
+func (reg *SchemaRegistry) current(name string) (Schema, bool, error) {
+	var schema Schema
+
+	entry, err := reg.kv.Get(nameToken(name))
+	if errors.Is(err, nats.ErrKeyNotFound) {
+		return schema, false, nil
+	}
+	if err != nil {
+		return schema, false, err
+	}
+
+	err = json.Unmarshal(entry.Value(), &schema)
 	if err != nil {
+		return schema, false, err
+	}
+	schema.Revision = entry.Revision()
+	updated := entry.Created()
+	schema.Updated = &updated
+	if schema.State == "" {
+		schema.State = StateActive
+	}
+	return schema, true, nil
+}
+
+// authorize responds with a 403 error and returns false if the request is not
+// allowed to mutate the named schema.
+func (reg *SchemaRegistry) authorize(r micro.Request, name string) bool {
+	if reg.auth.AllowedOwner(name, reg.owner(name), nats.Header(r.Headers())) {
+		return true
+	}
+	r.Error("403", fmt.Sprintf("not authorized to modify schema %q", name), nil)
+	return false
+}
+
+// validationPrefix returns the subject prefix validation requests are
+// received under.
+func (reg *SchemaRegistry) validationPrefix() string {
+	if reg.validatePrefix != "" {
+		return reg.validatePrefix
+	}
+	return reg.prefix + ".VALIDATE"
+}
+
+// validateSubject returns the subscription validation requests are received
+// on.
+func (reg *SchemaRegistry) validateSubject() string {
+	return reg.validationPrefix() + ".>"
+}
+
+// Validate subject: $SCHEMA.VALIDATE.<subject>
+func (reg *SchemaRegistry) ValidatePayload(m *nats.Msg) {
+	// Pull out the subject from the request subject
+	subject := strings.TrimPrefix(m.Subject, reg.validationPrefix()+".")
+
+	reg.validateMsg(m, subject, RouteSubject)
+}
+
+// validateMsg validates a message published to subject and forwards it to the
+// subject route returns. Rejected messages are answered and recorded, the
+// error is only returned if a valid message couldn't be forwarded.
+func (reg *SchemaRegistry) validateMsg(m *nats.Msg, subject string, route func(Schema, string, map[string]string) string) error {
+	start := time.Now()
+	defer func() { reg.validations.Observe(time.Since(start)) }()
+	ensureRequestID(m)
+
+	if err := reg.settings().Limits.CheckPayload(m.Data); err != nil {
+		reg.respondError(m, errorCode(err), err.Error())
+		return nil
+	}
+
+	// find a schema that matches the subject
+	schema, ok := reg.match(subject)
+	if !ok {
+		reg.respondError(m, "404", fmt.Sprintf("could not find schema for subject %q", subject))
+		return nil
+	}
+
+	if schema.State == StateDisabled {
+		reg.respondError(m, "409", fmt.Sprintf("schema %q is disabled", schema.Name))
+		return nil
+	}
+
+	// Messages over quota aren't rejected, they can be sent again later
+	if err := reg.settings().quotas.AllowValidation(schema.Name); err != nil {
+		reg.respondError(m, errorCode(err), err.Error())
 		return err
 	}
-	if !result.Valid() {
-		var errors []string
-		for _, desc := range result.Errors() {
-			errors = append(errors, desc.String())
+
+	schema, err := reg.pin(schema, m.Header)
+	if err != nil {
+		reg.respondError(m, errorCode(err), err.Error())
+		return nil
+	}
+
+	// Until a revision in canary is promoted, payloads are validated against
+	// the previous one and only shadow-validated against it
+	canary := schema
+	inCanary := false
+	if m.Header.Get(SchemaRevisionHeader) == "" {
+		schema, inCanary = reg.canaryRevision(schema, time.Now())
+	}
+
+	// Payloads too large for a message are referenced in an Object Store
+	data := m.Data
+	if ref := m.Header.Get(SchemaObjectHeader); ref != "" {
+		var err error
+		data, err = reg.objectPayload(ref)
+		if err != nil {
+			reg.respondError(m, errorCode(err), err.Error())
+			return nil
 		}
-		return fmt.Errorf("invalid payload: %v", strings.Join(errors, ", "))
+	}
+
+	// validate the payload
+	params, err := reg.check(schema, subject, data, m.Header)
+	if inCanary {
+		reg.shadowValidate(m, canary, subject, data, err)
+	}
+	if schema.Compare != "" {
+		reg.compareVerdicts(m, schema, subject, data, err)
+	}
+	if err != nil {
+		reg.reject(m, schema, subject, err)
+		return nil
+	}
+	reg.stats.Record(schema.Name, true)
+	requestLogger(m, schema, subject).Debug("Validated message")
+
+	msg := reg.forwardMsg(route(schema, subject, params), schema, subject, params, m)
+	reg.checkDeprecatedFields(msg, schema, data)
+	if err := reg.encryptMsg(msg, schema, data); err != nil {
+		reg.respondError(m, errorCode(err), err.Error())
+		return nil
+	}
+
+	// Requests whose replies are also under contract are made by the registry
+	// itself, so the reply can be checked before it is relayed
+	if schema.ResponseBody != "" && m.Reply != "" {
+		reg.proxyRequest(m, msg, schema)
+		return nil
+	}
+
+	err = reg.publishValidated(m, msg, schema, subject, params)
+
+	// Dead-lettered messages can still be replayed
+	if errors.Is(err, errDeadLettered) {
+		return nil
+	}
+	return err
+}
+
+// publishValidated publishes the forwarding message of a validated and
+// encrypted message, to the schema's stream or its destination, followed by
+// its redacted copy. Failures are retried and dead-lettered, and answered to
+// the reply subject of the validated message like the PubAck of a stream.
+func (reg *SchemaRegistry) publishValidated(m *nats.Msg, msg *nats.Msg, schema Schema, subject string, params map[string]string) error {
+	var err error
+	if schema.JetStream {
+		err = reg.publishJetStream(m, msg, schema, subject)
+	} else {
+		err = reg.forward(m, schema, subject, func() error { return reg.nc.PublishMsg(msg) })
+		if err != nil {
+			requestLogger(m, schema, subject).Error("error publishing message", "destination", msg.Subject, "error", err)
+			reg.respondError(m, "500", err.Error())
+		}
+	}
+	if err == nil {
+		reg.publishRedacted(msg, schema, subject, params, msg.Data)
+	}
+	return err
+}
+
+// respondError answers a validation request with a micro error response, so
+// clients can handle it like an error from any other endpoint. The body holds
+// the same error in the JetStream API format, for producers publishing with a
+// JetStream client. Every error is counted in the validate endpoint's stats,
+// even without a reply subject.
+func (reg *SchemaRegistry) respondError(m *nats.Msg, code, description string) {
+	reg.respondErrorHeader(m, code, description, nil)
+}
+
+// respondErrorHeader is respondError with additional headers.
+func (reg *SchemaRegistry) respondErrorHeader(m *nats.Msg, code, description string, header nats.Header) {
+	reg.validations.Error(description)
+	if m.Reply == "" {
+		return
+	}
+
+	status, _ := strconv.Atoi(code)
+	body, _ := json.Marshal(struct {
+		Error nats.APIError `json:"error"`
+	}{nats.APIError{Code: status, Description: description}})
+
+	resp := nats.NewMsg(m.Reply)
+	resp.Data = body
+	for key, values := range header {
+		resp.Header[key] = values
+	}
+	resp.Header.Set(micro.ErrorHeader, description)
+	resp.Header.Set(micro.ErrorCodeHeader, code)
+	if id := m.Header.Get(SchemaRequestIDHeader); id != "" {
+		resp.Header.Set(SchemaRequestIDHeader, id)
+	}
+	err := m.RespondMsg(resp)
+	if err != nil {
+		logger("validation").Error("error responding to validation request", "subject", m.Subject, "request_id", m.Header.Get(SchemaRequestIDHeader), "error", err)
+	}
+}
+
+// EndpointStats bridges the validation counts into the micro service stats,
+// as the data of the validate endpoint.
+func (reg *SchemaRegistry) EndpointStats(endpoint *micro.Endpoint) interface{} {
+	if endpoint.Subject != reg.validateSubject() {
+		return nil
+	}
+	stats := reg.validations.Get()
+	stats.Name = "validate"
+	stats.Subject = endpoint.Subject
+	return stats
+}
+
+// maxOverdueValidations is how many validations may finish in the background
+// after timing out.
+const maxOverdueValidations = 64
+
+// check validates a message published to subject against every part of the
+// schema, and returns the subject parameters it carries. Validation that takes
+// longer than the validation timeout fails with a 408 error. It can't be
+// interrupted, so it finishes in the background, unless too many already do:
+// the worker then waits for it before taking the next message, so slow
+// schemas can't pile up goroutines.
+func (reg *SchemaRegistry) check(schema Schema, subject string, data []byte, headers nats.Header) (map[string]string, error) {
+	if reg.validationTimeout <= 0 {
+		return reg.checkMsg(schema, subject, data, headers)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), reg.validationTimeout)
+	defer cancel()
+
+	type result struct {
+		params map[string]string
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		params, err := reg.checkMsg(schema, subject, data, headers)
+		done <- result{params, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.params, r.err
+	case <-ctx.Done():
+	}
+
+	err := newError("408", "validation against schema %q timed out after %v", schema.Name, reg.validationTimeout)
+	if reg.overdue.Add(1) > reg.maxOverdue {
+		<-done
+		reg.overdue.Add(-1)
+		return nil, err
+	}
+	go func() {
+		<-done
+		reg.overdue.Add(-1)
+	}()
+	return nil, err
+}
+
+// checkMsg validates a message against a schema without a timeout: its
+// payload against the body, or the part of it a pointer header selects,
+// reusing the cached verdict of an identical payload where the body allows,
+// then its headers and subject parameters. It returns the parameters.
+func (reg *SchemaRegistry) checkMsg(schema Schema, subject string, data []byte, headers nats.Header) (map[string]string, error) {
+	var err error
+	if pointer := headers.Get(SchemaPointerHeader); pointer != "" {
+		// Verdicts are cached per payload, not per part of the schema
+		err = reg.validatePointer(data, schema.Body, pointer)
+	} else if hasRegistryRefs(schema.Body) || hasExistsRules(schema) {
+		// The referenced schemas and keys may change without this one, so its
+		// verdicts can't be cached by revision
+		err = reg.validatePayload(schema, data)
+	} else {
+		key := resultKeyFor(schema, reg.strict(schema), data)
+		var cached bool
+		err, cached = reg.results.Get(key)
+		if !cached {
+			err = reg.validatePayload(schema, data)
+			// Plugins timing out or failing may do better next time
+			if _, invalid := err.(*ValidationError); err == nil || invalid {
+				reg.results.Add(key, err)
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if schema.HeaderBody != "" {
+		err = reg.validateHeaders(headers, schema.HeaderBody)
+		if err != nil {
+			return nil, err
+		}
+	}
+	params := SubjectParams(subject, schema.Subject)
+	err = validateParams(params, schema.Parameters)
+	if err != nil {
+		return nil, err
+	}
+	return params, nil
+}
+
+// forwardMsg builds the message forwarding a validated message to the
+// destination subject, annotated with the schema it was validated against.
+func (reg *SchemaRegistry) forwardMsg(destination string, schema Schema, subject string, params map[string]string, m *nats.Msg) *nats.Msg {
+	msg := nats.NewMsg(destination)
+	msg.Reply = m.Reply
+	msg.Data = m.Data
+	msg.Header = m.Header
+	if msg.Header == nil {
+		msg.Header = nats.Header{}
+	}
+	msg.Header.Set("Schema-Name", schema.Name)
+	msg.Header.Set("Schema-Revision", fmt.Sprintf("%d", schema.Revision))
+	msg.Header.Set("Schema-Subject", schema.Subject)
+	msg.Header.Set("Schema-Type", schema.Type)
+	msg.Header.Set("Schema-Validated", "true")
+	if msg.Subject != subject {
+		msg.Header.Set("Schema-Original-Subject", subject)
+	}
+	if schema.ID != 0 {
+		msg.Header.Set("Schema-Id", fmt.Sprintf("%d", schema.ID))
+	}
+	for name, value := range params {
+		msg.Header.Set("Schema-Param-"+name, value)
+	}
+	stampAnnotations(msg.Header, schema)
+	if schema.State == StateDeprecated {
+		msg.Header.Set("Schema-Deprecation", fmt.Sprintf("schema %q is deprecated", schema.Name))
+	}
+	return msg
+}
+
+// match returns the schema validating payloads for a subject. Drafts never
+// match. The read lock is only held for the lookup, so validation and
+// publishing don't block cache updates.
+func (reg *SchemaRegistry) match(subject string) (Schema, bool) {
+	reg.schemasMu.RLock()
+	defer reg.schemasMu.RUnlock()
+
+	for _, schema := range reg.schemas {
+		if schema.State == StateDraft || !SubjectsMatch(subject, SubjectPattern(schema.Subject)) {
+			continue
+		}
+		return schema, true
+	}
+	return Schema{}, false
+}
+
+func (reg *SchemaRegistry) validate(data []byte, body string) error {
+	body, err := reg.resolvedBody(body)
+	if err != nil {
+		return err
+	}
+
+	violations, err := violations(data, body)
+	if err != nil {
+		return err
+	}
+	if len(violations) > 0 {
+		return &ValidationError{What: "payload", Violations: violations}
 	}
 
 	return nil
 }
 
+// validatePayload validates a payload against the body of a schema, and then
+// against its rules and plugin. The payloads of batch schemas are arrays, whose
+// items are validated one by one.
+func (reg *SchemaRegistry) validatePayload(schema Schema, data []byte) error {
+	if schema.Batch {
+		return reg.validateBatch(schema, data)
+	}
+
+	body, err := reg.payloadBody(schema)
+	if err != nil {
+		return err
+	}
+	violations, err := violations(data, body)
+	if err != nil {
+		return err
+	}
+	if len(violations) == 0 {
+		violations, err = reg.extraViolations(schema, data)
+		if err != nil {
+			return err
+		}
+	}
+	if len(violations) > 0 {
+		return &ValidationError{What: "payload", Violations: violations}
+	}
+	return nil
+}
+
+// extraViolations validates a payload that conforms to the body of a schema
+// against its rules, and then its plugin.
+func (reg *SchemaRegistry) extraViolations(schema Schema, data []byte) ([]string, error) {
+	violations, err := reg.ruleViolations(schema, data)
+	if err != nil || len(violations) > 0 {
+		return violations, err
+	}
+	return reg.pluginViolations(schema, data)
+}
+
+// violations validates a JSON document against a schema body and returns a
+// description of every violation.
+func violations(data []byte, body string) ([]string, error) {
+	dataBody := gojsonschema.NewStringLoader(string(data))
+	schemaBody := gojsonschema.NewStringLoader(body)
+
+	result, err := gojsonschema.Validate(schemaBody, dataBody)
+	if err != nil {
+		return nil, err
+	}
+
+	var errors []string
+	for _, desc := range result.Errors() {
+		errors = append(errors, desc.String())
+	}
+	return errors, nil
+}
+
 // SubjectsMatch returns true if the literal subject matches the wildcard subject.
 // Subjects are case sensitive and can contain tokens delimited by the dot (.) character.
 // The wildcard subject can contain the * wildcard.
@@ -1,6 +1,16 @@
 package main
 
-import "testing"
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/micro"
+)
 
 func TestSubjectsMatch(t *testing.T) {
 	// Test that the subjects match
@@ -22,3 +32,216 @@ func TestSubjectsMatch(t *testing.T) {
 		t.Errorf("Expected subject to not match")
 	}
 }
+
+func TestReadOnly(t *testing.T) {
+	reg := NewSchemaRegistry(nil, nil)
+	reg.readOnly = true
+
+	_, err := reg.register(Schema{Name: "foo", Subject: "foo", Body: "{}"})
+	if errorCode(err) != "403" {
+		t.Errorf("Expected register to be rejected, got %v", err)
+	}
+	_, err = reg.update(Schema{Name: "foo", Subject: "foo", Body: "{}"})
+	if errorCode(err) != "403" {
+		t.Errorf("Expected update to be rejected, got %v", err)
+	}
+	err = reg.unregister("foo")
+	if errorCode(err) != "403" {
+		t.Errorf("Expected unregister to be rejected, got %v", err)
+	}
+}
+
+func TestCheckTimeout(t *testing.T) {
+	reg := NewSchemaRegistry(nil, nil)
+	schema := Schema{Name: "numbers", Subject: "numbers", Body: `{"type": "array", "items": {"type": "integer"}}`}
+	payload := "[" + strings.Repeat("1,", 100000) + "1]"
+
+	_, err := reg.check(schema, "numbers", []byte(payload), nil)
+	if err != nil {
+		t.Fatalf("Expected payload to be valid, got %v", err)
+	}
+
+	reg.validationTimeout = time.Nanosecond
+	_, err = reg.check(schema, "numbers", []byte(payload), nil)
+	if errorCode(err) != "408" {
+		t.Errorf("Expected a 408 timeout error, got %v", err)
+	}
+}
+
+func TestCheckTimeoutBoundsOverdue(t *testing.T) {
+	reg := NewSchemaRegistry(nil, nil)
+	reg.validationTimeout = time.Millisecond
+	reg.maxOverdue = 1
+	schema := Schema{Name: "numbers", Subject: "numbers", Body: `{"type": "array", "uniqueItems": true}`}
+	items := make([]string, 30000)
+	for i := range items {
+		items[i] = fmt.Sprintf(`{"n": %d}`, i)
+	}
+	payload := []byte("[" + strings.Join(items, ",") + "]")
+
+	// The first validation finishes in the background
+	_, err := reg.check(schema, "numbers", payload, nil)
+	if errorCode(err) != "408" {
+		t.Errorf("Expected a 408 timeout error, got %v", err)
+	}
+	if overdue := reg.overdue.Load(); overdue != 1 {
+		t.Errorf("Expected 1 overdue validation, got %d", overdue)
+	}
+
+	// The next one is waited for
+	_, err = reg.check(schema, "numbers", payload, nil)
+	if errorCode(err) != "408" {
+		t.Errorf("Expected a 408 timeout error, got %v", err)
+	}
+	if overdue := reg.overdue.Load(); overdue > 1 {
+		t.Errorf("Expected at most 1 overdue validation, got %d", overdue)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for reg.overdue.Load() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if overdue := reg.overdue.Load(); overdue != 0 {
+		t.Errorf("Expected overdue validations to finish, got %d", overdue)
+	}
+}
+
+func TestValidatePayloadTrimsPrefix(t *testing.T) {
+	tests := []struct {
+		prefix         string
+		validatePrefix string
+		subject        string
+	}{
+		{DefaultPrefix, "", "$SCHEMA.VALIDATE.orders.new"},
+		{"ACME", "", "ACME.VALIDATE.orders.new"},
+		{DefaultPrefix, "validate.prod", "validate.prod.orders.new"},
+	}
+	for _, test := range tests {
+		reg := NewSchemaRegistry(nil, nil)
+		reg.failures = NewFailureLog(10)
+		reg.prefix = test.prefix
+		reg.validatePrefix = test.validatePrefix
+		reg.remember(Schema{Name: "orders", Subject: "orders.*", Body: `{"type": "object", "required": ["id"]}`})
+
+		reg.ValidatePayload(&nats.Msg{Subject: test.subject, Data: []byte(`{}`)})
+		failures := reg.failures.List("orders")
+		if len(failures) != 1 || failures[0].Subject != "orders.new" {
+			t.Errorf("Expected %s to be validated as orders.new, got %+v", test.subject, failures)
+		}
+	}
+
+	// Subjects without a schema are reported without the prefix
+	reg := NewSchemaRegistry(nil, nil)
+	reg.ValidatePayload(&nats.Msg{Subject: "$SCHEMA.VALIDATE.invoices.new", Data: []byte(`{}`)})
+	if last := reg.validations.Get().LastError; last != `could not find schema for subject "invoices.new"` {
+		t.Errorf("Expected the subject to be reported without the prefix, got %q", last)
+	}
+}
+
+// dyingKV is a bucket whose watchers can be stopped, as when the server
+// restarts.
+type dyingKV struct {
+	*memKV
+
+	mu       sync.Mutex
+	watchers []*memWatcher
+}
+
+func (kv *dyingKV) Watch(keys string, opts ...nats.WatchOpt) (nats.KeyWatcher, error) {
+	w, err := kv.memKV.Watch(keys, opts...)
+	kv.mu.Lock()
+	kv.watchers = append(kv.watchers, w.(*memWatcher))
+	kv.mu.Unlock()
+	return w, err
+}
+
+// kill closes the updates of the latest watcher.
+func (kv *dyingKV) kill() {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	close(kv.watchers[len(kv.watchers)-1].updates)
+}
+
+func (kv *dyingKV) watches() int {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	return len(kv.watchers)
+}
+
+// waitFor fails the test unless done returns true within a few seconds.
+func waitFor(t *testing.T, what string, done func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !done() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// cached returns true if the named schema is in the registry's cache.
+func cached(reg *SchemaRegistry, name string) bool {
+	reg.schemasMu.RLock()
+	defer reg.schemasMu.RUnlock()
+	_, ok := reg.schemas[name]
+	return ok
+}
+
+func TestWatchRestarts(t *testing.T) {
+	kv := &dyingKV{memKV: newMemKV()}
+	reg := NewSchemaRegistry(kv, nil)
+	for _, name := range []string{"orders", "customers"} {
+		if _, err := reg.register(Schema{Name: name, Subject: name + ".*", Body: `{"type": "object"}`}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := reg.Watch(ctx); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the initial schemas to load", reg.Healthy)
+	if !cached(reg, "orders") {
+		t.Fatalf("Expected orders to be cached")
+	}
+
+	// Changes made while the watch is down are picked up by the next one,
+	// including deletions
+	if err := kv.Delete(nameToken("orders")); err != nil {
+		t.Fatal(err)
+	}
+	kv.kill()
+	waitFor(t, "the watch to restart", func() bool { return kv.watches() == 2 && reg.Healthy() })
+	if cached(reg, "orders") {
+		t.Errorf("Expected orders deleted while the watch was down to be removed")
+	}
+	if !cached(reg, "customers") {
+		t.Errorf("Expected customers to still be cached")
+	}
+
+	reg.Resync()
+	waitFor(t, "the watch to restart on resync", func() bool { return kv.watches() == 3 && reg.Healthy() })
+
+	cancel()
+	waitFor(t, "the watch to stop", func() bool { return !reg.Healthy() })
+}
+
+func TestHealth(t *testing.T) {
+	reg := NewSchemaRegistry(newMemKV(), nil)
+	reg.remember(Schema{Name: "orders"})
+
+	r := &recordingRequest{}
+	reg.Health(r)
+	if code := r.resp.Header.Get(micro.ErrorCodeHeader); code != "503" {
+		t.Errorf("Expected a stale cache to be unhealthy, got %q", code)
+	}
+
+	reg.healthy.Store(true)
+	r = &recordingRequest{}
+	reg.Health(r)
+	if code := r.resp.Header.Get(micro.ErrorCodeHeader); code != "" {
+		t.Errorf("Expected an up to date cache to be healthy, got %q", code)
+	}
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/nats-io/nats.go/micro"
+)
+
+// Kinds of the words a schema is found by.
+const (
+	SearchName        = "name"
+	SearchField       = "field"
+	SearchDescription = "description"
+	SearchTag         = "tag"
+)
+
+// searchTerm is a word a schema is found by, lower cased, along with where it
+// comes from and how it was written.
+type searchTerm struct {
+	Term string
+	Kind string
+	Text string
+}
+
+// SearchHit is a schema matching a search, with the words it matched as kind
+// and text, such as "field accountId".
+type SearchHit struct {
+	Name    string   `json:"name"`
+	Subject string   `json:"subject,omitempty"`
+	Matches []string `json:"matches"`
+}
+
+// SearchIndex is an inverted index of the names, field names, titles and
+// descriptions, and tags of schemas. The watcher keeps it up to date with the
+// cache.
+type SearchIndex struct {
+	mu sync.RWMutex
+	// terms holds the names of the schemas with each term
+	terms map[string]map[string]bool
+	// docs holds the terms and subject of each schema
+	docs     map[string][]searchTerm
+	subjects map[string]string
+}
+
+func NewSearchIndex() *SearchIndex {
+	return &SearchIndex{
+		terms:    map[string]map[string]bool{},
+		docs:     map[string][]searchTerm{},
+		subjects: map[string]string{},
+	}
+}
+
+// Put indexes a schema, replacing its previous revision.
+func (idx *SearchIndex) Put(schema Schema) {
+	terms := schemaTerms(schema)
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.remove(schema.Name)
+	idx.docs[schema.Name] = terms
+	idx.subjects[schema.Name] = schema.Subject
+	for _, t := range terms {
+		if idx.terms[t.Term] == nil {
+			idx.terms[t.Term] = map[string]bool{}
+		}
+		idx.terms[t.Term][schema.Name] = true
+	}
+}
+
+// Remove drops a schema from the index.
+func (idx *SearchIndex) Remove(name string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.remove(name)
+}
+
+func (idx *SearchIndex) remove(name string) {
+	for _, t := range idx.docs[name] {
+		delete(idx.terms[t.Term], name)
+		if len(idx.terms[t.Term]) == 0 {
+			delete(idx.terms, t.Term)
+		}
+	}
+	delete(idx.docs, name)
+	delete(idx.subjects, name)
+}
+
+// Replace indexes schemas in place of everything indexed before.
+func (idx *SearchIndex) Replace(schemas map[string]Schema) {
+	idx.mu.Lock()
+	idx.terms = map[string]map[string]bool{}
+	idx.docs = map[string][]searchTerm{}
+	idx.subjects = map[string]string{}
+	idx.mu.Unlock()
+
+	for _, schema := range schemas {
+		idx.Put(schema)
+	}
+}
+
+// Search returns the schemas matching every word of a query, sorted by name.
+// Words are matched case insensitively and whole, unless they end with * to
+// match a prefix. A word may be limited to a kind, as in field:accountId.
+func (idx *SearchIndex) Search(query string) []SearchHit {
+	type word struct{ kind, term string }
+	var words []word
+	for _, w := range strings.Fields(query) {
+		kind, term, ok := strings.Cut(w, ":")
+		if !ok {
+			kind, term = "", w
+		}
+		words = append(words, word{kind, strings.ToLower(term)})
+	}
+	if len(words) == 0 {
+		return []SearchHit{}
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	matches := map[string][]string{}
+	for i, w := range words {
+		found := map[string]bool{}
+		for term, names := range idx.terms {
+			if !matchesTerm(term, w.term) {
+				continue
+			}
+			for name := range names {
+				if i > 0 && matches[name] == nil {
+					continue
+				}
+				for _, t := range idx.docs[name] {
+					if t.Term == term && (w.kind == "" || w.kind == t.Kind) {
+						found[name] = true
+						matches[name] = append(matches[name], t.Kind+" "+t.Text)
+					}
+				}
+			}
+		}
+		// Every word must match
+		for name := range matches {
+			if !found[name] {
+				delete(matches, name)
+			}
+		}
+	}
+
+	hits := []SearchHit{}
+	for name, texts := range matches {
+		hits = append(hits, SearchHit{Name: name, Subject: idx.subjects[name], Matches: sortedKeys(toSet(texts))})
+	}
+	sort.Slice(hits, func(i, j int) bool { return hits[i].Name < hits[j].Name })
+	return hits
+}
+
+// matchesTerm returns true if an indexed term matches a query word.
+func matchesTerm(term, word string) bool {
+	if prefix, ok := strings.CutSuffix(word, "*"); ok {
+		return strings.HasPrefix(term, prefix)
+	}
+	return term == word
+}
+
+func toSet(values []string) map[string]bool {
+	set := map[string]bool{}
+	for _, v := range values {
+		set[v] = true
+	}
+	return set
+}
+
+// schemaTerms returns the words a schema is found by: its name and the parts
+// of it, the property names, titles and descriptions of its body, and its
+// tags.
+func schemaTerms(schema Schema) []searchTerm {
+	var terms []searchTerm
+	add := func(kind, text string) {
+		terms = append(terms, searchTerm{Term: strings.ToLower(text), Kind: kind, Text: text})
+	}
+	addWords := func(kind, text string) {
+		for _, w := range strings.FieldsFunc(text, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+			add(kind, w)
+		}
+	}
+
+	add(SearchName, schema.Name)
+	if parts := strings.FieldsFunc(schema.Name, func(r rune) bool { return r == '.' || r == '_' || r == '-' }); len(parts) > 1 {
+		for _, part := range parts {
+			add(SearchName, part)
+		}
+	}
+	for _, tag := range schema.Tags {
+		add(SearchTag, tag)
+	}
+
+	var doc interface{}
+	if json.Unmarshal([]byte(schema.Body), &doc) != nil {
+		return terms
+	}
+	var walk func(node interface{})
+	walk = func(node interface{}) {
+		switch node := node.(type) {
+		case map[string]interface{}:
+			for key, value := range node {
+				switch key {
+				case "properties":
+					props, _ := value.(map[string]interface{})
+					for name, prop := range props {
+						add(SearchField, name)
+						walk(prop)
+					}
+				case "title", "description":
+					if text, ok := value.(string); ok {
+						addWords(SearchDescription, text)
+					}
+				case "examples", "enum", "const", "default":
+					// Payloads, not schemas
+				default:
+					walk(value)
+				}
+			}
+		case []interface{}:
+			for _, item := range node {
+				walk(item)
+			}
+		}
+	}
+	walk(doc)
+	return terms
+}
+
+// Search subject: $SCHEMA.SEARCH
+//
+// The request is the query, such as accountId or field:account* tag:billing.
+func (reg *SchemaRegistry) Search(r micro.Request) {
+	r.RespondJSON(reg.search.Search(string(r.Data())))
+}
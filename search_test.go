@@ -0,0 +1,49 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSearchIndex(t *testing.T) {
+	idx := NewSearchIndex()
+	idx.Replace(map[string]Schema{
+		"com.acme.account": {Name: "com.acme.account", Subject: "accounts.*", Tags: []string{"billing"},
+			Body: `{"title": "Customer account", "properties": {"accountId": {"type": "string"}, "owner": {"properties": {"email": {"description": "Contact address"}}}}}`},
+		"payments": {Name: "payments", Tags: []string{"billing"},
+			Body: `{"properties": {"accountId": {"type": "string"}, "amount": {"type": "number", "examples": [{"owner": 1}]}}}`},
+	})
+
+	names := func(hits []SearchHit) []string {
+		var names []string
+		for _, hit := range hits {
+			names = append(names, hit.Name)
+		}
+		return names
+	}
+
+	hits := idx.Search("accountid")
+	if !reflect.DeepEqual(names(hits), []string{"com.acme.account", "payments"}) {
+		t.Errorf("Expected both schemas with the field, got %v", names(hits))
+	}
+	if len(hits) > 0 && !reflect.DeepEqual(hits[0].Matches, []string{"field accountId"}) {
+		t.Errorf("Expected the field to be reported, got %v", hits[0].Matches)
+	}
+	if got := names(idx.Search("field:email tag:billing")); !reflect.DeepEqual(got, []string{"com.acme.account"}) {
+		t.Errorf("Expected a nested field and a tag to match, got %v", got)
+	}
+	if got := names(idx.Search("owner")); !reflect.DeepEqual(got, []string{"com.acme.account"}) {
+		t.Errorf("Expected examples not to be indexed, got %v", got)
+	}
+	if got := names(idx.Search("cust* acme")); !reflect.DeepEqual(got, []string{"com.acme.account"}) {
+		t.Errorf("Expected a prefix of the title and a part of the name to match, got %v", got)
+	}
+	if got := names(idx.Search("name:accountId")); len(got) != 0 {
+		t.Errorf("Expected a word of another kind not to match, got %v", got)
+	}
+
+	idx.Remove("payments")
+	if got := names(idx.Search("amount")); len(got) != 0 {
+		t.Errorf("Expected a removed schema not to be found, got %v", got)
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LoadSeedDir reads every .json, .yaml and .yml schema definition in dir.
+func LoadSeedDir(dir string) ([]Schema, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var schemas []Schema
+	for _, entry := range entries {
+		name, ok := schemaFileName(entry.Name())
+		if entry.IsDir() || !ok {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		schema, err := ParseSchemaFile(name, data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+		schemas = append(schemas, schema)
+	}
+
+	return schemas, nil
+}
+
+// schemaFileName returns the file name without its extension, and whether the
+// file is a schema definition at all.
+func schemaFileName(file string) (string, bool) {
+	ext := filepath.Ext(file)
+	if ext != ".json" && ext != ".yaml" && ext != ".yml" {
+		return "", false
+	}
+	return strings.TrimSuffix(filepath.Base(file), ext), true
+}
+
+// ParseSchemaFile decodes a JSON or YAML schema definition. The body may be
+// given either as a string or as an inline document, and the name defaults to
+// the given file name.
+func ParseSchemaFile(name string, data []byte) (Schema, error) {
+	var schema Schema
+
+	// YAML is a superset of JSON, so this handles both
+	var raw map[string]interface{}
+	err := yaml.Unmarshal(data, &raw)
+	if err != nil {
+		return schema, err
+	}
+
+	if body, ok := raw["body"]; ok {
+		if _, isString := body.(string); !isString {
+			encoded, err := json.Marshal(body)
+			if err != nil {
+				return schema, err
+			}
+			raw["body"] = string(encoded)
+		}
+	}
+
+	encoded, err := json.Marshal(raw)
+	if err != nil {
+		return schema, err
+	}
+	err = json.Unmarshal(encoded, &schema)
+	if err != nil {
+		return schema, err
+	}
+
+	if schema.Name == "" {
+		schema.Name = name
+	}
+	return schema, nil
+}
+
+// Seed registers the given schemas if they are missing, and updates those
+// whose definition differs from the stored revision.
+func (reg *SchemaRegistry) Seed(schemas []Schema) error {
+	for _, schema := range schemas {
+		action, err := reg.apply(schema)
+		if err != nil {
+			return fmt.Errorf("seeding schema %q: %w", schema.Name, err)
+		}
+		if action != "" {
+			logger("seed").Info("Seeded schema", "schema", schema.Name, "action", action)
+		}
+	}
+
+	return nil
+}
+
+// apply reconciles the stored schema with the desired definition. It returns
+// "created" or "updated" for the change it made, or "" if the schema was
+// already up to date.
+func (reg *SchemaRegistry) apply(schema Schema) (string, error) {
+	current, exists, err := reg.current(schema.Name)
+	if err != nil {
+		return "", err
+	}
+
+	if !exists {
+		_, err = reg.register(schema)
+		if err == nil {
+			err = reg.applyOwner(schema, "")
+		}
+		return "created", err
+	}
+
+	if definitionChanged(current, schema) {
+		_, err = reg.update(schema)
+		if err == nil {
+			err = reg.applyOwner(schema, current.Owner)
+		}
+		return "updated", err
+	}
+	if schema.Owner != "" && schema.Owner != current.Owner {
+		return "updated", reg.applyOwner(schema, current.Owner)
+	}
+	return "", nil
+}
+
+// applyOwner transfers a schema to the owner of its desired definition, as the
+// owner is never taken from the schemas being stored.
+func (reg *SchemaRegistry) applyOwner(desired Schema, owner string) error {
+	if desired.Owner == "" || desired.Owner == owner {
+		return nil
+	}
+	_, err := reg.transfer(desired.Name, desired.Owner, desired.UpdatedBy, desired.RequestID)
+	return err
+}
+
+// definitionChanged returns true if the user supplied parts of the desired
+// schema differ from the current one.
+func definitionChanged(current, desired Schema) bool {
+	if desired.State != "" && desired.State != current.State {
+		return true
+	}
+	if desired.Examples != nil && !reflect.DeepEqual(desired.Examples, current.Examples) {
+		return true
+	}
+	if desired.Annotations != nil && !reflect.DeepEqual(desired.Annotations, current.Annotations) {
+		return true
+	}
+	if !sameExpiry(desired.Expiry, current.Expiry) {
+		return true
+	}
+	if desired.Compare != current.Compare {
+		return true
+	}
+	if desired.Type == TypeProtobuf {
+		// The body is derived from the source
+		return current.Type != desired.Type ||
+			current.Subject != desired.Subject ||
+			current.Source != desired.Source ||
+			current.Message != desired.Message ||
+			current.SemVer != desired.SemVer ||
+			current.Signature != desired.Signature
+	}
+	return current.Subject != desired.Subject ||
+		current.Type != desired.Type ||
+		current.Body != desired.Body ||
+		current.SemVer != desired.SemVer ||
+		current.Signature != desired.Signature
+}
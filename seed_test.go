@@ -0,0 +1,52 @@
+package main
+
+import "testing"
+
+func TestParseSchemaFile(t *testing.T) {
+	schema, err := ParseSchemaFile("orders_created", []byte(`
+subject: orders.created
+type: jsonschema
+body:
+  type: object
+  required: [id]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if schema.Name != "orders_created" {
+		t.Errorf("Expected name to default to the file name, got %q", schema.Name)
+	}
+	if schema.Body != `{"required":["id"],"type":"object"}` {
+		t.Errorf("Expected inline body to be encoded as JSON, got %q", schema.Body)
+	}
+
+	schema, err = ParseSchemaFile("ignored", []byte(`{"name": "numbers", "subject": "numbers.>", "type": "jsonschema", "body": "{ \"type\": \"integer\" }"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if schema.Name != "numbers" || schema.Body != `{ "type": "integer" }` {
+		t.Errorf("Expected JSON definition to be parsed as is, got %+v", schema)
+	}
+}
+
+func TestApplyOwner(t *testing.T) {
+	reg := NewSchemaRegistry(newMemKV(), nil)
+	desired := Schema{Name: "refunds", Subject: "refunds.*", Body: `{"type": "object"}`, Owner: "payments"}
+	if action, err := reg.apply(desired); action != "created" || err != nil {
+		t.Fatalf("Expected the schema to be created, got %q, %v", action, err)
+	}
+	if schema, _, _ := reg.current("refunds"); schema.Owner != "payments" || schema.Transfer == nil {
+		t.Errorf("Expected the seeded schema to be transferred to payments, got %q, %+v", schema.Owner, schema.Transfer)
+	}
+	if action, err := reg.apply(desired); action != "" || err != nil {
+		t.Errorf("Expected an owned schema to be up to date, got %q, %v", action, err)
+	}
+
+	desired.Owner = "orders"
+	if action, err := reg.apply(desired); action != "updated" || err != nil {
+		t.Fatalf("Expected the schema to be updated, got %q, %v", action, err)
+	}
+	if schema, _, _ := reg.current("refunds"); schema.Owner != "orders" || schema.Transfer.From != "payments" {
+		t.Errorf("Expected the schema to be transferred from payments to orders, got %q, %+v", schema.Owner, schema.Transfer)
+	}
+}
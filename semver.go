@@ -0,0 +1,398 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/micro"
+)
+
+// SemVer is a parsed semantic version. Build metadata is ignored.
+type SemVer struct {
+	Major, Minor, Patch uint64
+	Prerelease          string
+}
+
+// ParseSemVer parses a version such as 1.2.3 or 2.0.0-rc.1. A leading v is
+// allowed.
+func ParseSemVer(s string) (SemVer, error) {
+	var v SemVer
+
+	core, _, _ := strings.Cut(strings.TrimPrefix(s, "v"), "+")
+	core, v.Prerelease, _ = strings.Cut(core, "-")
+
+	parts := strings.Split(core, ".")
+	if len(parts) != 3 {
+		return v, fmt.Errorf("invalid semantic version %q", s)
+	}
+	numbers := []*uint64{&v.Major, &v.Minor, &v.Patch}
+	for i, part := range parts {
+		n, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return v, fmt.Errorf("invalid semantic version %q", s)
+		}
+		*numbers[i] = n
+	}
+	return v, nil
+}
+
+func (v SemVer) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Prerelease != "" {
+		s += "-" + v.Prerelease
+	}
+	return s
+}
+
+// Compare returns -1, 0 or 1 if v is lower, equal or higher than other.
+// Prereleases are lower than the release they precede.
+func (v SemVer) Compare(other SemVer) int {
+	for _, pair := range [][2]uint64{{v.Major, other.Major}, {v.Minor, other.Minor}, {v.Patch, other.Patch}} {
+		if pair[0] != pair[1] {
+			if pair[0] < pair[1] {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	switch {
+	case v.Prerelease == other.Prerelease:
+		return 0
+	case v.Prerelease == "":
+		return 1
+	case other.Prerelease == "":
+		return -1
+	}
+	return comparePrerelease(v.Prerelease, other.Prerelease)
+}
+
+// comparePrerelease compares dot separated prerelease identifiers, numeric
+// ones numerically.
+func comparePrerelease(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aErr := strconv.ParseUint(as[i], 10, 64)
+		bn, bErr := strconv.ParseUint(bs[i], 10, 64)
+		switch {
+		case aErr == nil && bErr == nil && an != bn:
+			if an < bn {
+				return -1
+			}
+			return 1
+		case aErr == nil && bErr != nil:
+			return -1
+		case aErr != nil && bErr == nil:
+			return 1
+		case as[i] != bs[i]:
+			return strings.Compare(as[i], bs[i])
+		}
+	}
+	switch {
+	case len(as) < len(bs):
+		return -1
+	case len(as) > len(bs):
+		return 1
+	}
+	return 0
+}
+
+// comparator is a single condition of a range, such as >=1.2.0.
+type comparator struct {
+	op      string
+	version SemVer
+}
+
+func (c comparator) matches(v SemVer) bool {
+	cmp := v.Compare(c.version)
+	switch c.op {
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	}
+	return cmp == 0
+}
+
+// SemVerRange is a set of alternatives, each a set of comparators that must
+// all match.
+type SemVerRange [][]comparator
+
+// ParseSemVerRange parses a range in the npm syntax: exact versions,
+// comparisons (>=1.2.0 <2.0.0), caret (^2.1) and tilde (~2.1.3) ranges, x
+// ranges (2.x, *) and alternatives separated by ||.
+func ParseSemVerRange(s string) (SemVerRange, error) {
+	var r SemVerRange
+	for _, alternative := range strings.Split(s, "||") {
+		var set []comparator
+		for _, term := range strings.Fields(alternative) {
+			comparators, err := parseRangeTerm(term)
+			if err != nil {
+				return nil, err
+			}
+			set = append(set, comparators...)
+		}
+		r = append(r, set)
+	}
+	return r, nil
+}
+
+// Matches returns true if the version satisfies any alternative of the range.
+// Prereleases only match alternatives that mention a prerelease of the same
+// version, so ranges don't resolve to them by accident.
+func (r SemVerRange) Matches(v SemVer) bool {
+	for _, set := range r {
+		matches := true
+		allowsPrerelease := v.Prerelease == ""
+		for _, c := range set {
+			if !c.matches(v) {
+				matches = false
+				break
+			}
+			if c.version.Prerelease != "" && c.version.Major == v.Major && c.version.Minor == v.Minor && c.version.Patch == v.Patch {
+				allowsPrerelease = true
+			}
+		}
+		if matches && allowsPrerelease {
+			return true
+		}
+	}
+	return false
+}
+
+func parseRangeTerm(term string) ([]comparator, error) {
+	op := ""
+	for _, prefix := range []string{">=", "<=", ">", "<", "=", "^", "~"} {
+		if strings.HasPrefix(term, prefix) {
+			op, term = prefix, strings.TrimPrefix(term, prefix)
+			break
+		}
+	}
+
+	// Partial versions such as 2, 2.1 and 2.x leave the rest unconstrained
+	partial, _, _ := strings.Cut(strings.TrimPrefix(term, "v"), "-")
+	parts := strings.Split(partial, ".")
+	given := 0
+	for _, part := range parts {
+		if part == "x" || part == "X" || part == "*" {
+			break
+		}
+		given++
+	}
+	if given == 3 {
+		v, err := ParseSemVer(term)
+		if err != nil {
+			return nil, err
+		}
+		return expandRange(op, v, 3), nil
+	}
+	if len(parts) > 3 {
+		return nil, fmt.Errorf("invalid version range %q", term)
+	}
+
+	var v SemVer
+	numbers := []*uint64{&v.Major, &v.Minor, &v.Patch}
+	for i := 0; i < given; i++ {
+		n, err := strconv.ParseUint(parts[i], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version range %q", term)
+		}
+		*numbers[i] = n
+	}
+	return expandRange(op, v, given), nil
+}
+
+// expandRange turns an operator applied to a version with given leading
+// numbers into plain comparators.
+func expandRange(op string, v SemVer, given int) []comparator {
+	next := func(level int) SemVer {
+		switch level {
+		case 0:
+			return SemVer{Major: v.Major + 1}
+		case 1:
+			return SemVer{Major: v.Major, Minor: v.Minor + 1}
+		}
+		return SemVer{Major: v.Major, Minor: v.Minor, Patch: v.Patch + 1}
+	}
+	upper := func(level int) []comparator {
+		return []comparator{{">=", v}, {"<", withPrerelease(next(level))}}
+	}
+
+	if given == 0 {
+		return nil
+	}
+
+	switch op {
+	case "^":
+		// The first non-zero number may not change
+		switch {
+		case v.Major > 0 || given == 1:
+			return upper(0)
+		case v.Minor > 0 || given == 2:
+			return upper(1)
+		}
+		return upper(2)
+	case "~":
+		if given == 1 {
+			return upper(0)
+		}
+		return upper(1)
+	case "", "=":
+		if given == 3 {
+			return []comparator{{"=", v}}
+		}
+		return upper(given - 1)
+	case ">":
+		if given == 3 {
+			return []comparator{{">", v}}
+		}
+		return []comparator{{">=", next(given - 1)}}
+	case "<=":
+		if given == 3 {
+			return []comparator{{"<=", v}}
+		}
+		return []comparator{{"<", withPrerelease(next(given - 1))}}
+	}
+	// >= and < only need the missing numbers to be zero
+	return []comparator{{op, v}}
+}
+
+// withPrerelease returns the lowest prerelease of a version, so an upper bound
+// of <2.0.0 also excludes 2.0.0-rc.1.
+func withPrerelease(v SemVer) SemVer {
+	v.Prerelease = "0"
+	return v
+}
+
+// checkSemVer returns an error if a schema's semantic version is invalid, or
+// doesn't follow from the current revision: it must increase when the body
+// changes, and breaking changes need a new major version.
+func checkSemVer(current Schema, exists bool, schema Schema) error {
+	if schema.SemVer == "" {
+		return nil
+	}
+	v, err := ParseSemVer(schema.SemVer)
+	if err != nil {
+		return newError("400", err.Error())
+	}
+	if !exists || current.SemVer == "" {
+		return nil
+	}
+
+	previous, err := ParseSemVer(current.SemVer)
+	if err != nil {
+		return nil
+	}
+	cmp := v.Compare(previous)
+	if cmp < 0 || (cmp == 0 && schema.Body != current.Body) {
+		return newError("409", "version %s must be higher than the current %s", v, previous)
+	}
+	if v.Major == previous.Major {
+		changes, err := DiffSchemas(current.Body, schema.Body)
+		if err != nil {
+			return newError("400", err.Error())
+		}
+		if breaking := BreakingChanges(changes); len(breaking) > 0 {
+			return newError("409", "breaking changes need a new major version: %s", describeChanges(breaking))
+		}
+	}
+	return nil
+}
+
+// Resolve subject: $SCHEMA.RESOLVE.<schema_name>
+func (reg *SchemaRegistry) Resolve(r micro.Request) {
+	name := subjectName(r.Subject())
+
+	schema, err := reg.resolve(name, strings.TrimSpace(string(r.Data())))
+	if err != nil {
+		r.Error(errorCode(err), err.Error(), nil)
+		return
+	}
+
+	r.RespondJSON(schema)
+}
+
+// resolve returns the stored revision of a schema with the highest semantic
+// version in the range. Unregistered schemas don't resolve.
+func (reg *SchemaRegistry) resolve(name string, versionRange string) (Schema, error) {
+	var best Schema
+
+	r, err := ParseSemVerRange(versionRange)
+	if err != nil {
+		return best, newError("400", err.Error())
+	}
+
+	_, exists, err := reg.current(name)
+	if err == nil && !exists {
+		err = newError("404", "Not found")
+	}
+	if err != nil {
+		return best, err
+	}
+
+	candidates, err := reg.storedVersions(name)
+	if err != nil {
+		return best, err
+	}
+
+	var bestVersion SemVer
+	found := false
+	for _, schema := range candidates {
+		v, err := ParseSemVer(schema.SemVer)
+		if err != nil || !r.Matches(v) {
+			continue
+		}
+		if !found || v.Compare(bestVersion) > 0 {
+			best, bestVersion, found = schema, v, true
+		}
+	}
+	if !found {
+		return best, newError("404", "no version of schema %q matches %q", name, versionRange)
+	}
+	return best, nil
+}
+
+// storedVersions returns every revision of a schema that can still be read:
+// its history and, for immutable registries, the stored copies of every
+// version.
+func (reg *SchemaRegistry) storedVersions(name string) ([]Schema, error) {
+	history, err := reg.history(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var schemas []Schema
+	for _, entry := range history {
+		if entry.Schema != nil {
+			schemas = append(schemas, *entry.Schema)
+		}
+	}
+	if !reg.immutable {
+		return schemas, nil
+	}
+
+	watcher, err := reg.kv.Watch(fmt.Sprintf("_version.%s.*", nameToken(name)), nats.IgnoreDeletes())
+	if err != nil {
+		return nil, err
+	}
+	defer watcher.Stop()
+
+	for entry := range watcher.Updates() {
+		if entry == nil {
+			break
+		}
+		var schema Schema
+		if err := json.Unmarshal(entry.Value(), &schema); err != nil {
+			return nil, fmt.Errorf("corrupt stored version %q: %w", entry.Key(), err)
+		}
+		schemas = append(schemas, schema)
+	}
+	return schemas, nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import "testing"
+
+func TestSemVerRange(t *testing.T) {
+	tests := []struct {
+		r       string
+		version string
+		matches bool
+	}{
+		{"^2.1", "2.1.0", true},
+		{"^2.1", "2.9.3", true},
+		{"^2.1", "3.0.0", false},
+		{"^2.1", "2.0.9", false},
+		{"^0.2.1", "0.2.5", true},
+		{"^0.2.1", "0.3.0", false},
+		{"~1.2.3", "1.2.9", true},
+		{"~1.2.3", "1.3.0", false},
+		{"1.x", "1.4.0", true},
+		{"*", "7.0.0", true},
+		{">=1.2.0 <2.0.0", "1.5.0", true},
+		{">=1.2.0 <2.0.0", "2.0.0-rc.1", false},
+		{"^1.0.0 || ^3.0.0", "3.1.0", true},
+		{"^2.0.0", "2.1.0-beta", false},
+		{"2.1.0-beta", "2.1.0-beta", true},
+	}
+
+	for _, test := range tests {
+		r, err := ParseSemVerRange(test.r)
+		if err != nil {
+			t.Fatalf("Expected %q to parse, got %v", test.r, err)
+		}
+		v, err := ParseSemVer(test.version)
+		if err != nil {
+			t.Fatalf("Expected %q to parse, got %v", test.version, err)
+		}
+		if r.Matches(v) != test.matches {
+			t.Errorf("Expected %q matching %q to be %v", test.r, test.version, test.matches)
+		}
+	}
+}
+
+func TestCheckSemVer(t *testing.T) {
+	current := Schema{SemVer: "1.2.0", Body: `{"type": "object", "properties": {"id": {"type": "string"}}}`}
+	breaking := Schema{SemVer: "1.3.0", Body: `{"type": "object", "properties": {"id": {"type": "string"}}, "required": ["id"]}`}
+
+	if err := checkSemVer(current, true, breaking); errorCode(err) != "409" {
+		t.Errorf("Expected breaking changes to need a major version, got %v", err)
+	}
+	breaking.SemVer = "2.0.0"
+	if err := checkSemVer(current, true, breaking); err != nil {
+		t.Errorf("Expected a major version to allow breaking changes, got %v", err)
+	}
+	breaking.SemVer = "1.1.0"
+	if err := checkSemVer(current, true, breaking); errorCode(err) != "409" {
+		t.Errorf("Expected a lower version to be rejected, got %v", err)
+	}
+	if err := checkSemVer(Schema{}, false, Schema{SemVer: "two"}); errorCode(err) != "400" {
+		t.Errorf("Expected an invalid version to be rejected, got %v", err)
+	}
+}
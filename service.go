@@ -0,0 +1,266 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/invopop/jsonschema"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/micro"
+)
+
+// registryService serves a registry's endpoints under its subject prefix.
+type registryService struct {
+	svc  micro.Service
+	subs []*nats.Subscription
+	pool *WorkerPool
+}
+
+// serve adds a micro service with the registry's endpoints, and subscribes to
+// the validation requests in the queue group with the given number of
+// subscriptions, all feeding the same pool of workers.
+func serve(nc *nats.Conn, registry *SchemaRegistry, name, queue string, workers, subscriptions int) (*registryService, error) {
+	svc, err := micro.AddService(nc, micro.Config{
+		Name:        name,
+		Description: "Register and manage schemas. Validate payloads against schemas.",
+		Version:     serviceVersion,
+		// Validations don't go through the service API, see below
+		StatsHandler: registry.EndpointStats,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	reflector := jsonschema.Reflector{
+		DoNotReference: true,
+	}
+
+	schema, err := reflector.Reflect(&Schema{}).MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := registry.prefix
+
+	svc.AddEndpoint("health", withRequestID(registry.Health),
+		micro.WithEndpointSubject(prefix+".HEALTH"))
+
+	svc.AddEndpoint("register", withRequestID(registry.RegisterSchema),
+		micro.WithEndpointSubject(prefix+".REGISTER.*"),
+		micro.WithEndpointSchema(&micro.Schema{
+			Request:  string(schema),
+			Response: string(schema),
+		}))
+
+	svc.AddEndpoint("register_bulk", withRequestID(registry.RegisterBulk),
+		micro.WithEndpointSubject(prefix+".REGISTER_BULK"))
+	svc.AddEndpoint("transaction", withRequestID(registry.Transaction),
+		micro.WithEndpointSubject(prefix+".TRANSACTION"))
+
+	svc.AddEndpoint("list", withRequestID(registry.List),
+		micro.WithEndpointSubject(prefix+".LIST"))
+	svc.AddEndpoint("search", withRequestID(registry.Search),
+		micro.WithEndpointSubject(prefix+".SEARCH"))
+
+	svc.AddEndpoint("get", withRequestID(registry.GetSchema),
+		micro.WithEndpointSubject(prefix+".GET.*"),
+		micro.WithEndpointSchema(&micro.Schema{
+			Response: string(schema),
+		}))
+
+	svc.AddEndpoint("dryrun", withRequestID(registry.DryRun),
+		micro.WithEndpointSubject(prefix+".DRYRUN.*"),
+		micro.WithEndpointSchema(&micro.Schema{
+			Request: string(schema),
+		}))
+
+	svc.AddEndpoint("ndjson", withRequestID(registry.ValidateNDJSON),
+		micro.WithEndpointSubject(prefix+".NDJSON.>"))
+
+	svc.AddEndpoint("try", withRequestID(registry.Try),
+		micro.WithEndpointSubject(prefix+".TRY"))
+
+	svc.AddEndpoint("try_schema", withRequestID(registry.TrySchema),
+		micro.WithEndpointSubject(prefix+".TRY.*"))
+
+	svc.AddEndpoint("infer", withRequestID(registry.Infer),
+		micro.WithEndpointSubject(prefix+".INFER"))
+
+	svc.AddEndpoint("sample", withRequestID(registry.Sample),
+		micro.WithEndpointSubject(prefix+".SAMPLE.*"))
+
+	svc.AddEndpoint("report", withRequestID(registry.Report),
+		micro.WithEndpointSubject(prefix+".REPORT"))
+
+	svc.AddEndpoint("ci_check", withRequestID(registry.CICheck),
+		micro.WithEndpointSubject(prefix+".CI.CHECK"))
+
+	svc.AddEndpoint("get_by_fingerprint", withRequestID(registry.GetByFingerprint),
+		micro.WithEndpointSubject(prefix+".GETBYFP.*"),
+		micro.WithEndpointSchema(&micro.Schema{
+			Response: string(schema),
+		}))
+
+	svc.AddEndpoint("get_by_id", withRequestID(registry.GetByID),
+		micro.WithEndpointSubject(prefix+".GETBYID.*"),
+		micro.WithEndpointSchema(&micro.Schema{
+			Response: string(schema),
+		}))
+
+	svc.AddEndpoint("unregister", withRequestID(registry.UnregisterSchema),
+		micro.WithEndpointSubject(prefix+".UNREGISTER.*"))
+	svc.AddEndpoint("unregister_prefix", withRequestID(registry.UnregisterPrefix),
+		micro.WithEndpointSubject(prefix+".UNREGISTER_PREFIX.*"))
+
+	svc.AddEndpoint("undelete", withRequestID(registry.Undelete),
+		micro.WithEndpointSubject(prefix+".UNDELETE.*"),
+		micro.WithEndpointSchema(&micro.Schema{
+			Response: string(schema),
+		}))
+
+	svc.AddEndpoint("transfer", withRequestID(registry.Transfer),
+		micro.WithEndpointSubject(prefix+".TRANSFER.*"))
+
+	svc.AddEndpoint("lock", withRequestID(registry.Lock),
+		micro.WithEndpointSubject(prefix+".LOCK.*"))
+
+	svc.AddEndpoint("unlock", withRequestID(registry.Unlock),
+		micro.WithEndpointSubject(prefix+".UNLOCK.*"))
+
+	svc.AddEndpoint("failures", withRequestID(registry.GetFailures),
+		micro.WithEndpointSubject(prefix+".FAILURES.*"))
+
+	svc.AddEndpoint("replay", withRequestID(registry.Replay),
+		micro.WithEndpointSubject(prefix+".REPLAY.*"))
+
+	svc.AddEndpoint("asyncapi", withRequestID(registry.GetAsyncAPI),
+		micro.WithEndpointSubject(prefix+".ASYNCAPI"))
+
+	svc.AddEndpoint("docs", withRequestID(registry.Docs),
+		micro.WithEndpointSubject(prefix+".DOCS.*"))
+
+	svc.AddEndpoint("graph", withRequestID(registry.Graph),
+		micro.WithEndpointSubject(prefix+".GRAPH.*"))
+
+	svc.AddEndpoint("debug_stats", withRequestID(registry.DebugStats),
+		micro.WithEndpointSubject(prefix+".DEBUG.STATS"))
+
+	svc.AddEndpoint("maintenance", withRequestID(registry.Maintenance),
+		micro.WithEndpointSubject(prefix+".MAINTENANCE"))
+
+	svc.AddEndpoint("history", withRequestID(registry.GetHistory),
+		micro.WithEndpointSubject(prefix+".HISTORY.*"))
+
+	svc.AddEndpoint("resolve", withRequestID(registry.Resolve),
+		micro.WithEndpointSubject(prefix+".RESOLVE.*"),
+		micro.WithEndpointSchema(&micro.Schema{
+			Response: string(schema),
+		}))
+
+	svc.AddEndpoint("which", withRequestID(registry.Which),
+		micro.WithEndpointSubject(prefix+".WHICH.*"))
+
+	svc.AddEndpoint("deps", withRequestID(registry.Deps),
+		micro.WithEndpointSubject(prefix+".DEPS.*"))
+
+	svc.AddEndpoint("consumer", withRequestID(registry.RegisterConsumer),
+		micro.WithEndpointSubject(prefix+".CONSUMER.*"))
+
+	svc.AddEndpoint("unregister_consumer", withRequestID(registry.UnregisterConsumer),
+		micro.WithEndpointSubject(prefix+".UNREGISTER_CONSUMER.*"))
+
+	svc.AddEndpoint("consumers", withRequestID(registry.GetConsumers),
+		micro.WithEndpointSubject(prefix+".CONSUMERS.*"))
+
+	svc.AddEndpoint("deprecations", withRequestID(registry.GetDeprecations),
+		micro.WithEndpointSubject(prefix+".DEPRECATIONS.*"))
+
+	svc.AddEndpoint("canary", withRequestID(registry.GetCanary),
+		micro.WithEndpointSubject(prefix+".CANARY.*"))
+
+	svc.AddEndpoint("comparison", withRequestID(registry.GetComparison),
+		micro.WithEndpointSubject(prefix+".COMPARISON.*"))
+
+	svc.AddEndpoint("proto", withRequestID(registry.GetProto),
+		micro.WithEndpointSubject(prefix+".PROTO.*"))
+
+	svc.AddEndpoint("convert_proto", withRequestID(registry.ConvertProto),
+		micro.WithEndpointSubject(prefix+".CONVERT.PROTO"))
+
+	svc.AddEndpoint("changelog", withRequestID(registry.GetChangelog),
+		micro.WithEndpointSubject(prefix+".CHANGELOG.*"))
+
+	svc.AddEndpoint("timeline", withRequestID(registry.GetTimeline),
+		micro.WithEndpointSubject(prefix+".TIMELINE.*"))
+
+	svc.AddEndpoint("update", withRequestID(registry.UpdateSchema),
+		micro.WithEndpointSubject(prefix+".UPDATE.*"),
+		micro.WithEndpointSchema(&micro.Schema{
+			Request:  string(schema),
+			Response: string(schema),
+		}))
+
+	svc.AddEndpoint("enum", withRequestID(registry.PutEnum),
+		micro.WithEndpointSubject(prefix+".ENUM.*"))
+
+	svc.AddEndpoint("enums", withRequestID(registry.ListEnums),
+		micro.WithEndpointSubject(prefix+".ENUMS"))
+
+	svc.AddEndpoint("decrypt", withRequestID(registry.Decrypt),
+		micro.WithEndpointSubject(prefix+".DECRYPT.*"))
+
+	svc.AddEndpoint("propose", withRequestID(registry.Propose),
+		micro.WithEndpointSubject(prefix+".PROPOSE.*"),
+		micro.WithEndpointSchema(&micro.Schema{
+			Request: string(schema),
+		}))
+
+	svc.AddEndpoint("proposal", withRequestID(registry.GetProposal),
+		micro.WithEndpointSubject(prefix+".PROPOSAL.*"))
+
+	svc.AddEndpoint("approve", withRequestID(registry.Approve),
+		micro.WithEndpointSubject(prefix+".APPROVE.*"))
+
+	svc.AddEndpoint("reject", withRequestID(registry.Reject),
+		micro.WithEndpointSubject(prefix+".REJECT.*"))
+
+	// Registered for discovery only, the requests are handled by the
+	// subscription below and their stats bridged through the StatsHandler
+	svc.AddEndpoint("validate", micro.HandlerFunc(func(r micro.Request) {}),
+		micro.WithEndpointSubject(registry.validateSubject()))
+
+	// Schema validation needs to have more access to the NATS message, namely the reply subject,
+	// so we need to use a raw subscription instead of the service API.
+	pool := NewWorkerPool(workers, registry.ValidatePayload)
+	service := &registryService{svc: svc, pool: pool}
+	for i := 0; i < subscriptions; i++ {
+		sub, err := nc.QueueSubscribe(registry.validateSubject(), queue, pool.Handle)
+		if err != nil {
+			return nil, err
+		}
+		service.subs = append(service.subs, sub)
+	}
+
+	return service, nil
+}
+
+// stop stops taking new validations, lets the ones already received finish
+// until the deadline and stops the service.
+func (s *registryService) stop(deadline time.Time) error {
+	for _, sub := range s.subs {
+		err := sub.Drain()
+		if err != nil {
+			return err
+		}
+	}
+	for _, sub := range s.subs {
+		for sub.IsValid() && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	if err := s.pool.Wait(deadline); err != nil {
+		return fmt.Errorf("stopping validation: %w", err)
+	}
+
+	return s.svc.Stop()
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/micro"
+)
+
+// LintOff turns a lint rule off.
+const LintOff = "off"
+
+// Settings are the options a registry can change while it serves. They are
+// read from the JSON file given with --settings, whose fields override the
+// flags of the same name, as in:
+//
+//	{"compatibility": "backward", "limits": {"max_payload": 65536}, "lint": {"description": "off"}}
+type Settings struct {
+	Compatibility string `json:"compatibility"`
+	Examples      string `json:"examples"`
+	ErrorDetail   string `json:"error_detail"`
+	Strict        bool   `json:"strict"`
+	Limits        Limits `json:"limits"`
+	// Lint changes the severity of lint rules, to error, warning or off.
+	Lint map[string]string `json:"lint,omitempty"`
+}
+
+// settings are the options a registry currently runs with.
+type settings struct {
+	Settings
+	// errorDetail is the number of violations rejections are answered with
+	// by default, zero for all of them
+	errorDetail int
+	// quotas cap the schemas and validations of namespaces, nil allows
+	// everything
+	quotas *Quotas
+}
+
+func defaultSettings() *settings {
+	return &settings{Settings: Settings{Compatibility: CompatibilityNone, Examples: ExamplesReject, ErrorDetail: ErrorsFull}}
+}
+
+// loadSettings reads the settings file over the flags, and the quotas.
+func loadSettings(cfg Config) (*settings, error) {
+	s := Settings{
+		Compatibility: cfg.Compatibility,
+		Examples:      cfg.Examples,
+		ErrorDetail:   cfg.ErrorDetail,
+		Strict:        cfg.Strict,
+		Limits:        cfg.Limits,
+	}
+	if cfg.SettingsFile != "" {
+		data, err := os.ReadFile(cfg.SettingsFile)
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal(data, &s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid settings: %w", err)
+		}
+	}
+
+	if s.Compatibility != CompatibilityNone && s.Compatibility != CompatibilityBackward {
+		return nil, fmt.Errorf("unknown compatibility mode %q", s.Compatibility)
+	}
+	if s.Examples != ExamplesReject && s.Examples != ExamplesWarn {
+		return nil, fmt.Errorf("unknown examples mode %q", s.Examples)
+	}
+	detail, err := ParseErrorDetail(s.ErrorDetail)
+	if err != nil {
+		return nil, err
+	}
+	for rule, severity := range s.Lint {
+		if severity != LintError && severity != LintWarning && severity != LintOff {
+			return nil, fmt.Errorf("unknown severity %q of lint rule %q, use %s, %s or %s", severity, rule, LintError, LintWarning, LintOff)
+		}
+	}
+
+	quotas, err := LoadQuotas(cfg.QuotasFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return &settings{Settings: s, errorDetail: detail, quotas: quotas}, nil
+}
+
+// settings returns the options the registry currently runs with. They are
+// replaced as a whole on reload, so a request sees either the old or the new
+// ones.
+func (reg *SchemaRegistry) settings() *settings {
+	return reg.opts.Load()
+}
+
+// Reloader reloads the settings and quotas of the registries of an instance,
+// and registers the seed schemas again, without restarting. Subscriptions
+// are left alone, so no validation request is dropped.
+type Reloader struct {
+	cfg Config
+	// registries are those of the instance, the first one is seeded
+	registries []*SchemaRegistry
+}
+
+func NewReloader(cfg Config, registries []*SchemaRegistry) *Reloader {
+	return &Reloader{cfg: cfg, registries: registries}
+}
+
+// Reload loads the settings and quotas of every registry, then seeds the
+// first. Either every registry is reloaded or none is.
+func (rl *Reloader) Reload() error {
+	loaded := make([]*settings, len(rl.registries))
+	for i := range rl.registries {
+		// Every registry has its own validation rates
+		s, err := loadSettings(rl.cfg)
+		if err != nil {
+			return err
+		}
+		loaded[i] = s
+	}
+	for i, reg := range rl.registries {
+		reg.opts.Store(loaded[i])
+	}
+
+	if rl.cfg.SeedDir != "" {
+		schemas, err := LoadSeedDir(rl.cfg.SeedDir)
+		if err != nil {
+			return err
+		}
+		return rl.registries[0].Seed(schemas)
+	}
+	return nil
+}
+
+// Run reloads on SIGHUP. It runs this in a goroutine and takes a context for
+// cancelation.
+func (rl *Reloader) Run(c context.Context) {
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+
+	go func() {
+		defer signal.Stop(hangups)
+
+		for {
+			select {
+			case <-c.Done():
+				return
+			case <-hangups:
+				err := rl.Reload()
+				if err != nil {
+					logger("reload").Error("error reloading configuration", "error", err)
+					continue
+				}
+				logger("reload").Info("Reloaded configuration")
+			}
+		}
+	}()
+}
+
+// Reload subject: $SCHEMA.RELOAD
+//
+// Reloading takes the right to modify every schema.
+func (rl *Reloader) Handle(r micro.Request) {
+	reg := rl.registries[0]
+	if !reg.auth.Allowed("*", nats.Header(r.Headers())) {
+		r.Error("403", "not authorized to reload the configuration", nil)
+		return
+	}
+
+	err := rl.Reload()
+	if err != nil {
+		r.Error("400", err.Error(), nil)
+		return
+	}
+	logger("reload").Info("Reloaded configuration")
+
+	r.RespondJSON(reg.settings().Settings)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadSettings(t *testing.T) {
+	cfg := Config{Compatibility: CompatibilityNone, Examples: ExamplesReject, ErrorDetail: ErrorsFull, Limits: Limits{MaxPayload: 10, MaxSchemaSize: 20}}
+
+	s, err := loadSettings(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Compatibility != CompatibilityNone || s.Limits.MaxPayload != 10 || s.errorDetail != 0 {
+		t.Errorf("Expected the flags without a settings file, got %+v", s)
+	}
+
+	cfg.SettingsFile = filepath.Join(t.TempDir(), "settings.json")
+	os.WriteFile(cfg.SettingsFile, []byte(`{"compatibility": "backward", "error_detail": "compact", "limits": {"max_payload": 5}, "lint": {"description": "off"}}`), 0o644)
+	s, err = loadSettings(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Compatibility != CompatibilityBackward || s.Examples != ExamplesReject || s.errorDetail != 1 {
+		t.Errorf("Expected the settings file to override the flags, got %+v", s)
+	}
+	if s.Limits.MaxPayload != 5 || s.Limits.MaxSchemaSize != 20 {
+		t.Errorf("Expected only the limits in the file to be overridden, got %+v", s.Limits)
+	}
+
+	os.WriteFile(cfg.SettingsFile, []byte(`{"lint": {"description": "fatal"}}`), 0o644)
+	if _, err := loadSettings(cfg); err == nil {
+		t.Errorf("Expected an unknown lint severity to be rejected")
+	}
+}
+
+func TestOverrideSeverities(t *testing.T) {
+	issues := []LintIssue{
+		{Rule: "root-type", Severity: LintError},
+		{Rule: "description", Severity: LintWarning},
+		{Rule: "property-case", Severity: LintWarning},
+	}
+
+	out := overrideSeverities(issues, map[string]string{"description": LintOff, "property-case": LintError})
+	if len(out) != 2 || out[0].Severity != LintError || out[1].Rule != "property-case" || out[1].Severity != LintError {
+		t.Errorf("Expected description to be dropped and property-case to be an error, got %+v", out)
+	}
+}
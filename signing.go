@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/nats-io/nkeys"
+)
+
+// SigningKey lists the public nkeys allowed to sign schemas whose names match
+// Namespace.
+type SigningKey struct {
+	// Namespace is a glob matched against the schema name, e.g. "orders_*".
+	Namespace  string   `json:"namespace"`
+	PublicKeys []string `json:"public_keys"`
+}
+
+// Verifier checks the Ed25519 signatures of schema bodies. A nil Verifier
+// accepts unsigned schemas.
+type Verifier struct {
+	Keys []SigningKey `json:"keys"`
+}
+
+// LoadVerifier reads signing keys from a JSON file. An empty path returns a
+// nil Verifier.
+func LoadVerifier(file string) (*Verifier, error) {
+	if file == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var v Verifier
+	err = json.Unmarshal(data, &v)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range v.Keys {
+		for _, pub := range key.PublicKeys {
+			if _, err := nkeys.FromPublicKey(pub); err != nil {
+				return nil, fmt.Errorf("invalid public key %q: %w", pub, err)
+			}
+		}
+	}
+
+	return &v, nil
+}
+
+// Verify returns an error unless the schema body carries a valid signature
+// from one of the keys registered for its namespace. Schemas outside of any
+// signed namespace are accepted as is.
+func (v *Verifier) Verify(schema Schema) error {
+	if v == nil {
+		return nil
+	}
+
+	var keys []string
+	for _, key := range v.Keys {
+		if ok, _ := path.Match(key.Namespace, schema.Name); ok {
+			keys = append(keys, key.PublicKeys...)
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	if schema.Signature == "" {
+		return fmt.Errorf("schema %q must be signed", schema.Name)
+	}
+	sig, err := decodeSignature(schema.Signature)
+	if err != nil {
+		return fmt.Errorf("invalid signature encoding: %w", err)
+	}
+
+	for _, pub := range keys {
+		kp, err := nkeys.FromPublicKey(pub)
+		if err != nil {
+			continue
+		}
+		if kp.Verify([]byte(schema.Body), sig) == nil {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("signature for schema %q does not match any trusted key", schema.Name)
+}
+
+// decodeSignature accepts both standard and raw URL base64, the latter being
+// what the nk tool prints.
+func decodeSignature(sig string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(sig)
+	if err == nil {
+		return data, nil
+	}
+	return base64.RawURLEncoding.DecodeString(sig)
+}
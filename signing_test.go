@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/nats-io/nkeys"
+)
+
+func TestVerifierVerify(t *testing.T) {
+	kp, err := nkeys.CreateUser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := kp.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v := &Verifier{Keys: []SigningKey{{Namespace: "orders_*", PublicKeys: []string{pub}}}}
+
+	schema := Schema{Name: "orders_created", Body: `{"type": "object"}`}
+	if v.Verify(schema) == nil {
+		t.Errorf("Expected unsigned schema to be rejected")
+	}
+
+	sig, err := kp.Sign([]byte(schema.Body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema.Signature = base64.StdEncoding.EncodeToString(sig)
+	if err := v.Verify(schema); err != nil {
+		t.Errorf("Expected signed schema to verify: %v", err)
+	}
+
+	schema.Body = `{"type": "string"}`
+	if v.Verify(schema) == nil {
+		t.Errorf("Expected tampered body to be rejected")
+	}
+
+	if err := v.Verify(Schema{Name: "payments", Body: "{}"}); err != nil {
+		t.Errorf("Expected schema outside signed namespaces to be accepted: %v", err)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go/micro"
+)
+
+// SchemaStats counts the payloads validated against a schema.
+type SchemaStats struct {
+	Passed uint64 `json:"passed"`
+	Failed uint64 `json:"failed"`
+	// Last is when a payload was last validated against the schema.
+	Last time.Time `json:"last,omitempty"`
+}
+
+// ValidationStats keeps validation counts per schema for this instance.
+type ValidationStats struct {
+	mu      sync.Mutex
+	schemas map[string]SchemaStats
+	// since is when counting started
+	since time.Time
+}
+
+func NewValidationStats() *ValidationStats {
+	return &ValidationStats{schemas: map[string]SchemaStats{}, since: time.Now().UTC()}
+}
+
+// Record counts a validation of a payload against the named schema.
+func (s *ValidationStats) Record(name string, passed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := s.schemas[name]
+	if passed {
+		stats.Passed++
+	} else {
+		stats.Failed++
+	}
+	stats.Last = time.Now().UTC()
+	s.schemas[name] = stats
+}
+
+// Get returns the validation counts of the named schema.
+func (s *ValidationStats) Get(name string) SchemaStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.schemas[name]
+}
+
+// Since returns when the counts started.
+func (s *ValidationStats) Since() time.Time {
+	return s.since
+}
+
+// EndpointStats counts the requests handled outside of the micro service API,
+// in the same shape micro reports its own endpoint stats in.
+type EndpointStats struct {
+	mu    sync.Mutex
+	stats micro.EndpointStats
+}
+
+// Observe counts a handled request and the time it took.
+func (s *EndpointStats) Observe(elapsed time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stats.NumRequests++
+	s.stats.ProcessingTime += elapsed
+	s.stats.AverageProcessingTime = s.stats.ProcessingTime / time.Duration(s.stats.NumRequests)
+}
+
+// Error counts a request that was answered with an error.
+func (s *EndpointStats) Error(description string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stats.NumErrors++
+	s.stats.LastError = description
+}
+
+// Get returns a snapshot of the counters.
+func (s *EndpointStats) Get() micro.EndpointStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.stats
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go/micro"
+)
+
+func TestEndpointStats(t *testing.T) {
+	reg := NewSchemaRegistry(newMemKV(), nil)
+	reg.validations.Observe(time.Second)
+	reg.validations.Observe(3 * time.Second)
+	reg.validations.Error("no schema for subject")
+
+	validate := &micro.Endpoint{EndpointConfig: micro.EndpointConfig{Subject: reg.validateSubject()}}
+	stats, ok := reg.EndpointStats(validate).(micro.EndpointStats)
+	if !ok {
+		t.Fatalf("Expected the validate endpoint to have stats, got %#v", reg.EndpointStats(validate))
+	}
+	if stats.Name != "validate" || stats.Subject != "$SCHEMA.VALIDATE.>" {
+		t.Errorf("Expected the stats to be named after the endpoint, got %q on %q", stats.Name, stats.Subject)
+	}
+	if stats.NumRequests != 2 || stats.NumErrors != 1 || stats.LastError != "no schema for subject" {
+		t.Errorf("Expected 2 requests and 1 error, got %+v", stats)
+	}
+	if stats.ProcessingTime != 4*time.Second || stats.AverageProcessingTime != 2*time.Second {
+		t.Errorf("Expected the processing time to add up, got %v on average %v", stats.ProcessingTime, stats.AverageProcessingTime)
+	}
+
+	get := &micro.Endpoint{EndpointConfig: micro.EndpointConfig{Subject: "$SCHEMA.GET.*"}}
+	if data := reg.EndpointStats(get); data != nil {
+		t.Errorf("Expected other endpoints to keep their own stats, got %#v", data)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+)
+
+// StrictBody returns a schema body that rejects unexpected properties: every
+// object schema with properties, but without additionalProperties, gets
+// "additionalProperties": false. Objects combined with allOf, anyOf, oneOf
+// or a $ref are left alone, since their properties may be declared by the
+// schemas they are combined with.
+func StrictBody(body string) (string, error) {
+	var doc interface{}
+	if err := json.Unmarshal([]byte(body), &doc); err != nil {
+		return "", err
+	}
+	strictNode(doc, false)
+	data, err := json.Marshal(doc)
+	return string(data), err
+}
+
+// strictNode makes a schema and its subschemas strict. combined is true for
+// the schemas of allOf, anyOf and oneOf.
+func strictNode(node interface{}, combined bool) {
+	switch n := node.(type) {
+	case []interface{}:
+		for _, item := range n {
+			strictNode(item, combined)
+		}
+		return
+	case map[string]interface{}:
+		_, hasProperties := n["properties"].(map[string]interface{})
+		if hasProperties && !combined && !hasAnyKey(n, "additionalProperties", "unevaluatedProperties", "allOf", "anyOf", "oneOf", "$ref") {
+			n["additionalProperties"] = false
+		}
+
+		for _, keyword := range []string{"properties", "patternProperties", "dependentSchemas", "definitions", "$defs"} {
+			schemas, _ := n[keyword].(map[string]interface{})
+			for _, schema := range schemas {
+				strictNode(schema, false)
+			}
+		}
+		for _, keyword := range []string{"additionalProperties", "items", "prefixItems", "contains", "not", "if", "then", "else"} {
+			strictNode(n[keyword], false)
+		}
+		for _, keyword := range []string{"allOf", "anyOf", "oneOf"} {
+			strictNode(n[keyword], true)
+		}
+	}
+}
+
+func hasAnyKey(node map[string]interface{}, keys ...string) bool {
+	for _, key := range keys {
+		if _, ok := node[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// strict returns true if unexpected properties in payloads of a schema are
+// rejected, as set on the schema or else on the registry.
+func (reg *SchemaRegistry) strict(schema Schema) bool {
+	if schema.Strict != nil {
+		return *schema.Strict
+	}
+	return reg.settings().Strict
+}
+
+// payloadBody returns the body payloads of a schema are validated against,
+// with its references resolved and made strict if the schema is.
+func (reg *SchemaRegistry) payloadBody(schema Schema) (string, error) {
+	body, err := reg.resolvedBody(schema.Body)
+	if err != nil || !reg.strict(schema) {
+		return body, err
+	}
+	return StrictBody(body)
+}
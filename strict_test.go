@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestStrictBody(t *testing.T) {
+	body := `{
+		"type": "object",
+		"properties": {
+			"id": {"type": "integer"},
+			"customer": {"type": "object", "properties": {"name": {"type": "string"}}},
+			"lines": {"type": "array", "items": {"type": "object", "properties": {"sku": {}}}},
+			"extra": {"type": "object", "properties": {"a": {}}, "additionalProperties": true},
+			"mixed": {"allOf": [{"properties": {"a": {}}}, {"properties": {"b": {}}}]}
+		}
+	}`
+	strict, err := StrictBody(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		payload string
+		valid   bool
+	}{
+		{`{"id": 1}`, true},
+		{`{"id": 1, "note": "x"}`, false},
+		{`{"customer": {"name": "Ada", "age": 36}}`, false},
+		{`{"lines": [{"sku": "A", "qty": 1}]}`, false},
+		{`{"extra": {"a": 1, "b": 2}}`, true},
+		{`{"mixed": {"a": 1, "b": 2}}`, true},
+	} {
+		problems, err := violations([]byte(tc.payload), strict)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if valid := len(problems) == 0; valid != tc.valid {
+			t.Errorf("Expected %s to be valid=%v, got %v", tc.payload, tc.valid, problems)
+		}
+	}
+}
+
+func TestStrict(t *testing.T) {
+	reg := NewSchemaRegistry(nil, nil)
+	on, off := true, false
+
+	if reg.strict(Schema{}) {
+		t.Errorf("Expected schemas not to be strict by default")
+	}
+	if !reg.strict(Schema{Strict: &on}) {
+		t.Errorf("Expected a strict schema to be strict")
+	}
+
+	reg.settings().Strict = true
+	if !reg.strict(Schema{}) || reg.strict(Schema{Strict: &off}) {
+		t.Errorf("Expected schemas to be strict unless they opt out")
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/micro"
+)
+
+// SyncReportSubject is where a report is published after every sync.
+const SyncReportSubject = "$SCHEMA.SYNC.REPORT"
+
+// SyncReport describes the outcome of reconciling the registry with the
+// schema definitions in the sync bucket.
+type SyncReport struct {
+	Time    time.Time `json:"time"`
+	Created []string  `json:"created,omitempty"`
+	Updated []string  `json:"updated,omitempty"`
+	Removed []string  `json:"removed,omitempty"`
+	// Drift lists registered schemas that are not defined in the bucket and
+	// were left in place because pruning is disabled.
+	Drift  []string `json:"drift,omitempty"`
+	Errors []string `json:"errors,omitempty"`
+}
+
+// Syncer keeps the registry in line with schema definition files stored in an
+// Object Store bucket, typically uploaded by CI from a git repository.
+type Syncer struct {
+	reg   *SchemaRegistry
+	store nats.ObjectStore
+	// prune unregisters schemas that are not defined in the bucket
+	prune bool
+
+	mu     sync.Mutex
+	report SyncReport
+}
+
+func NewSyncer(reg *SchemaRegistry, store nats.ObjectStore, prune bool) *Syncer {
+	return &Syncer{
+		reg:   reg,
+		store: store,
+		prune: prune,
+	}
+}
+
+// Run watches the bucket and syncs once its initial contents are known and
+// again on every change. It runs this in a goroutine and takes a context for
+// cancelation.
+func (s *Syncer) Run(c context.Context) error {
+	watcher, err := s.store.Watch()
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		defer watcher.Stop()
+
+		initialized := false
+		for {
+			select {
+			case <-c.Done():
+				return
+			case info, ok := <-watcher.Updates():
+				if !ok {
+					return
+				}
+				if info == nil {
+					initialized = true
+				}
+				if !initialized {
+					continue
+				}
+				s.Sync()
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Sync reconciles the registry with the bucket and publishes the report.
+func (s *Syncer) Sync() SyncReport {
+	report := SyncReport{Time: time.Now().UTC()}
+
+	objects, err := s.store.List()
+	if err != nil && !errors.Is(err, nats.ErrNoObjectsFound) {
+		report.Errors = append(report.Errors, err.Error())
+		return s.finish(report)
+	}
+
+	desired := map[string]bool{}
+	for _, obj := range objects {
+		name, ok := schemaFileName(obj.Name)
+		if !ok {
+			continue
+		}
+
+		data, err := s.store.GetBytes(obj.Name)
+		if err != nil {
+			report.Errors = append(report.Errors, obj.Name+": "+err.Error())
+			continue
+		}
+		schema, err := ParseSchemaFile(name, data)
+		if err != nil {
+			report.Errors = append(report.Errors, obj.Name+": "+err.Error())
+			continue
+		}
+		desired[schema.Name] = true
+
+		action, err := s.reg.apply(schema)
+		if err != nil {
+			report.Errors = append(report.Errors, schema.Name+": "+err.Error())
+			continue
+		}
+		switch action {
+		case "created":
+			report.Created = append(report.Created, schema.Name)
+		case "updated":
+			report.Updated = append(report.Updated, schema.Name)
+		}
+	}
+
+	// Anything registered that isn't defined in the bucket has drifted
+	s.reg.schemasMu.RLock()
+	var unmanaged []string
+	for name := range s.reg.schemas {
+		if !desired[name] {
+			unmanaged = append(unmanaged, name)
+		}
+	}
+	s.reg.schemasMu.RUnlock()
+
+	for _, name := range unmanaged {
+		if !s.prune {
+			report.Drift = append(report.Drift, name)
+			continue
+		}
+		if err := s.reg.unregister(name); err != nil {
+			report.Errors = append(report.Errors, name+": "+err.Error())
+			continue
+		}
+		report.Removed = append(report.Removed, name)
+	}
+
+	return s.finish(report)
+}
+
+func (s *Syncer) finish(report SyncReport) SyncReport {
+	s.mu.Lock()
+	s.report = report
+	s.mu.Unlock()
+
+	logger("sync").Info("Synced schemas", "created", len(report.Created), "updated", len(report.Updated),
+		"removed", len(report.Removed), "drifted", len(report.Drift), "errors", len(report.Errors))
+
+	data, err := json.Marshal(report)
+	if err == nil {
+		err = s.reg.nc.Publish(SyncReportSubject, data)
+	}
+	if err != nil {
+		logger("sync").Error("error publishing sync report", "error", err)
+	}
+
+	return report
+}
+
+// Sync status subject: $SCHEMA.SYNC.STATUS
+func (s *Syncer) Status(r micro.Request) {
+	s.mu.Lock()
+	report := s.report
+	s.mu.Unlock()
+
+	r.RespondJSON(report)
+}
@@ -0,0 +1,61 @@
+package testhelpers
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/codegangsta/schema_registry/client"
+)
+
+func TestClient(t *testing.T) {
+	nc := RunRegistry(t, Schema{
+		Name:    "orders",
+		Subject: "orders.new",
+		Body:    `{"type": "object", "required": ["id"]}`,
+	})
+	c := client.New(nc, 2*time.Second)
+
+	schema, err := c.Get("orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if schema.Name != "orders" || schema.Subject != "orders.new" || schema.Version != 1 {
+		t.Errorf("Expected version 1 of orders, got %+v", schema)
+	}
+
+	var apiErr *client.Error
+	if _, err := c.Get("customers"); !errors.As(err, &apiErr) || apiErr.Code != "404" {
+		t.Errorf("Expected a missing schema to fail with 404, got %v", err)
+	}
+
+	registered, err := c.Register(client.Schema{Name: "customers", Subject: "customers.new", Type: "json", Body: `{"type": "object"}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if registered.Name != "customers" || registered.Revision == 0 {
+		t.Errorf("Expected customers to be registered, got %+v", registered)
+	}
+	if _, err := c.Register(client.Schema{Name: "customers", Subject: "customers.new", Type: "json", Body: `{"type": "object"}`}); !errors.As(err, &apiErr) || apiErr.Code != "409" {
+		t.Errorf("Expected registering customers again to fail with 409, got %v", err)
+	}
+
+	// Payloads are validated against the named schema, and not passed on
+	sub, err := nc.SubscribeSync("orders.new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Validate("orders", []byte(`{"id": 1}`)); err != nil {
+		t.Errorf("Expected the payload to be valid, got %v", err)
+	}
+	var invalid *client.ValidationError
+	if err := c.Validate("orders", []byte(`{}`)); !errors.As(err, &invalid) || len(invalid.Violations) != 1 {
+		t.Errorf("Expected the payload to lack an id, got %v", err)
+	}
+	if err := c.Validate("shipments", []byte(`{}`)); !errors.As(err, &apiErr) || apiErr.Code != "404" {
+		t.Errorf("Expected validating against a missing schema to fail with 404, got %v", err)
+	}
+	if msg, err := sub.NextMsg(100 * time.Millisecond); err == nil {
+		t.Errorf("Expected nothing to be forwarded, got %s", msg.Data)
+	}
+}
@@ -0,0 +1,807 @@
+package testhelpers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// request sends a request to the registry, failing the test if it's answered
+// with an error.
+func request(t *testing.T, nc *nats.Conn, subject string, data string) *nats.Msg {
+	t.Helper()
+
+	msg, err := nc.Request(subject, []byte(data), 2*time.Second)
+	if err != nil {
+		t.Fatalf("requesting %s: %v", subject, err)
+	}
+	if code := msg.Header.Get("Nats-Service-Error-Code"); code != "" {
+		t.Fatalf("requesting %s: %s %s", subject, code, msg.Header.Get("Nats-Service-Error"))
+	}
+	return msg
+}
+
+// replayReport is the response of $SCHEMA.REPLAY.
+type replayReport struct {
+	Replayed int      `json:"replayed"`
+	Failed   int      `json:"failed"`
+	Errors   []string `json:"errors"`
+}
+
+func TestReplayEncrypts(t *testing.T) {
+	nc := RunRegistryArgs(t, []string{"--dead-letter-stream", "SCHEMA_DLQ", "--encryption-keys", "keys"})
+
+	js, err := nc.JetStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The key bucket is created by the registry once it runs
+	var keys nats.KeyValue
+	deadline := time.Now().Add(StartTimeout)
+	for keys == nil {
+		keys, err = js.KeyValue("keys")
+		if err != nil && time.Now().After(deadline) {
+			t.Fatalf("the registry didn't create the key bucket: %v", err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	_, err = keys.Put("customers", []byte(base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", 32)))))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := func(required string) string {
+		data, _ := json.Marshal(map[string]interface{}{
+			"subject":        "customers.*",
+			"type":           "json",
+			"encryption_key": "customers",
+			"body":           `{"type": "object", "properties": {"email": {"type": "string", "x-encrypt": true}}, "required": [` + required + `]}`,
+		})
+		return string(data)
+	}
+	// The keyring follows the bucket, so the key may not be known right away
+	for {
+		msg, err := nc.Request("$SCHEMA.REGISTER.customers", []byte(body(`"id"`)), time.Second)
+		if err == nil && msg.Header.Get("Nats-Service-Error-Code") == "" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("registering the schema: %v %s", err, msg.Header.Get("Nats-Service-Error"))
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	// Rejected for lacking an id, and dead-lettered
+	msg, err := nc.Request("$SCHEMA.VALIDATE.customers.new", []byte(`{"email": "alice@example.com"}`), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := msg.Header.Get("Nats-Service-Error-Code"); code != "400" {
+		t.Fatalf("Expected a customer without id to be rejected, got %q", code)
+	}
+
+	request(t, nc, "$SCHEMA.UPDATE.customers", body(""))
+
+	sub, err := nc.SubscribeSync("customers.*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+
+	var report replayReport
+	// The cache follows the update, until then the message still fails
+	for report.Replayed == 0 {
+		msg = request(t, nc, "$SCHEMA.REPLAY.customers", "")
+		if err := json.Unmarshal(msg.Data, &report); err != nil {
+			t.Fatal(err)
+		}
+		if report.Replayed == 0 && time.Now().After(deadline) {
+			t.Fatalf("Expected the message to be replayed, got %+v", report)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	forwarded, err := sub.NextMsg(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(forwarded.Data), "alice@example.com") || !strings.Contains(string(forwarded.Data), `"enc:customers:`) {
+		t.Errorf("Expected the replayed email to be encrypted, got %s", forwarded.Data)
+	}
+	if fields := forwarded.Header.Get("Schema-Encrypted"); fields != "/email" {
+		t.Errorf("Expected the encrypted fields to be listed, got %q", fields)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	nc := RunRegistryArgs(t, []string{"--dead-letter-stream", "SCHEMA_DLQ"}, Schema{
+		Name:    "orders",
+		Subject: "orders.*",
+		Body:    `{"type": "object", "required": ["id", "amount"]}`,
+	})
+
+	for _, payload := range []string{`{"id": 1}`, `{"amount": 1}`} {
+		msg, err := nc.Request("$SCHEMA.VALIDATE.orders.new", []byte(payload), time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code := msg.Header.Get("Nats-Service-Error-Code"); code != "400" {
+			t.Fatalf("Expected %s to be rejected, got %q", payload, code)
+		}
+	}
+
+	request(t, nc, "$SCHEMA.UPDATE.orders", `{"subject": "orders.*", "type": "json", "body": "{\"type\": \"object\", \"required\": [\"id\"]}"}`)
+
+	sub, err := nc.SubscribeSync("orders.*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+
+	// The cache follows the update, until then both messages still fail
+	var report replayReport
+	deadline := time.Now().Add(StartTimeout)
+	for report.Replayed == 0 {
+		msg := request(t, nc, "$SCHEMA.REPLAY.orders", "")
+		if err := json.Unmarshal(msg.Data, &report); err != nil {
+			t.Fatal(err)
+		}
+		if report.Replayed == 0 && time.Now().After(deadline) {
+			t.Fatalf("Expected a message to be replayed, got %+v", report)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if report.Replayed != 1 || report.Failed != 1 || len(report.Errors) != 1 {
+		t.Errorf("Expected the order with an id to be replayed and the other to fail, got %+v", report)
+	}
+	forwarded, err := sub.NextMsg(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(forwarded.Data) != `{"id": 1}` {
+		t.Errorf("Expected the order with an id to be forwarded, got %s", forwarded.Data)
+	}
+
+	// Failing messages stay for the next replay
+	msg := request(t, nc, "$SCHEMA.REPLAY.orders", "")
+	report = replayReport{}
+	if err := json.Unmarshal(msg.Data, &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Replayed != 0 || report.Failed != 1 {
+		t.Errorf("Expected the order without id to be kept, got %+v", report)
+	}
+
+	// Errors come with the report of what was replayed
+	js, err := nc.JetStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := js.DeleteStream("SCHEMA_DLQ"); err != nil {
+		t.Fatal(err)
+	}
+	msg, err = nc.Request("$SCHEMA.REPLAY.orders", nil, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := msg.Header.Get("Nats-Service-Error-Code"); code != "500" {
+		t.Errorf("Expected replaying without a stream to fail, got %q", code)
+	}
+	if err := json.Unmarshal(msg.Data, &report); err != nil {
+		t.Errorf("Expected the error to carry the report, got %q: %v", msg.Data, err)
+	}
+}
+
+func TestReplayWithoutDeadLetterStream(t *testing.T) {
+	nc := RunRegistry(t, Schema{Name: "orders", Subject: "orders.*", Body: `{"type": "object"}`})
+
+	msg, err := nc.Request("$SCHEMA.REPLAY.orders", nil, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := msg.Header.Get("Nats-Service-Error-Code"); code != "409" {
+		t.Errorf("Expected replaying without a dead-letter stream to fail, got %q", code)
+	}
+}
+
+func TestBucketTTLKeepsInternalKeys(t *testing.T) {
+	nc := RunRegistryArgs(t, []string{"--bucket-ttl", "1h"}, Schema{Name: "orders", Subject: "orders.*", Body: `{"type": "object"}`})
+
+	js, err := nc.JetStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := js.StreamInfo("KV_schema_registry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Config.MaxAge != 0 {
+		t.Errorf("Expected the bucket's entries not to expire, got a max age of %v", info.Config.MaxAge)
+	}
+}
+
+func TestMirror(t *testing.T) {
+	upstream := RunRegistry(t, Schema{Name: "orders", Subject: "orders.*", Body: `{"type": "object"}`})
+	mirror := RunRegistryArgs(t, []string{"--mirror", upstream.ConnectedUrl()})
+
+	// mirrored waits until the mirror serves a schema with the given body
+	mirrored := func(name, body string) {
+		t.Helper()
+		deadline := time.Now().Add(StartTimeout)
+		for {
+			msg, err := mirror.Request("$SCHEMA.GET."+name, nil, time.Second)
+			if err == nil && strings.Contains(string(msg.Data), body) {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("Expected %s to be mirrored with %s", name, body)
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+	}
+
+	// From the snapshot
+	mirrored("orders", `{\"type\": \"object\"}`)
+
+	// From events
+	request(t, upstream, "$SCHEMA.REGISTER.customers", `{"subject": "customers.*", "type": "json", "body": "{\"type\": \"array\"}"}`)
+	mirrored("customers", `{\"type\": \"array\"}`)
+	request(t, upstream, "$SCHEMA.UPDATE.orders", `{"subject": "orders.*", "type": "json", "body": "{\"type\": \"object\", \"required\": [\"id\"]}"}`)
+	mirrored("orders", `\"required\"`)
+
+	msg, err := mirror.Request("$SCHEMA.REGISTER.invoices", []byte(`{"subject": "invoices.*", "type": "json", "body": "{}"}`), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Header.Get("Nats-Service-Error-Code") == "" {
+		t.Errorf("Expected the mirror to be read-only")
+	}
+}
+
+func TestPublishJetStream(t *testing.T) {
+	nc := RunRegistry(t, Schema{Name: "orders", Subject: "orders.*", Body: `{"type": "object"}`})
+
+	js, err := nc.JetStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = js.AddStream(&nats.StreamConfig{Name: "ORDERS", Subjects: []string{"orders.>"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	request(t, nc, "$SCHEMA.UPDATE.orders", `{"subject": "orders.*", "type": "json", "jetstream": true, "body": "{\"type\": \"object\"}"}`)
+	request(t, nc, "$SCHEMA.REGISTER.invoices", `{"subject": "invoices.*", "type": "json", "jetstream": true, "body": "{\"type\": \"object\"}"}`)
+
+	// The stream's PubAck is relayed, so JetStream clients can publish
+	ack, err := js.Publish("$SCHEMA.VALIDATE.orders.new", []byte(`{"id": 1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ack.Stream != "ORDERS" || ack.Sequence != 1 {
+		t.Errorf("Expected the message to be stored in ORDERS, got %+v", ack)
+	}
+	for i := 0; i < 2; i++ {
+		ack, err = js.Publish("$SCHEMA.VALIDATE.orders.new", []byte(`{"id": 2}`), nats.MsgId("order-2"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !ack.Duplicate || ack.Sequence != 2 {
+		t.Errorf("Expected the retried message to be deduplicated, got %+v", ack)
+	}
+
+	// Errors of the stream keep their code
+	msg := nats.NewMsg("$SCHEMA.VALIDATE.orders.new")
+	msg.Data = []byte(`{"id": 3}`)
+	msg.Header.Set(nats.ExpectedLastSeqHdr, "1")
+	resp, err := nc.RequestMsg(msg, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body struct {
+		Error nats.APIError `json:"error"`
+	}
+	if err := json.Unmarshal(resp.Data, &body); err != nil {
+		t.Fatal(err)
+	}
+	if code := resp.Header.Get("Nats-Service-Error-Code"); code != "400" || body.Error.Code != 400 {
+		t.Errorf("Expected a wrong last sequence to fail with 400, got %q and %+v", code, body.Error)
+	}
+	_, err = js.Publish("$SCHEMA.VALIDATE.orders.new", []byte(`{"id": 3}`), nats.ExpectLastSequence(1))
+	var apiErr *nats.APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != 400 {
+		t.Errorf("Expected JetStream clients to get the stream's error, got %v", err)
+	}
+
+	// Without a stream there's no one to answer
+	resp, err = nc.Request("$SCHEMA.VALIDATE.invoices.new", []byte(`{"id": 1}`), 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := resp.Header.Get("Nats-Service-Error-Code"); code != "503" {
+		t.Errorf("Expected publishing without a stream to fail with 503, got %q: %s", code, resp.Header.Get("Nats-Service-Error"))
+	}
+}
+
+func TestValidationErrors(t *testing.T) {
+	nc := RunRegistry(t, Schema{Name: "orders", Subject: "orders.*", Body: `{"type": "object", "required": ["id"]}`})
+
+	tests := []struct {
+		subject string
+		code    string
+	}{
+		{"$SCHEMA.VALIDATE.orders.new", "400"},
+		{"$SCHEMA.VALIDATE.invoices.new", "404"},
+	}
+	for _, test := range tests {
+		msg := nats.NewMsg(test.subject)
+		msg.Data = []byte(`{"amount": 1}`)
+		msg.Header.Set("Schema-Request-Id", "req-"+test.code)
+		resp, err := nc.RequestMsg(msg, time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code := resp.Header.Get("Nats-Service-Error-Code"); code != test.code {
+			t.Errorf("Expected %s to fail with %s, got %q", test.subject, test.code, code)
+		}
+		description := resp.Header.Get("Nats-Service-Error")
+		if description == "" {
+			t.Errorf("Expected %s to fail with a description", test.subject)
+		}
+		if id := resp.Header.Get("Schema-Request-Id"); id != "req-"+test.code {
+			t.Errorf("Expected the request ID to be echoed, got %q", id)
+		}
+		var body struct {
+			Error nats.APIError `json:"error"`
+		}
+		if err := json.Unmarshal(resp.Data, &body); err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(body.Error.Code) != test.code || body.Error.Description != description {
+			t.Errorf("Expected the body to hold the same error, got %+v", body.Error)
+		}
+	}
+
+	// Validations are counted in the stats of the validate endpoint
+	resp, err := nc.Request("$SRV.STATS.schema_registry", nil, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stats struct {
+		Endpoints []struct {
+			Name string          `json:"name"`
+			Data json.RawMessage `json:"data"`
+		} `json:"endpoints"`
+	}
+	if err := json.Unmarshal(resp.Data, &stats); err != nil {
+		t.Fatal(err)
+	}
+	var validate struct {
+		NumRequests int    `json:"num_requests"`
+		NumErrors   int    `json:"num_errors"`
+		LastError   string `json:"last_error"`
+	}
+	for _, endpoint := range stats.Endpoints {
+		if endpoint.Name == "validate" {
+			if err := json.Unmarshal(endpoint.Data, &validate); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if validate.NumRequests != 2 || validate.NumErrors != 2 || validate.LastError == "" {
+		t.Errorf("Expected both validations to be counted as errors, got %+v", validate)
+	}
+}
+
+// eventually fails the test unless done returns true before the start
+// timeout.
+func eventually(t *testing.T, what string, done func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(StartTimeout)
+	for !done() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %s", what)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestWorkQueue(t *testing.T) {
+	nc := RunRegistryArgs(t, []string{"--work-queue", "SCHEMA_WQ"}, Schema{
+		Name:    "orders",
+		Subject: "orders.*",
+		Body:    `{"type": "object", "required": ["id"]}`,
+	})
+	request(t, nc, "$SCHEMA.UPDATE.orders", `{"subject": "orders.*", "type": "json", "jetstream": true, "body": "{\"type\": \"object\", \"required\": [\"id\"]}"}`)
+
+	js, err := nc.JetStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs := func(stream string) uint64 {
+		info, err := js.StreamInfo(stream)
+		if err != nil {
+			return 0
+		}
+		return info.State.Msgs
+	}
+
+	// Producers get the PubAck of the work queue
+	ack, err := js.Publish("$SCHEMA.VALIDATE.orders.new", []byte(`{"id": 1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ack.Stream != "SCHEMA_WQ" {
+		t.Errorf("Expected the message to be stored in the work queue, got %+v", ack)
+	}
+
+	// Without a stream for orders the message can't be forwarded, and is
+	// delivered again until it is
+	eventually(t, "the message to be delivered again", func() bool {
+		info, err := js.ConsumerInfo("SCHEMA_WQ", "schema_registry")
+		return err == nil && info.NumRedelivered > 0
+	})
+	if n := msgs("SCHEMA_WQ"); n != 1 {
+		t.Errorf("Expected the message to stay in the work queue, got %d messages", n)
+	}
+	_, err = js.AddStream(&nats.StreamConfig{Name: "ORDERS", Subjects: []string{"orders.>"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "the message to be forwarded and acknowledged", func() bool {
+		return msgs("ORDERS") == 1 && msgs("SCHEMA_WQ") == 0
+	})
+
+	// Rejected messages are acknowledged too, and recorded
+	_, err = js.Publish("$SCHEMA.VALIDATE.orders.new", []byte(`{"amount": 1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var failures []struct {
+		Error string `json:"error"`
+	}
+	eventually(t, "the rejection to be recorded", func() bool {
+		msg := request(t, nc, "$SCHEMA.FAILURES.orders", "")
+		return json.Unmarshal(msg.Data, &failures) == nil && len(failures) == 1
+	})
+	eventually(t, "the rejected message to be acknowledged", func() bool {
+		return msgs("SCHEMA_WQ") == 0
+	})
+	if n := msgs("ORDERS"); n != 1 {
+		t.Errorf("Expected the rejected message not to be forwarded, got %d messages", n)
+	}
+}
+
+func TestArchive(t *testing.T) {
+	nc := RunRegistryArgs(t, []string{"--archive-stream", "SCHEMA_ARCHIVE", "--bucket-history", "2"}, Schema{
+		Name:    "orders",
+		Subject: "orders.*",
+		Body:    `{"type": "object"}`,
+	})
+
+	js, err := nc.JetStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := js.StreamInfo("SCHEMA_ARCHIVE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Config.Mirror == nil || info.Config.Mirror.Name != "KV_schema_registry" || !info.Config.DenyDelete || !info.Config.DenyPurge {
+		t.Errorf("Expected the archive to mirror the bucket and deny deletes, got %+v", info.Config)
+	}
+
+	// The archive mirrors the bucket asynchronously, revisions the bucket
+	// drops before that are lost
+	archived := func() {
+		t.Helper()
+		eventually(t, "the archive to catch up with the bucket", func() bool {
+			archive, err := js.StreamInfo("SCHEMA_ARCHIVE")
+			if err != nil {
+				return false
+			}
+			bucket, err := js.StreamInfo("KV_schema_registry")
+			return err == nil && archive.State.LastSeq == bucket.State.LastSeq
+		})
+	}
+	for _, field := range []string{"id", "amount", "currency"} {
+		archived()
+		request(t, nc, "$SCHEMA.UPDATE.orders", `{"subject": "orders.*", "type": "json", "body": "{\"type\": \"object\", \"required\": [\"`+field+`\"]}"}`)
+	}
+	archived()
+	// Active schemas are unregistered by echoing a confirmation token
+	msg, err := nc.Request("$SCHEMA.UNREGISTER.orders", nil, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var confirmation struct {
+		Token string `json:"token"`
+	}
+	if err := json.Unmarshal(msg.Data, &confirmation); err != nil {
+		t.Fatal(err)
+	}
+	unregister := nats.NewMsg("$SCHEMA.UNREGISTER.orders")
+	unregister.Header.Set("Schema-Confirm", confirmation.Token)
+	msg, err = nc.RequestMsg(unregister, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := msg.Header.Get("Nats-Service-Error-Code"); code != "" {
+		t.Fatalf("unregistering: %s %s", code, msg.Header.Get("Nats-Service-Error"))
+	}
+
+	// The bucket only keeps 2 revisions, the archive all 5 mutations
+	var history []struct {
+		Operation string `json:"operation"`
+		Schema    *struct {
+			Body string `json:"body"`
+		} `json:"schema"`
+	}
+	eventually(t, "the archive to hold every mutation", func() bool {
+		msg, err := nc.Request("$SCHEMA.HISTORY.orders", nil, time.Second)
+		return err == nil && json.Unmarshal(msg.Data, &history) == nil && len(history) == 5
+	})
+	if history[0].Operation != "put" || history[0].Schema == nil || history[0].Schema.Body != `{"type": "object"}` {
+		t.Errorf("Expected the registration first, got %+v", history[0])
+	}
+	if history[4].Operation != "delete" || history[4].Schema != nil {
+		t.Errorf("Expected the unregistration last, got %+v", history[4])
+	}
+
+	msg, err = nc.Request("$SCHEMA.HISTORY.customers", nil, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := msg.Header.Get("Nats-Service-Error-Code"); code != "404" {
+		t.Errorf("Expected the history of a missing schema to be a 404, got %q", code)
+	}
+}
+
+// syncReport is the report of $SCHEMA.SYNC.STATUS.
+type syncReport struct {
+	Time    time.Time `json:"time"`
+	Created []string  `json:"created"`
+	Updated []string  `json:"updated"`
+	Removed []string  `json:"removed"`
+	Drift   []string  `json:"drift"`
+	Errors  []string  `json:"errors"`
+}
+
+// syncBucket waits for the registry to create the sync bucket.
+func syncBucket(t *testing.T, nc *nats.Conn) nats.ObjectStore {
+	t.Helper()
+	js, err := nc.JetStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var store nats.ObjectStore
+	eventually(t, "the registry to create the sync bucket", func() bool {
+		store, err = js.ObjectStore("schema_defs")
+		return err == nil
+	})
+	return store
+}
+
+// synced waits for a sync report matching done.
+func synced(t *testing.T, nc *nats.Conn, what string, done func(syncReport) bool) syncReport {
+	t.Helper()
+	var report syncReport
+	eventually(t, what, func() bool {
+		msg := request(t, nc, "$SCHEMA.SYNC.STATUS", "")
+		report = syncReport{}
+		return json.Unmarshal(msg.Data, &report) == nil && done(report)
+	})
+	return report
+}
+
+func TestSync(t *testing.T) {
+	nc := RunRegistryArgs(t, []string{"--sync-bucket", "schema_defs"}, Schema{Name: "legacy", Subject: "legacy.*", Body: `{"type": "object"}`})
+	store := syncBucket(t, nc)
+
+	_, err := store.PutString("orders.json", `{"subject": "orders.*", "type": "json", "body": "{\"type\": \"object\"}"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := synced(t, nc, "orders to be created", func(report syncReport) bool {
+		return len(report.Created) == 1 && report.Created[0] == "orders"
+	})
+	if len(report.Drift) != 1 || report.Drift[0] != "legacy" || len(report.Removed) != 0 {
+		t.Errorf("Expected legacy to be reported as drift and kept, got %+v", report)
+	}
+	request(t, nc, "$SCHEMA.GET.legacy", "")
+
+	_, err = store.PutString("orders.json", `{"subject": "orders.*", "type": "json", "body": "{\"type\": \"object\", \"required\": [\"id\"]}"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	synced(t, nc, "orders to be updated", func(report syncReport) bool {
+		return len(report.Updated) == 1 && report.Updated[0] == "orders"
+	})
+	msg := request(t, nc, "$SCHEMA.GET.orders", "")
+	if !strings.Contains(string(msg.Data), `required`) {
+		t.Errorf("Expected the updated definition to be registered, got %s", msg.Data)
+	}
+
+	_, err = store.PutString("invoices.json", `{"subject": "invoices.*", "type": "json", "body": "not json"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	synced(t, nc, "the invalid definition to be reported", func(report syncReport) bool {
+		return len(report.Errors) == 1 && strings.HasPrefix(report.Errors[0], "invoices")
+	})
+}
+
+func TestSyncPrune(t *testing.T) {
+	nc := RunRegistryArgs(t, []string{"--sync-bucket", "schema_defs", "--sync-prune"})
+	store := syncBucket(t, nc)
+
+	// Registered outside of the bucket, after the initial sync
+	synced(t, nc, "the initial sync", func(report syncReport) bool {
+		return !report.Time.IsZero()
+	})
+	eventually(t, "the registry to register legacy", func() bool {
+		msg, err := nc.Request("$SCHEMA.REGISTER.legacy", []byte(`{"subject": "legacy.*", "type": "json", "body": "{}"}`), time.Second)
+		return err == nil && msg.Header.Get("Nats-Service-Error-Code") == ""
+	})
+	eventually(t, "legacy to be served", func() bool {
+		msg, err := nc.Request("$SCHEMA.GET.legacy", nil, time.Second)
+		return err == nil && msg.Header.Get("Nats-Service-Error-Code") == ""
+	})
+
+	_, err := store.PutString("orders.json", `{"subject": "orders.*", "type": "json", "body": "{\"type\": \"object\"}"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := synced(t, nc, "orders to be created", func(report syncReport) bool {
+		return len(report.Created) == 1
+	})
+	if len(report.Removed) != 1 || report.Removed[0] != "legacy" || len(report.Drift) != 0 {
+		t.Errorf("Expected legacy to be unregistered, got %+v", report)
+	}
+	msg, err := nc.Request("$SCHEMA.GET.legacy", nil, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := msg.Header.Get("Nats-Service-Error-Code"); code != "404" {
+		t.Errorf("Expected legacy to be gone, got %q", code)
+	}
+}
+
+func TestProxyRequest(t *testing.T) {
+	nc := RunRegistryArgs(t, []string{"--response-timeout", "200ms"}, Schema{Name: "orders", Subject: "orders.*", Body: `{"type": "object"}`})
+	for _, name := range []string{"prices", "quotes"} {
+		request(t, nc, "$SCHEMA.REGISTER."+name, `{"subject": "`+name+`.*", "type": "json", "body": "{\"type\": \"object\"}", "response_body": "{\"type\": \"object\", \"required\": [\"price\"]}"}`)
+	}
+
+	// The service replies with what it's asked for
+	sub, err := nc.Subscribe("prices.*", func(m *nats.Msg) {
+		var req struct {
+			Reply string `json:"reply"`
+		}
+		json.Unmarshal(m.Data, &req)
+		if req.Reply == "" {
+			return
+		}
+		reply := nats.NewMsg(m.Reply)
+		reply.Data = []byte(req.Reply)
+		reply.Header.Set("Price-Source", "catalog")
+		m.RespondMsg(reply)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+	if err := nc.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := nats.NewMsg("$SCHEMA.VALIDATE.prices.get")
+	msg.Data = []byte(`{"reply": "{\"price\": 10}"}`)
+	msg.Header.Set("Schema-Request-Id", "price-1")
+	resp, err := nc.RequestMsg(msg, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(resp.Data) != `{"price": 10}` || resp.Header.Get("Nats-Service-Error-Code") != "" {
+		t.Errorf("Expected the valid reply to be relayed, got %s %v", resp.Data, resp.Header)
+	}
+	if resp.Header.Get("Schema-Response-Validated") != "true" || resp.Header.Get("Schema-Name") != "prices" {
+		t.Errorf("Expected the reply to be marked as validated against prices, got %v", resp.Header)
+	}
+	if resp.Header.Get("Price-Source") != "catalog" || resp.Header.Get("Schema-Request-Id") != "price-1" {
+		t.Errorf("Expected the service's headers and the request ID, got %v", resp.Header)
+	}
+
+	tests := []struct {
+		subject string
+		data    string
+		code    string
+	}{
+		// Invalid replies are never relayed, failed requests say why
+		{"$SCHEMA.VALIDATE.prices.get", `{"reply": "{\"cost\": 10}"}`, "502"},
+		{"$SCHEMA.VALIDATE.prices.get", `{}`, "504"},
+		{"$SCHEMA.VALIDATE.quotes.get", `{}`, "503"},
+	}
+	for _, test := range tests {
+		resp, err := nc.Request(test.subject, []byte(test.data), time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code := resp.Header.Get("Nats-Service-Error-Code"); code != test.code {
+			t.Errorf("Expected %s to fail with %s, got %q: %s", test.data, test.code, code, resp.Data)
+		}
+		if strings.Contains(string(resp.Data), "cost") {
+			t.Errorf("Expected the invalid reply not to be relayed, got %s", resp.Data)
+		}
+	}
+}
+
+// driftReport is a report of $SCHEMA.DRIFT.STATUS.
+type driftReport struct {
+	Stream    string `json:"stream"`
+	Sampled   int    `json:"sampled"`
+	Unmatched int    `json:"unmatched"`
+	Schemas   map[string]struct {
+		Sampled     int      `json:"sampled"`
+		Violations  int      `json:"violations"`
+		Unvalidated int      `json:"unvalidated"`
+		Errors      []string `json:"errors"`
+	} `json:"schemas"`
+	Error string `json:"error"`
+}
+
+func TestAudit(t *testing.T) {
+	nc := RunRegistryArgs(t, []string{"--audit-streams", "ORDERS", "--audit-interval", "100ms"}, Schema{
+		Name:    "orders",
+		Subject: "orders.new",
+		Body:    `{"type": "object", "required": ["id"]}`,
+	})
+
+	js, err := nc.JetStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = js.AddStream(&nats.StreamConfig{Name: "ORDERS", Subjects: []string{"orders.>"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// One message through the registry, two bypassing it, one of them invalid,
+	// and one no schema is bound to
+	request(t, nc, "$SCHEMA.VALIDATE.orders.new", `{"id": 1}`)
+	for subject, data := range map[string]string{"orders.new": `{}`, "orders.other": `{}`} {
+		if _, err := js.Publish(subject, []byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := js.Publish("orders.new", []byte(`{"id": 2}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	var report driftReport
+	eventually(t, "the stream to be audited", func() bool {
+		var reports []driftReport
+		if err := json.Unmarshal(request(t, nc, "$SCHEMA.DRIFT.STATUS", "").Data, &reports); err != nil {
+			t.Fatal(err)
+		}
+		if len(reports) != 1 || reports[0].Sampled != 4 {
+			return false
+		}
+		report = reports[0]
+		return true
+	})
+
+	stats := report.Schemas["orders"]
+	if report.Stream != "ORDERS" || report.Unmatched != 1 || report.Error != "" {
+		t.Errorf("Expected one unmatched message in ORDERS, got %+v", report)
+	}
+	if stats.Sampled != 3 || stats.Violations != 1 || stats.Unvalidated != 2 || len(stats.Errors) != 1 {
+		t.Errorf("Expected one violation and two unvalidated messages of orders, got %+v", stats)
+	}
+}
@@ -0,0 +1,168 @@
+// Package testhelpers runs the schema registry for integration tests of the
+// services using it, without docker-compose. NATS with JetStream runs in
+// process, and the registry runs as a child process of the test from the
+// schema_registry binary, built from this repository.
+//
+//	func TestOrders(t *testing.T) {
+//		nc := testhelpers.RunRegistry(t, testhelpers.Schema{
+//			Name:    "orders",
+//			Subject: "orders.*",
+//			Body:    `{"type": "object", "required": ["id"]}`,
+//		})
+//		msg, err := nc.Request("$SCHEMA.VALIDATE.orders.new", []byte(`{"id": 1}`), time.Second)
+//		...
+//	}
+package testhelpers
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+// BinaryEnv names the environment variable holding the path of the registry
+// binary. Without it, schema_registry is looked up in the PATH.
+const BinaryEnv = "SCHEMA_REGISTRY_BIN"
+
+// StartTimeout is how long the registry may take to serve its schemas.
+var StartTimeout = 10 * time.Second
+
+// Schema is a schema to seed the registry with.
+type Schema struct {
+	Name    string `json:"name"`
+	Subject string `json:"subject,omitempty"`
+	// Type defaults to json.
+	Type string `json:"type"`
+	Body string `json:"body"`
+}
+
+// RunServer starts a NATS server with JetStream on a random port, storing in
+// a temporary directory. It's shut down when the test ends.
+func RunServer(t testing.TB) *server.Server {
+	t.Helper()
+
+	ns, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      server.RANDOM_PORT,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+		NoLog:     true,
+		NoSigs:    true,
+	})
+	if err != nil {
+		t.Fatalf("starting NATS: %v", err)
+	}
+	go ns.Start()
+	if !ns.ReadyForConnections(5 * time.Second) {
+		ns.Shutdown()
+		t.Fatal("NATS isn't ready for connections")
+	}
+	t.Cleanup(func() {
+		ns.Shutdown()
+		ns.WaitForShutdown()
+	})
+	return ns
+}
+
+// Connect returns a client of the server, closed when the test ends.
+func Connect(t testing.TB, ns *server.Server) *nats.Conn {
+	t.Helper()
+
+	nc, err := nats.Connect(ns.ClientURL())
+	if err != nil {
+		t.Fatalf("connecting to NATS: %v", err)
+	}
+	t.Cleanup(nc.Close)
+	return nc
+}
+
+// RunRegistry starts a NATS server and a registry seeded with schemas, and
+// returns a client once every schema is served. Further flags are passed to
+// the registry with RunRegistryArgs.
+func RunRegistry(t testing.TB, schemas ...Schema) *nats.Conn {
+	t.Helper()
+	return RunRegistryArgs(t, nil, schemas...)
+}
+
+// RunRegistryArgs is RunRegistry with flags for the registry, such as
+// --compatibility backward.
+func RunRegistryArgs(t testing.TB, args []string, schemas ...Schema) *nats.Conn {
+	t.Helper()
+
+	binary := os.Getenv(BinaryEnv)
+	if binary == "" {
+		var err error
+		binary, err = exec.LookPath("schema_registry")
+		if err != nil {
+			t.Fatalf("schema_registry isn't in the PATH, install it or set %s: %v", BinaryEnv, err)
+		}
+	}
+
+	seeds := t.TempDir()
+	for _, schema := range schemas {
+		if schema.Type == "" {
+			schema.Type = "json"
+		}
+		data, err := json.Marshal(schema)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(filepath.Join(seeds, schema.Name+".json"), data, 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ns := RunServer(t)
+	flags := []string{"--server", ns.ClientURL()}
+	// Mirrors can't be seeded, their schemas come from upstream
+	if len(schemas) > 0 {
+		flags = append(flags, "--seed-dir", seeds)
+	}
+	cmd := exec.Command(binary, append(flags, args...)...)
+	logs := &strings.Builder{}
+	cmd.Stdout = logs
+	cmd.Stderr = logs
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("starting the registry: %v", err)
+	}
+	exited := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(exited)
+	}()
+	// Cleanups run last in first, so the registry stops before NATS does
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		<-exited
+	})
+
+	nc := Connect(t, ns)
+	deadline := time.Now().Add(StartTimeout)
+	for _, schema := range schemas {
+		subject := "$SCHEMA.GET." + strings.ReplaceAll(schema.Name, ".", "/")
+		for {
+			msg, err := nc.Request(subject, nil, time.Second)
+			if err == nil && msg.Header.Get("Nats-Service-Error-Code") == "" {
+				break
+			}
+			select {
+			case <-exited:
+				t.Fatalf("the registry exited:\n%s", logs)
+			default:
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("the registry doesn't serve schema %q:\n%s", schema.Name, logs)
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+	}
+	return nc
+}
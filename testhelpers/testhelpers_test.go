@@ -0,0 +1,64 @@
+package testhelpers
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+func TestMain(m *testing.M) {
+	// The registry under test is the one of this repository
+	dir, err := os.MkdirTemp("", "testhelpers")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	binary := filepath.Join(dir, "schema_registry")
+	build := exec.Command("go", "build", "-o", binary, ".")
+	build.Dir = ".."
+	build.Stderr = os.Stderr
+	if err := build.Run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Setenv(BinaryEnv, binary)
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func TestRunRegistry(t *testing.T) {
+	nc := RunRegistry(t, Schema{
+		Name:    "com.acme.order",
+		Subject: "orders.*",
+		Body:    `{"type": "object", "required": ["id"]}`,
+	})
+	// Valid orders are passed on to the service
+	sub, err := nc.Subscribe("orders.*", func(m *nats.Msg) { m.Respond([]byte("ok")) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+
+	msg, err := nc.Request("$SCHEMA.VALIDATE.orders.new", []byte(`{"id": 1}`), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(msg.Data) != "ok" {
+		t.Errorf("Expected a valid order to reach the service, got %q: %s", msg.Data, msg.Header.Get("Nats-Service-Error"))
+	}
+
+	msg, err = nc.Request("$SCHEMA.VALIDATE.orders.new", []byte(`{}`), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := msg.Header.Get("Nats-Service-Error-Code"); code != "400" {
+		t.Errorf("Expected an order without id to be rejected, got %q", code)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"time"
+
+	"github.com/nats-io/nats.go/micro"
+)
+
+// Compatibility verdicts of the revisions of a timeline, against the revision
+// before them.
+const (
+	VerdictInitial    = "initial"
+	VerdictCompatible = "compatible"
+	VerdictBreaking   = "breaking"
+)
+
+// TimelineEvent is a revision of a schema, or its removal, summed up for
+// rendering.
+type TimelineEvent struct {
+	Revision  uint64    `json:"revision"`
+	Time      time.Time `json:"time"`
+	Operation string    `json:"operation"`
+	Version   uint64    `json:"version,omitempty"`
+	SemVer    string    `json:"semver,omitempty"`
+	// Author is who made the revision, as the NATS server reported them.
+	Author    string `json:"author,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+	State     string `json:"state,omitempty"`
+	// PreviousState is set if the revision changed the state.
+	PreviousState string `json:"previous_state,omitempty"`
+	// PreviousSubject is set if the revision changed the subject.
+	PreviousSubject string `json:"previous_subject,omitempty"`
+	// Verdict compares the body with the revision before, Changes and
+	// Breaking count the changes and the breaking ones among them.
+	Verdict  string `json:"verdict,omitempty"`
+	Changes  int    `json:"changes,omitempty"`
+	Breaking int    `json:"breaking,omitempty"`
+}
+
+// Timeline subject: $SCHEMA.TIMELINE.<schema_name>
+func (reg *SchemaRegistry) GetTimeline(r micro.Request) {
+	name := subjectName(r.Subject())
+
+	history, err := reg.history(name)
+	if err != nil {
+		r.Error("500", err.Error(), nil)
+		return
+	}
+	if len(history) == 0 {
+		r.Error("404", "Not found", nil)
+		return
+	}
+
+	r.RespondJSON(Timeline(history))
+}
+
+// Timeline sums up the history of a schema, oldest first. Revisions are
+// compared with the latest revision before them, so one registered again
+// after being unregistered is compared with the one that was.
+func Timeline(history []HistoryEntry) []TimelineEvent {
+	events := make([]TimelineEvent, 0, len(history))
+	var previous *Schema
+	for _, entry := range history {
+		event := TimelineEvent{Revision: entry.Revision, Time: entry.Time, Operation: entry.Operation}
+		schema := entry.Schema
+		if schema == nil {
+			events = append(events, event)
+			continue
+		}
+
+		event.Version = schema.Version
+		event.SemVer = schema.SemVer
+		event.Author = schema.UpdatedBy
+		event.RequestID = schema.RequestID
+		event.State = schema.State
+		event.Verdict = VerdictInitial
+		if previous != nil {
+			if previous.State != schema.State {
+				event.PreviousState = previous.State
+			}
+			if previous.Subject != schema.Subject {
+				event.PreviousSubject = previous.Subject
+			}
+			event.Verdict = ""
+			if changes, err := DiffSchemas(previous.Body, schema.Body); err == nil {
+				event.Changes = len(changes)
+				event.Breaking = len(BreakingChanges(changes))
+				event.Verdict = VerdictCompatible
+				if event.Breaking > 0 {
+					event.Verdict = VerdictBreaking
+				}
+			}
+		}
+
+		events = append(events, event)
+		previous = schema
+	}
+	return events
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimeline(t *testing.T) {
+	v1 := &Schema{Subject: "orders", State: StateActive, Version: 1, UpdatedBy: "alice",
+		Body: `{"type": "object", "properties": {"amount": {"type": "number"}}}`}
+	v2 := &Schema{Subject: "orders", State: StateActive, Version: 2, UpdatedBy: "bob",
+		Body: `{"type": "object", "properties": {"amount": {"type": "number"}, "note": {"type": "string"}}}`}
+	v3 := &Schema{Subject: "orders.v2", State: StateDeprecated, Version: 3,
+		Body: `{"type": "object", "properties": {"amount": {"type": "number"}}, "required": ["amount"]}`}
+	history := []HistoryEntry{
+		{Revision: 1, Operation: "put", Time: time.Now(), Schema: v1},
+		{Revision: 2, Operation: "put", Time: time.Now(), Schema: v2},
+		{Revision: 3, Operation: "delete", Time: time.Now()},
+		{Revision: 4, Operation: "put", Time: time.Now(), Schema: v3},
+	}
+
+	events := Timeline(history)
+	if len(events) != 4 {
+		t.Fatalf("Expected 4 events, got %+v", events)
+	}
+	if events[0].Verdict != VerdictInitial || events[0].Author != "alice" {
+		t.Errorf("Expected the first revision to be initial and by alice, got %+v", events[0])
+	}
+	if events[1].Verdict != VerdictCompatible || events[1].Changes != 1 || events[1].Breaking != 0 {
+		t.Errorf("Expected an added field to be compatible, got %+v", events[1])
+	}
+	if events[2].Operation != "delete" || events[2].Verdict != "" {
+		t.Errorf("Expected a delete event without a verdict, got %+v", events[2])
+	}
+	if e := events[3]; e.Verdict != VerdictBreaking || e.PreviousState != StateActive || e.PreviousSubject != "orders" {
+		t.Errorf("Expected a breaking revision compared with revision 2, got %+v", e)
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/micro"
+)
+
+// Statuses of the schemas of a transaction.
+const (
+	TxCommitted = "committed"
+	TxFailed    = "failed"
+	// TxRolledBack schemas were committed, and restored to their previous
+	// revision, or removed if they were new, when another one failed.
+	TxRolledBack = "rolled_back"
+	// TxSkipped schemas weren't attempted since another one failed first.
+	TxSkipped = "skipped"
+)
+
+// TxResult is the outcome of updating one schema of a transaction.
+type TxResult struct {
+	Name   string  `json:"name"`
+	Status string  `json:"status"`
+	Schema *Schema `json:"schema,omitempty"`
+	Error  string  `json:"error,omitempty"`
+}
+
+// TxReport is the outcome of a transaction, with a result per schema in the
+// order they were requested.
+type TxReport struct {
+	Committed bool       `json:"committed"`
+	Results   []TxResult `json:"results"`
+}
+
+// Transaction subject: $SCHEMA.TRANSACTION
+func (reg *SchemaRegistry) Transaction(r micro.Request) {
+	var schemas []Schema
+	err := json.Unmarshal(r.Data(), &schemas)
+	if err != nil {
+		r.Error("400", err.Error(), nil)
+		return
+	}
+
+	for _, schema := range schemas {
+		if !reg.authorize(r, schema.Name) {
+			return
+		}
+	}
+	if reg.requireApproval {
+		r.Error(errorCode(errApprovalRequired), errApprovalRequired.Error(), nil)
+		return
+	}
+
+	identity := RequestIdentity(nats.Header(r.Headers()))
+	for i := range schemas {
+		schemas[i].UpdatedBy = identity
+		schemas[i].RequestID = RequestID(r)
+	}
+	report, err := reg.transaction(schemas)
+	if err != nil {
+		data, _ := json.Marshal(report)
+		r.Error(errorCode(err), err.Error(), data)
+		return
+	}
+
+	r.RespondJSON(report)
+}
+
+// transaction updates or creates schemas as a unit. Every schema is checked
+// with the new bodies of the others, so a component and its dependents can
+// change together in ways neither could alone. Schemas are committed after
+// those of the transaction they reference. When one fails, those committed
+// before it are restored, so other instances may briefly see them.
+func (reg *SchemaRegistry) transaction(schemas []Schema) (TxReport, error) {
+	report := TxReport{Results: make([]TxResult, len(schemas))}
+	for i, schema := range schemas {
+		report.Results[i] = TxResult{Name: schema.Name, Status: TxSkipped}
+	}
+	if err := checkBulkNames(schemas); err != nil {
+		return report, err
+	}
+
+	order, err := bulkOrder(schemas)
+	if err != nil {
+		return report, err
+	}
+
+	pending := map[string]string{}
+	for _, schema := range schemas {
+		// Protobuf schemas only have a body once it's derived
+		if err := deriveBody(&schema); err != nil {
+			return report, newError(errorCode(err), "schema %q: %v", schema.Name, err)
+		}
+		pending[schema.Name] = schema.Body
+	}
+
+	type commit struct {
+		index    int
+		previous Schema
+		existed  bool
+		schema   Schema
+	}
+	var committed []commit
+	for _, i := range order {
+		name := schemas[i].Name
+		previous, existed, err := reg.current(name)
+		var schema Schema
+		if err == nil {
+			delete(pending, name)
+			schema, err = reg.updatePending(schemas[i], pending)
+		}
+		if err != nil {
+			report.Results[i].Status = TxFailed
+			report.Results[i].Error = err.Error()
+			for _, c := range committed {
+				if c.existed {
+					reg.rollbackUpdate(c.previous, c.schema)
+				} else {
+					reg.rollbackRegister(c.schema)
+				}
+				report.Results[c.index].Status = TxRolledBack
+				report.Results[c.index].Schema = nil
+			}
+			return report, newError(errorCode(err), "schema %q: %v", name, err)
+		}
+		// Schemas committed later may reference it before the watcher caches
+		// it
+		reg.remember(schema)
+
+		report.Results[i].Status = TxCommitted
+		report.Results[i].Schema = &schema
+		committed = append([]commit{{i, previous, existed, schema}}, committed...)
+	}
+
+	report.Committed = true
+	return report, nil
+}
+
+// rollbackUpdate restores the previous revision of a schema updated by a
+// failed transaction, unless it was updated again since.
+func (reg *SchemaRegistry) rollbackUpdate(previous, committed Schema) {
+	previous.Revision = 0
+	previous.Updated = nil
+	data, err := json.Marshal(previous)
+	if err != nil {
+		logger("registry").Error("error rolling back schema", "schema", previous.Name, "error", err)
+		return
+	}
+	rev, err := reg.kv.Update(nameToken(previous.Name), data, committed.Revision)
+	if err != nil {
+		logger("registry").Error("error rolling back schema", "schema", previous.Name, "error", err)
+		return
+	}
+	previous.Revision = rev
+
+	// The version is free again for the next update
+	if reg.immutable {
+		err = reg.kv.Delete(versionKey(committed.Name, committed.Version))
+		if err != nil {
+			logger("registry").Error("error rolling back version", "schema", committed.Name, "version", committed.Version, "error", err)
+		}
+	}
+	if err := reg.indexFingerprint(previous); err != nil {
+		logger("registry").Error("error rolling back schema", "schema", previous.Name, "error", err)
+	}
+
+	reg.remember(previous)
+	reg.publishEvent(EventPut, previous.Name, &previous)
+}
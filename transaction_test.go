@@ -0,0 +1,58 @@
+package main
+
+import "testing"
+
+func TestCheckDependentsPending(t *testing.T) {
+	reg := NewSchemaRegistry(nil, nil)
+	reg.settings().Compatibility = CompatibilityBackward
+	reg.schemas = map[string]Schema{
+		"defs_money": {Name: "defs_money", Body: `{"type": "number"}`},
+		"defs_price": {Name: "defs_price", Body: `{"type": "object", "properties": {"amount": {"$ref": "schema:defs_money"}}}`},
+		"orders":     {Name: "orders", Subject: "orders", Body: `{"type": "object", "properties": {"price": {"$ref": "schema:defs_price"}}}`},
+	}
+
+	money := Schema{Name: "defs_money", Body: `{"type": "string"}`}
+	if err := reg.checkDependents(money, nil); errorCode(err) != "409" {
+		t.Errorf("Expected a breaking change to a dependent to be rejected, got %v", err)
+	}
+
+	// The price stops referencing the money component in the same transaction
+	pending := map[string]string{
+		"defs_price": `{"type": "object", "properties": {"amount": {"type": "number"}}}`,
+	}
+	if err := reg.checkDependents(money, pending); err != nil {
+		t.Errorf("Expected the change to be accepted with the pending dependent, got %v", err)
+	}
+}
+
+func TestTransactionInvalid(t *testing.T) {
+	reg := NewSchemaRegistry(nil, nil)
+
+	report, err := reg.transaction([]Schema{{Name: "a"}, {Name: "a"}})
+	if errorCode(err) != "400" {
+		t.Errorf("Expected duplicate names to be rejected, got %v", err)
+	}
+	if len(report.Results) != 2 || report.Results[1].Status != TxSkipped || report.Committed {
+		t.Errorf("Expected nothing to be committed, got %+v", report)
+	}
+}
+
+func TestTransactionRollback(t *testing.T) {
+	kv := newMemKV()
+	reg := NewSchemaRegistry(kv, nil)
+	reg.immutable = true
+
+	report, err := reg.transaction([]Schema{
+		{Name: "address", Subject: "address", Body: `{"type": "object"}`},
+		{Name: "orders", Subject: "orders", Body: `{"type": "unknown"}`},
+	})
+	if err == nil || report.Results[0].Status != TxRolledBack {
+		t.Fatalf("Expected the transaction to be rolled back, got %+v, %v", report, err)
+	}
+
+	for _, key := range []string{nameToken("address"), versionKey("address", 1), idKey(1), fingerprintKey(Fingerprint(`{"type": "object"}`))} {
+		if _, err := kv.Get(key); err == nil {
+			t.Errorf("Expected %s to be removed", key)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/micro"
+)
+
+// Transfer records who a schema revision was transferred from, and by whom.
+type Transfer struct {
+	From string    `json:"from,omitempty"`
+	By   string    `json:"by,omitempty"`
+	Time time.Time `json:"time"`
+}
+
+// TransferRequest names the team a schema is transferred to.
+type TransferRequest struct {
+	Owner string `json:"owner"`
+}
+
+// Transfer subject: $SCHEMA.TRANSFER.<schema_name>
+//
+// Moves a schema to a new owner in a new revision, keeping its history. The
+// authorization rules of the new owner apply from then on.
+func (reg *SchemaRegistry) Transfer(r micro.Request) {
+	name := subjectName(r.Subject())
+
+	var req TransferRequest
+	err := json.Unmarshal(r.Data(), &req)
+	if err != nil {
+		r.Error("400", err.Error(), nil)
+		return
+	}
+
+	if !reg.authorize(r, name) {
+		return
+	}
+	if reg.requireApproval {
+		r.Error(errorCode(errApprovalRequired), errApprovalRequired.Error(), nil)
+		return
+	}
+
+	schema, err := reg.transfer(name, req.Owner, RequestIdentity(nats.Header(r.Headers())), RequestID(r))
+	if err != nil {
+		r.Error(errorCode(err), err.Error(), nil)
+		return
+	}
+
+	r.RespondJSON(schema)
+}
+
+// transfer stores a revision of a schema owned by another team, and announces
+// it with a transfer event.
+func (reg *SchemaRegistry) transfer(name, owner, by, requestID string) (Schema, error) {
+	if owner == "" {
+		return Schema{}, newError("400", "the owner to transfer schema %q to is required", name)
+	}
+
+	schema, exists, err := reg.current(name)
+	if err != nil {
+		return schema, err
+	}
+	if !exists {
+		return schema, newError("404", "Not found")
+	}
+	if schema.Owner == owner {
+		return schema, newError("409", "schema %q is already owned by %q", name, owner)
+	}
+
+	transfer := &Transfer{From: schema.Owner, By: by, Time: time.Now().UTC()}
+	schema.UpdatedBy = by
+	schema.RequestID = requestID
+	updated, err := reg.updateAssigned(schema, nil, func(schema *Schema) {
+		schema.Owner = owner
+		schema.Transfer = transfer
+	})
+	if err != nil {
+		return updated, err
+	}
+
+	logger("transfer").Info("Transferred schema", "schema", name, "revision", updated.Revision, "from", updated.Transfer.From, "to", owner, "by", by)
+	reg.publishEvent(EventTransfer, name, &updated)
+	return updated, nil
+}
+
+// owner returns the team owning a cached schema.
+func (reg *SchemaRegistry) owner(name string) string {
+	reg.schemasMu.RLock()
+	defer reg.schemasMu.RUnlock()
+	return reg.schemas[name].Owner
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go/micro"
+)
+
+func TestTransfer(t *testing.T) {
+	reg := NewSchemaRegistry(newMemKV(), nil)
+	if _, err := reg.register(Schema{Name: "refunds", Subject: "refunds.*", Body: `{"type": "object"}`}); err != nil {
+		t.Fatal(err)
+	}
+
+	schema, err := reg.transfer("refunds", "orders", "alice@ORDERS", "")
+	if err != nil {
+		t.Fatalf("Expected the transfer to succeed, got %v", err)
+	}
+	if schema.Owner != "orders" || schema.Transfer == nil || schema.Transfer.From != "" || schema.Transfer.By != "alice@ORDERS" {
+		t.Errorf("Expected the schema to be transferred to orders by alice, got %q, %+v", schema.Owner, schema.Transfer)
+	}
+
+	schema, err = reg.transfer("refunds", "payments", "bob@ORDERS", "")
+	if err != nil {
+		t.Fatalf("Expected the transfer to succeed, got %v", err)
+	}
+	if schema.Owner != "payments" || schema.Transfer.From != "orders" {
+		t.Errorf("Expected the schema to be transferred from orders to payments, got %+v", schema)
+	}
+
+	if _, err := reg.transfer("refunds", "payments", "", ""); errorCode(err) != "409" {
+		t.Errorf("Expected a transfer to the current owner to fail with 409, got %v", err)
+	}
+	if _, err := reg.transfer("customers", "payments", "", ""); errorCode(err) != "404" {
+		t.Errorf("Expected a transfer of a missing schema to fail with 404, got %v", err)
+	}
+	if _, err := reg.transfer("refunds", "", "", ""); errorCode(err) != "400" {
+		t.Errorf("Expected a transfer without an owner to fail with 400, got %v", err)
+	}
+}
+
+func TestTransferIgnoresBodies(t *testing.T) {
+	reg := NewSchemaRegistry(newMemKV(), nil)
+	schema, err := reg.register(Schema{Name: "refunds", Subject: "refunds.*", Body: `{"type": "object"}`, Owner: "orders", Transfer: &Transfer{From: "payments"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if schema.Owner != "" || schema.Transfer != nil {
+		t.Errorf("Expected the owner and transfer to be ignored on registration, got %q, %+v", schema.Owner, schema.Transfer)
+	}
+
+	if _, err := reg.transfer("refunds", "payments", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	schema, err = reg.update(Schema{Name: "refunds", Subject: "refunds.*", Body: `{"type": "object"}`, Owner: "orders", Transfer: &Transfer{From: "shipping"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if schema.Owner != "payments" || schema.Transfer != nil {
+		t.Errorf("Expected updates to keep the owner and clear the transfer, got %q, %+v", schema.Owner, schema.Transfer)
+	}
+}
+
+func TestTransferAuthorization(t *testing.T) {
+	reg := NewSchemaRegistry(newMemKV(), nil)
+	reg.auth = &Authorizer{Rules: []AuthRule{
+		{Namespace: "refunds", Tokens: []string{"orders-token"}},
+		{Owner: "payments", Tokens: []string{"payments-token"}},
+	}}
+	if _, err := reg.register(Schema{Name: "refunds", Subject: "refunds.*", Body: `{"type": "object"}`}); err != nil {
+		t.Fatal(err)
+	}
+
+	transfer := func(token, owner string) string {
+		req := &recordingRequest{
+			subject: "$SCHEMA.TRANSFER.refunds",
+			headers: micro.Headers{AuthTokenHeader: []string{token}},
+			data:    []byte(`{"owner": "` + owner + `"}`),
+		}
+		reg.Transfer(req)
+		if schema, _, _ := reg.current("refunds"); schema.Owner != "" {
+			reg.remember(schema)
+		}
+		return req.resp.Header.Get(micro.ErrorCodeHeader)
+	}
+
+	if code := transfer("payments-token", "payments"); code != "403" {
+		t.Errorf("Expected the new owner's token to be rejected before the transfer, got %q", code)
+	}
+	if code := transfer("orders-token", "payments"); code != "" {
+		t.Fatalf("Expected the namespace's token to transfer the schema, got %q", code)
+	}
+	if code := transfer("orders-token", "orders"); code != "403" {
+		t.Errorf("Expected the previous team's token to be rejected after the transfer, got %q", code)
+	}
+	if code := transfer("payments-token", "orders"); code != "" {
+		t.Errorf("Expected the owner's token to transfer the schema, got %q", code)
+	}
+
+	reg.requireApproval = true
+	if code := transfer("orders-token", "payments"); code != errorCode(errApprovalRequired) {
+		t.Errorf("Expected transfers to need approval, got %q", code)
+	}
+}
+
+func TestTransferEvent(t *testing.T) {
+	events := make(chan SchemaEvent, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event SchemaEvent
+		body, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &event); err != nil {
+			t.Errorf("Expected a JSON event, got %s", body)
+		}
+		events <- event
+	}))
+	defer server.Close()
+
+	reg := NewSchemaRegistry(newMemKV(), nil)
+	if _, err := reg.register(Schema{Name: "refunds", Subject: "refunds.*", Body: `{"type": "object"}`}); err != nil {
+		t.Fatal(err)
+	}
+	reg.webhooks = &Webhooks{Hooks: []Webhook{{Namespace: "*", URL: server.URL}}}
+	if _, err := reg.transfer("refunds", "payments", "alice@ORDERS", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case event := <-events:
+			if event.Operation != EventTransfer {
+				continue
+			}
+			if event.Name != "refunds" || event.Schema == nil || event.Schema.Owner != "payments" || event.Schema.Transfer == nil {
+				t.Errorf("Expected a transfer event for refunds owned by payments, got %+v", event)
+			}
+			return
+		case <-timeout:
+			t.Fatal("Timed out waiting for the transfer event")
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/nats-io/nats.go/micro"
+)
+
+// TryRequest is a schema body and a payload to validate against it.
+type TryRequest struct {
+	Schema  json.RawMessage `json:"schema"`
+	Payload json.RawMessage `json:"payload"`
+	// Pointer optionally validates the payload against a part of the schema,
+	// like the Schema-Pointer header.
+	Pointer string `json:"pointer,omitempty"`
+}
+
+// TryResult is the verdict of validating a payload.
+type TryResult struct {
+	Valid  bool     `json:"valid"`
+	Errors []string `json:"errors,omitempty"`
+}
+
+// Try subject: $SCHEMA.TRY
+func (reg *SchemaRegistry) Try(r micro.Request) {
+	var req TryRequest
+	err := json.Unmarshal(r.Data(), &req)
+	if err != nil {
+		r.Error("400", err.Error(), nil)
+		return
+	}
+
+	result, err := reg.try(req)
+	if err != nil {
+		r.Error(errorCode(err), err.Error(), nil)
+		return
+	}
+	r.RespondJSON(result)
+}
+
+// try validates a payload against a schema body that isn't registered. The
+// body may reference registered schemas.
+func (reg *SchemaRegistry) try(req TryRequest) (TryResult, error) {
+	var result TryResult
+	if len(req.Schema) == 0 {
+		return result, newError("400", "missing schema")
+	}
+	if err := reg.settings().Limits.CheckSchema(Schema{Body: string(req.Schema)}); err != nil {
+		return result, err
+	}
+	if err := reg.settings().Limits.CheckPayload(req.Payload); err != nil {
+		return result, err
+	}
+	if err := reg.compileBody(string(req.Schema)); err != nil {
+		return result, err
+	}
+
+	body, err := reg.resolvedBody(string(req.Schema))
+	if err == nil {
+		body, err = fragmentSchema(body, req.Pointer)
+	}
+	if err == nil {
+		result.Errors, err = violations(req.Payload, body)
+	}
+	if err != nil {
+		result.Errors = []string{err.Error()}
+	}
+	result.Valid = len(result.Errors) == 0
+	return result, nil
+}
+
+// Try schema subject: $SCHEMA.TRY.<schema_name>
+//
+// Validates the payload against a registered schema, without passing it on
+// to the schema's subject. The Schema-Pointer header works like on
+// $SCHEMA.VALIDATE.
+func (reg *SchemaRegistry) TrySchema(r micro.Request) {
+	result, err := reg.trySchema(subjectName(r.Subject()), r.Data(), r.Headers().Get(SchemaPointerHeader))
+	if err != nil {
+		r.Error(errorCode(err), err.Error(), nil)
+		return
+	}
+	r.RespondJSON(result)
+}
+
+// trySchema validates a payload against the current revision of a registered
+// schema.
+func (reg *SchemaRegistry) trySchema(name string, payload []byte, pointer string) (TryResult, error) {
+	var result TryResult
+	schema, exists, err := reg.current(name)
+	if err == nil && !exists {
+		err = newError("404", "Not found")
+	}
+	if err != nil {
+		return result, err
+	}
+	if err := reg.settings().Limits.CheckPayload(payload); err != nil {
+		return result, err
+	}
+
+	body, err := reg.payloadBody(schema)
+	if err == nil {
+		body, err = fragmentSchema(body, pointer)
+	}
+	if err == nil {
+		result.Errors, err = violations(payload, body)
+	}
+	if err != nil {
+		result.Errors = []string{err.Error()}
+	}
+	result.Valid = len(result.Errors) == 0
+	return result, nil
+}
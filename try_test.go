@@ -0,0 +1,23 @@
+package main
+
+import "testing"
+
+func TestTry(t *testing.T) {
+	reg := NewSchemaRegistry(nil, nil)
+	schema := []byte(`{"type": "object", "properties": {"customer": {"type": "object", "required": ["id"]}}}`)
+
+	result, err := reg.try(TryRequest{Schema: schema, Payload: []byte(`{"customer": {"id": 1}}`)})
+	if err != nil || !result.Valid {
+		t.Errorf("Expected the payload to be valid, got %+v, %v", result, err)
+	}
+
+	result, err = reg.try(TryRequest{Schema: schema, Payload: []byte(`{}`), Pointer: "/properties/customer"})
+	if err != nil || result.Valid || len(result.Errors) != 1 {
+		t.Errorf("Expected the payload to miss the customer id, got %+v, %v", result, err)
+	}
+
+	_, err = reg.try(TryRequest{Schema: []byte(`{"type": 12}`), Payload: []byte(`{}`)})
+	if errorCode(err) != "400" {
+		t.Errorf("Expected an invalid schema to fail with 400, got %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/micro"
+)
+
+// Undelete subject: $SCHEMA.UNDELETE.<schema_name>
+func (reg *SchemaRegistry) Undelete(r micro.Request) {
+	name := subjectName(r.Subject())
+
+	if !reg.authorize(r, name) {
+		return
+	}
+	if reg.requireApproval {
+		r.Error(errorCode(errApprovalRequired), errApprovalRequired.Error(), nil)
+		return
+	}
+
+	schema, err := reg.undelete(name, RequestIdentity(nats.Header(r.Headers())))
+	if err != nil {
+		r.Error(errorCode(err), err.Error(), nil)
+		return
+	}
+
+	r.RespondJSON(schema)
+}
+
+// undelete restores the last revision of an unregistered schema from its
+// history. The schema keeps its ID and version, as if it was never deleted.
+func (reg *SchemaRegistry) undelete(name, by string) (Schema, error) {
+	if err := reg.writable(); err != nil {
+		return Schema{}, err
+	}
+
+	_, exists, err := reg.current(name)
+	if err != nil {
+		return Schema{}, err
+	}
+	if exists {
+		return Schema{}, newError("409", "schema %q isn't deleted", name)
+	}
+
+	history, err := reg.history(name)
+	if err != nil {
+		return Schema{}, err
+	}
+	schema, ok := lastRevision(history)
+	if !ok {
+		return Schema{}, newError("404", "no revision of schema %q to restore", name)
+	}
+
+	schema.Name = name
+	schema.UpdatedBy = by
+	schema.RequestID = ""
+	schema.Revision = 0
+	schema.LastUsed = nil
+	schema.Updated = nil
+	// The revision it was a canary of is gone
+	schema.Canary = nil
+
+	// The registry may have changed since the schema was deleted, so it goes
+	// through the same checks as registering it again
+	if err := reg.verifier.Verify(schema); err != nil {
+		return schema, newError("403", err.Error())
+	}
+	if !ValidState(schema.State) {
+		return schema, newError("400", "invalid state %q", schema.State)
+	}
+	if err := checkExpiry(&schema); err != nil {
+		return schema, err
+	}
+	if err := reg.checkDefinition(&schema); err != nil {
+		return schema, err
+	}
+	if err := checkSemVer(Schema{}, false, schema); err != nil {
+		return schema, err
+	}
+	if err := reg.settings().quotas.CheckSchemas(name, reg.countSchemas); err != nil {
+		return schema, err
+	}
+	warnings, err := reg.checkExamples(schema)
+	if err != nil {
+		return schema, err
+	}
+	data, err := json.Marshal(schema)
+	if err != nil {
+		return schema, err
+	}
+
+	// Create succeeds over a deleted key, but not if it was restored meanwhile
+	rev, err := reg.kv.Create(nameToken(name), data)
+	if errors.Is(err, nats.ErrKeyExists) {
+		return schema, newError("409", "schema %q isn't deleted", name)
+	}
+	if err != nil {
+		return schema, err
+	}
+	schema.Revision = rev
+	schema.Warnings = warnings
+
+	reg.publishEvent(EventPut, name, &schema)
+	return schema, nil
+}
+
+// lastRevision returns the schema of the last put in a history.
+func lastRevision(history []HistoryEntry) (Schema, bool) {
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Schema != nil {
+			return *history[i].Schema, true
+		}
+	}
+	return Schema{}, false
+}
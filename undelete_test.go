@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/nats-io/nats.go/micro"
+)
+
+func TestLastRevision(t *testing.T) {
+	history := []HistoryEntry{
+		{Revision: 1, Operation: "put", Schema: &Schema{Version: 1}},
+		{Revision: 2, Operation: "put", Schema: &Schema{Version: 2}},
+		{Revision: 3, Operation: "delete"},
+	}
+
+	schema, ok := lastRevision(history)
+	if !ok {
+		t.Fatalf("Expected a revision to restore")
+	}
+	if schema.Version != 2 {
+		t.Errorf("Expected version 2, got %d", schema.Version)
+	}
+
+	if _, ok := lastRevision(history[2:]); ok {
+		t.Errorf("Expected no revision to restore from a history of deletes")
+	}
+}
+
+func TestUndelete(t *testing.T) {
+	reg := NewSchemaRegistry(newMemKV(), nil)
+	if _, err := reg.register(Schema{Name: "orders", Subject: "orders", Body: `{"type": "object"}`}); err != nil {
+		t.Fatal(err)
+	}
+	if err := reg.unregister("orders"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The restored revision goes through the checks in effect now
+	reg.settings().Limits.MaxSchemaSize = 10
+	if _, err := reg.undelete("orders", ""); errorCode(err) != "413" {
+		t.Errorf("Expected a schema over the size limit not to be restored, got %v", err)
+	}
+	if _, exists, _ := reg.current("orders"); exists {
+		t.Fatalf("Expected the schema to stay deleted")
+	}
+	reg.settings().Limits.MaxSchemaSize = 0
+
+	reg.requireApproval = true
+	req := &recordingRequest{subject: "$SCHEMA.UNDELETE.orders"}
+	reg.Undelete(req)
+	if code := req.resp.Header.Get(micro.ErrorCodeHeader); code != errorCode(errApprovalRequired) {
+		t.Errorf("Expected restoring to need approval, got %q", code)
+	}
+	reg.requireApproval = false
+
+	schema, err := reg.undelete("orders", "alice@ORDERS")
+	if err != nil {
+		t.Fatalf("Expected the schema to be restored, got %v", err)
+	}
+	if schema.Version != 1 || schema.UpdatedBy != "alice@ORDERS" {
+		t.Errorf("Expected version 1 to be restored by alice, got %+v", schema)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/nats-io/nats.go/micro"
+)
+
+// SchemaDryRunHeader set to true lists what a request would remove, without
+// removing it.
+const SchemaDryRunHeader = "Schema-Dry-Run"
+
+// prefixConfirmKey returns the kv key holding the pending confirmation of
+// unregistering the schemas under a prefix.
+func prefixConfirmKey(prefix string) string {
+	return "_confirm_prefix." + nameToken(prefix)
+}
+
+// PrefixReport lists the schemas unregistered under a prefix, or those that
+// would be on a dry run.
+type PrefixReport struct {
+	Prefix  string   `json:"prefix"`
+	DryRun  bool     `json:"dry_run,omitempty"`
+	Schemas []string `json:"schemas"`
+}
+
+// Unregister prefix subject: $SCHEMA.UNREGISTER_PREFIX.<prefix>
+func (reg *SchemaRegistry) UnregisterPrefix(r micro.Request) {
+	prefix := subjectName(r.Subject())
+
+	for _, schema := range reg.underPrefix(prefix) {
+		if !reg.authorize(r, schema.Name) {
+			return
+		}
+	}
+
+	dryRun := r.Headers().Get(SchemaDryRunHeader) == "true"
+	report, confirmation, err := reg.unregisterPrefix(prefix, dryRun, r.Headers().Get(SchemaConfirmHeader))
+	if err != nil {
+		var data []byte
+		if confirmation != nil {
+			data, _ = json.Marshal(confirmation)
+		}
+		r.Error(errorCode(err), err.Error(), data)
+		return
+	}
+
+	r.RespondJSON(report)
+}
+
+// underPrefix returns the cached schemas whose names start with prefix, sorted
+// by name.
+func (reg *SchemaRegistry) underPrefix(prefix string) []Schema {
+	var schemas []Schema
+	for _, schema := range reg.list() {
+		if strings.HasPrefix(schema.Name, prefix) {
+			schemas = append(schemas, schema)
+		}
+	}
+	return schemas
+}
+
+// unregisterPrefix removes every schema whose name starts with prefix, keeping
+// their history like unregistering one does. Nothing is removed if one of them
+// is locked or referenced by a schema outside the prefix. Like unregistering
+// an active schema, removing active schemas must be confirmed with a token.
+func (reg *SchemaRegistry) unregisterPrefix(prefix string, dryRun bool, token string) (PrefixReport, *Confirmation, error) {
+	report := PrefixReport{Prefix: prefix, DryRun: dryRun}
+	if !dryRun {
+		if err := reg.writable(); err != nil {
+			return report, nil, err
+		}
+	}
+
+	schemas := reg.underPrefix(prefix)
+	if len(schemas) == 0 {
+		return report, nil, newError("404", "no schemas under %q", prefix)
+	}
+
+	active := false
+	for _, schema := range schemas {
+		if err := reg.checkLock(schema.Name); err != nil {
+			return report, nil, err
+		}
+		var outside []string
+		for _, dependent := range reg.dependents(schema.Name) {
+			if !strings.HasPrefix(dependent.Name, prefix) {
+				outside = append(outside, dependent.Name)
+			}
+		}
+		if len(outside) > 0 {
+			return report, nil, newError("409", "schema %q is still referenced by %s", schema.Name, strings.Join(outside, ", "))
+		}
+		active = active || schema.State == "" || schema.State == StateActive
+	}
+
+	// Schemas are removed before the schemas they reference
+	order, err := bulkOrder(schemas)
+	if err != nil {
+		return report, nil, err
+	}
+	if dryRun {
+		for i := len(order) - 1; i >= 0; i-- {
+			report.Schemas = append(report.Schemas, schemas[order[i]].Name)
+		}
+		return report, nil, nil
+	}
+
+	if active {
+		what := fmt.Sprintf("the schemas under %q", prefix)
+		confirmation, err := reg.confirm(prefixConfirmKey(prefix), token, what, what+" include active schemas")
+		if err != nil {
+			return report, confirmation, err
+		}
+	}
+
+	for i := len(order) - 1; i >= 0; i-- {
+		name := schemas[order[i]].Name
+		if err := reg.kv.Delete(nameToken(name)); err != nil {
+			return report, nil, err
+		}
+		reg.publishEvent(EventDelete, name, nil)
+		report.Schemas = append(report.Schemas, name)
+	}
+	return report, nil, nil
+}
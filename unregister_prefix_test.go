@@ -0,0 +1,25 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUnderPrefix(t *testing.T) {
+	reg := NewSchemaRegistry(nil, nil)
+	reg.schemas = map[string]Schema{
+		"com.acme.order": {Name: "com.acme.order"},
+		"com.acme.money": {Name: "com.acme.money"},
+		"com.acmecorp.x": {Name: "com.acmecorp.x"},
+		"orders":         {Name: "orders"},
+	}
+
+	names := dependentNames(reg.underPrefix("com.acme."))
+	if !reflect.DeepEqual(names, []string{"com.acme.money", "com.acme.order"}) {
+		t.Errorf("Expected the schemas under the prefix, got %v", names)
+	}
+
+	if _, _, err := reg.unregisterPrefix("billing_", true, ""); errorCode(err) != "404" {
+		t.Errorf("Expected a prefix without schemas to be rejected, got %v", err)
+	}
+}
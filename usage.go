@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// usageSyncInterval is how often instances share when they last used each
+// schema. Writing on every validation would write to the bucket as often as
+// payloads are validated.
+const usageSyncInterval = time.Minute
+
+// usedKey returns the kv key holding when a schema was last used. Like
+// version keys, it never matches the head keys the watcher loads.
+func usedKey(name string) string {
+	return "_used." + nameToken(name)
+}
+
+// UsageLog shares when schemas were last used between registry instances,
+// through the schema bucket. A nil UsageLog only knows about this instance.
+type UsageLog struct {
+	reg *SchemaRegistry
+
+	mu   sync.Mutex
+	last map[string]time.Time
+}
+
+func NewUsageLog(reg *SchemaRegistry) *UsageLog {
+	return &UsageLog{reg: reg, last: map[string]time.Time{}}
+}
+
+// Run syncs the usage once per interval. It runs this in a goroutine and takes
+// a context for cancelation.
+func (u *UsageLog) Run(c context.Context) {
+	go func() {
+		ticker := time.NewTicker(usageSyncInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-c.Done():
+				return
+			case <-ticker.C:
+				u.Sync()
+			}
+		}
+	}()
+}
+
+// Sync stores when this instance last used each schema, unless another
+// instance used it more recently, and learns about the uses of the others.
+func (u *UsageLog) Sync() {
+	for _, schema := range u.reg.list() {
+		shared, err := u.load(schema.Name)
+		if err != nil {
+			logger("usage").Error("error loading last use", "schema", schema.Name, "error", err)
+			continue
+		}
+
+		local := u.reg.stats.Get(schema.Name).Last
+		if local.After(shared) {
+			_, err := u.reg.kv.Put(usedKey(schema.Name), []byte(local.Format(time.RFC3339Nano)))
+			if err != nil {
+				logger("usage").Error("error storing last use", "schema", schema.Name, "error", err)
+				continue
+			}
+			shared = local
+		}
+
+		u.mu.Lock()
+		u.last[schema.Name] = shared
+		u.mu.Unlock()
+	}
+}
+
+func (u *UsageLog) load(name string) (time.Time, error) {
+	entry, err := u.reg.kv.Get(usedKey(name))
+	if errors.Is(err, nats.ErrKeyNotFound) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Parse(time.RFC3339Nano, string(entry.Value()))
+}
+
+// Get returns when any instance last used the named schema, as of the last
+// sync.
+func (u *UsageLog) Get(name string) time.Time {
+	if u == nil {
+		return time.Time{}
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.last[name]
+}
+
+// lastUsed returns when a payload was last validated against the named schema,
+// zero if that isn't known.
+func (reg *SchemaRegistry) lastUsed(name string) time.Time {
+	last := reg.stats.Get(name).Last
+	if shared := reg.usage.Get(name); shared.After(last) {
+		last = shared
+	}
+	return last
+}
+
+// withUsage returns the schema along with when it was last used.
+func (reg *SchemaRegistry) withUsage(schema Schema) Schema {
+	if last := reg.lastUsed(schema.Name); !last.IsZero() {
+		schema.LastUsed = &last
+	}
+	return schema
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestUsageSync(t *testing.T) {
+	kv := newMemKV()
+	reg := NewSchemaRegistry(kv, nil)
+	for _, name := range []string{"orders", "customers", "invoices"} {
+		reg.remember(Schema{Name: name, Subject: name, Body: `{"type": "object"}`})
+	}
+	reg.stats.Record("orders", true)
+	reg.stats.Record("customers", false)
+	local := reg.stats.Get("orders").Last
+
+	// Another instance used customers more recently
+	shared := time.Now().Add(time.Hour).UTC()
+	if _, err := kv.Put(usedKey("customers"), []byte(shared.Format(time.RFC3339Nano))); err != nil {
+		t.Fatal(err)
+	}
+
+	u := NewUsageLog(reg)
+	u.Sync()
+
+	entry, err := kv.Get(usedKey("orders"))
+	if err != nil || string(entry.Value()) != local.Format(time.RFC3339Nano) {
+		t.Errorf("Expected the local use of orders to be stored, got %v", err)
+	}
+	entry, err = kv.Get(usedKey("customers"))
+	if err != nil || string(entry.Value()) != shared.Format(time.RFC3339Nano) {
+		t.Errorf("Expected the more recent use of customers to be kept, got %v", err)
+	}
+	if _, err := kv.Get(usedKey("invoices")); err == nil {
+		t.Errorf("Expected unused schemas not to be stored")
+	}
+	if last := u.Get("customers"); !last.Equal(shared) {
+		t.Errorf("Expected to learn about the other instance's use, got %v", last)
+	}
+
+	reg.usage = u
+	if last := reg.withUsage(Schema{Name: "customers"}).LastUsed; last == nil || !last.Equal(shared) {
+		t.Errorf("Expected customers to be last used at %v, got %v", shared, last)
+	}
+	if last := reg.withUsage(Schema{Name: "orders"}).LastUsed; last == nil || !last.Equal(local) {
+		t.Errorf("Expected orders to be last used at %v, got %v", local, last)
+	}
+	if last := reg.withUsage(Schema{Name: "invoices"}).LastUsed; last != nil {
+		t.Errorf("Expected invoices not to have been used, got %v", last)
+	}
+
+	// Without a usage log only this instance's uses are known
+	reg.usage = nil
+	if last := reg.withUsage(Schema{Name: "customers"}).LastUsed; last == nil || last.Equal(shared) {
+		t.Errorf("Expected customers to be last used locally, got %v", last)
+	}
+}
+
+func TestMirrorSkipsUpstreamUsage(t *testing.T) {
+	kv := newMemKV()
+	m := &Mirror{reg: NewSchemaRegistry(kv, nil)}
+
+	used := time.Now()
+	schema := Schema{Name: "orders", Subject: "orders.*", Body: `{"type": "object"}`, ID: 1, LastUsed: &used}
+	if err := m.apply(SchemaEvent{Operation: EventPut, Name: "orders", Schema: &schema}); err != nil {
+		t.Fatal(err)
+	}
+
+	entry, err := kv.Get(nameToken("orders"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored Schema
+	if err := json.Unmarshal(entry.Value(), &stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored.LastUsed != nil {
+		t.Errorf("Expected upstream usage not to be stored, got %v", stored.LastUsed)
+	}
+}
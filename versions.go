@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+)
+
+// SchemaVersionHeader selects a specific stored version on GET requests.
+const SchemaVersionHeader = "Schema-Version"
+
+// versionKey returns the kv key an immutable copy of a schema version is
+// stored under. Version keys contain dots, so they never match the single token
+// head keys the watcher loads.
+func versionKey(name string, version uint64) string {
+	return fmt.Sprintf("_version.%s.%d", nameToken(name), version)
+}
+
+// storeVersion writes an immutable copy of the schema. It fails if that version
+// was already stored.
+func (reg *SchemaRegistry) storeVersion(schema Schema) error {
+	data, err := json.Marshal(schema)
+	if err != nil {
+		return err
+	}
+
+	_, err = reg.kv.Create(versionKey(schema.Name, schema.Version), data)
+	if errors.Is(err, nats.ErrKeyExists) {
+		return fmt.Errorf("version %d of schema %q already exists", schema.Version, schema.Name)
+	}
+	return err
+}
+
+// loadVersion returns a stored version of a schema.
+func (reg *SchemaRegistry) loadVersion(name string, version string) (Schema, error) {
+	var schema Schema
+
+	v, err := strconv.ParseUint(version, 10, 64)
+	if err != nil {
+		return schema, fmt.Errorf("invalid version %q", version)
+	}
+
+	entry, err := reg.kv.Get(versionKey(name, v))
+	if err != nil {
+		return schema, err
+	}
+
+	err = json.Unmarshal(entry.Value(), &schema)
+	return schema, err
+}
+
+// lastVersion returns the highest version stored for a schema, so a schema
+// registered again after being unregistered continues its version sequence.
+// Versions are read from their own keys, which unlike the history of the head
+// are kept however many revisions the bucket retains.
+func (reg *SchemaRegistry) lastVersion(name string) (uint64, error) {
+	watcher, err := reg.kv.Watch(fmt.Sprintf("_version.%s.*", nameToken(name)), nats.IgnoreDeletes(), nats.MetaOnly())
+	if err != nil {
+		return 0, err
+	}
+	defer watcher.Stop()
+
+	var last uint64
+	for entry := range watcher.Updates() {
+		if entry == nil {
+			break
+		}
+		version, err := strconv.ParseUint(entry.Key()[strings.LastIndex(entry.Key(), ".")+1:], 10, 64)
+		if err != nil {
+			continue
+		}
+		if version > last {
+			last = version
+		}
+	}
+	return last, nil
+}
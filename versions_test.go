@@ -0,0 +1,44 @@
+package main
+
+import "testing"
+
+func TestLastVersion(t *testing.T) {
+	kv := newMemKV()
+	// The bucket keeps only the latest revision of every key
+	kv.history = 1
+	reg := NewSchemaRegistry(kv, nil)
+	reg.immutable = true
+
+	if last, err := reg.lastVersion("orders"); err != nil || last != 0 {
+		t.Errorf("Expected no version of an unknown schema, got %d %v", last, err)
+	}
+
+	_, err := reg.register(Schema{Name: "orders", Subject: "orders", Body: `{"type": "object"}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = reg.update(Schema{Name: "orders", Subject: "orders", Body: `{"type": "object", "required": ["id"]}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Versions of other schemas don't count
+	_, err = reg.register(Schema{Name: "orders_v2", Subject: "orders.v2", Body: `{"type": "object"}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if last, err := reg.lastVersion("orders"); err != nil || last != 2 {
+		t.Errorf("Expected version 2, got %d %v", last, err)
+	}
+
+	if err := reg.unregister("orders"); err != nil {
+		t.Fatal(err)
+	}
+	schema, err := reg.register(Schema{Name: "orders", Subject: "orders", Body: `{"type": "object"}`})
+	if err != nil {
+		t.Fatalf("Expected registering again to continue the versions, got %v", err)
+	}
+	if schema.Version != 3 {
+		t.Errorf("Expected version 3, got %d", schema.Version)
+	}
+}
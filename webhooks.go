@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"time"
+)
+
+// WebhookSignatureHeader carries the hex encoded HMAC-SHA256 of the request
+// body, keyed with the webhook's secret, as sha256=<hex>.
+const WebhookSignatureHeader = "Schema-Webhook-Signature"
+
+// Webhook delivers the events of the schemas whose names match Namespace to
+// an HTTP endpoint.
+type Webhook struct {
+	// Namespace is a glob matched against the schema name, e.g. "orders_*".
+	Namespace string `json:"namespace"`
+	URL       string `json:"url"`
+	// Secret signs deliveries, so receivers can check they came from the
+	// registry. Unsigned when empty.
+	Secret string `json:"secret,omitempty"`
+	// Format is "slack" to post a message to a Slack incoming webhook, or
+	// empty to post the SchemaEvent as is.
+	Format string `json:"format,omitempty"`
+	// Retries is the number of times a failed delivery is retried, 3 when
+	// zero.
+	Retries int `json:"retries,omitempty"`
+}
+
+// Webhooks notifies HTTP endpoints of schema changes. A nil Webhooks delivers
+// nothing.
+type Webhooks struct {
+	Hooks []Webhook `json:"webhooks"`
+
+	client  *http.Client
+	backoff time.Duration
+}
+
+// LoadWebhooks reads webhooks from a JSON file. An empty path returns nil
+// Webhooks.
+func LoadWebhooks(file string) (*Webhooks, error) {
+	if file == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var w Webhooks
+	err = json.Unmarshal(data, &w)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, hook := range w.Hooks {
+		u, err := url.Parse(hook.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return nil, fmt.Errorf("invalid webhook url %q", hook.URL)
+		}
+		if hook.Format != "" && hook.Format != "slack" {
+			return nil, fmt.Errorf("unknown webhook format %q", hook.Format)
+		}
+	}
+
+	w.client = &http.Client{Timeout: 10 * time.Second}
+	w.backoff = time.Second
+	return &w, nil
+}
+
+// Notify delivers an event to every webhook of the schema's namespace. It
+// doesn't wait for the deliveries, which are retried in the background.
+func (w *Webhooks) Notify(event SchemaEvent) {
+	if w == nil {
+		return
+	}
+
+	for _, hook := range w.Hooks {
+		if ok, _ := path.Match(hook.Namespace, event.Name); !ok {
+			continue
+		}
+
+		body, err := webhookBody(hook, event)
+		if err != nil {
+			logger("webhooks").Error("error encoding webhook", "schema", event.Name, "error", err)
+			continue
+		}
+		go w.deliver(hook, body)
+	}
+}
+
+// deliver posts the body to the webhook, backing off between attempts.
+// Client errors other than 429 aren't retried, as they won't go away.
+func (w *Webhooks) deliver(hook Webhook, body []byte) {
+	retries := hook.Retries
+	if retries == 0 {
+		retries = 3
+	}
+
+	backoff := w.backoff
+	for attempt := 0; ; attempt++ {
+		status, err := w.post(hook, body)
+		if err == nil && status < 300 {
+			return
+		}
+		if err == nil {
+			err = fmt.Errorf("unexpected status %d", status)
+		}
+
+		retryable := status == 0 || status == http.StatusTooManyRequests || status >= 500
+		if !retryable || attempt >= retries {
+			logger("webhooks").Error("error delivering webhook", "url", hook.URL, "error", err)
+			return
+		}
+
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// post makes a single delivery attempt and returns the response status.
+func (w *Webhooks) post(hook Webhook, body []byte) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if hook.Secret != "" {
+		req.Header.Set(WebhookSignatureHeader, SignWebhook(hook.Secret, body))
+	}
+
+	client := w.client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// SignWebhook returns the signature header value of a webhook body.
+func SignWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookBody encodes an event in the format the webhook expects.
+func webhookBody(hook Webhook, event SchemaEvent) ([]byte, error) {
+	if hook.Format != "slack" {
+		return json.Marshal(event)
+	}
+
+	text := fmt.Sprintf("Schema `%s` was unregistered", event.Name)
+	if event.Schema != nil {
+		text = fmt.Sprintf("Schema `%s` is now at revision %d (%s) on `%s`",
+			event.Name, event.Schema.Revision, event.Schema.State, event.Schema.Subject)
+	}
+	return json.Marshal(map[string]string{"text": text})
+}
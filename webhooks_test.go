@@ -0,0 +1,53 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWebhookDelivery(t *testing.T) {
+	var attempts int
+	var signature string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		signature = r.Header.Get(WebhookSignatureHeader)
+		if signature != SignWebhook("secret", body) {
+			t.Errorf("Expected the body to be signed, got %q", signature)
+		}
+	}))
+	defer server.Close()
+
+	w := &Webhooks{}
+	w.deliver(Webhook{URL: server.URL, Secret: "secret"}, []byte(`{"name":"foo"}`))
+	if attempts != 3 || signature == "" {
+		t.Errorf("Expected the delivery to succeed on the third attempt, got %d attempts", attempts)
+	}
+
+	attempts = 0
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusBadRequest)
+	})
+	w.deliver(Webhook{URL: server.URL}, []byte(`{}`))
+	if attempts != 1 {
+		t.Errorf("Expected client errors not to be retried, got %d attempts", attempts)
+	}
+}
+
+func TestSlackWebhookBody(t *testing.T) {
+	body, err := webhookBody(Webhook{Format: "slack"}, SchemaEvent{Operation: EventDelete, Name: "foo"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !strings.Contains(string(body), `"text":"Schema`) {
+		t.Errorf("Expected a slack message, got %s", body)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/nats-io/nats.go/micro"
+)
+
+// RevisionMatch is a stored revision of a schema a payload conforms to.
+type RevisionMatch struct {
+	Revision uint64 `json:"revision"`
+	Version  uint64 `json:"version"`
+	SemVer   string `json:"semver,omitempty"`
+}
+
+// WhichResult lists the revisions of a schema a payload conforms to, out of
+// those checked.
+type WhichResult struct {
+	Name    string          `json:"name"`
+	Checked int             `json:"checked"`
+	Matches []RevisionMatch `json:"matches"`
+}
+
+// Which subject: $SCHEMA.WHICH.<schema_name>
+func (reg *SchemaRegistry) Which(r micro.Request) {
+	name := subjectName(r.Subject())
+
+	result, err := reg.which(name, r.Data())
+	if err != nil {
+		r.Error(errorCode(err), err.Error(), nil)
+		return
+	}
+
+	r.RespondJSON(result)
+}
+
+// which validates a payload against every stored revision of a schema, oldest
+// first.
+func (reg *SchemaRegistry) which(name string, data []byte) (WhichResult, error) {
+	result := WhichResult{Name: name, Matches: []RevisionMatch{}}
+
+	revisions, err := reg.storedVersions(name)
+	if err != nil {
+		return result, err
+	}
+	revisions = distinctRevisions(revisions)
+	if len(revisions) == 0 {
+		return result, newError("404", "Not found")
+	}
+
+	for _, schema := range revisions {
+		result.Checked++
+		body, err := reg.payloadBody(schema)
+		if err != nil {
+			return result, newError("400", err.Error())
+		}
+		problems, err := violations(data, body)
+		if err != nil {
+			return result, newError("400", err.Error())
+		}
+		if len(problems) > 0 {
+			continue
+		}
+		result.Matches = append(result.Matches, RevisionMatch{Revision: schema.Revision, Version: schema.Version, SemVer: schema.SemVer})
+	}
+	return result, nil
+}
+
+// distinctRevisions returns every revision once, oldest first. Immutable
+// registries read the same revision from the history and the stored versions.
+func distinctRevisions(schemas []Schema) []Schema {
+	seen := map[uint64]bool{}
+	var distinct []Schema
+	for _, schema := range schemas {
+		if !seen[schema.Revision] {
+			seen[schema.Revision] = true
+			distinct = append(distinct, schema)
+		}
+	}
+	sort.Slice(distinct, func(i, j int) bool { return distinct[i].Revision < distinct[j].Revision })
+	return distinct
+}
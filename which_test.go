@@ -0,0 +1,20 @@
+package main
+
+import "testing"
+
+func TestDistinctRevisions(t *testing.T) {
+	schemas := []Schema{
+		{Revision: 4, Version: 2},
+		{Revision: 1, Version: 1},
+		{Revision: 1, Version: 1},
+		{Revision: 4, Version: 2},
+	}
+
+	distinct := distinctRevisions(schemas)
+	if len(distinct) != 2 {
+		t.Fatalf("Expected 2 revisions, got %d", len(distinct))
+	}
+	if distinct[0].Revision != 1 || distinct[1].Revision != 4 {
+		t.Errorf("Expected revisions 1 and 4 in order, got %d and %d", distinct[0].Revision, distinct[1].Revision)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// workQueueAckWait is how long a validation may take before the message is
+// delivered again, assuming the registry instance handling it died.
+const workQueueAckWait = 30 * time.Second
+
+// workQueueRetryDelay is how long a message that couldn't be forwarded waits
+// before it is delivered again.
+const workQueueRetryDelay = time.Second
+
+// CreateWorkQueue creates the work-queue stream validation requests are stored
+// in until a registry has validated and forwarded them.
+func CreateWorkQueue(js nats.JetStreamContext, name, subject string) error {
+	_, err := js.AddStream(&nats.StreamConfig{
+		Name:        name,
+		Description: "Messages waiting to be validated by the schema registry.",
+		Subjects:    []string{subject},
+		Retention:   nats.WorkQueuePolicy,
+	})
+	if errors.Is(err, nats.ErrStreamNameAlreadyInUse) {
+		return nil
+	}
+	return err
+}
+
+// WorkQueue validates messages stored in a work-queue stream, rather than
+// receiving them directly. A message is only acknowledged once it is forwarded
+// or rejected, so a registry crashing mid-validation doesn't lose it.
+type WorkQueue struct {
+	reg  *SchemaRegistry
+	sub  *nats.Subscription
+	pool *WorkerPool
+	// batch is the number of messages fetched at once
+	batch int
+}
+
+// NewWorkQueue binds a durable consumer, shared by every registry instance, to
+// the work-queue stream.
+func NewWorkQueue(reg *SchemaRegistry, stream, durable string, workers int) (*WorkQueue, error) {
+	sub, err := reg.js.PullSubscribe(reg.validateSubject(), durable,
+		nats.BindStream(stream),
+		nats.AckExplicit(),
+		nats.AckWait(workQueueAckWait))
+	if err != nil {
+		return nil, err
+	}
+
+	q := &WorkQueue{reg: reg, sub: sub, batch: workers}
+	if q.batch < 1 {
+		q.batch = 1
+	}
+	q.pool = NewWorkerPool(workers, q.handle)
+	return q, nil
+}
+
+// Run fetches messages until the context is canceled. It runs this in a
+// goroutine.
+func (q *WorkQueue) Run(c context.Context) {
+	go func() {
+		for c.Err() == nil {
+			msgs, err := q.sub.Fetch(q.batch, nats.MaxWait(time.Second))
+			if err != nil && !errors.Is(err, nats.ErrTimeout) {
+				logger("workqueue").Error("error fetching from the work queue", "error", err)
+				time.Sleep(workQueueRetryDelay)
+			}
+			for _, m := range msgs {
+				q.pool.Handle(m)
+			}
+		}
+	}()
+}
+
+// handle validates and forwards a message, and acknowledges it unless it
+// couldn't be forwarded.
+func (q *WorkQueue) handle(m *nats.Msg) {
+	// The reply subject is the acknowledgement, not the producer
+	msg := &nats.Msg{Subject: m.Subject, Data: m.Data, Header: m.Header}
+	subject := strings.TrimPrefix(m.Subject, q.reg.validationPrefix()+".")
+
+	err := q.reg.validateMsg(msg, subject, RouteSubject)
+	if err != nil {
+		err = m.NakWithDelay(workQueueRetryDelay)
+	} else {
+		err = m.Ack()
+	}
+	if err != nil {
+		logger("workqueue").Error("error acknowledging work queue message", "error", err)
+	}
+}
+
+// Wait blocks until every fetched message has been handled, or returns an
+// error once the deadline passes. Messages left unacknowledged are delivered
+// again.
+func (q *WorkQueue) Wait(deadline time.Time) error {
+	if err := q.pool.Wait(deadline); err != nil {
+		return fmt.Errorf("stopping the work queue: %w", err)
+	}
+	return nil
+}